@@ -0,0 +1,11 @@
+<?php
+
+namespace App\Support;
+
+class DemoInfo
+{
+    public static function name(): string
+    {
+        return 'Laravel 1-click demo';
+    }
+}
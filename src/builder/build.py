@@ -1,8 +1,10 @@
+import hashlib
 import json
 import os
 import shutil
 import subprocess
 import sys
+import tarfile
 import importlib.util
 
 class Builder:
@@ -10,32 +12,76 @@ class Builder:
         self.manifest_path = manifest_path
         self.config = self.load_manifest()
         self.build_dir = "build"
-        self.resources_dir = os.path.join(self.build_dir, "resources")
+        # Everything under bundle/ is embedded into the launcher and
+        # extracted next to each other at runtime.
+        self.bundle_dir = os.path.join("src", "launcher", "bundle")
+        self.resources_dir = os.path.join(self.bundle_dir, "resources")
         self.app_dir = os.path.join(self.resources_dir, "app")
+        self.sign_key = None
+        self.public_key = None
+        self.encrypt = False
+        self.payload_key = None
+        self.console = False
 
     def load_manifest(self):
+        # The launcher reads YAML and TOML manifests too and resolves values
+        # as PyYAML and tomllib do (yes/no/on/off are booleans); YAML needs
+        # PyYAML here
+        ext = os.path.splitext(self.manifest_path)[1].lower()
+        if ext == ".toml":
+            import tomllib
+            with open(self.manifest_path, 'rb') as f:
+                return tomllib.load(f)
+        if ext in (".yaml", ".yml"):
+            try:
+                import yaml
+            except ImportError:
+                self.fail("Reading a YAML manifest needs PyYAML (pip install pyyaml)")
+            with open(self.manifest_path, 'r') as f:
+                return yaml.safe_load(f) or {}
         with open(self.manifest_path, 'r') as f:
             return json.load(f)
 
     def clean_build(self):
         if os.path.exists(self.build_dir):
             shutil.rmtree(self.build_dir)
+        os.makedirs(self.build_dir)
+        # Empty the bundle but keep the placeholder so go:embed always has a file
+        for entry in os.listdir(self.bundle_dir):
+            if entry == ".gitkeep":
+                continue
+            path = os.path.join(self.bundle_dir, entry)
+            if os.path.isdir(path):
+                shutil.rmtree(path)
+            else:
+                os.remove(path)
         os.makedirs(self.app_dir)
 
+    def app_dirs(self):
+        # A suite manifest bundles each app under its own "dir"; paths in the
+        # per-app entries stay relative to the bundle root.
+        apps = self.config.get('apps')
+        if not apps:
+            return [self.app_dir]
+        return [os.path.join(self.bundle_dir, app['dir']) for app in apps]
+
     def copy_source(self, source_path):
-        print(f"Copying source from {source_path} to {self.app_dir}...")
-        # Ignore .git, build, etc.
-        shutil.copytree(source_path, self.app_dir, dirs_exist_ok=True, ignore=shutil.ignore_patterns('.git', 'build', 'venv', '__pycache__'))
+        apps = self.config.get('apps')
+        sources = [os.path.join(source_path, app['dir']) for app in apps] if apps else [source_path]
+        for source, target in zip(sources, self.app_dirs()):
+            print(f"Copying source from {source} to {target}...")
+            # Ignore .git, build, etc.
+            shutil.copytree(source, target, dirs_exist_ok=True, ignore=shutil.ignore_patterns('.git', 'build', 'venv', '__pycache__'))
 
     def apply_scrambling(self):
         if not self.config.get('scramble_code', False):
             print("Scrambling disabled.")
             return
 
+        # A demo that was meant to be scrambled must never ship in the clear
         plugin_path = self.config.get('scramble_plugin_path')
         if not plugin_path or not os.path.exists(plugin_path):
-            print(f"Scramble plugin not found at {plugin_path}")
-            return
+            self.fail(f"scramble_code is on, but the scramble plugin was not found at {plugin_path}")
 
         print(f"Applying scrambling using {plugin_path}...")
 
@@ -44,12 +90,163 @@ class Builder:
         module = importlib.util.module_from_spec(spec)
         spec.loader.exec_module(module)
 
-        # Assume plugin has a class 'Scrambler' with method 'process(directory)'
-        if hasattr(module, 'Scrambler'):
-            scrambler = module.Scrambler()
-            scrambler.process(self.app_dir)
-        else:
-            print("Plugin does not have 'Scrambler' class.")
+        # The plugin has a class 'Scrambler' with method 'process(directory)',
+        # which gets the app/ folder with the app's own PHP sources
+        if not hasattr(module, 'Scrambler'):
+            self.fail(f"{plugin_path} does not have a 'Scrambler' class")
+        scrambler = module.Scrambler()
+        changed = 0
+        for app_dir in self.app_dirs():
+            sources = os.path.join(app_dir, "app")
+            if not os.path.isdir(sources):
+                self.fail(f"scramble_code is on, but {sources} doesn't exist")
+            before = self.php_hashes(sources)
+            scrambler.process(sources)
+            after = self.php_hashes(sources)
+            scrambled = sum(1 for path, digest in after.items() if before.get(path) != digest)
+            if before and not scrambled:
+                self.fail(f"The scramble plugin left every PHP file in {sources} untouched")
+            changed += scrambled
+
+        # The launcher refuses a scramble_code bundle without this
+        with open(os.path.join(self.bundle_dir, "scrambled.json"), 'w') as f:
+            json.dump({"plugin": os.path.basename(plugin_path), "files": changed}, f, indent=1)
+        print(f"Scrambled {changed} PHP files.")
+
+    @staticmethod
+    def php_hashes(directory):
+        hashes = {}
+        for root, dirs, files in os.walk(directory):
+            for file in files:
+                if file.endswith(".php"):
+                    path = os.path.join(root, file)
+                    with open(path, 'rb') as f:
+                        hashes[path] = hashlib.sha256(f.read()).hexdigest()
+        return hashes
+
+    @staticmethod
+    def fail(message):
+        print(f"Error: {message}")
+        sys.exit(1)
+
+    def php_binary(self, target_os):
+        # php_binaries picks the binary per platform, as the launcher does
+        binaries = self.config.get('php_binaries') or {}
+        for key in (f"{target_os}/amd64", target_os):
+            if key in binaries:
+                return binaries[key]
+        if binaries and not self.config.get('php_binary_path'):
+            self.fail(f"php_binaries has no PHP binary for {target_os}")
+        return self.config.get('php_binary_path', 'php/php')
+
+    def copy_php(self, php_dir, target_os):
+        # The binary's path is relative to the bundle root, e.g. php/php.exe
+        target = os.path.join(self.bundle_dir, os.path.dirname(self.php_binary(target_os)))
+        print(f"Copying PHP runtime from {php_dir} to {target}...")
+        shutil.copytree(php_dir, target, dirs_exist_ok=True)
+
+    def write_checksums(self):
+        print("Generating checksums...")
+        checksums = {}
+        for root, dirs, files in os.walk(self.bundle_dir):
+            for file in files:
+                path = os.path.join(root, file)
+                rel = os.path.relpath(path, self.bundle_dir).replace(os.sep, "/")
+                if rel in (".gitkeep", "checksums.json", "scrambled.json"):
+                    continue
+                with open(path, 'rb') as f:
+                    checksums[rel] = hashlib.sha256(f.read()).hexdigest()
+        with open(os.path.join(self.bundle_dir, "checksums.json"), 'w') as f:
+            json.dump(checksums, f, indent=1, sort_keys=True)
+
+    def sign_checksums(self):
+        # Same format as pack --sign-key: the hex Ed25519 signature of
+        # checksums.json. Python has no Ed25519, so openssl does the work.
+        if not self.sign_key:
+            return
+        print(f"Signing checksums.json with {self.sign_key}...")
+        checksums = os.path.join(self.bundle_dir, "checksums.json")
+        try:
+            sig = subprocess.check_output(["openssl", "pkeyutl", "-sign", "-rawin", "-inkey", self.sign_key, "-in", checksums])
+            der = subprocess.check_output(["openssl", "pkey", "-in", self.sign_key, "-pubout", "-outform", "DER"])
+        except (OSError, subprocess.CalledProcessError) as e:
+            print(f"Signing failed (needs OpenSSL 3 and an Ed25519 key): {e}")
+            sys.exit(1)
+        with open(os.path.join(self.bundle_dir, "checksums.json.sig"), 'w') as f:
+            f.write(sig.hex() + "\n")
+        # The raw key is the end of the DER SubjectPublicKeyInfo
+        self.public_key = der[-32:].hex()
+        print(f"Signed checksums.json (public key {self.public_key})")
+
+    def write_payload(self):
+        # One compressed tarball embeds far smaller than the loose tree and
+        # extracts faster; the launcher still reads loose bundles too.
+        print("Compressing bundle into payload.tar.gz...")
+        keep = (".gitkeep", "checksums.json", "checksums.json.sig", "scrambled.json", "payload.tar.gz")
+        payload = os.path.join(self.bundle_dir, "payload.tar.gz")
+        with tarfile.open(payload, "w:gz", compresslevel=9, format=tarfile.PAX_FORMAT) as tar:
+            for entry in sorted(os.listdir(self.bundle_dir)):
+                if entry not in keep:
+                    tar.add(os.path.join(self.bundle_dir, entry), arcname=entry, filter=self.payload_entry)
+        for entry in os.listdir(self.bundle_dir):
+            if entry in keep:
+                continue
+            path = os.path.join(self.bundle_dir, entry)
+            if os.path.isdir(path):
+                shutil.rmtree(path)
+            else:
+                os.remove(path)
+        print(f"Payload is {os.path.getsize(payload) / (1 << 20):.1f} MB")
+
+    @staticmethod
+    def payload_entry(info):
+        # Only files and folders; owners mean nothing on the demo machine
+        if not (info.isfile() or info.isdir()):
+            print(f"Skipping {info.name}: not a file or folder")
+            return None
+        info.uid = info.gid = 0
+        info.uname = info.gname = ""
+        return info
+
+    def seal_payload(self):
+        # Python has no AES, so the launcher's own "seal" command encrypts
+        # the payload and prints the masked key to compile in.
+        if not self.encrypt:
+            return
+        print("Encrypting payload.tar.gz...")
+        env = os.environ.copy()
+        env["GO111MODULE"] = "off"
+        try:
+            out = subprocess.check_output(["go", "run", ".", "seal", "--dir", "bundle"], env=env, cwd=os.path.join("src", "launcher"), text=True)
+        except subprocess.CalledProcessError as e:
+            print(f"Encryption failed: {e}")
+            sys.exit(1)
+        lines = out.strip().splitlines()
+        print("\n".join(lines[:-1]))
+        self.payload_key = lines[-1]
+
+    def write_resources(self, target_os):
+        # The Windows launcher gets icon_path as its icon, the app name and
+        # version in its file details and an asInvoker manifest. This reads
+        # the staged bundle, so it runs before write_payload.
+        if target_os != "windows":
+            return
+        print("Writing Windows resources...")
+        env = os.environ.copy()
+        env["GO111MODULE"] = "off"
+        cmd = ["go", "run", ".", "winres", "--manifest", os.path.abspath(self.manifest_path),
+               "--dir", "bundle", "--exe", self.output_name(target_os)]
+        try:
+            subprocess.check_call(cmd, env=env, cwd=os.path.join("src", "launcher"))
+        except subprocess.CalledProcessError as e:
+            print(f"Writing Windows resources failed: {e}")
+            sys.exit(1)
+
+    def output_name(self, target_os):
+        name = self.config.get('app_name', 'demo').replace(" ", "_").lower()
+        if target_os == "windows":
+            name += ".exe"
+        return name
 
     def compile_launcher(self, target_os="linux"):
         print(f"Compiling launcher for {target_os}...")
@@ -57,30 +254,54 @@ class Builder:
         env = os.environ.copy()
         env["GOOS"] = target_os
         env["GOARCH"] = "amd64"
+        # The launcher is a plain package directory without a go.mod, so
+        # build it in GOPATH mode to keep per-OS files (*_windows.go) working.
+        env["GO111MODULE"] = "off"
 
-        output_name = self.config.get('app_name', 'demo').replace(" ", "_").lower()
-        if target_os == "windows":
-            output_name += ".exe"
-
-        output_path = os.path.join(self.build_dir, output_name)
+        output_path = os.path.join(self.build_dir, self.output_name(target_os))
 
-        cmd = ["go", "build", "-o", output_path, "src/launcher/main.go"]
+        cmd = ["go", "build", "-o", os.path.abspath(output_path)]
+        ldflags = []
+        if self.public_key:
+            # The launcher then refuses bundles not signed with this key
+            ldflags.append(f"-X main.bundlePublicKey={self.public_key}")
+        if self.payload_key:
+            ldflags.append(f"-X {self.payload_key}")
+        if target_os == "windows" and not self.console:
+            # A GUI-subsystem app, so no console window opens behind the demo
+            ldflags.append("-H windowsgui")
+        if ldflags:
+            cmd += ["-ldflags", " ".join(ldflags)]
+        cmd.append(".")
 
         try:
-            subprocess.check_call(cmd, env=env)
+            subprocess.check_call(cmd, env=env, cwd=os.path.join("src", "launcher"))
             print(f"Launcher compiled to {output_path}")
         except subprocess.CalledProcessError as e:
             print(f"Compilation failed: {e}")
             sys.exit(1)
+        finally:
+            resources = os.path.join("src", "launcher", "winres_windows_amd64.syso")
+            if os.path.exists(resources):
+                os.remove(resources)
 
     def bundle_config(self):
-        # Copy manifest to build dir so launcher can read it
-        shutil.copy(self.manifest_path, os.path.join(self.build_dir, "manifest.json"))
+        # Copy manifest to build dir so launcher can read it, keeping its format
+        ext = os.path.splitext(self.manifest_path)[1].lower()
+        name = "manifest" + ext if ext in (".yaml", ".yml", ".toml") else "manifest.json"
+        shutil.copy(self.manifest_path, os.path.join(self.build_dir, name))
 
-    def build(self, source_path, target_os="linux"):
+    def build(self, source_path, target_os="linux", php_dir=None):
         self.clean_build()
         self.copy_source(source_path)
+        if php_dir:
+            self.copy_php(php_dir, target_os)
         self.apply_scrambling()
+        self.write_resources(target_os)
+        self.write_checksums()
+        self.sign_checksums()
+        self.write_payload()
+        self.seal_payload()
         self.compile_launcher(target_os)
         self.bundle_config()
         print("Build complete.")
@@ -88,11 +309,18 @@ class Builder:
 if __name__ == "__main__":
     import argparse
     parser = argparse.ArgumentParser(description="Build Laravel Demo")
-    parser.add_argument("--source", required=True, help="Path to Laravel source code")
+    parser.add_argument("--source", required=True, help="Path to Laravel source code (for a manifest with \"apps\", the folder holding one subfolder per app dir)")
     parser.add_argument("--manifest", default="manifest.json", help="Path to manifest.json")
     parser.add_argument("--os", default="linux", choices=["linux", "windows", "darwin"], help="Target OS")
+    parser.add_argument("--php-dir", help="Directory with the PHP runtime to bundle")
+    parser.add_argument("--encrypt", action="store_true", help="Encrypt the embedded payload (AES-GCM) with a key compiled into the launcher")
+    parser.add_argument("--sign-key", help="PEM file with an Ed25519 private key; signs the bundle and makes the launcher refuse any bundle not signed with it")
+    parser.add_argument("--console", action="store_true", help="Build the Windows launcher as a console app, for debugging")
 
     args = parser.parse_args()
 
     builder = Builder(args.manifest)
-    builder.build(args.source, args.os)
+    builder.sign_key = args.sign_key
+    builder.encrypt = args.encrypt
+    builder.console = args.console
+    builder.build(args.source, args.os, args.php_dir)
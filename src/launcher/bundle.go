@@ -0,0 +1,18 @@
+package main
+
+import "embed"
+
+// bundleFS holds the application files staged by the builder. A plain
+// development checkout only contains bundle/.gitkeep.
+//
+//go:embed all:bundle
+var bundleFS embed.FS
+
+// bundlePublicKey and payloadKey are compiled in by build.py and pack, as
+// the import path of the demo package depends on where the source lives:
+//
+//	go build -ldflags "-X main.bundlePublicKey=<hex> -X main.payloadKey=<hex>"
+//
+// main hands them to the demo package; see demo.BundlePublicKey and
+// demo.PayloadKey.
+var bundlePublicKey, payloadKey string
@@ -0,0 +1,48 @@
+package demo
+
+import "fmt"
+
+// Accessibility are display settings for evaluators who need larger text,
+// more contrast or no animations. The CLI flags override the manifest.
+type Accessibility struct {
+	Zoom          float64 `json:"zoom,omitempty"` // e.g. 1.25; 0 leaves it alone
+	HighContrast  bool    `json:"high_contrast,omitempty"`
+	ReducedMotion bool    `json:"reduced_motion,omitempty"`
+}
+
+func (a Accessibility) enabled() bool {
+	return a.Zoom > 0 || a.HighContrast || a.ReducedMotion
+}
+
+// accessibilityBrowserArgs returns the command line switches that apply a
+// to browser. Only Chromium-based browsers take them; they're ignored by
+// an instance that's already running, unless it's a session's own.
+func accessibilityBrowserArgs(browser string, a Accessibility) []string {
+	if browser != "chrome" && browser != "edge" {
+		return nil
+	}
+	var args []string
+	if a.Zoom > 0 {
+		args = append(args, fmt.Sprintf("--force-device-scale-factor=%g", a.Zoom))
+	}
+	if a.HighContrast {
+		args = append(args, "--force-high-contrast", "--force-dark-mode")
+	}
+	if a.ReducedMotion {
+		args = append(args, "--force-prefers-reduced-motion")
+	}
+	return args
+}
+
+// accessibilityEnv tells the app which settings are on, so it can drop
+// animations or switch themes itself.
+func accessibilityEnv(a Accessibility) map[string]string {
+	env := make(map[string]string)
+	if a.ReducedMotion {
+		env["DEMO_REDUCED_MOTION"] = "1"
+	}
+	if a.HighContrast {
+		env["DEMO_HIGH_CONTRAST"] = "1"
+	}
+	return env
+}
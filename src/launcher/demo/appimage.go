@@ -0,0 +1,125 @@
+package demo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// For Linux, pack --out --appimage puts the launcher in an AppDir, with a
+// .desktop file and the icon at its root as AppImages have them, and turns
+// that into an .AppImage with appimagetool where it's installed. File
+// managers then start the demo on a double-click, without a terminal.
+
+// appDirExecutable is where the launcher named name goes in the AppDir,
+// with its manifest next to it.
+func appDirExecutable(appDir, name string) string {
+	return filepath.Join(appDir, "usr", "bin", name)
+}
+
+// appImageIconSize is the size the icon is scaled to, the largest most
+// desktops show.
+const appImageIconSize = 256
+
+// appImageArches are appimagetool's names for the architectures.
+var appImageArches = map[string]string{"amd64": "x86_64", "arm64": "aarch64"}
+
+// writeAppDir starts appDir for the launcher named name: AppRun, the
+// .desktop file and icon_path from the staged bundle as a PNG. An AppImage
+// must have an icon, so without a usable one it gets a plain square.
+func writeAppDir(config *Manifest, bundleDir, appDir, name string) error {
+	if err := os.MkdirAll(filepath.Dir(appDirExecutable(appDir, name)), 0755); err != nil {
+		return err
+	}
+	var icon image.Image
+	if config.IconPath != "" {
+		var err error
+		if icon, err = loadPackIcon(filepath.Join(bundleDir, filepath.FromSlash(config.IconPath))); err != nil {
+			fmt.Printf("Warning: icon_path %s can't be the AppImage's icon: %v\n", config.IconPath, err)
+		}
+	}
+	if icon == nil {
+		plain := image.NewNRGBA(image.Rect(0, 0, appImageIconSize, appImageIconSize))
+		draw.Draw(plain, plain.Bounds(), image.NewUniform(color.NRGBA{0x6b, 0x72, 0x80, 0xff}), image.Point{}, draw.Src)
+		icon = plain
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, scaleIcon(icon, appImageIconSize)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(appDir, name+".png"), b.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Symlink(name+".png", filepath.Join(appDir, ".DirIcon")); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(appDir, name+".desktop"), appImageDesktopEntry(config, name), 0644); err != nil {
+		return err
+	}
+	// The AppImage runs AppRun, which the launcher resolves to its real
+	// path to find the manifest
+	return os.Symlink(filepath.Join("usr", "bin", name), filepath.Join(appDir, "AppRun"))
+}
+
+// appImageDesktopEntry is the AppDir's .desktop file. Unlike the
+// create_shortcuts ones it starts the demo without a terminal, whose
+// output is in launcher.log.
+func appImageDesktopEntry(config *Manifest, name string) []byte {
+	title := config.AppName
+	if title == "" {
+		title = name
+	}
+	var b strings.Builder
+	b.WriteString("[Desktop Entry]\nType=Application\n")
+	fmt.Fprintf(&b, "Name=%s\n", title)
+	fmt.Fprintf(&b, "Exec=%s\n", name)
+	fmt.Fprintf(&b, "Icon=%s\n", name)
+	b.WriteString("Terminal=false\nCategories=Office;\n")
+	if config.AppVersion != "" {
+		fmt.Fprintf(&b, "X-AppImage-Version=%s\n", config.AppVersion)
+	}
+	return []byte(b.String())
+}
+
+// loadPackIcon decodes a PNG icon, or the largest PNG image of an .ico.
+func loadPackIcon(file string) (image.Image, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(file), ".ico") {
+		if data, err = largestICOImage(data); err != nil {
+			return nil, err
+		}
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("not a PNG or .ico file: %w", err)
+	}
+	return img, nil
+}
+
+// buildAppImage turns appDir into the AppImage out with appimagetool.
+// Without the tool the AppDir stays for the vendor to package.
+func buildAppImage(appDir, out, goarch string) error {
+	tool, err := exec.LookPath("appimagetool")
+	if err != nil {
+		fmt.Printf("appimagetool isn't on PATH, so %s is left as it is; run appimagetool on it to get an AppImage.\n", appDir)
+		return nil
+	}
+	fmt.Printf("Building %s...\n", out)
+	cmd := exec.Command(tool, "--no-appstream", appDir, out)
+	cmd.Env = append(os.Environ(), "ARCH="+appImageArches[goarch])
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("building the AppImage %s: %w", out, err)
+	}
+	return os.RemoveAll(appDir)
+}
@@ -0,0 +1,74 @@
+package demo
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// app_key values: a key kept for the installation (the default), or for
+// a --session, a fresh one on every start, or the bundled .env's.
+const (
+	appKeyInstallation = "installation"
+	appKeyRun          = "run"
+	appKeyBundled      = "bundled"
+)
+
+// appKeyFile holds the installation's or session's APP_KEY.
+const appKeyFile = "app_key"
+
+// newAppKey is a key like artisan key:generate makes for AES-256-CBC,
+// without starting PHP for it.
+func newAppKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "base64:" + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// appKeyEnv sets APP_KEY to a key of this installation's own, so a demo
+// shipped with the key it was built with doesn't share it with every
+// prospect, whose cookies and encrypted data then can't be read by
+// another copy. A key set in env_vars is the vendor's choice and stays.
+func (l *Launcher) appKeyEnv() ([]string, error) {
+	if _, ok := l.Config.EnvVars["APP_KEY"]; ok || l.Config.AppKey == appKeyBundled || l.Options.ServeDir != "" {
+		return nil, nil
+	}
+	if l.Config.AppKey == appKeyRun {
+		key, err := newAppKey()
+		if err != nil {
+			return nil, err
+		}
+		return []string{"APP_KEY=" + key}, nil
+	}
+
+	dir := ""
+	if l.session != nil {
+		dir = l.session.dir
+	} else {
+		var err error
+		if dir, err = appDataDir(&l.Config); err != nil {
+			return nil, err
+		}
+	}
+	file := filepath.Join(dir, appKeyFile)
+	if data, err := ioutil.ReadFile(file); err == nil && strings.HasPrefix(strings.TrimSpace(string(data)), "base64:") {
+		return []string{"APP_KEY=" + strings.TrimSpace(string(data))}, nil
+	}
+	key, err := newAppKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(file, []byte(key+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("saving the app key: %w", err)
+	}
+	return []string{"APP_KEY=" + key}, nil
+}
@@ -0,0 +1,171 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// appEntries decodes the manifest's apps array for listing.
+func appEntries(config *Manifest) ([]Manifest, error) {
+	apps := make([]Manifest, len(config.Apps))
+	for i, raw := range config.Apps {
+		if err := json.Unmarshal(raw, &apps[i]); err != nil {
+			return nil, fmt.Errorf("apps[%d]: %w", i, err)
+		}
+		if apps[i].AppName == "" || apps[i].Dir == "" {
+			return nil, fmt.Errorf("apps[%d]: app_name and dir are required", i)
+		}
+		if reason := appNameProblem(apps[i].AppName); reason != "" {
+			return nil, fmt.Errorf("apps[%d]: app_name %s", i, reason)
+		}
+	}
+	return apps, nil
+}
+
+// SelectApp returns the configuration for the named app: the top-level
+// manifest with the app's own entry laid over it, so anything an entry
+// leaves out is shared by all apps.
+func SelectApp(config *Manifest, name string) (Manifest, error) {
+	apps, err := appEntries(config)
+	if err != nil {
+		return Manifest{}, err
+	}
+	for i, app := range apps {
+		if !strings.EqualFold(app.AppName, name) {
+			continue
+		}
+		selected := *config
+		selected.Apps = nil
+		if err := json.Unmarshal(config.Apps[i], &selected); err != nil {
+			return Manifest{}, err
+		}
+		return selected, nil
+	}
+
+	names := make([]string, len(apps))
+	for i, app := range apps {
+		names[i] = app.AppName
+	}
+	return Manifest{}, fmt.Errorf("no app named %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// appChooser serves a small local page listing the bundled apps. After a
+// choice, the page waits until the launcher reports the app's URL and then
+// navigates there, so the same browser tab carries on into the demo.
+type appChooser struct {
+	srv    *http.Server
+	url    string
+	chosen chan string
+
+	mu     sync.Mutex
+	target string
+}
+
+// startAppChooser serves the chooser on a free port of host. App icons are
+// read from the bundle in fsys.
+func startAppChooser(host string, config *Manifest, fsys fs.FS) (*appChooser, error) {
+	apps, err := appEntries(config)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &appChooser{
+		url:    serverURL(host, l.Addr().(*net.TCPAddr).Port) + "/",
+		chosen: make(chan string, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, chooserPage(config.AppName, apps))
+	})
+	mux.HandleFunc("/icon", func(w http.ResponseWriter, r *http.Request) {
+		for _, app := range apps {
+			if app.AppName == r.URL.Query().Get("app") && app.IconPath != "" {
+				data, err := readBundleFile(fsys, app.IconPath)
+				if err != nil {
+					break
+				}
+				w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(app.IconPath)))
+				w.Write(data)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/launch", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case ch.chosen <- r.URL.Query().Get("app"):
+		default:
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, chooserWaitPage, html.EscapeString(msg("chooser_wait_title")), html.EscapeString(msg("chooser_wait")))
+	})
+	mux.HandleFunc("/target", func(w http.ResponseWriter, r *http.Request) {
+		ch.mu.Lock()
+		target := ch.target
+		ch.mu.Unlock()
+		if target == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, target)
+	})
+
+	ch.srv = &http.Server{Handler: mux}
+	go ch.srv.Serve(l)
+	return ch, nil
+}
+
+// redirect tells the waiting chooser page where the chosen app is running.
+func (ch *appChooser) redirect(target string) {
+	ch.mu.Lock()
+	ch.target = target
+	ch.mu.Unlock()
+}
+
+func (ch *appChooser) Close() error {
+	return ch.srv.Close()
+}
+
+func chooserPage(title string, apps []Manifest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>%s</title>", html.EscapeString(title))
+	b.WriteString("<style>body{font-family:sans-serif;max-width:40em;margin:3em auto}a.app{display:flex;gap:1em;align-items:center;padding:1em;margin:.5em 0;border:1px solid #ccc;border-radius:6px;color:inherit;text-decoration:none}a.app:hover{background:#f4f4f4}img{width:48px;height:48px}</style></head><body>")
+	fmt.Fprintf(&b, "<h1>%s</h1><p>%s</p>", html.EscapeString(title), html.EscapeString(msg("chooser_prompt")))
+	for _, app := range apps {
+		q := url.QueryEscape(app.AppName)
+		fmt.Fprintf(&b, "<a class=\"app\" href=\"/launch?app=%s\">", q)
+		if app.IconPath != "" {
+			fmt.Fprintf(&b, "<img src=\"/icon?app=%s\" alt=\"\">", q)
+		}
+		fmt.Fprintf(&b, "<div><strong>%s</strong><br>%s</div></a>", html.EscapeString(app.AppName), html.EscapeString(app.Description))
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+const chooserWaitPage = `<!DOCTYPE html><html><head><meta charset="utf-8"><title>%s</title></head>
+<body style="font-family:sans-serif;text-align:center;margin-top:5em"><p>%s</p>
+<script>
+(function poll() {
+	fetch("/target").then(function (r) {
+		if (r.status === 200) { return r.text().then(function (u) { location.replace(u); }); }
+		setTimeout(poll, 500);
+	}).catch(function () { setTimeout(poll, 500); });
+})();
+</script></body></html>`
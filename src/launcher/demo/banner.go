@@ -0,0 +1,264 @@
+package demo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed pages/setup.html
+var setupPage string
+
+const (
+	setupEventsPath = "/__demo/setup/events"
+	setupSplashPath = "/__demo/setup/splash"
+
+	// bannerMaxEvents bounds the history replayed to new page loads; a big
+	// seeder can print far more than anyone reads.
+	bannerMaxEvents = 2000
+	// bannerMaxOutput is how much of a failed step's output is kept for
+	// the diagnostics.
+	bannerMaxOutput = 200
+)
+
+// setupEvent is one message of the setup progress stream.
+type setupEvent struct {
+	Type    string   `json:"type"` // step, output, ready or failed
+	Text    string   `json:"text,omitempty"`
+	Elapsed float64  `json:"elapsed"` // seconds since setup started
+	Output  []string `json:"output,omitempty"`
+}
+
+// setupBanner is the "Preparing your demo" page the browser sees while
+// the bundle is extracted, setup commands run and PHP starts. It streams
+// progress over server-sent events and reloads into the app once the
+// launcher reports ready.
+type setupBanner struct {
+	page       string
+	clock      Clock
+	start      time.Time
+	splash     []byte // splash_screen_image, if any
+	splashType string
+	icon       *appIcon
+
+	mu      sync.Mutex
+	events  []setupEvent
+	dropped int           // events trimmed from the front of events
+	changed chan struct{} // closed and replaced on every publish
+	output  []string      // output of the current step
+}
+
+func newSetupBanner(config *Manifest, clock Clock, splash []byte, icon *appIcon) *setupBanner {
+	js := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	app := fmt.Sprintf("%s %s (%s/%s)", config.AppName, config.AppVersion, runtime.GOOS, runtime.GOARCH)
+	var img string
+	if splash != nil {
+		img = fmt.Sprintf(`<img id="splash" src="%s" alt="%s">`, setupSplashPath, html.EscapeString(config.AppName))
+	}
+	r := strings.NewReplacer(
+		"{{title}}", html.EscapeString(msg("setup_title")),
+		"{{failed}}", html.EscapeString(msg("setup_failed")),
+		"{{copy}}", html.EscapeString(msg("setup_copy")),
+		"{{copied_js}}", js(msg("setup_copied")),
+		"{{app_js}}", js(app),
+		"{{events_path_js}}", js(setupEventsPath),
+		"{{splash}}", img,
+	)
+	return &setupBanner{
+		page:       r.Replace(setupPage),
+		clock:      clock,
+		start:      clock.Now(),
+		splash:     splash,
+		icon:       icon,
+		splashType: mime.TypeByExtension(path.Ext(config.SplashScreenImage)),
+		changed:    make(chan struct{}),
+	}
+}
+
+func (b *setupBanner) publish(e setupEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e.Elapsed = b.clock.Now().Sub(b.start).Seconds()
+	b.events = append(b.events, e)
+	if n := len(b.events) - bannerMaxEvents; n > 0 {
+		b.events = b.events[n:]
+		b.dropped += n
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Step announces the next setup step, also on the console.
+func (b *setupBanner) Step(text string) {
+	fmt.Println(text)
+	b.mu.Lock()
+	b.output = nil
+	b.mu.Unlock()
+	b.publish(setupEvent{Type: "step", Text: text})
+}
+
+// Show announces a step on the page only, for steps that are already
+// logged on the console.
+func (b *setupBanner) Show(text string) {
+	b.mu.Lock()
+	b.output = nil
+	b.mu.Unlock()
+	b.publish(setupEvent{Type: "step", Text: text})
+}
+
+// Output adds a line of output of the current step.
+func (b *setupBanner) Output(line string) {
+	b.mu.Lock()
+	b.output = append(b.output, line)
+	if len(b.output) > bannerMaxOutput {
+		b.output = b.output[1:]
+	}
+	b.mu.Unlock()
+	b.publish(setupEvent{Type: "output", Text: line})
+}
+
+// Ready tells open pages to reload into the app.
+func (b *setupBanner) Ready() {
+	b.publish(setupEvent{Type: "ready"})
+}
+
+// Fail shows err and the failed step's output instead of the spinner.
+func (b *setupBanner) Fail(err error) {
+	b.mu.Lock()
+	output := b.output
+	b.mu.Unlock()
+	b.publish(setupEvent{Type: "failed", Text: err.Error(), Output: output})
+}
+
+func (b *setupBanner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == setupEventsPath {
+		b.serveEvents(w, r)
+		return
+	}
+	if b.icon.serve(w, r) {
+		return
+	}
+	if r.URL.Path == setupSplashPath && b.splash != nil {
+		w.Header().Set("Content-Type", b.splashType)
+		w.Write(b.splash)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method != http.MethodGet {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "The demo is still starting.", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, b.page)
+}
+
+// serveEvents replays the history and then streams new events until the
+// page goes away.
+func (b *setupBanner) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+
+	next := 0
+	for {
+		b.mu.Lock()
+		if next < b.dropped {
+			next = b.dropped
+		}
+		batch := b.events[next-b.dropped:]
+		changed := b.changed
+		b.mu.Unlock()
+
+		for _, e := range batch {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		next += len(batch)
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// splashImage reads splash_screen_image for the setup banner, which is
+// shown before the bundle is extracted. Without it the banner shows the
+// progress only.
+func (l *Launcher) splashImage() []byte {
+	p := l.Config.SplashScreenImage
+	if p == "" {
+		return nil
+	}
+	data, err := l.readAppFile(p)
+	if err != nil {
+		fmt.Printf("Warning: splash_screen_image %s can't be shown: %v\n", p, err)
+		return nil
+	}
+	return data
+}
+
+// lineWriter calls fn for every complete line written to it.
+type lineWriter struct {
+	fn  func(line string)
+	buf []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := strings.IndexByte(string(lw.buf), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		lw.fn(strings.TrimRight(string(lw.buf[:i]), "\r"))
+		lw.buf = lw.buf[i+1:]
+	}
+}
+
+// Flush passes on a last line without a newline.
+func (lw *lineWriter) Flush() {
+	if len(lw.buf) > 0 {
+		lw.fn(string(lw.buf))
+		lw.buf = nil
+	}
+}
+
+// switchHandler serves the public port: the setup banner first, then the
+// proxy once PHP is ready.
+type switchHandler struct {
+	mu sync.RWMutex
+	h  http.Handler
+}
+
+func (s *switchHandler) Set(h http.Handler) {
+	s.mu.Lock()
+	s.h = h
+	s.mu.Unlock()
+}
+
+func (s *switchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	h := s.h
+	s.mu.RUnlock()
+	h.ServeHTTP(w, r)
+}
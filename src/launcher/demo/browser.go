@@ -0,0 +1,198 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// BrowserNone is the --browser value that never opens a browser.
+const BrowserNone = "none"
+
+// errBrowserDisabled is returned by openBrowser for --browser=none.
+var errBrowserDisabled = errors.New("opening a browser is disabled (--browser=none)")
+
+// browserCommand is one way of opening a URL. Helpers like xdg-open return
+// once the browser is launched, so they're waited for to learn whether it
+// worked; browsers started directly keep running and are only started.
+type browserCommand struct {
+	name string
+	args []string
+	wait bool
+}
+
+// runBrowserCommand runs a browserCommand; it is a variable so opening a
+// browser can be exercised without starting real processes.
+var runBrowserCommand = func(c browserCommand) error {
+	cmd := newCommand(c.name, c.args...)
+	if c.wait {
+		return cmd.Run()
+	}
+	return cmd.Start()
+}
+
+// defaultBrowsers are the commands that open the user's default browser,
+// tried in order on the current platform. Named browsers are started from
+// where installedBrowsers found them instead.
+var defaultBrowsers = platformBrowsers(
+	[]browserCommand{{"xdg-open", nil, true}, {"sensible-browser", nil, false}, {"x-www-browser", nil, false}, {"gio", []string{"open"}, true}},
+	[]browserCommand{{"rundll32", []string{"url.dll,FileProtocolHandler"}, true}},
+	[]browserCommand{{"open", nil, true}},
+)
+
+// KnownBrowser reports whether name is a valid --browser value.
+func KnownBrowser(name string) bool {
+	if name == BrowserNone || name == "default" {
+		return true
+	}
+	for _, b := range namedBrowsers {
+		if name == b {
+			return true
+		}
+	}
+	return false
+}
+
+func platformBrowsers(linux, windows, darwin []browserCommand) []browserCommand {
+	switch runtime.GOOS {
+	case "windows":
+		return windows
+	case "darwin":
+		return darwin
+	default:
+		return linux
+	}
+}
+
+// openBrowser opens url with browser, a --browser value. For the default
+// browser each command is tried in turn, and it only returns an error when
+// all of them failed.
+func openBrowser(browser, url string) error {
+	return openBrowserWith(browser, url, "", nil)
+}
+
+// Notes printed once per run when a browser can't do what was asked.
+var sharedProfileNoted, browserArgsNoted bool
+
+// openBrowserWith is openBrowser with a separate browser profile in
+// profileDir (for named sessions) and extra command line switches. Only
+// --browser chrome, edge and firefox can be given a profile, and only
+// chrome and edge take the switches; the default browser opens as is.
+func openBrowserWith(browser, url, profileDir string, extra []string) error {
+	if browser == BrowserNone {
+		return errBrowserDisabled
+	}
+
+	var args []string
+	if profileDir != "" {
+		profile := browserProfileArgs(browser, profileDir)
+		if profile == nil && !sharedProfileNoted {
+			fmt.Println("The default browser shares its profile between sessions; use --browser chrome, edge or firefox for a separate one.")
+			sharedProfileNoted = true
+		}
+		os.MkdirAll(profileDir, 0755)
+		args = append(args, profile...)
+	}
+	args = append(args, extra...)
+
+	commands := defaultBrowsers
+	if browser != "default" {
+		p := installedBrowsers()[browser]
+		if p == "" {
+			return fmt.Errorf("%s is not installed (see doctor)", browser)
+		}
+		commands = []browserCommand{browserLaunch(p)}
+	}
+
+	var errs []string
+	for _, c := range commands {
+		err := launchBrowser(c, args, url)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", c.name, err))
+	}
+	return fmt.Errorf("no browser could be started (%s)", strings.Join(errs, "; "))
+}
+
+// launchBrowser runs c with the browser switches args and then target,
+// usually the URL to open.
+func launchBrowser(c browserCommand, args []string, target string) error {
+	cmdArgs := append([]string(nil), c.args...)
+	if len(args) > 0 {
+		if c.name == "open" {
+			// macOS: a new instance, with the rest passed to the browser
+			cmdArgs = append(append([]string{"-n"}, cmdArgs...), "--args")
+		}
+		cmdArgs = append(cmdArgs, args...)
+	}
+	c.args = append(cmdArgs, target)
+	return runBrowserCommand(c)
+}
+
+// browserProfileArgs returns the arguments that start browser with its
+// profile in dir, or nil when it can't be told.
+func browserProfileArgs(browser, dir string) []string {
+	switch browser {
+	case "chrome", "edge":
+		return []string{"--user-data-dir=" + dir, "--no-first-run", "--new-window"}
+	case "firefox":
+		return []string{"-profile", dir, "-no-remote"}
+	}
+	return nil
+}
+
+// printBrowserFallback tells the user how to reach the demo when no browser
+// could be opened, e.g. on a server reached over SSH.
+func printBrowserFallback(target string, err error) {
+	lines := []string{msg("browser_open"), "", "  " + target}
+	if err != errBrowserDisabled {
+		lines = append([]string{msg("browser_failed"), ""}, lines...)
+	}
+	if u, perr := url.Parse(target); perr == nil && u.Port() != "" {
+		port, host := u.Port(), u.Hostname()
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		lines = append(lines, "",
+			msg("browser_ssh"),
+			"",
+			fmt.Sprintf("  ssh -L %s:%s:%s user@host", port, host, port),
+			"",
+			msg("browser_ssh_then"))
+	}
+
+	width := 0
+	for _, l := range lines {
+		if w := displayWidth(l); w > width {
+			width = w
+		}
+	}
+	border := "+" + strings.Repeat("-", width+2) + "+"
+	fmt.Println(border)
+	for _, l := range lines {
+		fmt.Printf("| %s%s |\n", l, strings.Repeat(" ", width-displayWidth(l)))
+	}
+	fmt.Println(border)
+}
+
+// displayWidth approximates how many terminal columns s takes: CJK and
+// fullwidth characters take two.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case r >= 0x1100 && r <= 0x115f, r >= 0x2e80 && r <= 0xa4cf,
+			r >= 0xac00 && r <= 0xd7a3, r >= 0xf900 && r <= 0xfaff,
+			r >= 0xfe30 && r <= 0xfe4f, r >= 0xff00 && r <= 0xff60,
+			r >= 0xffe0 && r <= 0xffe6:
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
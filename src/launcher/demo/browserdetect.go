@@ -0,0 +1,65 @@
+package demo
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// browserCacheTTL is how long detected browser locations are trusted
+// before looking again. A cached browser that has since been removed
+// triggers a fresh look right away.
+const browserCacheTTL = 24 * time.Hour
+
+// namedBrowsers are the --browser values that pick a specific browser.
+var namedBrowsers = []string{"chrome", "edge", "firefox"}
+
+// browserCache is browsers.json in the user cache dir: where each named
+// browser was found (an executable, or the .app bundle on macOS).
+type browserCache struct {
+	Detected time.Time         `json:"detected"`
+	Browsers map[string]string `json:"browsers"`
+}
+
+func browserCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "laravel_demo", "browsers.json")
+}
+
+// installedBrowsers returns the named browsers installed on this machine,
+// from the cache while it's fresh.
+func installedBrowsers() map[string]string {
+	var cache browserCache
+	data, err := os.ReadFile(browserCachePath())
+	if err == nil && json.Unmarshal(data, &cache) == nil && time.Since(cache.Detected) < browserCacheTTL {
+		stale := false
+		for _, p := range cache.Browsers {
+			if _, err := os.Stat(p); err != nil {
+				stale = true
+			}
+		}
+		if !stale {
+			return cache.Browsers
+		}
+	}
+	return detectBrowsers()
+}
+
+// detectBrowsers looks for every named browser and caches the result.
+func detectBrowsers() map[string]string {
+	cache := browserCache{Detected: time.Now(), Browsers: make(map[string]string)}
+	for _, name := range namedBrowsers {
+		if p := findBrowser(name); p != "" {
+			cache.Browsers[name] = p
+		}
+	}
+	if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+		os.MkdirAll(filepath.Dir(browserCachePath()), 0755)
+		os.WriteFile(browserCachePath(), data, 0644)
+	}
+	return cache.Browsers
+}
@@ -0,0 +1,53 @@
+//go:build !windows
+
+package demo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// browserExecutables are the commands each browser installs on Linux, in
+// order of preference.
+var browserExecutables = map[string][]string{
+	"chrome":  {"google-chrome", "google-chrome-stable", "chromium", "chromium-browser"},
+	"edge":    {"microsoft-edge", "microsoft-edge-stable"},
+	"firefox": {"firefox"},
+}
+
+// browserApps are the macOS application bundles.
+var browserApps = map[string]string{
+	"chrome":  "Google Chrome.app",
+	"edge":    "Microsoft Edge.app",
+	"firefox": "Firefox.app",
+}
+
+// findBrowser returns where the named browser is installed, or "".
+func findBrowser(name string) string {
+	if runtime.GOOS == "darwin" {
+		home, _ := os.UserHomeDir()
+		for _, dir := range []string{"/Applications", filepath.Join(home, "Applications")} {
+			p := filepath.Join(dir, browserApps[name])
+			if _, err := os.Stat(p); err == nil {
+				return p
+			}
+		}
+		return ""
+	}
+	for _, exe := range browserExecutables[name] {
+		if p, err := exec.LookPath(exe); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// browserLaunch is the command that starts the browser found at p.
+func browserLaunch(p string) browserCommand {
+	if runtime.GOOS == "darwin" {
+		return browserCommand{"open", []string{"-a", p}, true}
+	}
+	return browserCommand{p, nil, false}
+}
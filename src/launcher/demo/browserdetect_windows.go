@@ -0,0 +1,94 @@
+package demo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	browserExecutables = map[string]string{
+		"chrome":  "chrome.exe",
+		"edge":    "msedge.exe",
+		"firefox": "firefox.exe",
+	}
+	// browserInstallDirs are the usual folders below Program Files (and
+	// LocalAppData for per-user installs).
+	browserInstallDirs = map[string]string{
+		"chrome":  `Google\Chrome\Application`,
+		"edge":    `Microsoft\Edge\Application`,
+		"firefox": `Mozilla Firefox`,
+	}
+)
+
+// findBrowser returns where the named browser is installed, or "": its
+// App Paths registration first, then the usual install folders, then PATH.
+func findBrowser(name string) string {
+	exe := browserExecutables[name]
+	for _, root := range []syscall.Handle{syscall.HKEY_CURRENT_USER, syscall.HKEY_LOCAL_MACHINE} {
+		if p := appPath(root, exe); p != "" && fileExists(p) {
+			return p
+		}
+	}
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "LocalAppData"} {
+		if base := os.Getenv(env); base != "" {
+			if p := filepath.Join(base, browserInstallDirs[name], exe); fileExists(p) {
+				return p
+			}
+		}
+	}
+	if p, err := exec.LookPath(exe); err == nil {
+		return p
+	}
+	return ""
+}
+
+var envReference = regexp.MustCompile(`%([^%]+)%`)
+
+// appPath reads the App Paths entry installers register so exe can be
+// started by name.
+func appPath(root syscall.Handle, exe string) string {
+	subkey, err := syscall.UTF16PtrFromString(`SOFTWARE\Microsoft\Windows\CurrentVersion\App Paths\` + exe)
+	if err != nil {
+		return ""
+	}
+	var key syscall.Handle
+	if syscall.RegOpenKeyEx(root, subkey, 0, syscall.KEY_READ, &key) != nil {
+		return ""
+	}
+	defer syscall.RegCloseKey(key)
+
+	var typ, size uint32
+	if syscall.RegQueryValueEx(key, nil, nil, &typ, nil, &size) != nil || size == 0 {
+		return ""
+	}
+	if typ != syscall.REG_SZ && typ != syscall.REG_EXPAND_SZ {
+		return ""
+	}
+	buf := make([]uint16, size/2+1)
+	if syscall.RegQueryValueEx(key, nil, nil, &typ, (*byte)(unsafe.Pointer(&buf[0])), &size) != nil {
+		return ""
+	}
+	p := strings.Trim(syscall.UTF16ToString(buf), `"`)
+	if typ == syscall.REG_EXPAND_SZ {
+		p = envReference.ReplaceAllStringFunc(p, func(ref string) string {
+			return os.Getenv(strings.Trim(ref, "%"))
+		})
+	}
+	return p
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+// browserLaunch is the command that starts the browser found at p.
+// Starting it directly avoids the console window of "cmd /c start".
+func browserLaunch(p string) browserCommand {
+	return browserCommand{p, nil, false}
+}
@@ -0,0 +1,321 @@
+package demo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// BundleFS holds the application files staged by the builder. main sets
+// it to the bundle folder it embeds, as go:embed only reaches the folder
+// of the package being built.
+var BundleFS fs.FS
+
+const (
+	bundleRoot    = "bundle"
+	checksumsFile = "checksums.json"
+	signatureFile = "checksums.json.sig" // optional, written by pack --sign-key
+	placeholder   = ".gitkeep"
+	scrambledFile = "scrambled.json" // written by build.py once the plugin ran
+)
+
+// hasBundle reports whether fsys holds a bundle staged by the builder. The
+// builder always writes checksums.json, so its presence is the marker.
+func hasBundle(fsys fs.FS) bool {
+	_, err := fs.Stat(fsys, path.Join(bundleRoot, checksumsFile))
+	return err == nil
+}
+
+// prepareWorkDir returns the directory the bundle is extracted to and
+// whether the launcher created it (and so owns its removal).
+func prepareWorkDir(workDir string) (string, bool, error) {
+	if workDir == "" {
+		dir, err := makeTempDir(workDirPrefix)
+		return dir, true, err
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return "", false, err
+	}
+	return workDir, false, nil
+}
+
+// copyBufferSize is the chunk files are streamed in, so memory use doesn't
+// grow with the largest bundled file (database dumps can be gigabytes).
+const copyBufferSize = 1 << 20
+
+// copyBuffers are shared by concurrent copies.
+var copyBuffers = sync.Pool{New: func() interface{} {
+	buf := make([]byte, copyBufferSize)
+	return &buf
+}}
+
+// copyStream copies r to w through a pooled buffer of copyBufferSize.
+func copyStream(w io.Writer, r io.Reader) (int64, error) {
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+	// Hide ReadFrom so the copy goes through buf
+	return io.CopyBuffer(struct{ io.Writer }{w}, r, *buf)
+}
+
+// extractedFile is a bundle file as written to disk.
+type extractedFile struct {
+	rel  string
+	size int64
+	sum  string // hex SHA-256 of what was written
+}
+
+// extractStats sums up an extraction for the log.
+type extractStats struct {
+	files          int
+	bytes, largest int64
+}
+
+func (s *extractStats) add(n int64) {
+	s.files++
+	s.bytes += n
+	if n > s.largest {
+		s.largest = n
+	}
+}
+
+// bundleMeta reports whether rel is one of the builder's own files rather
+// than part of the demo.
+func bundleMeta(rel string) bool {
+	return rel == placeholder || rel == checksumsFile || rel == signatureFile || rel == payloadFile || rel == sealedPayloadFile || rel == scrambledFile
+}
+
+// checkScrambled refuses a bundle built with scramble_code from sources
+// the plugin never touched, e.g. one staged with pack, so the demo can't
+// ship readable code by mistake.
+func checkScrambled(fsys fs.FS, config *Manifest) error {
+	if !config.ScrambleCode {
+		return nil
+	}
+	if _, err := fs.Stat(fsys, path.Join(bundleRoot, scrambledFile)); err != nil {
+		return fmt.Errorf("scramble_code is on but this bundle wasn't scrambled; build it with src/builder/build.py")
+	}
+	return nil
+}
+
+// extractWorkers is how many files are written at once. Extraction is
+// mostly small files, so it's bound by file creation rather than CPU.
+func extractWorkers() int {
+	n := runtime.GOMAXPROCS(0) * 4
+	if n < 4 {
+		return 4
+	}
+	if n > 16 {
+		return 16
+	}
+	return n
+}
+
+// extractPool writes bundle files on a fixed number of goroutines,
+// summing them up, collecting their checksums and keeping the first error.
+type extractPool struct {
+	jobs chan func() (extractedFile, error)
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	stats   extractStats
+	written map[string]string // checksum by path
+	err     error
+}
+
+func newExtractPool(workers int) *extractPool {
+	p := &extractPool{jobs: make(chan func() (extractedFile, error), workers), written: make(map[string]string)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				p.record(job())
+			}
+		}()
+	}
+	return p
+}
+
+func (p *extractPool) record(f extractedFile, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.add(f.size)
+	if err == nil {
+		p.written[f.rel] = f.sum
+	}
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+}
+
+// submit queues job, blocking while every worker is busy. It returns
+// false once a job has failed, so the caller can stop early.
+func (p *extractPool) submit(job func() (extractedFile, error)) bool {
+	p.mu.Lock()
+	failed := p.err != nil
+	p.mu.Unlock()
+	if failed {
+		return false
+	}
+	p.jobs <- job
+	return true
+}
+
+// wait lets the queued jobs finish.
+func (p *extractPool) wait() (extractStats, error) {
+	close(p.jobs)
+	p.wg.Wait()
+	return p.stats, p.err
+}
+
+// extractBundle writes every bundle file of fsys below dest, leaving out
+// the bundle directories listed in skip (those of apps that weren't
+// selected). Files are written by an extractPool and checked against
+// checksums.json as they are written.
+func extractBundle(fsys fs.FS, dest string, skip []string) error {
+	sums, err := loadChecksums(fsys, skip)
+	if err != nil {
+		return err
+	}
+	pool := newExtractPool(extractWorkers())
+	if hasPayload(fsys) {
+		err = extractPayload(fsys, dest, func(rel string) bool {
+			return !bundleMeta(rel) && !skipped(rel, skip)
+		}, pool)
+	} else {
+		err = fs.WalkDir(fsys, bundleRoot, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel := p[len(bundleRoot):]
+			if rel == "" {
+				return nil
+			}
+			rel = rel[1:]
+			if bundleMeta(rel) {
+				return nil
+			}
+			if skipped(rel, skip) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				dir, err := safeJoin(dest, rel)
+				if err != nil {
+					return err
+				}
+				return os.MkdirAll(dir, 0755)
+			}
+			if !pool.submit(func() (extractedFile, error) { return extractFile(fsys, dest, rel) }) {
+				return fs.SkipAll
+			}
+			return nil
+		})
+	}
+	stats, poolErr := pool.wait()
+	if err == nil {
+		err = poolErr
+	}
+	if err == nil {
+		err = checkIntegrity(sums, pool.written)
+	}
+	if err != nil {
+		return err
+	}
+	// Sys only grows, so it shows the peak. The embedded files themselves
+	// are mapped from the executable and don't count.
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fmt.Printf("Extracted %d files, %.1f MB written (largest %.1f MB), peak launcher memory %.1f MB\n",
+		stats.files, float64(stats.bytes)/(1<<20), float64(stats.largest)/(1<<20), float64(mem.Sys)/(1<<20))
+	return nil
+}
+
+// reextract writes the bundle files rels below dest once more, in one pass
+// over the payload when there is one.
+func reextract(fsys fs.FS, dest string, rels []string) {
+	if hasPayload(fsys) {
+		want := make(map[string]bool, len(rels))
+		for _, rel := range rels {
+			want[rel] = true
+		}
+		pool := newExtractPool(1)
+		err := extractPayload(fsys, dest, func(rel string) bool { return want[rel] }, pool)
+		if _, poolErr := pool.wait(); err == nil {
+			err = poolErr
+		}
+		if err != nil {
+			fmt.Printf("Error re-extracting files: %v\n", err)
+		}
+		return
+	}
+	for _, rel := range rels {
+		if _, err := extractFile(fsys, dest, rel); err != nil {
+			fmt.Printf("Error re-extracting %s: %v\n", rel, err)
+		}
+	}
+}
+
+// skipped reports whether the bundle path rel lies in one of the skip dirs.
+func skipped(rel string, skip []string) bool {
+	for _, dir := range skip {
+		if rel == dir || strings.HasPrefix(rel, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// extractFile streams a single bundle path (slash-separated, relative to
+// the bundle root) to below dest.
+func extractFile(fsys fs.FS, dest, rel string) (extractedFile, error) {
+	in, err := openBundleFile(fsys, rel)
+	if err != nil {
+		return extractedFile{rel: rel}, err
+	}
+	defer in.Close()
+	// Embedded files lose their mode bits; the PHP binary is made
+	// executable after extraction
+	return writeBundleFile(dest, rel, in, 0644)
+}
+
+// writeBundleFile writes the bundle file rel below dest from in with
+// mode, hashing it on the way.
+func writeBundleFile(dest, rel string, in io.Reader, mode os.FileMode) (extractedFile, error) {
+	f := extractedFile{rel: rel}
+	target, err := safeJoin(dest, rel)
+	if err != nil {
+		return f, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return f, err
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return f, fmt.Errorf("writing %s: %w", rel, err)
+	}
+	h := sha256.New()
+	f.size, err = copyStream(io.MultiWriter(out, h), in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// OpenFile keeps the mode of a file that existed, and umask applies
+		err = os.Chmod(target, mode)
+	}
+	if err != nil {
+		return f, fmt.Errorf("writing %s: %w", rel, err)
+	}
+	f.sum = hex.EncodeToString(h.Sum(nil))
+	return f, nil
+}
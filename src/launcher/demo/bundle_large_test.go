@@ -0,0 +1,220 @@
+//go:build largefile
+
+// The large-file extraction tests write hundreds of megabytes, so they
+// only build with the tag:
+//
+//	GO111MODULE=off go test -tags largefile -run Large -v .
+//
+// LAUNCHER_TEST_LARGE_MB sets the file size (default 512).
+
+package demo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// largeHeapLimit is how much the heap may grow while extracting, well
+// below any of the file sizes.
+const largeHeapLimit = 64 << 20
+
+const largeRel = "database/dump.sql"
+
+func largeSize(t *testing.T) int64 {
+	mb := int64(512)
+	if s := os.Getenv("LAUNCHER_TEST_LARGE_MB"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mb = n
+	}
+	return mb << 20
+}
+
+// patternReader produces size bytes of a fixed pattern without holding
+// them, standing in for a SQL dump.
+type patternReader struct {
+	size, off int64
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	if rest := r.size - r.off; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	for i := range p {
+		o := r.off + int64(i)
+		p[i] = byte(o ^ o>>8 ^ o>>19)
+	}
+	r.off += int64(len(p))
+	return len(p), nil
+}
+
+func patternSum(size int64) string {
+	h := sha256.New()
+	io.Copy(h, &patternReader{size: size})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// streamFile is an fs.File read from a stream; nothing of it is in memory
+// until read.
+type streamFile struct {
+	io.Reader
+	close func() error
+	info  fs.FileInfo
+}
+
+func (f *streamFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *streamFile) Close() error {
+	if f.close != nil {
+		return f.close()
+	}
+	return nil
+}
+
+// largeFS is a bundle whose file at name is opened through open instead of
+// from the MapFS, which only lists it.
+type largeFS struct {
+	fstest.MapFS
+	name string
+	open func() io.ReadCloser
+}
+
+func (l largeFS) Open(name string) (fs.File, error) {
+	if name != l.name {
+		return l.MapFS.Open(name)
+	}
+	f, err := l.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, _ := f.Stat()
+	f.Close()
+	r := l.open()
+	return &streamFile{Reader: r, close: r.Close, info: info}, nil
+}
+
+func largeChecksums(size int64) []byte {
+	sums, _ := json.Marshal(map[string]string{largeRel: patternSum(size)})
+	return sums
+}
+
+// heapPeak samples the heap until stop is called and returns its growth
+// over the starting value.
+func heapPeak() (stop func() uint64) {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	base, peak := m.HeapAlloc, m.HeapAlloc
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			if m.HeapAlloc > peak {
+				peak = m.HeapAlloc
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		wg.Wait()
+		if peak < base {
+			return 0
+		}
+		return peak - base
+	}
+}
+
+func checkLargeExtraction(t *testing.T, fsys fs.FS, size int64) {
+	t.Helper()
+	dest := t.TempDir()
+	stop := heapPeak()
+	start := time.Now()
+	if err := extractBundle(fsys, dest, nil); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+	grown := stop()
+
+	info, err := os.Stat(dest + "/" + largeRel)
+	if err != nil || info.Size() != size {
+		t.Fatalf("extracted %s: %v, %v; want %d bytes", largeRel, info, err, size)
+	}
+	t.Logf("%d MB in %s (%.0f MB/s), heap grew by %.1f MB",
+		size>>20, elapsed.Round(time.Millisecond), float64(size>>20)/elapsed.Seconds(), float64(grown)/(1<<20))
+	if grown > largeHeapLimit {
+		t.Errorf("the heap grew by %d MB extracting a %d MB file, more than %d MB",
+			grown>>20, size>>20, largeHeapLimit>>20)
+	}
+}
+
+func TestLargeFileExtractionStreams(t *testing.T) {
+	size := largeSize(t)
+	fsys := largeFS{
+		MapFS: fstest.MapFS{
+			"bundle/" + checksumsFile: {Data: largeChecksums(size)},
+			"bundle/" + largeRel:      {Mode: 0644},
+		},
+		name: "bundle/" + largeRel,
+		open: func() io.ReadCloser { return io.NopCloser(&patternReader{size: size}) },
+	}
+	checkLargeExtraction(t, fsys, size)
+}
+
+func TestLargeFilePayloadExtractionStreams(t *testing.T) {
+	size := largeSize(t)
+	// The payload is compressed while it's read, so it's never whole
+	// either
+	payload := func() io.ReadCloser {
+		pr, pw := io.Pipe()
+		go func() {
+			zw, _ := gzip.NewWriterLevel(pw, gzip.BestSpeed)
+			tw := tar.NewWriter(zw)
+			err := tw.WriteHeader(&tar.Header{Name: largeRel, Typeflag: tar.TypeReg, Mode: 0644, Size: size})
+			if err == nil {
+				_, err = io.Copy(tw, &patternReader{size: size})
+			}
+			if err == nil {
+				err = tw.Close()
+			}
+			if err == nil {
+				err = zw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr
+	}
+	fsys := largeFS{
+		MapFS: fstest.MapFS{
+			"bundle/" + checksumsFile: {Data: largeChecksums(size)},
+			"bundle/" + payloadFile:   {Mode: 0644},
+		},
+		name: "bundle/" + payloadFile,
+		open: payload,
+	}
+	checkLargeExtraction(t, fsys, size)
+}
@@ -0,0 +1,204 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// workDirPrefix names the temp dirs the launcher creates, so the stale
+	// sweep never touches anything else.
+	workDirPrefix = "laravel_demo_"
+	staleListFile = "laravel_demo_stale.txt"
+	// ownerFile in each of those dirs holds the PID of the launcher that
+	// made it, so the orphan sweep knows when it's gone.
+	ownerFile = ".launcher_pid"
+
+	removeTimeout  = 10 * time.Second
+	removeMaxDelay = time.Second
+	// removeConsoleTimeout is the budget when the console is closing,
+	// which Windows ends after consoleHandlerTimeout with PHP to kill first.
+	removeConsoleTimeout = 1500 * time.Millisecond
+)
+
+// removeAllRetry removes dir, retrying for up to timeout. On Windows the
+// handles of a process that just exited (the PHP image, SQLite, opcache
+// mappings) are released with a delay, and antivirus scanners open fresh
+// files, so the first attempts often fail with a sharing violation.
+func removeAllRetry(dir string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := 50 * time.Millisecond
+	reported := false
+	for {
+		err := os.RemoveAll(dir)
+		if err == nil {
+			return nil
+		}
+		switch {
+		case isSharingViolation(err):
+			if !reported {
+				fmt.Println(msg("files_in_use"))
+				reported = true
+			}
+		case errors.Is(err, fs.ErrPermission):
+			// Read-only files (e.g. from a git checkout in the bundle)
+			// can't be deleted on Windows
+			clearReadOnly(dir)
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > removeMaxDelay {
+			delay = removeMaxDelay
+		}
+	}
+}
+
+// clearReadOnly makes everything below dir writable.
+func clearReadOnly(dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		mode := os.FileMode(0666)
+		if d.IsDir() {
+			mode = 0777
+		}
+		os.Chmod(path, mode)
+		return nil
+	})
+}
+
+// removeWorkDir removes the session's work dir. If it can't be removed in
+// time, it's left for the stale sweep at the next launch. A closing
+// console may kill the launcher any moment, so then dir is recorded for
+// the sweep up front and only tried briefly; a dir already gone is
+// dropped from the list by the sweep.
+func removeWorkDir(dir string, consoleClosed bool) {
+	if consoleClosed {
+		serr := scheduleStaleSweep(dir)
+		if err := removeAllRetry(dir, removeConsoleTimeout); err != nil {
+			if serr != nil {
+				fmt.Printf("Error removing work directory: %v\n", err)
+				return
+			}
+			fmt.Println(msg("work_dir_stale", dir))
+		}
+		return
+	}
+	if err := removeAllRetry(dir, removeTimeout); err != nil {
+		if serr := scheduleStaleSweep(dir); serr != nil {
+			fmt.Printf("Error removing work directory: %v\n", err)
+			return
+		}
+		fmt.Println(msg("work_dir_stale", dir))
+	}
+}
+
+func staleListPath() string {
+	return filepath.Join(os.TempDir(), staleListFile)
+}
+
+// scheduleStaleSweep records dir for sweepStaleDirs.
+func scheduleStaleSweep(dir string) error {
+	f, err := os.OpenFile(staleListPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, dir)
+	return err
+}
+
+// sweepStaleDirs removes the work dirs earlier sessions couldn't. Only
+// the launcher's own temp dirs are touched, whatever the list says.
+func sweepStaleDirs() {
+	data, err := ioutil.ReadFile(staleListPath())
+	if err != nil {
+		return
+	}
+	tmp := filepath.Clean(os.TempDir())
+	var remaining []string
+	for _, dir := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		dir = strings.TrimSpace(dir)
+		if dir == "" || filepath.Dir(dir) != tmp || !strings.HasPrefix(filepath.Base(dir), workDirPrefix) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			remaining = append(remaining, dir)
+		}
+	}
+	if len(remaining) == 0 {
+		os.Remove(staleListPath())
+		return
+	}
+	ioutil.WriteFile(staleListPath(), []byte(strings.Join(remaining, "\n")+"\n"), 0644)
+}
+
+// makeTempDir creates a temp dir for this run whose name starts with
+// prefix, itself starting with workDirPrefix, and marks it as owned by
+// this process.
+func makeTempDir(prefix string) (string, error) {
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ownerFile), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// sweepOrphanedDirs removes the temp dirs of launchers that were killed
+// before they could clean up, e.g. by a power loss or the task manager.
+func sweepOrphanedDirs() {
+	if removed := removeOrphanedDirs(); len(removed) > 0 {
+		fmt.Println(msg("orphans_removed", len(removed)))
+	}
+}
+
+// removeOrphanedDirs removes the launcher temp dirs whose owner no longer
+// runs and returns them. Dirs without an owner file are left alone: an
+// older launcher made them, or one starting right now hasn't marked its
+// dir yet.
+func removeOrphanedDirs() []string {
+	tmp := os.TempDir()
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return nil
+	}
+	var removed []string
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), workDirPrefix) {
+			continue
+		}
+		dir := filepath.Join(tmp, e.Name())
+		data, err := ioutil.ReadFile(filepath.Join(dir, ownerFile))
+		if err != nil {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || pid == os.Getpid() || processRunning(pid) {
+			continue
+		}
+		if os.RemoveAll(dir) == nil {
+			removed = append(removed, dir)
+		}
+	}
+	return removed
+}
+
+// processRunning reports whether a process with the PID exists.
+func processRunning(pid int) bool {
+	_, err := sampleProcess(pid)
+	return err == nil
+}
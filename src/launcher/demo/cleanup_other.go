@@ -0,0 +1,8 @@
+//go:build !windows
+
+package demo
+
+// isSharingViolation is always false: Unix lets open files be deleted.
+func isSharingViolation(err error) bool {
+	return false
+}
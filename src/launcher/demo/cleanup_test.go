@@ -0,0 +1,29 @@
+package demo
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRemoveWorkDirOnConsoleCloseRecordsFirst(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	dir, err := ioutil.TempDir("", workDirPrefix+"test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeWorkDir(dir, true)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("%s still exists: %v", dir, err)
+	}
+	// Recorded before trying, in case the console kills the launcher
+	data, _ := ioutil.ReadFile(staleListPath())
+	if !strings.Contains(string(data), dir) {
+		t.Errorf("%s isn't in the stale list: %q", dir, data)
+	}
+	sweepStaleDirs()
+	if _, err := os.Stat(staleListPath()); !os.IsNotExist(err) {
+		t.Errorf("the sweep kept the list of a dir already gone: %v", err)
+	}
+}
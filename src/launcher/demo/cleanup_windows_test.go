@@ -0,0 +1,81 @@
+package demo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// heldWorkDir returns a work dir with a file held open the way an exiting
+// PHP holds the database: without FILE_SHARE_DELETE, which os.Open and
+// os.Create leave out.
+func heldWorkDir(t *testing.T) (string, *os.File) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", workDirPrefix+"test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, "database.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		f.Close()
+		os.RemoveAll(dir)
+	})
+	if err := os.RemoveAll(dir); !isSharingViolation(err) {
+		t.Fatalf("RemoveAll with the file open = %v, want a sharing violation", err)
+	}
+	return dir, f
+}
+
+func TestRemoveAllRetrySucceedsAfterRelease(t *testing.T) {
+	dir, f := heldWorkDir(t)
+	const hold = 300 * time.Millisecond
+	go func() {
+		time.Sleep(hold)
+		f.Close()
+	}()
+
+	start := time.Now()
+	if err := removeAllRetry(dir, removeTimeout); err != nil {
+		t.Fatalf("removeAllRetry = %v after the handle was released", err)
+	}
+	if elapsed := time.Since(start); elapsed < hold {
+		t.Errorf("removed after %s, before the handle was released", elapsed)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("%s still exists: %v", dir, err)
+	}
+}
+
+func TestRemoveAllRetryGivesUpWhileHeld(t *testing.T) {
+	dir, _ := heldWorkDir(t)
+	err := removeAllRetry(dir, 200*time.Millisecond)
+	if !isSharingViolation(err) {
+		t.Errorf("removeAllRetry = %v, want the sharing violation once the time is up", err)
+	}
+}
+
+func TestRemoveWorkDirOnConsoleCloseLeavesItForTheSweep(t *testing.T) {
+	dir, f := heldWorkDir(t)
+	t.Cleanup(func() { os.Remove(staleListPath()) })
+
+	start := time.Now()
+	removeWorkDir(dir, true)
+	if elapsed := time.Since(start); elapsed > consoleHandlerTimeout/2 {
+		t.Errorf("removeWorkDir took %s with the console closing", elapsed)
+	}
+	data, _ := os.ReadFile(staleListPath())
+	if !strings.Contains(string(data), dir) {
+		t.Fatalf("%s isn't in the stale list: %q", dir, data)
+	}
+
+	f.Close()
+	sweepStaleDirs()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("the sweep left %s: %v", dir, err)
+	}
+}
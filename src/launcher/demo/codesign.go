@@ -0,0 +1,251 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// packSigning is how pack --out signs the launchers it built: with
+// signtool for Windows and codesign or rcodesign for macOS, or with a
+// command of the vendor's own for every target.
+type packSigning struct {
+	identity, timestamp string
+	// command is split into arguments like a shell would, without
+	// expanding anything but the placeholders
+	command string
+}
+
+// codesignPasswordEnv holds the password of a .pfx or .p12 identity, kept
+// out of pack's command line and shell history. rcodesign reads it from a
+// private temp file; signtool only takes it as an argument, so it does
+// show in the process list while signtool runs.
+const codesignPasswordEnv = "PACK_CODESIGN_PASSWORD"
+
+func (s *packSigning) enabled() bool {
+	return s.identity != "" || s.command != ""
+}
+
+// identityFile reports whether the identity is a certificate file rather
+// than a name looked up in the certificate store or keychain.
+func (s *packSigning) identityFile() bool {
+	ext := strings.ToLower(filepath.Ext(s.identity))
+	return ext == ".pfx" || ext == ".p12"
+}
+
+// check catches, before anything is built, signing that can't happen.
+func (s *packSigning) check(targets []packTarget) []string {
+	if !s.enabled() {
+		return nil
+	}
+	if s.command != "" {
+		if _, err := splitCommandLine(s.command); err != nil {
+			return []string{fmt.Sprintf("--codesign-command: %v", err)}
+		}
+		return nil
+	}
+	var problems []string
+	if s.identityFile() {
+		if _, err := os.Stat(s.identity); err != nil {
+			problems = append(problems, fmt.Sprintf("--codesign-identity %s can't be read: %v", s.identity, err))
+		}
+	}
+	for _, t := range targets {
+		if _, err := s.tool(t.goos); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// tool picks the signing tool for goos.
+func (s *packSigning) tool(goos string) (string, error) {
+	switch goos {
+	case "windows":
+		if _, err := exec.LookPath("signtool"); err != nil {
+			return "", errors.New("signing for Windows needs signtool on PATH (from the Windows SDK); use --codesign-command for another tool")
+		}
+		return "signtool", nil
+	case "darwin":
+		if _, err := exec.LookPath("codesign"); err == nil && !s.identityFile() {
+			return "codesign", nil
+		}
+		if _, err := exec.LookPath("rcodesign"); err == nil && s.identityFile() {
+			return "rcodesign", nil
+		}
+		if !s.identityFile() {
+			return "", errors.New("signing for macOS with a keychain identity needs codesign, i.e. packing on a Mac; elsewhere pass a .p12 file for rcodesign")
+		}
+		return "", errors.New("signing for macOS with a .p12 file needs rcodesign on PATH")
+	}
+	return "", nil
+}
+
+// signCommand is the command that signs file, built for goos, or nil where
+// there is nothing to sign it with. passwordFile holds the identity's
+// password for the tools that read it from a file.
+func (s *packSigning) signCommand(goos, file, passwordFile string) ([]string, error) {
+	if s.command != "" {
+		args, err := splitCommandLine(s.command)
+		if err != nil {
+			return nil, err
+		}
+		r := strings.NewReplacer("{file}", file, "{identity}", s.identity, "{timestamp}", s.timestamp)
+		for i := range args {
+			args[i] = r.Replace(args[i])
+		}
+		return args, nil
+	}
+	tool, err := s.tool(goos)
+	if err != nil {
+		return nil, err
+	}
+	password := os.Getenv(codesignPasswordEnv)
+	var args []string
+	switch tool {
+	case "signtool":
+		args = []string{"signtool", "sign", "/fd", "sha256"}
+		if s.identityFile() {
+			args = append(args, "/f", s.identity)
+			if password != "" {
+				args = append(args, "/p", password)
+			}
+		} else {
+			args = append(args, "/n", s.identity)
+		}
+		if s.timestamp != "" {
+			args = append(args, "/tr", s.timestamp, "/td", "sha256")
+		}
+	case "codesign":
+		// The hardened runtime, which notarization requires
+		args = []string{"codesign", "--force", "--deep", "--options", "runtime", "--sign", s.identity}
+		if s.timestamp != "" {
+			args = append(args, "--timestamp="+s.timestamp)
+		} else {
+			args = append(args, "--timestamp")
+		}
+	case "rcodesign":
+		args = []string{"rcodesign", "sign", "--p12-file", s.identity, "--code-signature-flags", "runtime"}
+		if passwordFile != "" {
+			args = append(args, "--p12-password-file", passwordFile)
+		}
+		if s.timestamp != "" {
+			args = append(args, "--timestamp-url", s.timestamp)
+		}
+	default:
+		return nil, nil
+	}
+	return append(args, file), nil
+}
+
+// sign signs file, the launcher or bundle built for goos. Without signing
+// set up, a macOS bundle still gets the ad-hoc signature.
+func (s *packSigning) sign(goos, file string) error {
+	if !s.enabled() {
+		if goos == "darwin" {
+			signAppBundle(file)
+		}
+		return nil
+	}
+	var passwordFile string
+	if password := os.Getenv(codesignPasswordEnv); password != "" && s.command == "" {
+		tool, _ := s.tool(goos)
+		switch tool {
+		case "rcodesign":
+			f, err := writePasswordFile(password)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(f)
+			passwordFile = f
+		case "signtool":
+			fmt.Printf("Warning: signtool takes the %s password on its command line, where other users of this machine can see it while it runs; import the certificate and pass its subject name to keep it private\n", codesignPasswordEnv)
+		}
+	}
+	args, err := s.signCommand(goos, file, passwordFile)
+	if err != nil {
+		return err
+	}
+	if args == nil {
+		fmt.Printf("Warning: %s isn't signed; there's no signing tool for %s, use --codesign-command\n", file, goos)
+		return nil
+	}
+	fmt.Printf("Signing %s...\n", file)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("signing %s: %w", file, err)
+	}
+	return nil
+}
+
+// writePasswordFile writes password to a temp file only the user can
+// read, for the caller to remove.
+func writePasswordFile(password string) (string, error) {
+	f, err := ioutil.TempFile("", "pack_codesign_*")
+	if err != nil {
+		return "", err
+	}
+	// TempFile creates it 0600
+	_, err = f.WriteString(password)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("writing the signing password: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// splitCommandLine splits s into arguments at spaces outside single or
+// double quotes, which group, with backslash escaping a character in
+// double quotes and outside quotes, except on Windows where paths are
+// full of them.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'' && os.PathSeparator != '\\':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return args, nil
+}
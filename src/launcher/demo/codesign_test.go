@@ -0,0 +1,44 @@
+package demo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSignCommandKeepsPasswordOffCommandLine(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs an rcodesign script on PATH")
+	}
+	bin := t.TempDir()
+	ioutil.WriteFile(filepath.Join(bin, "rcodesign"), []byte("#!/bin/sh\n"), 0755)
+	t.Setenv("PATH", bin)
+	const password = "hunter2"
+	t.Setenv(codesignPasswordEnv, password)
+
+	s := &packSigning{identity: "cert.p12"}
+	file, err := writePasswordFile(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file)
+	args, err := s.signCommand("darwin", "Demo.app", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Join(args, " ")
+	if strings.Contains(line, password) || !strings.Contains(line, "--p12-password-file "+file) {
+		t.Errorf("rcodesign command %q, want the password file and not the password", line)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("password file mode %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	if data, _ := ioutil.ReadFile(file); string(data) != password {
+		t.Errorf("password file holds %q", data)
+	}
+}
@@ -0,0 +1,36 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// consoleCloseSignal is delivered on the shutdown channel when the console
+// window is closed or the user logs off or shuts down. The OS only grants a
+// few seconds before terminating the process, so shutdown must skip anything
+// slow when it sees this signal.
+type consoleCloseSignal struct{}
+
+func (consoleCloseSignal) String() string { return "console closed" }
+func (consoleCloseSignal) Signal()        {}
+
+// Error lets the signal double as the cancel cause of the run context.
+func (consoleCloseSignal) Error() string { return "console closed" }
+
+// SignalCause turns a shutdown signal into a context cancel cause for Run,
+// keeping consoleCloseSignal recognizable for the fast shutdown path.
+func SignalCause(sig os.Signal) error {
+	if cs, ok := sig.(consoleCloseSignal); ok {
+		return cs
+	}
+	return fmt.Errorf("received %v", sig)
+}
+
+// newCommand is exec.Command for the console programs the launcher runs,
+// which must not open console windows of their own when it has none.
+func newCommand(name string, arg ...string) *exec.Cmd {
+	cmd := exec.Command(name, arg...)
+	hideConsoleWindow(cmd)
+	return cmd
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package demo
+
+import (
+	"os"
+	"os/exec"
+)
+
+// NotifyConsoleClose is a no-op outside Windows, where closing the terminal
+// delivers SIGHUP through os/signal instead.
+func NotifyConsoleClose(c chan<- os.Signal) func() {
+	return func() {}
+}
+
+// AttachConsole, OpenConsole and hideConsoleWindow are no-ops outside
+// Windows, where the launcher always has the terminal it was started from.
+func AttachConsole()                  {}
+func OpenConsole()                    {}
+func hideConsoleWindow(cmd *exec.Cmd) {}
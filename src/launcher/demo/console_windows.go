@@ -0,0 +1,231 @@
+package demo
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	kernel32                  = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleCtrlHandler = kernel32.NewProc("SetConsoleCtrlHandler")
+	procGetModuleHandleW      = kernel32.NewProc("GetModuleHandleW")
+	procAttachConsole         = kernel32.NewProc("AttachConsole")
+	procAllocConsole          = kernel32.NewProc("AllocConsole")
+	procRegisterClassExW      = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW       = user32.NewProc("CreateWindowExW")
+	procDefWindowProcW        = user32.NewProc("DefWindowProcW")
+	procGetMessageW           = user32.NewProc("GetMessageW")
+	procDispatchMessageW      = user32.NewProc("DispatchMessageW")
+)
+
+// Console control events not covered by os/signal.
+const (
+	ctrlCloseEvent    = 2
+	ctrlLogoffEvent   = 5
+	ctrlShutdownEvent = 6
+)
+
+const (
+	attachParentProcess = ^uintptr(0) // ATTACH_PARENT_PROCESS, (DWORD)-1
+	createNoWindow      = 0x08000000
+)
+
+const (
+	wmQueryEndSession = 0x11
+	wmEndSession      = 0x16
+)
+
+// consoleHandlerTimeout stays below the ~5 seconds Windows waits for a
+// handler before terminating the process.
+const consoleHandlerTimeout = 4500 * time.Millisecond
+
+// wndClassEx is WNDCLASSEXW.
+type wndClassEx struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   syscall.Handle
+	Icon       syscall.Handle
+	Cursor     syscall.Handle
+	Background syscall.Handle
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     syscall.Handle
+}
+
+// winMsg is MSG.
+type winMsg struct {
+	Hwnd    syscall.Handle
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      struct{ X, Y int32 }
+	Private uint32
+}
+
+// windowless is set when the launcher, built as a GUI-subsystem app, runs
+// without a console: its output then only reaches launcher.log.
+var windowless bool
+
+// AttachConsole borrows the console of the cmd.exe or PowerShell the
+// launcher was started from, so "status" or "logs" print there. A console
+// build has its own already; started from Explorer there is none, and
+// output goes to the null device so child processes get valid handles.
+func AttachConsole() {
+	if hwnd, _, _ := procGetConsoleWindow.Call(); hwnd != 0 {
+		return
+	}
+	if redirected() {
+		return // e.g. "demo.exe status > status.txt"
+	}
+	if ok, _, _ := procAttachConsole.Call(attachParentProcess); ok == 0 {
+		windowless = true
+		useNullOutput()
+		return
+	}
+	useConsoleOutput()
+}
+
+// OpenConsole gives a windowless launcher a console window of its own, for
+// --console.
+func OpenConsole() {
+	if !windowless {
+		return
+	}
+	if ok, _, _ := procAllocConsole.Call(); ok == 0 {
+		return
+	}
+	windowless = false
+	useConsoleOutput()
+}
+
+// redirected reports whether the launcher was given an output handle, which
+// a GUI-subsystem app only gets when its output is redirected.
+func redirected() bool {
+	h, err := syscall.GetStdHandle(syscall.STD_OUTPUT_HANDLE)
+	return err == nil && h != 0 && h != syscall.InvalidHandle
+}
+
+func useConsoleOutput() {
+	if out, err := os.OpenFile("CONOUT$", os.O_RDWR, 0); err == nil {
+		os.Stdout, os.Stderr = out, out
+	}
+	if in, err := os.OpenFile("CONIN$", os.O_RDWR, 0); err == nil {
+		os.Stdin = in
+	}
+}
+
+func useNullOutput() {
+	if out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout, os.Stderr = out, out
+	}
+	if in, err := os.Open(os.DevNull); err == nil {
+		os.Stdin = in
+	}
+}
+
+// hideConsoleWindow keeps a console program started by a windowless
+// launcher, PHP or taskkill, from opening a console window of its own.
+func hideConsoleWindow(cmd *exec.Cmd) {
+	if !windowless {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// Ignored by GUI programs, so the browser still shows
+	cmd.SysProcAttr.CreationFlags |= createNoWindow
+}
+
+// NotifyConsoleClose forwards console close, logoff and shutdown events to c
+// as consoleCloseSignal. Windows kills the process as soon as the handler
+// returns, so the handler blocks until the returned function is called
+// (after cleanup) or the timeout runs out.
+func NotifyConsoleClose(c chan<- os.Signal) func() {
+	done := make(chan struct{})
+	closing := consoleClosing(c, done, consoleHandlerTimeout)
+	procSetConsoleCtrlHandler.Call(syscall.NewCallback(consoleCtrlHandler(closing)), 1)
+	go watchEndSession(closing)
+
+	return func() { close(done) }
+}
+
+// consoleClosing returns what a close event does: send consoleCloseSignal
+// on c, then wait for done or the timeout.
+func consoleClosing(c chan<- os.Signal, done <-chan struct{}, timeout time.Duration) func() {
+	return func() {
+		select {
+		case c <- consoleCloseSignal{}:
+		default:
+		}
+		select {
+		case <-done:
+		case <-time.After(timeout):
+		}
+	}
+}
+
+// consoleCtrlHandler is the HandlerRoutine for SetConsoleCtrlHandler.
+func consoleCtrlHandler(closing func()) func(event uint32) uintptr {
+	return func(event uint32) uintptr {
+		switch event {
+		case ctrlCloseEvent, ctrlLogoffEvent, ctrlShutdownEvent:
+			closing()
+			return 1
+		}
+		// Ctrl+C and Ctrl+Break are left to os/signal
+		return 0
+	}
+}
+
+// watchEndSession runs a hidden window for WM_ENDSESSION. Once a process
+// has loaded user32.dll, which setting the console icon does, Windows
+// sends logoff and shutdown to its windows instead of the console
+// handler, and without a window the launcher would just be terminated.
+func watchEndSession(closing func()) {
+	// The window belongs to this thread, which must pump its messages
+	runtime.LockOSThread()
+	instance, _, _ := procGetModuleHandleW.Call(0)
+	class, _ := syscall.UTF16PtrFromString("laravel_demo_endsession")
+	wc := wndClassEx{
+		WndProc: syscall.NewCallback(func(hwnd, message, wParam, lParam uintptr) uintptr {
+			switch message {
+			case wmQueryEndSession:
+				return 1
+			case wmEndSession:
+				if wParam != 0 {
+					closing()
+				}
+				return 0
+			}
+			ret, _, _ := procDefWindowProcW.Call(hwnd, message, wParam, lParam)
+			return ret
+		}),
+		Instance:  syscall.Handle(instance),
+		ClassName: class,
+	}
+	wc.Size = uint32(unsafe.Sizeof(wc))
+	if atom, _, _ := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); atom == 0 {
+		return
+	}
+	// A top-level window, as message-only ones miss the broadcast
+	hwnd, _, _ := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(class)), 0, 0,
+		0, 0, 0, 0, 0, 0, instance, 0)
+	if hwnd == 0 {
+		return
+	}
+	var m winMsg
+	for {
+		if r, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0); int32(r) <= 0 {
+			return
+		}
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
@@ -0,0 +1,68 @@
+package demo
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// ctrlCEvent is CTRL_C_EVENT, which os/signal handles like Ctrl+Break.
+const ctrlCEvent = 0
+
+func TestConsoleCtrlHandlerForwardsCloseEvents(t *testing.T) {
+	for _, event := range []uint32{ctrlCloseEvent, ctrlLogoffEvent, ctrlShutdownEvent} {
+		c := make(chan os.Signal, 1)
+		done := make(chan struct{})
+		handler := consoleCtrlHandler(consoleClosing(c, done, time.Minute))
+		returned := make(chan uintptr, 1)
+		go func() { returned <- handler(event) }()
+
+		select {
+		case sig := <-c:
+			if _, ok := sig.(consoleCloseSignal); !ok {
+				t.Errorf("event %d sent %v, want consoleCloseSignal", event, sig)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %d sent no signal", event)
+		}
+		// Windows ends the process once the handler returns
+		select {
+		case <-returned:
+			t.Fatalf("event %d: the handler returned before cleanup finished", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(done)
+		select {
+		case r := <-returned:
+			if r != 1 {
+				t.Errorf("event %d: handler returned %d, want 1 (handled)", event, r)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %d: the handler didn't return after cleanup", event)
+		}
+	}
+}
+
+func TestConsoleCtrlHandlerLeavesCtrlCToSignal(t *testing.T) {
+	c := make(chan os.Signal, 1)
+	handler := consoleCtrlHandler(consoleClosing(c, make(chan struct{}), time.Minute))
+	for _, event := range []uint32{ctrlCEvent, ctrlBreakEvent} {
+		if r := handler(event); r != 0 {
+			t.Errorf("event %d: handler returned %d, want 0 so os/signal gets it", event, r)
+		}
+	}
+	select {
+	case sig := <-c:
+		t.Errorf("Ctrl+C sent %v", sig)
+	default:
+	}
+}
+
+func TestConsoleClosingGivesUpAfterTimeout(t *testing.T) {
+	c := make(chan os.Signal) // nobody listens
+	start := time.Now()
+	consoleClosing(c, make(chan struct{}), 50*time.Millisecond)()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("closing took %s with a 50ms timeout", elapsed)
+	}
+}
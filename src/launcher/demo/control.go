@@ -0,0 +1,176 @@
+package demo
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// controlServer is a loopback-only JSON endpoint for inspecting a running
+// demo, on its own random port so the app can't shadow it.
+type controlServer struct {
+	srv      *http.Server
+	url      string
+	token    string // what actions must send, published in control.json only
+	infoPath string // control.json, once published
+}
+
+// controlInfoFile is published in the run's stateDir.
+const controlInfoFile = "control.json"
+
+// controlInfo is control.json, where kiosk supervisors and test harnesses
+// find the control API of a running demo without reading the console.
+type controlInfo struct {
+	PID     int    `json:"pid"`
+	URL     string `json:"url"`      // the control API
+	Token   string `json:"token"`    // for the Authorization header of actions
+	DemoURL string `json:"demo_url"` // what the browser opens
+}
+
+// authorize sets the header that lets req run an action.
+func (info controlInfo) authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+info.Token)
+}
+
+// startControlServer serves GET /status, GET /time-remaining and GET
+// /php-output on a free port of host, and POST /<name> for every entry of
+// actions, which answers with the new status. Scripts on the demo's own origin may read
+// /time-remaining. The functions are called concurrently and must be safe
+// for that.
+//
+// Any web page the prospect opens can send requests to loopback, so only
+// a Host naming the control API itself gets an answer, which defeats DNS
+// rebinding, browsers' requests, which carry an Origin, are refused beyond
+// /time-remaining, and actions need the token of control.json.
+func startControlServer(host, origin string, status func() map[string]interface{}, timeLeft func() timeRemaining, phpOutput func() []phpOutputLog, actions map[string]func() error) (*controlServer, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	cs := &controlServer{
+		url:   serverURL(host, l.Addr().(*net.TCPAddr).Port),
+		token: hex.EncodeToString(token),
+	}
+	hosts := map[string]bool{net.JoinHostPort(host, port): true, net.JoinHostPort("localhost", port): true}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, status())
+	})
+	mux.HandleFunc("/php-output", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"php": phpOutput()})
+	})
+	mux.HandleFunc("/time-remaining", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Origin")
+		if r.Header.Get("Origin") == origin {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Cache-Control", "no-store")
+			writeJSON(w, timeLeft())
+		case http.MethodOptions:
+			w.Header().Set("Access-Control-Allow-Methods", "GET")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	for name, action := range actions {
+		name, action := name, action
+		mux.HandleFunc("/"+name, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+cs.token)) != 1 {
+				http.Error(w, "the token of control.json is missing or wrong", http.StatusUnauthorized)
+				return
+			}
+			logger().Debug("control API", "action", name)
+			if err := action(); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				writeJSON(w, map[string]interface{}{"error": err.Error()})
+				return
+			}
+			writeJSON(w, status())
+		})
+	}
+
+	cs.srv = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hosts[strings.ToLower(r.Host)] {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if o := r.Header.Get("Origin"); o != "" && (o != origin || r.URL.Path != "/time-remaining") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})}
+	go cs.srv.Serve(l)
+	return cs, nil
+}
+
+// publish writes control.json to path, readable by the user only since
+// it holds the token.
+func (cs *controlServer) publish(path, demoURL string) error {
+	data, _ := json.MarshalIndent(controlInfo{PID: os.Getpid(), URL: cs.url, Token: cs.token, DemoURL: demoURL}, "", "  ")
+	// An older file would keep its mode
+	os.Remove(path)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	cs.infoPath = path
+	return nil
+}
+
+// Close stops the server and removes control.json.
+func (cs *controlServer) Close() error {
+	if cs.infoPath != "" {
+		os.Remove(cs.infoPath)
+	}
+	return cs.srv.Close()
+}
+
+// stateDir is where this run publishes control.json and launcher.log:
+// its session's dir, or the app's cache dir for the app's single
+// instance, as instanceStateDir finds them. Other runs, such as
+// --serve-dir, would clobber the demo's and publish nothing.
+func (l *Launcher) stateDir() string {
+	switch {
+	case l.session != nil:
+		return l.session.dir
+	case l.instance != nil:
+		return filepath.Dir(l.instance.infoPath)
+	}
+	return ""
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
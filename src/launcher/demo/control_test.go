@@ -0,0 +1,123 @@
+package demo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func startTestControl(t *testing.T, called *int) (*controlServer, controlInfo) {
+	t.Helper()
+	status := func() map[string]interface{} { return map[string]interface{}{"ok": true} }
+	timeLeft := func() timeRemaining { return timeRemaining{} }
+	phpOutput := func() []phpOutputLog {
+		return []phpOutputLog{{Addr: "127.0.0.1:9000", Lines: []string{"PHP Deprecated: x"}}}
+	}
+	cs, err := startControlServer("127.0.0.1", "http://127.0.0.1:8000", status, timeLeft, phpOutput, map[string]func() error{
+		"shutdown": func() error { *called++; return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+
+	path := filepath.Join(t.TempDir(), controlInfoFile)
+	if err := cs.publish(path, "http://127.0.0.1:8000/"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info controlInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Token == "" {
+		t.Fatal("control.json has no token")
+	}
+	return cs, info
+}
+
+func TestControlActionsNeedToken(t *testing.T) {
+	called := 0
+	_, info := startTestControl(t, &called)
+
+	tests := []struct {
+		name   string
+		modify func(*http.Request)
+		status int
+	}{
+		{"no token", func(*http.Request) {}, http.StatusUnauthorized},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"token", info.authorize, http.StatusOK},
+		{"cross-site form", func(r *http.Request) {
+			info.authorize(r)
+			r.Header.Set("Origin", "https://evil.example")
+		}, http.StatusForbidden},
+		{"demo origin", func(r *http.Request) {
+			info.authorize(r)
+			r.Header.Set("Origin", "http://127.0.0.1:8000")
+		}, http.StatusForbidden},
+		{"rebound host", func(r *http.Request) {
+			info.authorize(r)
+			r.Host = "evil.example"
+		}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, info.URL+"/shutdown", nil)
+		tt.modify(req)
+		resp, err := loopbackClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+	if called != 1 {
+		t.Errorf("action ran %d times, want 1", called)
+	}
+}
+
+func TestControlTimeRemainingAllowsDemoOrigin(t *testing.T) {
+	called := 0
+	_, info := startTestControl(t, &called)
+	for origin, status := range map[string]int{
+		"http://127.0.0.1:8000": http.StatusOK,
+		"https://evil.example":  http.StatusForbidden,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, info.URL+"/time-remaining", nil)
+		req.Header.Set("Origin", origin)
+		resp, err := loopbackClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Origin %s: status %d, want %d", origin, resp.StatusCode, status)
+		}
+	}
+}
+
+func TestControlPHPOutput(t *testing.T) {
+	called := 0
+	_, info := startTestControl(t, &called)
+	resp, err := loopbackClient.Get(info.URL + "/php-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		PHP []phpOutputLog `json:"php"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.PHP) != 1 || len(out.PHP[0].Lines) != 1 || out.PHP[0].Lines[0] != "PHP Deprecated: x" {
+		t.Errorf("GET /php-output = %+v", out.PHP)
+	}
+}
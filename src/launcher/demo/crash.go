@@ -0,0 +1,125 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// crashExitCode tells scripts a crash apart from an ordinary failure
+	// (1) and a usage error (2).
+	crashExitCode = 3
+	// crashCleanupTimeout bounds the cleanup after a panic, which may
+	// wait on a lock the panicking goroutine held.
+	crashCleanupTimeout = 10 * time.Second
+
+	crashReportFile = "crash-report.txt"
+	crashMarkerFile = "crash.json"
+)
+
+// activeLauncher is the running launcher, whose children and work dir a
+// crash in any goroutine cleans up.
+var activeLauncher atomic.Pointer[Launcher]
+
+var crashOnce sync.Once
+
+// crashMarker is left behind by a crash for the next launch to find.
+type crashMarker struct {
+	Time   time.Time `json:"time"`
+	Report string    `json:"report"`
+}
+
+// goSafe runs fn in a goroutine whose panic is handled by RecoverCrash
+// instead of tearing the process down without cleanup.
+func goSafe(where string, fn func()) {
+	go func() {
+		defer RecoverCrash(where)
+		fn()
+	}()
+}
+
+// RecoverCrash must be deferred at the top of main and of every
+// long-lived goroutine. On a panic it writes a crash report with the full
+// stack, stops PHP and the side processes, removes the work dir, leaves a
+// marker for the next launch and exits with crashExitCode.
+func RecoverCrash(where string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	crashOnce.Do(func() {
+		l := activeLauncher.Load()
+		config := &Manifest{AppName: "laravel_demo"}
+		if l != nil {
+			config = &l.Config
+		}
+
+		if l != nil {
+			stack = append(stack, "\nRecent PHP output:\n"+phpOutputText(l.phpOutput())...)
+		}
+		report := writeCrashReport(config, where, r, stack)
+		fmt.Println(msg("crashed", report))
+
+		if l != nil {
+			done := make(chan struct{})
+			go func() {
+				defer func() { recover() }()
+				l.cleanup()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(crashCleanupTimeout):
+			}
+		}
+		os.Exit(crashExitCode)
+	})
+	// Another goroutine is already handling a crash and will exit
+	select {}
+}
+
+// writeCrashReport saves the report and the marker in the app's cache dir
+// and returns the report's path.
+func writeCrashReport(config *Manifest, where string, r interface{}, stack []byte) string {
+	dir := appCacheDir(config)
+	os.MkdirAll(dir, 0755)
+	report := filepath.Join(dir, crashReportFile)
+	text := fmt.Sprintf("App: %s %s\nTime: %s\nSystem: %s/%s\nPanic in %s: %v\n\n%s",
+		config.AppName, config.AppVersion, time.Now().Format(time.RFC3339), runtime.GOOS, runtime.GOARCH, where, r, stack)
+	if err := ioutil.WriteFile(report, []byte(text), 0644); err != nil {
+		// The console is all that's left
+		fmt.Print(text)
+		return report
+	}
+	data, _ := json.Marshal(crashMarker{Time: time.Now(), Report: report})
+	ioutil.WriteFile(filepath.Join(dir, crashMarkerFile), data, 0644)
+	return report
+}
+
+// checkCrashMarker tells the user when the previous run crashed and where
+// its report is, then forgets about it.
+func checkCrashMarker(config *Manifest) {
+	path := filepath.Join(appCacheDir(config), crashMarkerFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	os.Remove(path)
+	var marker crashMarker
+	if json.Unmarshal(data, &marker) != nil {
+		return
+	}
+	fmt.Println(msg("crashed_last_time", marker.Time.Local().Format("2006-01-02 15:04"), marker.Report))
+	if config.SupportURL != "" {
+		fmt.Println(msg("crash_send", config.SupportURL))
+	}
+}
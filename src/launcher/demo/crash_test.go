@@ -0,0 +1,106 @@
+package demo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// crashEnv makes the test binary run a demo that panics; its value is the
+// dir the run's details are written to.
+const crashEnv = "LAUNCHER_TEST_CRASH"
+
+// panicClock is the system clock until armed; then the next timer a
+// goroutine asks for panics in that goroutine.
+type panicClock struct {
+	systemClock
+	armed atomic.Bool
+}
+
+func (c *panicClock) After(d time.Duration) <-chan time.Time {
+	if c.armed.Load() {
+		panic("injected by the test")
+	}
+	return c.systemClock.After(d)
+}
+
+// crashedRun is what the crashing launcher leaves for the test.
+type crashedRun struct {
+	WorkDir  string `json:"work_dir"`
+	CacheDir string `json:"cache_dir"`
+	PHPAddr  string `json:"php_addr"`
+}
+
+// TestCrashingLauncher is not a test: TestPanicInGoroutineStopsChildren
+// runs the test binary with it as a launcher that crashes.
+func TestCrashingLauncher(t *testing.T) {
+	dir := os.Getenv(crashEnv)
+	if dir == "" {
+		t.Skip("only run by TestPanicInGoroutineStopsChildren")
+	}
+	opened := make(chan string, 1)
+	// With a duration, the expiry timer asks the clock every second
+	l := testLauncher(t, "serve", fmt.Sprintf(testManifest, `, "allowed_demo_duration_minutes": 60`), opened)
+	clock := &panicClock{}
+	l.Clock = clock
+	done := runLauncher(l, context.Background())
+	select {
+	case url := <-opened:
+		waitServed(t, url)
+	case err := <-done:
+		t.Fatalf("Run returned before opening the browser: %v", err)
+	}
+
+	data, _ := json.Marshal(crashedRun{WorkDir: l.workDir, CacheDir: appCacheDir(&l.Config), PHPAddr: l.servers[0].addr})
+	if err := ioutil.WriteFile(filepath.Join(dir, "run.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	clock.armed.Store(true)
+	// RecoverCrash exits the process
+	time.Sleep(30 * time.Second)
+	t.Fatal("no goroutine crashed")
+}
+
+func TestPanicInGoroutineStopsChildren(t *testing.T) {
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrashingLauncher$")
+	cmd.Env = append(os.Environ(), crashEnv+"="+dir)
+	out, err := cmd.CombinedOutput()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != crashExitCode {
+		t.Fatalf("crashing launcher ended with %v, want exit code %d:\n%s", err, crashExitCode, out)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "run.json"))
+	if err != nil {
+		t.Fatalf("the launcher never came up: %v\n%s", err, out)
+	}
+	var run crashedRun
+	json.Unmarshal(data, &run)
+	defer os.RemoveAll(run.CacheDir)
+
+	if conn, err := net.DialTimeout("tcp", run.PHPAddr, time.Second); err == nil {
+		conn.Close()
+		t.Errorf("PHP on %s still runs after the crash", run.PHPAddr)
+	}
+	if _, err := os.Stat(run.WorkDir); !os.IsNotExist(err) {
+		t.Errorf("work dir %s left behind: %v", run.WorkDir, err)
+	}
+	if _, err := os.Stat(filepath.Join(run.CacheDir, crashMarkerFile)); err != nil {
+		t.Errorf("no crash marker: %v", err)
+	}
+	report, _ := ioutil.ReadFile(filepath.Join(run.CacheDir, crashReportFile))
+	if !strings.Contains(string(report), "injected by the test") || !strings.Contains(string(report), "goroutine") {
+		t.Errorf("the crash report lacks the panic or its stack:\n%s", report)
+	}
+}
@@ -0,0 +1,73 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// cleansOnExit reports whether the demo's data goes when it ends, which is
+// the default. With "clean_on_exit": false the database and storage live
+// in the per-user data dir instead of the work dir, and the next launch
+// picks them up again, so a prospect's data survives restarts while the
+// code is extracted afresh (or taken from extraction_cache):
+//
+//	<data>/laravel_demo/<app>/data/   database and storage
+func (m *Manifest) cleansOnExit() bool {
+	return m.CleanOnExit == nil || *m.CleanOnExit
+}
+
+// keepsData reports whether this run uses the kept data. Sessions keep
+// their data anyway; --work-dir, --check and --deterministic always get
+// the bundled data.
+func (l *Launcher) keepsData() bool {
+	return !l.Config.cleansOnExit() && l.extractsBundle() && l.Options.Session == "" &&
+		l.Options.WorkDir == "" && !l.Options.Check && !l.Options.Deterministic
+}
+
+// userDataDir is the OS's place for data an app keeps for the user:
+// $XDG_DATA_HOME or ~/.local/share, %APPDATA% on Windows, and
+// ~/Library/Application Support on macOS.
+func userDataDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows", "darwin", "ios", "plan9":
+		return os.UserConfigDir()
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share"), nil
+}
+
+// appDataDir is where the launcher keeps the app's data for the user. It
+// is named like appCacheDir.
+func appDataDir(config *Manifest) (string, error) {
+	dir, err := userDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "laravel_demo", appSlug(config)), nil
+}
+
+// prepareKeptData uses the data of the last run, copying the bundled data
+// on the first.
+func (l *Launcher) prepareKeptData() error {
+	dir, err := appDataDir(&l.Config)
+	if err != nil {
+		return err
+	}
+	l.dataDir = filepath.Join(dir, "data")
+	created, err := prepareDataDir(&l.Config, l.baseDir, l.appRoot, l.dataDir)
+	if err != nil {
+		return err
+	}
+	if !created {
+		fmt.Println(msg("using_kept_data", l.dataDir))
+	}
+	return nil
+}
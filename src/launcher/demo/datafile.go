@@ -0,0 +1,182 @@
+package demo
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dataFileMeta is the first entry of an exported data archive and ties the
+// archive to the app that produced it.
+type dataFileMeta struct {
+	AppName    string    `json:"app_name"`
+	AppVersion string    `json:"app_version"`
+	Created    time.Time `json:"created"`
+}
+
+const dataFileMetaName = "demo-export.json"
+
+// exportData zips paths (absolute, below baseDir) into dest. PHP must be
+// stopped first so the SQLite file isn't copied in the middle of a write.
+func exportData(dest string, config *Manifest, baseDir string, paths []string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+
+	meta, _ := json.Marshal(dataFileMeta{AppName: config.AppName, AppVersion: config.AppVersion, Created: time.Now()})
+	w, err := zw.Create(dataFileMetaName)
+	if err == nil {
+		_, err = w.Write(meta)
+	}
+
+	for _, root := range paths {
+		if err != nil {
+			break
+		}
+		if _, statErr := os.Stat(root); os.IsNotExist(statErr) {
+			continue
+		}
+		err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(baseDir, p)
+			if err != nil {
+				return err
+			}
+			return addZipFile(zw, p, filepath.ToSlash(rel))
+		})
+	}
+
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dest)
+	}
+	return err
+}
+
+func addZipFile(zw *zip.Writer, src, name string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
+
+// importData unpacks an archive written by exportData over baseDir. An
+// archive from another app is rejected; one from another version of the
+// same app only produces a warning. Only the demo's data, paths as
+// exportData was given them, may be in it: the file travels by email, and
+// an entry for public/index.php or vendor/ would be code PHP runs.
+func importData(src string, config *Manifest, baseDir string, paths []string) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid demo data file: %w", src, err)
+	}
+	defer zr.Close()
+
+	if len(zr.File) == 0 || zr.File[0].Name != dataFileMetaName {
+		return fmt.Errorf("%s is not a demo data file", src)
+	}
+	var meta dataFileMeta
+	if err := readZipJSON(zr.File[0], &meta); err != nil {
+		return fmt.Errorf("%s is corrupt: %w", src, err)
+	}
+	if meta.AppName != config.AppName {
+		return fmt.Errorf("%s was exported from %q, not %q", src, meta.AppName, config.AppName)
+	}
+	if meta.AppVersion != config.AppVersion {
+		fmt.Printf("Warning: data was exported from version %s, this is version %s.\n", meta.AppVersion, config.AppVersion)
+	}
+
+	// Every entry is checked before anything is written
+	entries := zr.File[1:]
+	targets := make([]string, len(entries))
+	for i, zf := range entries {
+		target, err := safeJoin(baseDir, zf.Name)
+		if err != nil {
+			return fmt.Errorf("%s is corrupt: %w", src, err)
+		}
+		if !isDataPath(target, paths) {
+			return fmt.Errorf("%s holds %s, which isn't demo data; it was not written by this demo", src, zf.Name)
+		}
+		targets[i] = target
+	}
+	for i, zf := range entries {
+		if err := extractZipFile(zf, targets[i]); err != nil {
+			return fmt.Errorf("%s is corrupt: %s: %w", src, zf.Name, err)
+		}
+	}
+	return nil
+}
+
+// isDataPath reports whether target is one of paths or inside one.
+func isDataPath(target string, paths []string) bool {
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if target == p || strings.HasPrefix(target, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportFileName is what a data export made while the demo runs is
+// called, unique to the second.
+func exportFileName(config *Manifest, now time.Time) string {
+	return appSlug(config) + "_data_" + now.Format("20060102_150405") + ".zip"
+}
+
+func readZipJSON(zf *zip.File, v interface{}) error {
+	r, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return json.NewDecoder(r).Decode(v)
+}
+
+func extractZipFile(zf *zip.File, target string) error {
+	if zf.FileInfo().IsDir() {
+		return errors.New("unexpected directory entry")
+	}
+	r, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	// Reading to the end makes zip verify the entry's CRC
+	if _, err := copyStream(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,100 @@
+package demo
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeDataFile writes a data archive for config holding entries.
+func writeDataFile(t *testing.T, config *Manifest, entries map[string]string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "data.zip")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create(dataFileMetaName)
+	json.NewEncoder(w).Encode(dataFileMeta{AppName: config.AppName, AppVersion: config.AppVersion})
+	for name, body := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return file
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	config := &Manifest{AppName: "Demo", AppVersion: "1.0", DBType: "sqlite", DBPath: "database/database.sqlite"}
+	src := t.TempDir()
+	paths := resetPaths(config, src, src)
+	for name, body := range map[string]string{
+		"database/database.sqlite":     "db",
+		"storage/app/uploads/logo.png": "png",
+	} {
+		file := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(t.TempDir(), "export.zip")
+	if err := exportData(archive, config, src, paths); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if err := importData(archive, config, dest, resetPaths(config, dest, dest)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dest, "storage", "app", "uploads", "logo.png"))
+	if err != nil || string(data) != "png" {
+		t.Errorf("imported logo.png = %q, %v", data, err)
+	}
+}
+
+func TestImportDataRejectsCode(t *testing.T) {
+	config := &Manifest{AppName: "Demo", AppVersion: "1.0", DBType: "sqlite", DBPath: "database/database.sqlite"}
+	for _, name := range []string{
+		"public/index.php",
+		"vendor/autoload.php",
+		"storage/application.php",
+		"database/database.sqlite-evil",
+		"../outside",
+	} {
+		dest := t.TempDir()
+		archive := writeDataFile(t, config, map[string]string{
+			"storage/app/ok.txt": "ok",
+			name:                 "<?php system($_GET['c']);",
+		})
+		err := importData(archive, config, dest, resetPaths(config, dest, dest))
+		if err == nil {
+			t.Errorf("importing %s: no error", name)
+			continue
+		}
+		if _, statErr := os.Stat(filepath.Join(dest, "storage", "app", "ok.txt")); statErr == nil {
+			t.Errorf("importing %s: wrote other entries before failing", name)
+		}
+	}
+}
+
+func TestImportDataRejectsOtherApp(t *testing.T) {
+	archive := writeDataFile(t, &Manifest{AppName: "Other"}, nil)
+	dest := t.TempDir()
+	config := &Manifest{AppName: "Demo"}
+	err := importData(archive, config, dest, resetPaths(config, dest, dest))
+	if err == nil || !strings.Contains(err.Error(), "Other") {
+		t.Errorf("importData = %v, want an error naming the other app", err)
+	}
+}
@@ -0,0 +1,100 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deterministic configures --deterministic, the mode for recording demo
+// videos: every take starts from the same data, time and port.
+type Deterministic struct {
+	Port       int    `json:"port"`        // default: php_port
+	FakeNow    string `json:"fake_now"`    // RFC 3339, passed as DEMO_FAKE_NOW
+	RandomSeed int64  `json:"random_seed"` // passed as DEMO_RANDOM_SEED
+}
+
+const (
+	defaultFakeNow    = "2025-01-06T09:00:00Z"
+	defaultRandomSeed = 42
+)
+
+// ApplyDeterministic checks that --deterministic fits the other settings
+// and pins everything that would vary between runs: the port, the clock
+// and seed the app sees, and the data, which is always extracted fresh.
+// The expiry timer is off and, with offline mode, every outbound call.
+func ApplyDeterministic(config *Manifest, opts *Options) error {
+	d := &config.Deterministic
+	if d.Port == 0 {
+		d.Port = config.PHPPort
+	}
+	if d.FakeNow == "" {
+		d.FakeNow = defaultFakeNow
+	}
+	if d.RandomSeed == 0 {
+		d.RandomSeed = defaultRandomSeed
+	}
+
+	var problems []string
+	if d.Port == 0 {
+		problems = append(problems, "it needs a fixed port in deterministic.port or php_port")
+	}
+	if _, err := time.Parse(time.RFC3339, d.FakeNow); err != nil {
+		problems = append(problems, fmt.Sprintf("deterministic.fake_now %q is not an RFC 3339 time", d.FakeNow))
+	}
+	if host, err := listenHost(config); err == nil && !net.ParseIP(host).IsLoopback() {
+		problems = append(problems, fmt.Sprintf("listen_address %s shares the demo on the network", host))
+	}
+	if opts.Session != "" {
+		problems = append(problems, "--session keeps data between runs")
+	}
+	if opts.WorkDir != "" {
+		problems = append(problems, "--work-dir keeps data between runs")
+	}
+	if opts.ImportData != "" {
+		problems = append(problems, "--import-data replaces the pristine data")
+	}
+	if len(problems) > 0 {
+		return errors.New("--deterministic can't be used here: " + strings.Join(problems, "; "))
+	}
+
+	config.PHPPort = d.Port
+	// The recording must not depend on where it was made
+	if config.PinTimezone == "" {
+		config.PinTimezone = fallbackTimezone
+	}
+	if config.PinLocale == "" {
+		config.PinLocale = fallbackLocale
+	}
+	config.AllowedDemoDurationMinutes = 0
+	config.Offline = true
+	opts.Deterministic = true
+	return nil
+}
+
+// deterministicEnv is the frozen time and seed for the app.
+func (l *Launcher) deterministicEnv() []string {
+	if !l.Options.Deterministic {
+		return nil
+	}
+	d := l.Config.Deterministic
+	return []string{"DEMO_FAKE_NOW=" + d.FakeNow, "DEMO_RANDOM_SEED=" + strconv.FormatInt(d.RandomSeed, 10)}
+}
+
+// deterministicBrowserDir is the browser profile every take uses, so the
+// window opens at the same place and size.
+func deterministicBrowserDir(config *Manifest) string {
+	return filepath.Join(appCacheDir(config), "deterministic", "browser")
+}
+
+// announceDeterministic prints what a take depends on, so it can be
+// reproduced.
+func announceDeterministic(config *Manifest) {
+	d := config.Deterministic
+	fmt.Printf("Deterministic mode: port %d, DEMO_FAKE_NOW=%s, DEMO_RANDOM_SEED=%d, fresh data, no expiry, offline, browser profile %s\n",
+		d.Port, d.FakeNow, d.RandomSeed, deterministicBrowserDir(config))
+}
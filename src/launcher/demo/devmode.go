@@ -0,0 +1,70 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ApplyServeDir adapts the run to --serve-dir, where developers point the
+// launcher at their working copy: nothing is extracted or deleted, and the
+// timers that would get in the way of iterating are off.
+func ApplyServeDir(config *Manifest, opts *Options) error {
+	var problems []string
+	if opts.Session != "" {
+		problems = append(problems, "--session")
+	}
+	if opts.WorkDir != "" {
+		problems = append(problems, "--work-dir")
+	}
+	if opts.Check {
+		problems = append(problems, "--check")
+	}
+	if opts.Deterministic {
+		problems = append(problems, "--deterministic")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("--serve-dir can't be combined with %s", strings.Join(problems, ", "))
+	}
+	dir, err := filepath.Abs(opts.ServeDir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("--serve-dir %s is not a directory", opts.ServeDir)
+	}
+	opts.ServeDir = dir
+
+	config.AllowedDemoDurationMinutes = 0
+	// Both would modify or delete files in the working copy
+	config.AutoResetMinutes = 0
+	config.MaxWorkDirMB = 0
+	config.AllowSystemPHP = true
+	return nil
+}
+
+// serveDirManifest returns the working copy's own manifest, if it has
+// one.
+func serveDirManifest(dir string) string {
+	return findManifest(dir)
+}
+
+// serveInPlace uses the --serve-dir checkout as the base dir. A bundle
+// manifest's public_root usually points into the build layout, so the
+// checkout's own public folder is used when that path doesn't exist.
+func (l *Launcher) serveInPlace() error {
+	l.baseDir = l.Options.ServeDir
+	l.dataDir = l.baseDir
+	fmt.Printf("Dev mode: serving %s in place; nothing is extracted or deleted.\n", l.baseDir)
+
+	if _, err := os.Stat(l.bundlePath(l.Config.PublicRoot)); err != nil {
+		if _, err := os.Stat(filepath.Join(l.baseDir, "public", "index.php")); err == nil {
+			fmt.Printf("public_root %q is not in %s, using public instead.\n", l.Config.PublicRoot, l.baseDir)
+			l.Config.PublicRoot = "public"
+			l.Config.AppRoot = ""
+			l.Config.ArtisanPath = ""
+		}
+	}
+	return l.resolvePublicDir()
+}
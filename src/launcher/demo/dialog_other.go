@@ -0,0 +1,40 @@
+//go:build !windows
+
+package demo
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// showErrorDialog shows an alert through osascript on macOS, or zenity or
+// kdialog on Linux, whichever is installed, and waits for it to be closed.
+func showErrorDialog(title, text string) error {
+	if runtime.GOOS == "darwin" {
+		script := "display alert " + appleScriptString(title) + " message " + appleScriptString(text) + " as critical"
+		return runDialog(exec.Command("osascript", "-e", script))
+	}
+	if path, err := exec.LookPath("zenity"); err == nil {
+		return runDialog(exec.Command(path, "--error", "--no-markup", "--title", title, "--text", text))
+	}
+	if path, err := exec.LookPath("kdialog"); err == nil {
+		return runDialog(exec.Command(path, "--title", title, "--error", text))
+	}
+	return errors.New("neither zenity nor kdialog is installed")
+}
+
+// runDialog runs a dialog tool, which was shown unless it failed to start:
+// closing the window instead of clicking OK is an exit status, too.
+func runDialog(cmd *exec.Cmd) error {
+	var exit *exec.ExitError
+	if err := cmd.Run(); err != nil && !errors.As(err, &exit) {
+		return err
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
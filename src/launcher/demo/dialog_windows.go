@@ -0,0 +1,28 @@
+package demo
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	mbIconError = 0x10
+	mbTopmost   = 0x40000
+)
+
+// showErrorDialog shows a message box, in front of other windows as the
+// launcher has none of its own to sit on.
+func showErrorDialog(title, text string) error {
+	t, err := syscall.UTF16PtrFromString(title)
+	if err != nil {
+		return err
+	}
+	m, err := syscall.UTF16PtrFromString(text)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procMessageBoxW.Call(0, uintptr(unsafe.Pointer(m)), uintptr(unsafe.Pointer(t)), mbIconError|mbSetForeground|mbTopmost); r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package demo
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// RunDoctor implements "doctor": what the launcher finds on this machine,
+// to paste into a support request. It looks for browsers afresh and
+// updates the cache.
+func RunDoctor() int {
+	fmt.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("Embedded bundle: %v\n", hasBundle(BundleFS))
+
+	browsers := detectBrowsers()
+	fmt.Println("Browsers:")
+	for _, name := range namedBrowsers {
+		p := browsers[name]
+		if p == "" {
+			p = "not found"
+		}
+		fmt.Printf("  %-8s %s\n", name, p)
+	}
+	fmt.Printf("Browser locations are cached for %v in %s\n", browserCacheTTL, browserCachePath())
+	return 0
+}
@@ -0,0 +1,161 @@
+package demo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The launcher sets Laravel's configuration in PHP's environment, which
+// only reaches what the launcher starts. When the code tree is this run's
+// own it's also written into the app's .env, so an artisan command run by
+// hand, a scheduler or anything else that starts PHP afresh sees the same
+// values, and so does config:cache. A tree shared by sessions or cached
+// runs is left alone: each of them has only its environment, which wins
+// over the file, and its own config cache in its data dir.
+
+// dotEnvHeader introduces the values the bundled .env didn't have.
+const dotEnvHeader = "# Set by the demo launcher on every start"
+
+// bareDotEnvValue is a value that needs no quotes in a .env file.
+var bareDotEnvValue = regexp.MustCompile(`^[A-Za-z0-9_./:@+,=-]*$`)
+
+// writeDotEnv renders vars, KEY=value entries of which the last one for a
+// key wins, into the .env in the app root: keys the file has keep their
+// place with the new value, the others are added at the end. A shared
+// code tree only has its config cache dropped.
+func (l *Launcher) writeDotEnv(vars []string) error {
+	if l.Options.ServeDir != "" {
+		// The developer's own .env
+		return nil
+	}
+	if l.dataDir != l.baseDir {
+		l.dropConfigCache()
+		return nil
+	}
+	values := make(map[string]string)
+	var order []string
+	for _, kv := range vars {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if _, seen := values[k]; !seen {
+			order = append(order, k)
+		}
+		values[k] = v
+	}
+	if l.Config.DBType == "sqlite" && l.Config.DBPath != "" {
+		if _, ok := values["DB_DATABASE"]; !ok {
+			// A relative path only works from the app root
+			values["DB_DATABASE"] = resetPaths(&l.Config, l.dataDir, l.dataAppRoot())[0]
+			order = append(order, "DB_DATABASE")
+		}
+	}
+
+	file := filepath.Join(l.appRoot, ".env")
+	mode := os.FileMode(0600)
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		if info, err := os.Stat(file); err == nil {
+			mode = info.Mode().Perm()
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("reading .env: %w", err)
+	}
+
+	written := make(map[string]bool)
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		m := dotEnvLine.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			continue
+		}
+		if v, ok := values[m[2]]; ok {
+			lines[i] = m[2] + "=" + quoteDotEnv(v) + "\n"
+			written[m[2]] = true
+		}
+	}
+	out := strings.Join(lines, "")
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	var added strings.Builder
+	for _, k := range order {
+		if !written[k] {
+			fmt.Fprintf(&added, "%s=%s\n", k, quoteDotEnv(values[k]))
+		}
+	}
+	if added.Len() > 0 {
+		if !strings.Contains(out, dotEnvHeader) {
+			if out != "" {
+				out += "\n"
+			}
+			out += dotEnvHeader + "\n"
+		}
+		out += added.String()
+	}
+
+	// A unique name, so a PHP reading the old file never sees half of it
+	tmp, err := ioutil.TempFile(l.appRoot, ".env.*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing .env: %w", err)
+	}
+	_, err = tmp.WriteString(out)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing .env: %w", err)
+	}
+	l.dropConfigCache()
+	return nil
+}
+
+// quoteDotEnv writes v so Laravel's dotenv reads it back unchanged: bare
+// when it can be, else double-quoted with backslashes, quotes and the $
+// of variable references escaped.
+func quoteDotEnv(v string) string {
+	if bareDotEnvValue.MatchString(v) {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`)
+	return `"` + r.Replace(v) + `"`
+}
+
+// dropConfigCache removes the config cache, which Laravel reads instead
+// of .env and the environment: one the bundle shipped with holds the
+// values of the machine the demo was built on, one from the last start
+// its port. With warmup_artisan_caches it's built again.
+func (l *Launcher) dropConfigCache() {
+	cache := l.configCachePath()
+	if _, err := os.Stat(cache); err != nil {
+		return
+	}
+	if !l.Config.WarmupArtisanCaches {
+		fmt.Println("Removing the cached config, which would ignore the launcher's settings")
+	}
+	if err := os.Remove(cache); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// configCachePath is where Laravel keeps the config cache: bootstrap/cache
+// in the app's own tree, else a file in the data dir that dataEnv names
+// in APP_CONFIG_CACHE, so sessions sharing a tree don't share it.
+func (l *Launcher) configCachePath() string {
+	if l.dataDir == l.baseDir {
+		return filepath.Join(l.appRoot, "bootstrap", "cache", "config.php")
+	}
+	return filepath.Join(l.dataDir, "config.php")
+}
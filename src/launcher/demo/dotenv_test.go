@@ -0,0 +1,71 @@
+package demo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dotEnvTree returns an app root with a bundled .env and config cache.
+func dotEnvTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "bootstrap", "cache"), 0755)
+	ioutil.WriteFile(filepath.Join(root, ".env"), []byte("APP_NAME=Demo\nAPP_URL=http://localhost\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "bootstrap", "cache", "config.php"), []byte("<?php return [];"), 0644)
+	return root
+}
+
+func TestWriteDotEnvOwnTree(t *testing.T) {
+	root := dotEnvTree(t)
+	l := &Launcher{baseDir: root, dataDir: root, appRoot: root}
+	if err := l.writeDotEnv([]string{"APP_URL=http://127.0.0.1:8123", "DEMO_NOTE=two words"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(root, ".env"))
+	want := "APP_NAME=Demo\nAPP_URL=http://127.0.0.1:8123\n\n" + dotEnvHeader + "\nDEMO_NOTE=\"two words\"\n"
+	if string(data) != want {
+		t.Errorf(".env =\n%s\nwant\n%s", data, want)
+	}
+	if info, err := os.Stat(filepath.Join(root, ".env")); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf(".env lost its mode: %v, %v", info.Mode(), err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "bootstrap", "cache", "config.php")); !os.IsNotExist(err) {
+		t.Errorf("the bundled config cache is still there: %v", err)
+	}
+	entries, _ := os.ReadDir(root)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("temp file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestWriteDotEnvSharedTree(t *testing.T) {
+	root := dotEnvTree(t)
+	data := t.TempDir()
+	l := &Launcher{baseDir: root, dataDir: data, appRoot: root}
+	ioutil.WriteFile(l.configCachePath(), []byte("<?php return ['last' => 'start'];"), 0644)
+
+	if err := l.writeDotEnv([]string{"APP_URL=http://127.0.0.1:8123"}); err != nil {
+		t.Fatal(err)
+	}
+	if env, _ := ioutil.ReadFile(filepath.Join(root, ".env")); string(env) != "APP_NAME=Demo\nAPP_URL=http://localhost\n" {
+		t.Errorf("the shared .env was changed:\n%s", env)
+	}
+	if _, err := os.Stat(filepath.Join(root, "bootstrap", "cache", "config.php")); err != nil {
+		t.Errorf("the shared config cache was touched: %v", err)
+	}
+	if _, err := os.Stat(l.configCachePath()); !os.IsNotExist(err) {
+		t.Errorf("the session's config cache from the last start is still there: %v", err)
+	}
+	found := false
+	for _, kv := range l.dataEnv() {
+		found = found || kv == "APP_CONFIG_CACHE="+filepath.Join(data, "config.php")
+	}
+	if !found {
+		t.Errorf("dataEnv() = %q lacks APP_CONFIG_CACHE in the data dir", l.dataEnv())
+	}
+}
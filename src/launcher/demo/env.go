@@ -0,0 +1,117 @@
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// appURLPlaceholder in an env var value is replaced by the runtime base URL,
+// e.g. "ASSET_URL": "{{app_url}}/assets".
+const appURLPlaceholder = "{{app_url}}"
+
+// appEnv returns what PHP gets on top of the launcher's own environment,
+// which also goes into the app's .env: the time zone and locale in loc,
+// then the manifest's env_vars, then the demo mode and accessibility
+// flags, then values only known at runtime, then the sandbox_network
+// overrides. Later entries win, both for exec and for Laravel, whose
+// dotenv loader never overrides variables that are already set.
+func appEnv(config *Manifest, appRoot, baseURL string, loc localization) []string {
+	var env []string
+	local := loc.env()
+	for _, k := range sortedKeys(local) {
+		env = append(env, fmt.Sprintf("%s=%s", k, local[k]))
+	}
+	placeholders := strings.NewReplacer(appURLPlaceholder, baseURL, timezonePlaceholder, loc.Timezone, localePlaceholder, loc.Locale)
+	for _, k := range sortedKeys(config.EnvVars) {
+		env = append(env, fmt.Sprintf("%s=%s", k, placeholders.Replace(config.EnvVars[k])))
+	}
+	demoEnv := demoModeEnv(config)
+	for _, k := range sortedKeys(demoEnv) {
+		env = append(env, fmt.Sprintf("%s=%s", k, demoEnv[k]))
+	}
+	a11y := accessibilityEnv(config.Accessibility)
+	for _, k := range sortedKeys(a11y) {
+		env = append(env, fmt.Sprintf("%s=%s", k, a11y[k]))
+	}
+
+	computed := map[string]string{"APP_URL": baseURL}
+	if config.Offline {
+		// Tells the app to skip CDN-hosted fonts and scripts
+		computed["DEMO_OFFLINE"] = "1"
+	}
+	if v, ok := config.EnvVars["ASSET_URL"]; ok && strings.Contains(v, appURLPlaceholder) {
+		computed["ASSET_URL"] = placeholders.Replace(v)
+	}
+
+	bundled := readDotEnv(filepath.Join(appRoot, ".env"))
+	for _, k := range sortedKeys(computed) {
+		if v, ok := config.EnvVars[k]; ok && v != computed[k] && !strings.Contains(v, appURLPlaceholder) {
+			fmt.Printf("Overriding %s=%s from env_vars with %s\n", k, v, computed[k])
+		} else if v, ok := bundled[k]; ok && v != computed[k] {
+			fmt.Printf("Overriding %s=%s from the bundled .env with %s\n", k, v, computed[k])
+		}
+		env = append(env, fmt.Sprintf("%s=%s", k, computed[k]))
+	}
+
+	// sandbox_network kill-switches win over everything
+	sandbox := sandboxEnv(config)
+	for _, k := range sortedKeys(sandbox) {
+		env = append(env, fmt.Sprintf("%s=%s", k, sandbox[k]))
+	}
+	return env
+}
+
+// demoModeEnv merges the legacy demo_mode_env_key (set to
+// demo_mode_env_value, "true" by default) with the demo_mode_env map.
+func demoModeEnv(config *Manifest) map[string]string {
+	env := make(map[string]string, len(config.DemoModeEnv)+1)
+	if config.DemoModeEnvKey != "" {
+		value := config.DemoModeEnvValue
+		if value == "" {
+			value = "true"
+		}
+		env[config.DemoModeEnvKey] = value
+	}
+	for k, v := range config.DemoModeEnv {
+		env[k] = v
+	}
+	return env
+}
+
+// readDotEnv does a minimal parse of a .env file, enough to tell which
+// values the bundle ships with. A missing file yields an empty map.
+func readDotEnv(path string) map[string]string {
+	values := make(map[string]string)
+	f, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"'`)
+	}
+	return values
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
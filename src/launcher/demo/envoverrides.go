@@ -0,0 +1,131 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envOverridePrefix marks environment variables that set a manifest key,
+// e.g. LAUNCHER_PHP_PORT=8080 for php_port, so kiosk provisioning can tune
+// a demo without flags or a rebuild.
+const envOverridePrefix = "LAUNCHER_"
+
+// envOverrideAliases are shorter names for keys people set often; they
+// match the command-line flags.
+var envOverrideAliases = map[string]string{
+	"demo_duration": "allowed_demo_duration_minutes",
+	"landing_page":  "landing_page_url",
+	"port":          "php_port",
+}
+
+// envOverride is one LAUNCHER_ variable and the manifest key it sets.
+type envOverride struct {
+	name, key, value string
+}
+
+// envOverrides picks the LAUNCHER_ variables out of environ and checks
+// that each holds a value of its key's type. Numbers and booleans are
+// written as in JSON (booleans also as 1 or 0), lists and objects as
+// JSON. A variable that names no manifest key may be meant for something
+// else, so it is only warned about and skipped.
+func envOverrides(environ []string) (overrides []envOverride, warnings, problems []string) {
+	fields := jsonFields(reflect.TypeOf(Manifest{}))
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, envOverridePrefix) {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(name, envOverridePrefix))
+		if alias, ok := envOverrideAliases[key]; ok {
+			key = alias
+		}
+		if _, ok := fields[key]; !ok {
+			warnings = append(warnings, fmt.Sprintf("ignoring %s: %s is not a manifest key%s", name, key, suggestKey(key, fields)))
+			continue
+		}
+		var scratch Manifest
+		if err := setManifestValue(&scratch, key, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		overrides = append(overrides, envOverride{name, key, value})
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].name < overrides[j].name })
+	sort.Strings(warnings)
+	return overrides, warnings, problems
+}
+
+// setManifestValue sets the top-level manifest key to value, parsed for
+// the key's type.
+func setManifestValue(config *Manifest, key, value string) error {
+	v := reflect.ValueOf(config).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != key {
+			continue
+		}
+		if key == "apps" {
+			return fmt.Errorf("apps can't be set from the environment")
+		}
+		return setFieldValue(v.Field(i), key, value)
+	}
+	return fmt.Errorf("%s is not a manifest key%s", key, suggestKey(key, jsonFields(t)))
+}
+
+func setFieldValue(f reflect.Value, key, value string) error {
+	switch f.Kind() {
+	case reflect.Ptr:
+		// Settings such as clean_on_exit that are on unless set
+		n := reflect.New(f.Type().Elem())
+		if err := setFieldValue(n.Elem(), key, value); err != nil {
+			return err
+		}
+		f.Set(n)
+		return nil
+	case reflect.String:
+		f.SetString(value)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s takes true or false, not %q", key, value)
+		}
+		f.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s takes a whole number, not %q", key, value)
+		}
+		f.SetInt(n)
+		return nil
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("%s takes a number, not %q", key, value)
+		}
+		f.SetFloat(n)
+		return nil
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return fmt.Errorf("%s takes JSON here: %v", key, err)
+	}
+	var problems []string
+	checkJSONValue(raw, f.Type(), key, &problems)
+	if len(problems) > 0 {
+		return &manifestError{problems}
+	}
+	n := reflect.New(f.Type())
+	if err := json.Unmarshal([]byte(value), n.Interface()); err != nil {
+		return err
+	}
+	f.Set(n.Elem())
+	return nil
+}
@@ -0,0 +1,27 @@
+package demo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvOverrides(t *testing.T) {
+	overrides, warnings, problems := envOverrides([]string{
+		"PATH=/usr/bin",
+		"LAUNCHER_PORT=8080",
+		"LAUNCHER_START_MAXIMIZED=true",
+		"LAUNCHER_FOO=1",
+		"LAUNCHER_PHP_PROT=9000",
+		"LAUNCHER_IDLE_TIMEOUT_MINUTES=soon",
+	})
+	if len(overrides) != 2 || overrides[0].key != "php_port" || overrides[1].key != "start_maximized" {
+		t.Errorf("overrides = %+v, want php_port and start_maximized", overrides)
+	}
+	// Unknown names are skipped with a warning, a misspelt key suggested
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "LAUNCHER_FOO") || !strings.Contains(warnings[1], "php_port") {
+		t.Errorf("warnings = %q", warnings)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0], "LAUNCHER_IDLE_TIMEOUT_MINUTES") {
+		t.Errorf("problems = %q, want only the bad idle_timeout_minutes", problems)
+	}
+}
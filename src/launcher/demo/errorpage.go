@@ -0,0 +1,186 @@
+package demo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Launch error categories, as message IDs.
+const (
+	errorCategoryManifest  = "error_category_manifest"
+	errorCategorySetup     = "error_category_setup"
+	errorCategoryExtract   = "error_category_extract"
+	errorCategoryIntegrity = "error_category_integrity"
+	errorCategoryPort      = "error_category_port"
+	errorCategoryServer    = "error_category_server"
+)
+
+// launchError is a fatal error before the demo was up, tagged with the
+// step that failed.
+type launchError struct {
+	category string
+	err      error
+}
+
+func (e launchError) Error() string { return e.err.Error() }
+func (e launchError) Unwrap() error { return e.err }
+
+func launchFailure(category string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return launchError{category, err}
+}
+
+// canShowError reports whether a dialog or browser page can reach the
+// user: not with browser none, which --check runs and --no-browser pass,
+// not on CI, over SSH or without a display, where the console output is
+// all that's needed and a modal dialog would keep the launcher from
+// exiting.
+func canShowError(browser string) bool {
+	if browser == BrowserNone || os.Getenv("CI") != "" {
+		return false
+	}
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "" {
+		return false
+	}
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// PresentManifestError is presentError for a manifest that can't be read
+// or decoded; config is nil when there's none at all. browser is none in
+// --check runs and with --no-browser.
+func PresentManifestError(config *Manifest, browser string, err error) {
+	presentError(config, browser, launchFailure(errorCategoryManifest, err))
+}
+
+// ShowError tells the user why Run failed: on the console, unless the
+// setup banner or a message already did, and with presentError for those
+// who started the demo by double-clicking.
+func (l *Launcher) ShowError(err error) {
+	if _, ok := err.(reportedError); !ok {
+		fmt.Println(msg("error", err))
+	}
+	browser := l.Options.Browser
+	if l.Options.Check {
+		browser = BrowserNone
+	}
+	presentError(&l.Config, browser, err)
+}
+
+// presentError is the last resort for a user who started the demo by
+// double-clicking and never sees the console: it writes a diagnostics
+// file to the temp dir and shows the error in a native dialog, or where
+// there's none, such as on Linux without zenity or kdialog, on a
+// self-contained error page in the browser.
+func presentError(config *Manifest, browser string, err error) {
+	if !canShowError(browser) || errors.Is(err, context.Canceled) {
+		return
+	}
+	var reported reportedError
+	if errors.As(err, &reported) {
+		// Already on the setup banner
+		return
+	}
+	category := errorCategoryServer
+	var le launchError
+	if errors.As(err, &le) {
+		category = le.category
+	}
+
+	name := "laravel_demo"
+	if config != nil && config.AppName != "" {
+		name = strings.ReplaceAll(strings.ToLower(config.AppName), " ", "_")
+	}
+	diagPath := filepath.Join(os.TempDir(), name+"_diagnostics.txt")
+	if werr := ioutil.WriteFile(diagPath, []byte(errorDiagnostics(config, category, err)), 0644); werr != nil {
+		diagPath = ""
+	}
+
+	if showErrorDialog(errorDialogTitle(config), errorDialogText(config, category, err, diagPath)) == nil {
+		return
+	}
+	pagePath := filepath.Join(os.TempDir(), name+"_error.html")
+	if werr := ioutil.WriteFile(pagePath, []byte(errorPage(config, category, err, diagPath)), 0644); werr != nil {
+		return
+	}
+	openBrowser(browser, "file://"+filepath.ToSlash(pagePath))
+}
+
+func errorDialogTitle(config *Manifest) string {
+	if config != nil && config.AppName != "" {
+		return config.AppName
+	}
+	return "Laravel demo"
+}
+
+// errorDialogText says what failed and where to find out more: the log
+// of the run, or the diagnostics file when there's none.
+func errorDialogText(config *Manifest, category string, err error, diagPath string) string {
+	text := msg(category) + "\n\n" + err.Error()
+	details := consoleLogPath
+	if details == "" {
+		details = diagPath
+	}
+	if details != "" {
+		text += "\n\n" + msg("error_dialog_details", details)
+	}
+	if config != nil && config.SupportURL != "" {
+		text += "\n" + msg("error_dialog_support", config.SupportURL)
+	}
+	return text
+}
+
+func errorDiagnostics(config *Manifest, category string, err error) string {
+	var b strings.Builder
+	if config != nil {
+		fmt.Fprintf(&b, "App: %s\n", strings.TrimSpace(config.AppName+" "+config.AppVersion))
+	}
+	fmt.Fprintf(&b, "Time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "System: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "Step: %s\n\n%v\n", msg(category), err)
+	if l := activeLauncher.Load(); l != nil {
+		if logs := l.phpOutput(); len(logs) > 0 {
+			fmt.Fprintf(&b, "\nRecent PHP output:\n%s", phpOutputText(logs))
+		}
+	}
+	return b.String()
+}
+
+// sanitizeDetail hides the user's home directory, which usually contains
+// their name, from error text meant to be screenshotted.
+func sanitizeDetail(s string) string {
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		s = strings.ReplaceAll(s, home, "~")
+	}
+	return s
+}
+
+func errorPage(config *Manifest, category string, err error, diagPath string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html><html><head><meta charset=\"utf-8\">")
+	fmt.Fprintf(&b, "<title>%s</title>", html.EscapeString(msg("error_title")))
+	b.WriteString("<style>body{font-family:sans-serif;max-width:44em;margin:4em auto;padding:0 1em;color:#222}h1{font-size:1.6em;font-weight:normal;color:#d33}pre{background:#f4f4f4;border-radius:6px;padding:1em;white-space:pre-wrap;font-size:.85em}</style></head><body>")
+	fmt.Fprintf(&b, "<h1>%s</h1><p><strong>%s</strong></p>", html.EscapeString(msg("error_title")), html.EscapeString(msg(category)))
+	fmt.Fprintf(&b, "<pre>%s</pre>", html.EscapeString(sanitizeDetail(err.Error())))
+	if diagPath != "" {
+		fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(msg("error_diagnostics", diagPath)))
+	}
+	if config != nil && config.SupportURL != "" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">%s</a></p>", html.EscapeString(config.SupportURL), html.EscapeString(msg("error_support")))
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
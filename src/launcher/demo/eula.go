@@ -0,0 +1,194 @@
+package demo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errEULADeclined ends the run without an error once the user declined.
+var errEULADeclined = errors.New("evaluation agreement declined")
+
+// eulaRecord is stored in the user cache dir after acceptance.
+type eulaRecord struct {
+	AcceptedAt time.Time `json:"accepted_at"`
+	AppVersion string    `json:"app_version"`
+}
+
+// CheckEULA shows the manifest's eula_path and waits for the user to
+// accept it, unless they did so before or --accept-eula was given. It
+// returns errEULADeclined when they don't.
+func (l *Launcher) CheckEULA(ctx context.Context) error {
+	if l.Config.EULAPath == "" || l.Options.AcceptEULA {
+		return nil
+	}
+	recordPath := eulaRecordPath(&l.Config)
+	if rec, err := readEULARecord(recordPath); err == nil {
+		if rec.AppVersion == l.Config.AppVersion || !l.Config.EULAReacceptOnUpdate {
+			return nil
+		}
+	}
+
+	text, err := l.readEULA()
+	if err != nil {
+		return fmt.Errorf("reading eula_path: %w", err)
+	}
+
+	accepted, err := l.askEULAInBrowser(ctx, text)
+	if err != nil {
+		// No browser to show it in; ask on the console instead
+		accepted, err = askEULAOnConsole(ctx, text)
+	}
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return errEULADeclined
+	}
+
+	rec := eulaRecord{AcceptedAt: l.Clock.Now(), AppVersion: l.Config.AppVersion}
+	if err := writeEULARecord(recordPath, rec); err != nil {
+		fmt.Printf("Error recording acceptance of the agreement: %v\n", err)
+	}
+	return nil
+}
+
+// readEULA reads eula_path from the embedded bundle, or next to the
+// executable for a development build.
+func (l *Launcher) readEULA() (string, error) {
+	if hasBundle(l.Bundle) {
+		data, err := readBundleFile(l.Bundle, filepath.ToSlash(l.Config.EULAPath))
+		return string(data), err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(l.ExeDir, l.Config.EULAPath))
+	return string(data), err
+}
+
+// askEULAInBrowser serves the agreement with Accept and Decline buttons on
+// a loopback port and waits for the answer.
+func (l *Launcher) askEULAInBrowser(ctx context.Context, text string) (bool, error) {
+	host, err := listenHost(&l.Config)
+	if err != nil {
+		return false, err
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return false, err
+	}
+	answer := make(chan bool, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, eulaPage(&l.Config, text))
+	})
+	for name, accepted := range map[string]bool{"/accept": true, "/decline": false} {
+		accepted := accepted
+		mux.HandleFunc(name, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			select {
+			case answer <- accepted:
+			default:
+			}
+			reply := msg("eula_declined")
+			if accepted {
+				reply = msg("eula_accepted")
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"></head><body style=\"font-family:sans-serif;text-align:center;margin-top:5em\"><p>%s</p></body></html>", html.EscapeString(reply))
+		})
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	url := serverURL(host, ln.Addr().(*net.TCPAddr).Port)
+	if err := l.OpenURL(url); err != nil {
+		return false, err
+	}
+	fmt.Println(msg("eula_waiting", url))
+	select {
+	case accepted := <-answer:
+		return accepted, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// askEULAOnConsole prints the agreement and reads a yes or no from stdin.
+func askEULAOnConsole(ctx context.Context, text string) (bool, error) {
+	fmt.Println(text)
+	fmt.Print(msg("eula_prompt"))
+	answer := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer <- strings.ToLower(strings.TrimSpace(line))
+	}()
+	select {
+	case a := <-answer:
+		switch a {
+		case "y", "yes", "j", "ja", "o", "oui", "はい":
+			return true, nil
+		}
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+func eulaPage(config *Manifest, text string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>%s</title>", html.EscapeString(config.AppName))
+	b.WriteString("<style>body{font-family:sans-serif;max-width:48em;margin:3em auto;padding:0 1em}#terms{border:1px solid #ccc;border-radius:6px;padding:1em;max-height:60vh;overflow:auto}pre{white-space:pre-wrap;font-family:inherit}form{display:inline}button{font-size:1em;padding:.5em 1.5em;margin:1em .5em 0 0}</style></head><body>")
+	fmt.Fprintf(&b, "<h1>%s</h1><div id=\"terms\">", html.EscapeString(msg("eula_title")))
+	switch strings.ToLower(path.Ext(config.EULAPath)) {
+	case ".html", ".htm":
+		b.WriteString(text)
+	default:
+		// Plain text and Markdown are shown as written
+		fmt.Fprintf(&b, "<pre>%s</pre>", html.EscapeString(text))
+	}
+	fmt.Fprintf(&b, "</div><form method=\"post\" action=\"/accept\"><button>%s</button></form>", html.EscapeString(msg("eula_accept")))
+	fmt.Fprintf(&b, "<form method=\"post\" action=\"/decline\"><button>%s</button></form>", html.EscapeString(msg("eula_decline")))
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+// eulaRecordPath is per app, so apps of a suite are accepted separately.
+func eulaRecordPath(config *Manifest) string {
+	return filepath.Join(appCacheDir(config), "eula.json")
+}
+
+func readEULARecord(path string) (eulaRecord, error) {
+	var rec eulaRecord
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return rec, err
+	}
+	err = json.Unmarshal(data, &rec)
+	return rec, err
+}
+
+func writeEULARecord(path string, rec eulaRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
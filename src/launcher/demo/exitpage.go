@@ -0,0 +1,112 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Exit reasons, as message IDs; their text is the exit page's {{reason}}.
+const (
+	exitReasonExpired      = "exit_reason_expired"
+	exitReasonQuit         = "exit_reason_quit"
+	exitReasonIdle         = "exit_reason_idle"
+	exitReasonWindowClosed = "exit_reason_window_closed"
+)
+
+// exitPagePath is where the app window goes for the exit page; any path
+// gets it, this one is just never the app's.
+const exitPagePath = "/__launcher/exit"
+
+// defaultExitPageGrace is how long the exit page stays reachable on the old
+// address when the manifest doesn't say otherwise.
+const defaultExitPageGrace = 10 * time.Second
+
+// renderExitPage loads the manifest's exit_page from baseDir and fills in
+// its placeholders.
+func renderExitPage(config *Manifest, baseDir, reason string) (string, error) {
+	pagePath := config.ExitPage
+	if !filepath.IsAbs(pagePath) {
+		pagePath = filepath.Join(baseDir, pagePath)
+	}
+	data, err := ioutil.ReadFile(pagePath)
+	if err != nil {
+		return "", err
+	}
+
+	r := strings.NewReplacer(
+		"{{reason}}", html.EscapeString(msg(reason)),
+		"{{contact_url}}", html.EscapeString(config.ContactURL),
+		"{{app_name}}", html.EscapeString(config.AppName),
+	)
+	return r.Replace(string(data)), nil
+}
+
+// presentExitPage shows the exit page after PHP has stopped. When a browser
+// window is tracked, which sendExit told to wait for it, the page is
+// served on the old address for the grace period so the window lands on
+// it; otherwise it's written to a file and opened directly, and the
+// launcher doesn't wait at all.
+func presentExitPage(config *Manifest, browser, baseDir, bindAddr, reason string, tracked bool) {
+	if config.ExitPage == "" {
+		return
+	}
+
+	page, err := renderExitPage(config, baseDir, reason)
+	if err != nil {
+		fmt.Printf("Error rendering exit page: %v\n", err)
+		return
+	}
+
+	if tracked {
+		grace := defaultExitPageGrace
+		if config.ExitPageGraceSeconds > 0 {
+			grace = time.Duration(config.ExitPageGraceSeconds) * time.Second
+		}
+		serveExitPage(bindAddr, page, grace)
+		return
+	}
+
+	// Written outside the work dir, which is about to be removed. The name
+	// is fixed so repeated runs overwrite rather than accumulate files.
+	name := strings.ReplaceAll(strings.ToLower(config.AppName), " ", "_") + "_exit.html"
+	pagePath := filepath.Join(os.TempDir(), name)
+	if err := ioutil.WriteFile(pagePath, []byte(page), 0644); err != nil {
+		fmt.Printf("Error writing exit page: %v\n", err)
+		return
+	}
+	if err := openBrowser(browser, "file://"+filepath.ToSlash(pagePath)); err != nil && err != errBrowserDisabled {
+		fmt.Printf("Error opening exit page: %v\n", err)
+	}
+}
+
+// serveExitPage answers every request on addr with page until grace has
+// passed.
+func serveExitPage(addr, page string, grace time.Duration) {
+	srv := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("X-Demo-Exit-Page", "1")
+			fmt.Fprint(w, page)
+		}),
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Error serving exit page: %v\n", err)
+		}
+	}()
+
+	time.Sleep(grace)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+}
@@ -0,0 +1,100 @@
+package demo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// expiryTick is how often the expiry timer accounts for elapsed time.
+	expiryTick = time.Second
+	// expirySleepGap is how much longer than a tick the wall clock has to
+	// move before the gap is taken to be a suspend or hibernation.
+	expirySleepGap = 30 * time.Second
+	// expiryWarning is how long before the end the user is warned.
+	expiryWarning = 5 * time.Minute
+)
+
+// expiryTimer ends the demo after allowed_demo_duration_minutes of counted
+// time. Time is accounted tick by tick on the wall clock, so stretches
+// that shouldn't count (a pause with pause_stops_timer, a suspended laptop
+// unless expiry_counts_sleep is set) can be left out. A single sleep on
+// the monotonic clock would either count the suspend or not depending on
+// the OS.
+type expiryTimer struct {
+	clock       Clock
+	limit       time.Duration
+	countsSleep bool
+	warned      bool
+
+	mu      sync.Mutex
+	used    time.Duration
+	stopped bool // not counting, e.g. while paused
+
+	expired chan struct{} // closed when the limit is reached
+}
+
+func newExpiryTimer(clock Clock, limit time.Duration, countsSleep bool) *expiryTimer {
+	return &expiryTimer{clock: clock, limit: limit, countsSleep: countsSleep, expired: make(chan struct{})}
+}
+
+// Run accounts time until the limit is reached or stop is closed.
+func (t *expiryTimer) Run(stop <-chan struct{}) {
+	// Round(0) drops the monotonic reading, which stops during suspend on
+	// some systems; the wall clock always moves on
+	last := t.clock.Now().Round(0)
+	for {
+		select {
+		case <-t.clock.After(expiryTick):
+		case <-stop:
+			return
+		}
+		now := t.clock.Now().Round(0)
+		elapsed := now.Sub(last)
+		last = now
+		switch {
+		case elapsed < 0:
+			// The clock was set back; count the tick and nothing else
+			elapsed = expiryTick
+		case elapsed > expiryTick+expirySleepGap:
+			fmt.Printf("The system was asleep or the clock jumped for %s.\n", elapsed.Round(time.Second))
+			if !t.countsSleep {
+				elapsed = expiryTick
+			}
+		}
+
+		t.mu.Lock()
+		if !t.stopped {
+			t.used += elapsed
+		}
+		remaining := t.limit - t.used
+		t.mu.Unlock()
+
+		if remaining <= 0 {
+			close(t.expired)
+			return
+		}
+		if remaining <= expiryWarning && !t.warned && t.limit > expiryWarning {
+			t.warned = true
+			fmt.Println(msg("demo_expiring", int(remaining.Round(time.Minute).Minutes())))
+		}
+	}
+}
+
+// SetStopped stops or resumes counting time.
+func (t *expiryTimer) SetStopped(stopped bool) {
+	t.mu.Lock()
+	t.stopped = stopped
+	t.mu.Unlock()
+}
+
+// Remaining returns how much counted time is left.
+func (t *expiryTimer) Remaining() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.used >= t.limit {
+		return 0
+	}
+	return t.limit - t.used
+}
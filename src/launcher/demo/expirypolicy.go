@@ -0,0 +1,115 @@
+package demo
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// on_expiry policies: what happens when allowed_demo_duration_minutes is
+// used up.
+const (
+	expiryTerminate = "terminate" // shut down and show the exit page
+	expiryReadonly  = "readonly"  // keep running, refuse changes
+	expiryNag       = "nag"       // keep running, remind every nagInterval
+)
+
+const (
+	nagInterval = 10 * time.Minute
+	// expiredHeader tells the app the demo has expired, e.g. to show a
+	// watermark, since its environment can't change at runtime.
+	expiredHeader = "X-Demo-Expired"
+)
+
+// expiryState is how an expired demo keeps running under the readonly or
+// nag policy.
+type expiryState struct {
+	mu      sync.Mutex
+	policy  string
+	at      time.Time
+	lastNag time.Time
+}
+
+func (s *expiryState) set(policy string, at time.Time) {
+	s.mu.Lock()
+	s.policy, s.at = policy, at
+	s.mu.Unlock()
+}
+
+func (s *expiryState) get() (string, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.policy, s.at
+}
+
+// nagDue reports whether r is a page load that should get the nag page,
+// and if so counts it as shown.
+func (s *expiryState) nagDue(r *http.Request, now time.Time) bool {
+	if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastNag) < nagInterval {
+		return false
+	}
+	s.lastNag = now
+	return true
+}
+
+// expiryPolicy returns the manifest's on_expiry, terminate by default.
+func (l *Launcher) expiryPolicy() string {
+	if l.Config.OnExpiry == "" {
+		return expiryTerminate
+	}
+	return l.Config.OnExpiry
+}
+
+// degrade keeps an expired demo running under the readonly or nag policy
+// until the user quits, it goes idle or its window is closed.
+func (l *Launcher) degrade(policy string) {
+	l.proxy.expired.set(policy, l.Clock.Now())
+	if policy == expiryReadonly {
+		fmt.Println(msg("demo_readonly"))
+		return
+	}
+	fmt.Println(msg("demo_nag"))
+	goSafe("expiry reminder", func() {
+		for {
+			select {
+			case <-l.Clock.After(nagInterval):
+				fmt.Println(msg("demo_nag"))
+			case <-l.stopLoops:
+				return
+			}
+		}
+	})
+}
+
+// serveExpired applies the expiry policy to r. It returns false when it
+// answered the request itself.
+func (p *demoProxy) serveExpired(w http.ResponseWriter, r *http.Request) bool {
+	// Only the launcher sets it
+	r.Header.Del(expiredHeader)
+	policy, _ := p.expired.get()
+	if policy == "" {
+		return true
+	}
+	r.Header.Set(expiredHeader, "1")
+
+	switch {
+	case policy == expiryReadonly && r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, p.withScripts(renderNoticePage(p.config, "readonly_title", "readonly_text", "readonly_back", "/")))
+		return false
+	case policy == expiryNag && p.expired.nagDue(r, time.Now()):
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, p.withScripts(renderNoticePage(p.config, "nag_title", "nag_text", "nag_continue", r.URL.RequestURI())))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package demo
+
+import (
+	"fmt"
+)
+
+// usesExtractionCache reports whether this run reuses the code tree that
+// sessions share (extraction_cache) instead of extracting to a fresh work
+// dir. --work-dir and --check always extract.
+func (l *Launcher) usesExtractionCache() bool {
+	return l.Config.ExtractionCache && l.Options.Session == "" && l.Options.WorkDir == "" && !l.Options.Check
+}
+
+// openCachedCode uses the code tree of this build in the user cache dir as
+// the work dir, extracting it on the first run.
+func (l *Launcher) openCachedCode() error {
+	var extracted bool
+	var err error
+	l.workDir, extracted, err = sharedCodeDir(&l.Config, l.Bundle, l.otherApps)
+	if err != nil {
+		return fmt.Errorf("extracting bundle: %w", err)
+	}
+	if extracted {
+		fmt.Println(msg("extracting", l.workDir))
+	} else {
+		fmt.Println(msg("using_cached_code", l.workDir))
+	}
+	return nil
+}
+
+// prepareRunData copies the mutable data of the cached code tree to a
+// fresh temp dir, so every run starts from the bundled data as it does
+// after a full extraction.
+func (l *Launcher) prepareRunData() error {
+	dir, err := makeTempDir(workDirPrefix)
+	if err != nil {
+		return err
+	}
+	l.runDir = dir
+	if err := copyData(&l.Config, l.baseDir, l.appRoot, dir); err != nil {
+		return err
+	}
+	l.dataDir = dir
+	return nil
+}
@@ -0,0 +1,385 @@
+package demo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FastCGI 1.0 record types and the one role the launcher needs.
+const (
+	fcgiVersion      = 1
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+	fcgiResponder    = 1
+
+	fcgiMaxContent = 65535
+	fcgiRequestID  = 1 // one request per connection
+)
+
+// fcgiTransport hands requests to php-fpm over FastCGI, doing what php -S
+// or a web server in front of php-fpm would: assets below docRoot that
+// staticName allows are sent as they are, .php files run directly and
+// everything else goes to index.php, Laravel's front controller. As an
+// http.RoundTripper it slots into the proxy, the landing check and the
+// warm-up unchanged.
+type fcgiTransport struct {
+	addr    string
+	docRoot string
+	// stderr gets what PHP logs, like php -S output; the console when nil
+	stderr   io.Writer
+	stderrMu sync.Mutex
+}
+
+func (t *fcgiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	script, scriptName := filepath.Join(t.docRoot, "index.php"), "/index.php"
+	if name := strings.TrimPrefix(req.URL.Path, "/"); name != "" {
+		// Symlinks are followed, so storage:link's folder is served
+		if strings.ToLower(path.Ext(name)) == ".php" {
+			if file, err := lexicalJoin(t.docRoot, name); err == nil && plainName(name) {
+				if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+					script, scriptName = file, "/"+name
+				}
+			}
+		} else if name, ok := staticName(req.URL.Path); ok {
+			if file, err := lexicalJoin(t.docRoot, name); err == nil {
+				if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+					return fileResponse(req, file, info)
+				}
+			}
+		}
+	}
+
+	body, length, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := (&net.Dialer{Timeout: 5 * time.Second}).DialContext(req.Context(), "tcp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+	// Closing the connection is the only way to abort a FastCGI request,
+	// e.g. when the browser goes away or the proxy's timeout fires
+	stop := context.AfterFunc(req.Context(), func() { conn.Close() })
+
+	err = writeRequest(conn, t.params(req, script, scriptName, length), body)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = readResponse(req, conn, stop, t.logStderr); err == nil {
+			return resp, nil
+		}
+	}
+	stop()
+	conn.Close()
+	if req.Context().Err() != nil {
+		return nil, req.Context().Err()
+	}
+	return nil, fmt.Errorf("php-fpm: %w", err)
+}
+
+// requestBody returns the body and its length, which CGI needs up front.
+// Chunked uploads are read into memory first; the proxy has capped them at
+// max_request_body_mb.
+func requestBody(req *http.Request) (io.Reader, int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, 0, nil
+	}
+	if req.ContentLength >= 0 {
+		return req.Body, req.ContentLength, nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// params are the CGI variables PHP builds $_SERVER from.
+func (t *fcgiTransport) params(req *http.Request, script, scriptName string, length int64) map[string]string {
+	host, port, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		host, port = req.Host, "80"
+	}
+	p := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "laravel_demo",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"SERVER_NAME":       host,
+		"SERVER_PORT":       port,
+		"REMOTE_ADDR":       "127.0.0.1",
+		"REQUEST_METHOD":    req.Method,
+		"REQUEST_URI":       req.URL.RequestURI(),
+		"QUERY_STRING":      req.URL.RawQuery,
+		"DOCUMENT_ROOT":     t.docRoot,
+		"DOCUMENT_URI":      scriptName,
+		"SCRIPT_FILENAME":   script,
+		"SCRIPT_NAME":       scriptName,
+		"PHP_SELF":          scriptName,
+		"HTTP_HOST":         req.Host,
+	}
+	if length > 0 || req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch {
+		p["CONTENT_LENGTH"] = strconv.FormatInt(length, 10)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		p["CONTENT_TYPE"] = ct
+	}
+	for k, v := range req.Header {
+		switch k {
+		case "Content-Type", "Content-Length", "Proxy":
+			// The first two have their own variables; HTTP_PROXY is
+			// the httpoxy hole
+			continue
+		}
+		p["HTTP_"+strings.ToUpper(strings.ReplaceAll(k, "-", "_"))] = strings.Join(v, ", ")
+	}
+	return p
+}
+
+func writeRequest(conn net.Conn, params map[string]string, body io.Reader) error {
+	w := bufio.NewWriterSize(conn, 64<<10)
+	begin := []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0} // not keeping the connection
+	if err := writeRecord(w, fcgiBeginRequest, begin); err != nil {
+		return err
+	}
+	if err := writeStream(w, fcgiParams, encodeParams(params)); err != nil {
+		return err
+	}
+	if body != nil {
+		buf := make([]byte, fcgiMaxContent)
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				if werr := writeRecord(w, fcgiStdin, buf[:n]); werr != nil {
+					return werr
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := writeRecord(w, fcgiStdin, nil); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeStream writes data as records of at most fcgiMaxContent bytes,
+// ending the stream with an empty one.
+func writeStream(w io.Writer, recType byte, data []byte) error {
+	for len(data) > 0 {
+		n := len(data)
+		if n > fcgiMaxContent {
+			n = fcgiMaxContent
+		}
+		if err := writeRecord(w, recType, data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return writeRecord(w, recType, nil)
+}
+
+func writeRecord(w io.Writer, recType byte, content []byte) error {
+	padding := -len(content) & 7
+	header := [8]byte{fcgiVersion, recType}
+	binary.BigEndian.PutUint16(header[2:], fcgiRequestID)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(content)))
+	header[6] = byte(padding)
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	_, err := w.Write(make([]byte, padding))
+	return err
+}
+
+// encodeParams encodes name-value pairs, each length in one byte below 128
+// and in four with the top bit set otherwise.
+func encodeParams(params map[string]string) []byte {
+	var b []byte
+	size := func(n int) {
+		if n < 128 {
+			b = append(b, byte(n))
+		} else {
+			b = binary.BigEndian.AppendUint32(b, uint32(n)|1<<31)
+		}
+	}
+	for _, k := range sortedKeys(params) {
+		size(len(k))
+		size(len(params[k]))
+		b = append(b, k...)
+		b = append(b, params[k]...)
+	}
+	return b
+}
+
+// logStderr passes on a message PHP wrote to stderr. Requests run at
+// once, so messages are written whole and one at a time.
+func (t *fcgiTransport) logStderr(msg []byte) {
+	if t.stderr == nil {
+		os.Stdout.Write(msg)
+		return
+	}
+	if !bytes.HasSuffix(msg, []byte("\n")) {
+		msg = append(msg, '\n')
+	}
+	t.stderrMu.Lock()
+	defer t.stderrMu.Unlock()
+	t.stderr.Write(msg)
+}
+
+// readResponse parses the CGI headers PHP writes to stdout and leaves the
+// rest of the stream as the body, passing stderr to logStderr.
+func readResponse(req *http.Request, conn net.Conn, stop func() bool, logStderr func([]byte)) (*http.Response, error) {
+	br := bufio.NewReader(&fcgiReader{r: bufio.NewReader(conn), stderr: logStderr})
+	header, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("reading the response headers: %w", err)
+	}
+	status := http.StatusOK
+	if s := header.Get("Status"); s != "" {
+		code, err := strconv.Atoi(strings.Fields(s)[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad Status header %q", s)
+		}
+		status = code
+		header.Del("Status")
+	} else if header.Get("Location") != "" {
+		status = http.StatusFound
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header(header),
+		ContentLength: -1,
+		Body:          &fcgiBody{Reader: br, conn: conn, stop: stop},
+		Request:       req,
+	}
+	if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = n
+	}
+	return resp, nil
+}
+
+// fcgiReader reads the stdout stream of a FastCGI response. What PHP logs
+// to stderr goes to stderr, and the end of the request is io.EOF.
+type fcgiReader struct {
+	r       *bufio.Reader
+	stderr  func([]byte)
+	left    int // stdout bytes left in the current record
+	padding int
+	done    bool
+}
+
+func (s *fcgiReader) Read(p []byte) (int, error) {
+	for s.left == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if _, err := s.r.Discard(s.padding); err != nil {
+			return 0, err
+		}
+		var header [8]byte
+		if _, err := io.ReadFull(s.r, header[:]); err != nil {
+			return 0, err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		s.padding = int(header[6])
+		switch header[1] {
+		case fcgiStdout:
+			s.left = length
+		case fcgiStderr:
+			msg := make([]byte, length)
+			if _, err := io.ReadFull(s.r, msg); err != nil {
+				return 0, err
+			}
+			s.stderr(msg)
+		case fcgiEndRequest:
+			s.done = true
+			fallthrough
+		default:
+			if _, err := s.r.Discard(length); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if len(p) > s.left {
+		p = p[:s.left]
+	}
+	n, err := s.r.Read(p)
+	s.left -= n
+	return n, err
+}
+
+// fcgiBody closes the connection with the response body.
+type fcgiBody struct {
+	io.Reader
+	conn net.Conn
+	stop func() bool
+}
+
+func (b *fcgiBody) Close() error {
+	b.stop()
+	return b.conn.Close()
+}
+
+// fileResponse answers with a file from docRoot, as php -S does for
+// anything that isn't a script.
+func fileResponse(req *http.Request, file string, info os.FileInfo) (*http.Response, error) {
+	header := make(http.Header)
+	contentType := mime.TypeByExtension(filepath.Ext(file))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+
+	var body io.ReadCloser = http.NoBody
+	if req.Method != http.MethodHead {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		body = f
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		ContentLength: info.Size(),
+		Body:          body,
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,133 @@
+package demo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFPM answers every FastCGI request with the script it was asked to
+// run, logging a line to stderr first.
+func fakeFPM(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeFPM(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFakeFPM(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var params []byte
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		content := make([]byte, int(binary.BigEndian.Uint16(header[4:]))+int(header[6]))
+		if _, err := io.ReadFull(r, content); err != nil {
+			return
+		}
+		content = content[:binary.BigEndian.Uint16(header[4:])]
+		if header[1] == fcgiParams {
+			params = append(params, content...)
+		}
+		if header[1] == fcgiStdin && len(content) == 0 {
+			break
+		}
+	}
+	script := decodeParams(params)["SCRIPT_NAME"]
+	w := bufio.NewWriter(conn)
+	writeRecord(w, fcgiStderr, []byte("PHP Notice: ran "+script))
+	writeStream(w, fcgiStdout, []byte("Content-Type: text/plain\r\n\r\nran "+script))
+	writeRecord(w, fcgiEndRequest, make([]byte, 8))
+	w.Flush()
+}
+
+func decodeParams(b []byte) map[string]string {
+	size := func() int {
+		if b[0] < 128 {
+			n := int(b[0])
+			b = b[1:]
+			return n
+		}
+		n := int(binary.BigEndian.Uint32(b) &^ (1 << 31))
+		b = b[4:]
+		return n
+	}
+	params := make(map[string]string)
+	for len(b) > 0 {
+		kn, vn := size(), size()
+		params[string(b[:kn])] = string(b[kn : kn+vn])
+		b = b[kn+vn:]
+	}
+	return params
+}
+
+func TestFastCGIServesOnlyAssets(t *testing.T) {
+	docRoot := t.TempDir()
+	for name, body := range map[string]string{
+		"index.php":            "<?php // the front controller",
+		"info.php":             "<?php phpinfo();",
+		"css/app.css":          "body{}",
+		".env":                 "APP_KEY=secret",
+		"storage/logs/app.log": "a stack trace",
+		"notes.md":             "internal notes",
+	} {
+		file := filepath.Join(docRoot, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := newOutputRing(outputMaxLines, outputMaxBytes)
+	client := &http.Client{Transport: &fcgiTransport{addr: fakeFPM(t), docRoot: docRoot, stderr: output}}
+
+	for path, want := range map[string]string{
+		"/css/app.css":          "body{}",
+		"/info.php":             "ran /info.php",
+		"/":                     "ran /index.php",
+		"/dashboard":            "ran /index.php",
+		"/.env":                 "ran /index.php",
+		"/storage/logs/app.log": "ran /index.php",
+		"/notes.md":             "ran /index.php",
+		"/index.php.":           "ran /index.php",
+		"/index.php%20":         "ran /index.php",
+		"/index.php::$DATA":     "ran /index.php",
+		"/.hidden.php":          "ran /index.php",
+	} {
+		resp, err := client.Get("http://127.0.0.1" + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Errorf("GET %s = %q, want %q", path, body, want)
+		}
+	}
+
+	lines := output.Lines()
+	if len(lines) == 0 || !strings.Contains(strings.Join(lines, "\n"), "PHP Notice: ran /info.php") {
+		t.Errorf("PHP's stderr didn't reach the output ring: %q", lines)
+	}
+}
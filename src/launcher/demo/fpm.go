@@ -0,0 +1,117 @@
+package demo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// server_mode values.
+const (
+	serverModeBuiltin = "builtin" // php -S, the default
+	serverModeFPM     = "fpm"
+
+	defaultFPMWorkers = 4
+)
+
+// fpmBinary looks for php-fpm next to the PHP binary or in the sbin folder
+// of PHP's usual install layout, and on PATH for the system php. It
+// returns "" when there's none; php-fpm doesn't exist for Windows.
+func fpmBinary(phpBin string) string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	if phpBin == "php" {
+		p, _ := exec.LookPath("php-fpm")
+		return p
+	}
+	dir := filepath.Dir(phpBin)
+	for _, p := range []string{
+		filepath.Join(dir, "php-fpm"),
+		filepath.Join(dir, "sbin", "php-fpm"),
+		filepath.Join(dir, "..", "sbin", "php-fpm"),
+	} {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// fpmConfigPath is where the generated configuration goes. It's outside
+// the work dir so --serve-dir checkouts stay untouched.
+func fpmConfigPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("laravel_demo_fpm_%d.conf", os.Getpid()))
+}
+
+// fpmConfig is a php-fpm configuration with one pool of workers on addr.
+// It stays in the foreground, logs to stderr like php -S and passes the
+// launcher's environment on to PHP.
+func fpmConfig(addr string, workers int) string {
+	return fmt.Sprintf(`[global]
+error_log = /dev/stderr
+daemonize = no
+
+[demo]
+listen = %s
+pm = static
+pm.max_children = %d
+clear_env = no
+catch_workers_output = yes
+decorate_workers_output = no
+`, addr, workers)
+}
+
+// useFPM turns server into php-fpm on the same address and sends the
+// requests to PHP over FastCGI.
+func (l *Launcher) useFPM(server *phpServer, fpm, phpBin string) error {
+	conf := fpmConfigPath()
+	workers := orDefault(l.Config.FPMWorkers, defaultFPMWorkers)
+	if err := ioutil.WriteFile(conf, []byte(fpmConfig(server.addr, workers)), 0644); err != nil {
+		return fmt.Errorf("writing the php-fpm configuration: %w", err)
+	}
+	l.fpmConf = conf
+
+	// -R: the pool has no user to switch to when the demo runs as root
+	args := []string{"--nodaemonize", "--force-stderr", "--allow-to-run-as-root", "--fpm-config", conf}
+	if phpBin != "php" {
+		// Embedded files lose their mode bits
+		os.Chmod(fpm, 0755)
+		ini := filepath.Join(filepath.Dir(phpBin), "php.ini")
+		if _, err := os.Stat(ini); err == nil && l.phpIni == "" {
+			args = append(args, "--php-ini", ini)
+		}
+	}
+	if l.phpIni != "" {
+		args = append(args, "--php-ini", l.phpIni)
+	}
+	server.bin = fpm
+	server.args = args
+	// Worker errors come over FastCGI rather than php-fpm's own output
+	l.phpTransport = &fcgiTransport{addr: server.addr, docRoot: l.publicDir, stderr: server.outputWriter()}
+	return nil
+}
+
+// phpClient talks to PHP directly: over HTTP to php -S, over FastCGI to
+// php-fpm.
+func (l *Launcher) phpClient() *http.Client {
+	if l.phpTransport == nil {
+		return loopbackClient
+	}
+	c := *loopbackClient
+	c.Transport = l.phpTransport
+	return &c
+}
+
+// serverMode is the server_mode in use, which is builtin when fpm was
+// asked for but not found.
+func (l *Launcher) serverMode() string {
+	if l.phpTransport != nil {
+		return serverModeFPM
+	}
+	return serverModeBuiltin
+}
@@ -0,0 +1,133 @@
+package demo
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Backoff of the hiccup page's automatic reload.
+const (
+	hiccupFirstRetry = 2 * time.Second
+	hiccupMaxRetry   = 30 * time.Second
+)
+
+// hiccupDelay is how long the hiccup page waits before reloading after
+// that many upstream failures in a row: doubling each time, up to
+// hiccupMaxRetry.
+func hiccupDelay(failures int64) time.Duration {
+	d := hiccupFirstRetry
+	for i := int64(1); i < failures && d < hiccupMaxRetry; i++ {
+		d *= 2
+	}
+	if d > hiccupMaxRetry {
+		d = hiccupMaxRetry
+	}
+	return d
+}
+
+// serveHiccup answers a request PHP never answered (refused, reset or
+// timed out) with a branded page that reloads itself, instead of the
+// browser's own error page. Responses PHP did send, 5xx included, never
+// get here. Requests that aren't page loads just get the status and text.
+func (p *demoProxy) serveHiccup(w http.ResponseWriter, r *http.Request, status int, text string) {
+	p.hiccups.Add(1)
+	failures := p.failuresInRow.Add(1)
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, text, status)
+		return
+	}
+
+	var page string
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		delay := int(hiccupDelay(failures).Seconds())
+		page = renderHiccupPage(p.config, msg("hiccup_text", delay), msg("hiccup_retry"), r.URL.RequestURI())
+		page = strings.Replace(page, "<head>", fmt.Sprintf("<head>\n<meta http-equiv=\"refresh\" content=\"%d\">", delay), 1)
+	} else {
+		// Reloading would turn the form post into a GET
+		page = renderHiccupPage(p.config, msg("hiccup_resubmit"), msg("hiccup_home"), "/")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	fmt.Fprint(w, p.withScripts(page))
+}
+
+// servePHPFailed replaces the hiccup page once PHP has crashed too often
+// to be restarted: reloading won't bring it back, restarting the demo may.
+func (p *demoProxy) servePHPFailed(w http.ResponseWriter, r *http.Request) {
+	p.hiccups.Add(1)
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, msg("php_failed_text"), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprint(w, p.withScripts(renderHiccupPage(p.config, msg("php_failed_text"), msg("hiccup_home"), "/")))
+}
+
+// busyRetry is how soon the page shown while PHP is stopped for a data
+// reset or export reloads itself.
+const busyRetry = 2 * time.Second
+
+// SetBusy makes requests for PHP get a page saying what's happening, e.g.
+// "resetting", while a data reset or export has PHP stopped, instead of the
+// hiccup page; "" ends it. Those requests count neither as server errors
+// nor toward the hiccup page's backoff.
+func (p *demoProxy) SetBusy(kind string) {
+	if kind == "" {
+		p.busy.Store(nil)
+		return
+	}
+	p.busy.Store(&kind)
+}
+
+// serveBusy answers r with the busy page while SetBusy is on and reports
+// whether it did.
+func (p *demoProxy) serveBusy(w http.ResponseWriter, r *http.Request) bool {
+	kind := p.busy.Load()
+	if kind == nil {
+		return false
+	}
+	if rec, ok := w.(*statusRecorder); ok {
+		rec.uncounted = true
+	}
+	title, text := msg("busy_"+*kind+"_title"), msg("busy_"+*kind+"_text")
+	w.Header().Set("Retry-After", fmt.Sprint(int(busyRetry.Seconds())))
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, title, http.StatusServiceUnavailable)
+		return true
+	}
+
+	var page string
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		page = renderNoticeText(p.config, title, text, msg("hiccup_retry"), r.URL.RequestURI())
+		page = strings.Replace(page, "<head>", fmt.Sprintf("<head>\n<meta http-equiv=\"refresh\" content=\"%d\">", int(busyRetry.Seconds())), 1)
+	} else {
+		page = renderNoticeText(p.config, title, msg("hiccup_resubmit"), msg("hiccup_home"), "/")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprint(w, p.withScripts(page))
+	return true
+}
+
+func renderHiccupPage(config *Manifest, text, link, href string) string {
+	return renderNoticeText(config, msg("hiccup_title"), text, link, href)
+}
+
+// renderNoticeText is renderNoticePage with the texts themselves.
+func renderNoticeText(config *Manifest, title, text, link, href string) string {
+	r := strings.NewReplacer(
+		"{{app_name}}", html.EscapeString(config.AppName),
+		"{{title}}", html.EscapeString(title),
+		"{{text}}", html.EscapeString(text),
+		"{{link}}", html.EscapeString(link),
+		"{{href}}", html.EscapeString(href),
+	)
+	return r.Replace(noticePage)
+}
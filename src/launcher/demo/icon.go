@@ -0,0 +1,65 @@
+package demo
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+)
+
+// faviconPath is where the setup banner and the proxy answer with
+// icon_path. Browsers show it on the tab, and Chrome and Edge app windows
+// also use it for the window and its taskbar or dock entry.
+const faviconPath = "/favicon.ico"
+
+// appIcon is icon_path, read once at startup.
+type appIcon struct {
+	data        []byte
+	contentType string
+}
+
+// loadAppIcon reads icon_path, if any. Like public_root it's relative to
+// the bundle root, also for the apps of a suite. It returns nil when there's none,
+// it can't be read or it's empty.
+func (l *Launcher) loadAppIcon() *appIcon {
+	if l.Config.IconPath == "" {
+		return nil
+	}
+	data, err := l.readAppFile(l.Config.IconPath)
+	if err != nil {
+		fmt.Printf("Warning: icon_path %s can't be shown: %v\n", l.Config.IconPath, err)
+		return nil
+	}
+	if len(data) == 0 {
+		// Laravel's default public/favicon.ico
+		return nil
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(l.Config.IconPath))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return &appIcon{data, contentType}
+}
+
+// serve answers faviconPath with the icon and reports whether it did. The
+// app's own favicon, typically Laravel's empty default, is never asked.
+func (icon *appIcon) serve(w http.ResponseWriter, r *http.Request) bool {
+	if icon == nil || r.URL.Path != faviconPath {
+		return false
+	}
+	w.Header().Set("Content-Type", icon.contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(icon.data)
+	return true
+}
+
+// applyConsoleIcon gives the console window the product icon where the
+// platform allows it. It runs once the icon is extracted to disk.
+func (l *Launcher) applyConsoleIcon() {
+	if l.Config.IconPath == "" {
+		return
+	}
+	if err := setConsoleIcon(l.bundlePath(l.Config.IconPath)); err != nil {
+		fmt.Printf("Warning: icon_path %s can't be used for the console window: %v\n", l.Config.IconPath, err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package demo
+
+// setConsoleIcon is a no-op outside Windows: a terminal's icon belongs to
+// the terminal app, and the browser window gets the favicon.
+func setConsoleIcon(file string) error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package demo
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32               = syscall.NewLazyDLL("user32.dll")
+	procLoadImageW       = user32.NewProc("LoadImageW")
+	procSendMessageW     = user32.NewProc("SendMessageW")
+	procGetSystemMetrics = user32.NewProc("GetSystemMetrics")
+	procGetConsoleWindow = kernel32.NewProc("GetConsoleWindow")
+)
+
+const (
+	imageIcon      = 1
+	lrLoadFromFile = 0x10
+	wmSetIcon      = 0x80
+	iconSmall      = 0
+	iconBig        = 1
+	smCXIcon       = 11
+	smCXSmIcon     = 49
+)
+
+// setConsoleIcon shows the .ico file on the console window's title bar and
+// taskbar button, in place of the generic console icon. Windows Terminal
+// keeps its own icon; a launcher started without a console has nothing to
+// change.
+func setConsoleIcon(file string) error {
+	if !strings.EqualFold(filepath.Ext(file), ".ico") {
+		return errors.New("Windows needs an .ico file")
+	}
+	hwnd, _, _ := procGetConsoleWindow.Call()
+	if hwnd == 0 {
+		return nil
+	}
+	name, err := syscall.UTF16PtrFromString(file)
+	if err != nil {
+		return err
+	}
+	for _, icon := range []struct{ which, metric uintptr }{{iconSmall, smCXSmIcon}, {iconBig, smCXIcon}} {
+		size, _, _ := procGetSystemMetrics.Call(icon.metric)
+		h, _, err := procLoadImageW.Call(0, uintptr(unsafe.Pointer(name)), imageIcon, size, size, lrLoadFromFile)
+		if h == 0 {
+			return err
+		}
+		procSendMessageW.Call(hwnd, wmSetIcon, icon.which, h)
+	}
+	return nil
+}
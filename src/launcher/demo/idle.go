@@ -0,0 +1,108 @@
+package demo
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// idle_action values.
+const (
+	idleActionShutdown = "shutdown"
+	idleActionReset    = "reset"
+)
+
+// idleWatch notices when nobody has used the demo for idle_timeout_minutes,
+// i.e. no request went through the proxy, so a kiosk or trade-show demo
+// walked away from doesn't run forever.
+type idleWatch struct {
+	clock   Clock
+	timeout time.Duration
+	last    atomic.Int64 // UnixNano of the last request
+}
+
+func newIdleWatch(clock Clock, timeout time.Duration) *idleWatch {
+	w := &idleWatch{clock: clock, timeout: timeout}
+	w.Touch()
+	return w
+}
+
+// Touch records a request. A nil watch ignores it.
+func (w *idleWatch) Touch() {
+	if w != nil {
+		w.last.Store(w.clock.Now().UnixNano())
+	}
+}
+
+// Run calls onIdle once the timeout passes without a request, and again
+// only after someone has used the demo in between, until stop is closed.
+func (w *idleWatch) Run(stop <-chan struct{}, onIdle func()) {
+	var handled int64 // the last request before the idle stretch handled
+	for {
+		last := w.last.Load()
+		wait := w.timeout - w.clock.Now().Sub(time.Unix(0, last))
+		switch {
+		case last == handled:
+			wait = idlePoll // until someone comes back
+		case wait <= 0:
+			onIdle()
+			handled = last
+			continue
+		}
+		select {
+		case <-w.clock.After(wait):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// idlePoll is how often an idle demo checks for someone coming back.
+const idlePoll = time.Minute
+
+// onIdle ends the demo, or with idle_action "reset" puts its data back and
+// sends the next visitor to the landing page. A paused demo is idle on
+// purpose and left alone.
+func (l *Launcher) onIdle() {
+	l.pauseMu.Lock()
+	paused := l.paused
+	l.pauseMu.Unlock()
+	if paused {
+		return
+	}
+
+	minutes := l.Config.IdleTimeoutMinutes
+	if l.Config.IdleAction != idleActionReset {
+		fmt.Println(msg("idle_shutdown", minutes))
+		l.idleOnce.Do(func() { close(l.idle) })
+		return
+	}
+	fmt.Println(msg("idle_reset", minutes))
+	if l.resetter == nil {
+		return // its snapshot failed, which was reported at startup
+	}
+	if err := l.resetter.Reset(); err != nil {
+		fmt.Printf("Error resetting demo data: %v\n", err)
+	}
+	l.proxy.sendHome.Store(true)
+}
+
+// serveSendHome redirects the first page load after an idle reset to the
+// landing page, so the next visitor starts where the demo begins instead
+// of deep inside the last one's session.
+func (p *demoProxy) serveSendHome(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	if !p.sendHome.CompareAndSwap(true, false) {
+		return false
+	}
+	if r.URL.RequestURI() == p.config.LandingPageURL {
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, p.config.LandingPageURL, http.StatusFound)
+	return true
+}
@@ -0,0 +1,183 @@
+package demo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// initManifest is what "init" writes: the keys a first demo needs, in the
+// order of the sample manifest. Everything else keeps its default until the
+// vendor adds it.
+type initManifest struct {
+	AppName                    string            `json:"app_name"`
+	AppVersion                 string            `json:"app_version"`
+	WindowWidth                int               `json:"window_width"`
+	WindowHeight               int               `json:"window_height"`
+	StartMaximized             bool              `json:"start_maximized"`
+	ListenAddress              string            `json:"listen_address"`
+	DBType                     string            `json:"db_type"`
+	DBPath                     string            `json:"db_path"`
+	EnvVars                    map[string]string `json:"env_vars"`
+	DemoModeEnvKey             string            `json:"demo_mode_env_key"`
+	DemoModeEnvValue           string            `json:"demo_mode_env_value"`
+	LandingPageURL             string            `json:"landing_page_url"`
+	PHPBinaryPath              string            `json:"php_binary_path"`
+	AllowSystemPHP             bool              `json:"allow_system_php"`
+	PublicRoot                 string            `json:"public_root"`
+	CleanOnExit                bool              `json:"clean_on_exit"`
+	AllowedDemoDurationMinutes int               `json:"allowed_demo_duration_minutes"`
+}
+
+// prompter asks questions on the console. At the end of input every
+// question takes its default, so init also runs from a script.
+type prompter struct {
+	in  *bufio.Reader
+	eof bool
+}
+
+// ask prints question with its default and returns the answer, asking
+// again while check rejects it.
+func (p *prompter) ask(question, def string, check func(string) error) string {
+	for {
+		fmt.Printf("%s [%s]: ", question, def)
+		answer := def
+		if !p.eof {
+			line, err := p.in.ReadString('\n')
+			p.eof = err == io.EOF
+			if line = strings.TrimSpace(line); line != "" {
+				answer = line
+			}
+		}
+		if p.eof {
+			// Keep the transcript readable without typed newlines
+			fmt.Println()
+		}
+		err := check(answer)
+		if err == nil {
+			return answer
+		}
+		fmt.Printf("  %v\n", err)
+		if p.eof {
+			// Nobody left to answer; the default always passes
+			return def
+		}
+	}
+}
+
+func anyAnswer(string) error { return nil }
+
+// RunInit implements "init": a short questionnaire that writes a manifest
+// the launcher and pack accept, instead of copying one by hand.
+func RunInit(args []string) int {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	out := flags.String("out", "manifest.json", "Manifest to write")
+	force := flags.Bool("force", false, "Overwrite an existing manifest")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if _, err := os.Stat(*out); err == nil && !*force {
+		fmt.Printf("Error: %s already exists; pass --force to replace it\n", *out)
+		return 1
+	}
+
+	fmt.Println("Answer each question or press Enter for the default in brackets.")
+	m := askManifest(&prompter{in: bufio.NewReader(os.Stdin)})
+	if err := writeInitManifest(*out, m); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s. Build the demo with:\n", *out)
+	fmt.Printf("  launcher pack --app <laravel dir> --php-dir <php dir> --manifest %s --out build/demo\n", *out)
+	return 0
+}
+
+func askManifest(p *prompter) *initManifest {
+	m := &initManifest{
+		AppVersion:       "1.0.0",
+		ListenAddress:    "127.0.0.1",
+		EnvVars:          map[string]string{"APP_ENV": "local", "APP_DEBUG": "true"},
+		DemoModeEnvKey:   "IS_DEMO_MODE",
+		DemoModeEnvValue: "true",
+		PublicRoot:       "resources/app/public",
+		CleanOnExit:      true,
+	}
+
+	m.AppName = p.ask("App name", "Laravel Demo", anyAnswer)
+
+	size := p.ask("Window size (WIDTHxHEIGHT, or maximized)", "1024x768", func(s string) error {
+		_, _, err := parseWindowSize(s)
+		return err
+	})
+	if strings.EqualFold(size, "maximized") {
+		m.StartMaximized = true
+	} else {
+		m.WindowWidth, m.WindowHeight, _ = parseWindowSize(size)
+	}
+
+	m.PHPBinaryPath = p.ask("PHP binary inside the bundle (php/php.exe for Windows)", "php/php.exe", func(s string) error {
+		if strings.HasPrefix(s, "/") || strings.Contains(s, ":") {
+			return errors.New("give the path relative to the bundle, where --php-dir is copied")
+		}
+		return nil
+	})
+
+	db := p.ask("Database (sqlite or none)", "sqlite", func(s string) error {
+		if s != "sqlite" && s != "none" {
+			return errors.New("answer sqlite, or none if the app needs no database of its own")
+		}
+		return nil
+	})
+	if db == "sqlite" {
+		m.DBType = "sqlite"
+		m.DBPath = p.ask("SQLite file, relative to the app", "database/database.sqlite", anyAnswer)
+	}
+
+	minutes := p.ask("Demo duration in minutes (0 for no limit)", "60", func(s string) error {
+		if n, err := strconv.Atoi(s); err != nil || n < 0 {
+			return errors.New("answer a number of minutes, 0 or more")
+		}
+		return nil
+	})
+	m.AllowedDemoDurationMinutes, _ = strconv.Atoi(minutes)
+
+	m.LandingPageURL = p.ask("Landing page", "/", func(s string) error {
+		if !strings.HasPrefix(s, "/") {
+			return errors.New("the landing page is a path on the demo, starting with /")
+		}
+		return nil
+	})
+	return m
+}
+
+func parseWindowSize(s string) (int, int, error) {
+	if strings.EqualFold(s, "maximized") {
+		return 0, 0, nil
+	}
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	width, werr := strconv.Atoi(strings.TrimSpace(w))
+	height, herr := strconv.Atoi(strings.TrimSpace(h))
+	if !ok || werr != nil || herr != nil || width <= 0 || height <= 0 {
+		return 0, 0, errors.New("answer a size like 1280x800, or maximized")
+	}
+	return width, height, nil
+}
+
+// writeInitManifest writes m after running it through the checks pack
+// applies, so the file works as written.
+func writeInitManifest(file string, m *initManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := decodeManifest(data); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
@@ -0,0 +1,111 @@
+package demo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errAlreadyRunning means another launcher holds the app's instance lock.
+var errAlreadyRunning = errors.New("the demo is already running")
+
+// instanceWait is how long a second launch waits for a first one that is
+// still starting to publish its URL.
+const instanceWait = 15 * time.Second
+
+// instanceInfo is instance.json in the app's cache dir, written by the
+// launcher holding the lock so a second launch knows where to send the
+// user.
+type instanceInfo struct {
+	PID int    `json:"pid"`
+	URL string `json:"url"`
+}
+
+// appInstance is this launcher's hold on the app's single-instance lock:
+// a named mutex on Windows, an flock on the lock file elsewhere.
+type appInstance struct {
+	infoPath string
+	release  func()
+}
+
+func instanceInfoPath(config *Manifest) string {
+	return filepath.Join(appCacheDir(config), "instance.json")
+}
+
+// lockInstance takes the app's single-instance lock, or returns
+// errAlreadyRunning.
+func lockInstance(config *Manifest) (*appInstance, error) {
+	dir := appCacheDir(config)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	release, err := lockInstanceFile(filepath.Join(dir, "instance.lock"), filepath.Base(dir))
+	if err != nil {
+		return nil, err
+	}
+	return &appInstance{infoPath: instanceInfoPath(config), release: release}, nil
+}
+
+// publish records the URL a second launch should open.
+func (a *appInstance) publish(url string) {
+	data, _ := json.Marshal(instanceInfo{PID: os.Getpid(), URL: url})
+	ioutil.WriteFile(a.infoPath, data, 0644)
+}
+
+// Close releases the lock.
+func (a *appInstance) Close() {
+	os.Remove(a.infoPath)
+	a.release()
+}
+
+// runningInstanceURL returns the URL the running launcher published,
+// waiting for it while that launcher starts.
+func runningInstanceURL(config *Manifest) string {
+	deadline := time.Now().Add(instanceWait)
+	for {
+		var info instanceInfo
+		if data, err := ioutil.ReadFile(instanceInfoPath(config)); err == nil {
+			json.Unmarshal(data, &info)
+		}
+		if info.URL != "" && processRunning(info.PID) {
+			return info.URL
+		}
+		if time.Now().After(deadline) {
+			return ""
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// usesInstanceLock reports whether this run is the app's single instance.
+// Sessions have locks of their own and are meant to run side by side;
+// --check and --serve-dir runs don't get in the way of a demo.
+func (l *Launcher) usesInstanceLock() bool {
+	return l.Options.Session == "" && !l.Options.Check && l.Options.ServeDir == ""
+}
+
+// publishInstance records url for a second launch, if this run holds the
+// lock.
+func (l *Launcher) publishInstance(url string) {
+	if l.instance != nil {
+		l.instance.publish(url)
+	}
+}
+
+// focusRunningInstance sends the user to the demo another launcher is
+// running instead of starting a second PHP server and browser window.
+func (l *Launcher) focusRunningInstance() error {
+	url := runningInstanceURL(&l.Config)
+	if url == "" {
+		return fmt.Errorf("%w; close it first", errAlreadyRunning)
+	}
+	fmt.Println(msg("already_running", url))
+	if err := l.OpenURL(url); err != nil {
+		printBrowserFallback(url, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+package demo
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockInstanceFile takes an exclusive flock on path. The kernel drops it
+// when the process dies, so a killed launcher never blocks the next one.
+func lockInstanceFile(path, name string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errAlreadyRunning
+		}
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
+
+// instanceFileLocked reports whether a process holds the flock on path.
+func instanceFileLocked(path, name string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == nil {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}
+	return errors.Is(err, syscall.EWOULDBLOCK)
+}
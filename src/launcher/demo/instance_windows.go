@@ -0,0 +1,53 @@
+package demo
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procCreateMutexW = kernel32.NewProc("CreateMutexW")
+	procOpenMutexW   = kernel32.NewProc("OpenMutexW")
+)
+
+const (
+	errorAlreadyExists syscall.Errno = 183
+	synchronize                      = 0x00100000
+)
+
+// lockInstanceFile creates the named mutex Local\laravel_demo_<name>,
+// which Windows closes when the process dies. The lock file isn't needed.
+func lockInstanceFile(path, name string) (func(), error) {
+	mutexName, err := instanceMutexName(name)
+	if err != nil {
+		return nil, err
+	}
+	h, _, callErr := procCreateMutexW.Call(0, 0, uintptr(unsafe.Pointer(mutexName)))
+	if h == 0 {
+		return nil, callErr
+	}
+	if callErr == errorAlreadyExists {
+		syscall.CloseHandle(syscall.Handle(h))
+		return nil, errAlreadyRunning
+	}
+	return func() { syscall.CloseHandle(syscall.Handle(h)) }, nil
+}
+
+// instanceFileLocked reports whether a process holds the named mutex.
+func instanceFileLocked(path, name string) bool {
+	mutexName, err := instanceMutexName(name)
+	if err != nil {
+		return false
+	}
+	h, _, _ := procOpenMutexW.Call(synchronize, 0, uintptr(unsafe.Pointer(mutexName)))
+	if h == 0 {
+		return false
+	}
+	syscall.CloseHandle(syscall.Handle(h))
+	return true
+}
+
+func instanceMutexName(name string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(`Local\laravel_demo_` + strings.ReplaceAll(name, `\`, "_"))
+}
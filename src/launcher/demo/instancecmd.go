@@ -0,0 +1,213 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// instanceStopWait is how long "stop" waits for the demo to exit, which
+// includes stopping PHP and the side processes with their grace periods.
+const instanceStopWait = time.Minute
+
+// logTailLines is how much of launcher.log "logs" shows before following.
+const logTailLines = 50
+
+// instanceStateDir is where the running demo or --session publishes
+// control.json and launcher.log, see Launcher.stateDir.
+func instanceStateDir(config *Manifest, session string) string {
+	if session != "" {
+		return filepath.Join(appCacheDir(config), "sessions", session)
+	}
+	return appCacheDir(config)
+}
+
+// RunInstanceCommand handles "status", "stop" and "logs", which talk to
+// the running demo, or to a --session, through what it publishes.
+func RunInstanceCommand(config *Manifest, session, command string) int {
+	if session != "" && !sessionNamePattern.MatchString(session) {
+		fmt.Printf("Error: invalid session name %q\n", session)
+		return 2
+	}
+	dir := instanceStateDir(config, session)
+	if command == "logs" {
+		return followLog(dir)
+	}
+	info, ok := runningControl(dir)
+	if !ok {
+		fmt.Println("The demo isn't running.")
+		return 1
+	}
+	var err error
+	if command == "status" {
+		err = printInstanceStatus(info)
+	} else {
+		err = stopInstance(info)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runningControl reads control.json, if the launcher that wrote it is
+// still running.
+func runningControl(dir string) (controlInfo, bool) {
+	var info controlInfo
+	data, err := ioutil.ReadFile(filepath.Join(dir, controlInfoFile))
+	if err != nil || json.Unmarshal(data, &info) != nil {
+		return info, false
+	}
+	return info, info.URL != "" && processRunning(info.PID)
+}
+
+func printInstanceStatus(info controlInfo) error {
+	resp, err := loopbackClient.Get(info.URL + "/status")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var status struct {
+		URL       string `json:"url"`
+		Port      int    `json:"port"`
+		Uptime    int    `json:"uptime_seconds"`
+		Remaining *int   `json:"remaining_seconds"`
+		Paused    bool   `json:"paused"`
+		Session   string `json:"session"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("reading the status: %w", err)
+	}
+
+	state := "running"
+	if status.Paused {
+		state = "paused"
+	}
+	fmt.Printf("The demo is %s (pid %d).\n", state, info.PID)
+	if status.Session != "" {
+		fmt.Printf("  Session:        %s\n", status.Session)
+	}
+	fmt.Printf("  URL:            %s\n", status.URL)
+	fmt.Printf("  Port:           %d\n", status.Port)
+	fmt.Printf("  Uptime:         %s\n", time.Duration(status.Uptime)*time.Second)
+	remaining := "no limit"
+	if status.Remaining != nil {
+		remaining = (time.Duration(*status.Remaining) * time.Second).String()
+	}
+	fmt.Printf("  Time remaining: %s\n", remaining)
+	fmt.Printf("  Control API:    %s\n", info.URL)
+	return nil
+}
+
+// stopInstance shuts the demo down as Ctrl+C in its console would and
+// waits for it to be gone.
+func stopInstance(info controlInfo) error {
+	fmt.Printf("Stopping the demo (pid %d)...\n", info.PID)
+	req, err := http.NewRequest(http.MethodPost, info.URL+"/shutdown", nil)
+	if err != nil {
+		return err
+	}
+	info.authorize(req)
+	resp, err := loopbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the demo answered %s", resp.Status)
+	}
+	deadline := time.Now().Add(instanceStopWait)
+	for processRunning(info.PID) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("still running after %s", instanceStopWait)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	fmt.Println("Stopped.")
+	return nil
+}
+
+// followLog prints the end of launcher.log and, while the demo runs, what
+// is added to it, like tail -f.
+func followLog(dir string) int {
+	path := filepath.Join(dir, consoleLogFile)
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Error: no log yet: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	offset, err := printLogTail(f)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", path, err)
+		return 1
+	}
+	for {
+		_, running := runningControl(dir)
+		if replacedLog(f, path, offset) {
+			// Rotated, or a new run started
+			io.Copy(os.Stdout, f)
+			f.Close()
+			if f, err = os.Open(path); err != nil {
+				return 0
+			}
+			offset = 0
+		}
+		n, _ := io.Copy(os.Stdout, f)
+		offset += n
+		if !running {
+			return 0
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// replacedLog reports whether path is no longer the file f, or was
+// truncated to less than what was read of it.
+func replacedLog(f *os.File, path string, offset int64) bool {
+	now, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	open, err := f.Stat()
+	return err == nil && !os.SameFile(open, now) || now.Size() < offset
+}
+
+// printLogTail prints the last logTailLines lines of f and returns where
+// it stopped.
+func printLogTail(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// The lines are in the last 64 KB unless PHP printed huge ones
+	start := fi.Size() - 64*1024
+	if start < 0 {
+		start = 0
+	}
+	data := make([]byte, fi.Size()-start)
+	if _, err := f.ReadAt(data, start); err != nil && err != io.EOF {
+		return 0, err
+	}
+	if start > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines) > logTailLines+1 {
+		lines = lines[len(lines)-logTailLines-1:]
+	}
+	os.Stdout.Write(bytes.Join(lines, nil))
+	if _, err := f.Seek(fi.Size(), io.SeekStart); err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
@@ -0,0 +1,123 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultStartupTimeoutSeconds bounds how long PHP may take to accept
+// connections and then to answer the landing page, unless the manifest's
+// startup_timeout_seconds says otherwise.
+const defaultStartupTimeoutSeconds = 15
+
+// Readiness probes start quickly and back off, so a fast PHP is seen at
+// once and a slow one isn't hammered.
+const (
+	probeFirstWait = 50 * time.Millisecond
+	probeMaxWait   = time.Second
+)
+
+// startupTimeout is startup_timeout_seconds as a duration.
+func startupTimeout(config *Manifest) time.Duration {
+	return time.Duration(orDefault(config.StartupTimeoutSeconds, defaultStartupTimeoutSeconds)) * time.Second
+}
+
+// probeWait returns how long to wait after the given number of failed
+// probes.
+func probeWait(failures int) time.Duration {
+	wait := probeFirstWait
+	for i := 0; i < failures && wait < probeMaxWait; i++ {
+		wait *= 2
+	}
+	if wait > probeMaxWait {
+		return probeMaxWait
+	}
+	return wait
+}
+
+// readyStatus reports whether a response means the server is up: 2xx, or
+// 3xx such as the redirect to a login page.
+func readyStatus(code int) bool {
+	return code >= 200 && code < 400
+}
+
+// checkPublicRoot catches a public_root pointing at the wrong folder, which
+// php -S would happily serve as a directory listing.
+func checkPublicRoot(config *Manifest, publicDir string) error {
+	info, err := os.Stat(publicDir)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("public_root %q does not exist (looked for %s)", config.PublicRoot, publicDir)
+	}
+	if _, err := os.Stat(filepath.Join(publicDir, "index.php")); err != nil {
+		return fmt.Errorf("public_root %q has no index.php; is it the Laravel public folder?", config.PublicRoot)
+	}
+	return nil
+}
+
+// loopbackClient talks to the local PHP server: it never uses a proxy and
+// doesn't follow redirects, so the first response is what gets judged.
+var loopbackClient = &http.Client{
+	Transport: &http.Transport{Proxy: nil},
+	Timeout:   5 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// checkLanding requests the landing page from PHP directly once it accepts
+// connections until it answers with 2xx or 3xx. A missing or forbidden
+// page fails at once; anything else, such as a 500 while the app warms
+// up, is retried until the startup timeout.
+func checkLanding(config *Manifest, client *http.Client, phpURL string, clock Clock) error {
+	target := phpURL + config.LandingPageURL
+	timeout := startupTimeout(config)
+	deadline := clock.Now().Add(timeout)
+	for failures := 0; ; failures++ {
+		resp, err := client.Get(target)
+		if err == nil {
+			resp.Body.Close()
+			if readyStatus(resp.StatusCode) {
+				return nil
+			}
+			if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden {
+				return fmt.Errorf("landing_page_url %q returned %s; check landing_page_url and public_root, or set skip_landing_check", config.LandingPageURL, resp.Status)
+			}
+		}
+		if clock.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("PHP did not answer on %s within %s: %w", target, timeout, err)
+			}
+			return fmt.Errorf("landing_page_url %q still returned %s after %s; see the PHP output above, or raise startup_timeout_seconds", config.LandingPageURL, resp.Status, timeout)
+		}
+		<-clock.After(probeWait(failures))
+	}
+}
+
+// waitPublic waits until the launcher's own public port answers, which
+// shows the setup banner, so the browser never opens on a refused
+// connection.
+func waitPublic(ctx context.Context, url string, timeout time.Duration, clock Clock) error {
+	deadline := clock.Now().Add(timeout)
+	for failures := 0; ; failures++ {
+		resp, err := loopbackClient.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if readyStatus(resp.StatusCode) {
+				return nil
+			}
+			err = fmt.Errorf("it returned %s", resp.Status)
+		}
+		if clock.Now().After(deadline) {
+			return fmt.Errorf("the demo did not answer on %s within %s: %w", url, timeout, err)
+		}
+		select {
+		case <-clock.After(probeWait(failures)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,1030 @@
+// Package demo runs a bundled Laravel demo: the Launcher type extracts
+// the bundle, starts PHP, opens the browser and tears it all down again,
+// and the Run functions implement the launcher's subcommands. The
+// launcher's main package only parses the command line.
+package demo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options are the per-run settings that come from the command line rather
+// than the manifest.
+type Options struct {
+	WorkDir       string     // extract here instead of a fresh temp dir
+	NoVerify      bool       // skip verification even if the manifest asks for it
+	Check         bool       // extract and verify, then stop
+	App           string     // app to start from a suite manifest
+	ExportData    string     // save demo data here on exit
+	ImportData    string     // restore demo data from here before starting
+	AcceptEULA    bool       // skip the evaluation agreement gate
+	Session       string     // named session: own data, port and browser profile
+	Deterministic bool       // fixed port, time, seed and data for recordings
+	ServeDir      string     // dev mode: serve this checkout in place
+	Verbose       bool       // print timing details
+	LogLevel      slog.Level // lowest level written to launcher.log
+	LogJSON       bool       // launcher.log as JSON lines
+	Quiet         bool       // only errors on the console
+	Browser       string     // none, default, chrome, edge or firefox; "" for default
+
+	Overrides Overrides       // manifest values from the command line
+	Profile   json.RawMessage // the --profile entry, laid over the app's settings
+}
+
+// reportedError is a start failure that was already shown to the user.
+type reportedError struct{ error }
+
+func (e reportedError) Unwrap() error { return e.error }
+
+// Clock is the source of time for timers, so expiry and resets can be
+// driven by a fake clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Launcher runs one demo session: it picks the app, extracts the bundle,
+// starts PHP, opens the browser and tears everything down again. The
+// exported function fields are the dependency injection points; NewLauncher
+// fills them with the real implementations.
+type Launcher struct {
+	Config  Manifest
+	Options Options
+	ExeDir  string // directory a development build runs from in place
+
+	Bundle     fs.FS                                      // embedded bundle
+	Command    func(name string, arg ...string) *exec.Cmd // starts processes
+	Clock      Clock
+	OpenURL    func(url string) error // opens a URL in the browser
+	HTTPClient *http.Client           // for requests to the outside world
+
+	chooser      *appChooser
+	otherApps    []string
+	baseDir      string
+	dataDir      string // root of the mutable data; baseDir unless in a session, a cached run or kept
+	runDir       string // fresh data dir of a cached run, removed on exit
+	session      *demoSession
+	instance     *appInstance // the single-instance lock, if this run holds it
+	workDir      string
+	ownsWorkDir  bool
+	host         string            // literal IP everything listens on
+	bindAddr     string            // public address, served by the proxy
+	port         int               // of bindAddr
+	phpAddr      string            // internal address PHP listens on
+	phpTransport http.RoundTripper // FastCGI to php-fpm; nil for php -S
+	fpmConf      string            // php-fpm configuration to remove on exit
+	phpIni       string            // rendered php_ini, also removed on exit
+	baseURL      string
+	publicDir    string
+	appRoot      string // Laravel root: working dir for PHP and artisan
+	artisan      string // path of the artisan script
+	started      time.Time
+	timings      startupTimings
+	proxy        *demoProxy
+	proxySrv     *http.Server
+	front        *switchHandler // setup banner, then proxy
+	banner       *setupBanner
+	icon         *appIcon      // icon_path, served as the favicon
+	browserDone  chan struct{} // closed once the browser was opened or given up on
+	browserShown bool
+	control      *controlServer
+	consoleLog   *consoleLog    // launcher.log, while this run writes it
+	servers      phpPool        // one per php_workers
+	sides        []*sideProcess // in start order
+	dataGuard    *dataGuard     // stops PHP for resets and exports
+	resetter     *dataResetter
+	lastExport   atomic.Pointer[string] // the data file ExportData last wrote
+	watchdog     *watchdog
+	quota        *workDirQuota
+	expiry       *expiryTimer
+	idleWatch    *idleWatch    // with idle_timeout_minutes
+	idle         chan struct{} // closed when the demo is to end for being idle
+	idleOnce     sync.Once
+	window       *windowWatch // when the demo closes with its app window
+	tray         *trayIcon
+	quit         chan struct{} // closed by Quit
+	quitOnce     sync.Once
+	consoleGone  bool // Shutdown runs as the console closes
+	pauseMu      sync.Mutex
+	paused       bool
+	outputMu     sync.Mutex
+	outputs      []*phpServer  // every PHP started, for phpOutput
+	stopLoops    chan struct{} // closed on shutdown to end the background loops
+	cleanupOnce  sync.Once
+}
+
+// NewLauncher returns a Launcher for config using the embedded bundle, real
+// processes, the system clock and the browser in opts.
+func NewLauncher(config Manifest, opts Options, exeDir string) *Launcher {
+	if opts.Browser == "" {
+		opts.Browser = "default"
+	}
+	l := &Launcher{
+		Config:     config,
+		Options:    opts,
+		ExeDir:     exeDir,
+		Bundle:     BundleFS,
+		Command:    newCommand,
+		Clock:      systemClock{},
+		HTTPClient: newOutboundClient(config.Offline),
+	}
+	l.OpenURL = l.openBrowser
+	return l
+}
+
+// openBrowser opens url in the session's browser profile, if any, with
+// the accessibility settings. With app_window it opens its own window.
+func (l *Launcher) openBrowser(url string) error {
+	var profile string
+	if l.session != nil {
+		profile = l.session.browserDir()
+	} else if l.Options.Deterministic {
+		profile = deterministicBrowserDir(&l.Config)
+	}
+	a := l.Config.Accessibility
+	if l.Config.AppWindow && l.Options.Browser != BrowserNone {
+		if handled, err := l.openAppWindow(url, profile, accessibilityBrowserArgs("chrome", a)); handled {
+			return err
+		}
+	}
+	args := accessibilityBrowserArgs(l.Options.Browser, a)
+	if a.enabled() && args == nil && !browserArgsNoted {
+		fmt.Println("This browser can't be told the accessibility settings; use --browser chrome or edge.")
+		browserArgsNoted = true
+	}
+	return openBrowserWith(l.Options.Browser, url, profile, args)
+}
+
+// Run performs the whole session and returns once it is over: when ctx is
+// cancelled or Quit called (the user quit) or the demo duration expired. A cancel cause of
+// consoleCloseSignal{} selects the fast shutdown path.
+func (l *Launcher) Run(ctx context.Context) error {
+	activeLauncher.Store(l)
+	defer l.cleanup()
+
+	if l.Options.Deterministic {
+		announceDeterministic(&l.Config)
+	}
+	if l.Config.Offline {
+		fmt.Println(msg("offline_mode"))
+	}
+	if l.usesInstanceLock() {
+		var err error
+		l.instance, err = lockInstance(&l.Config)
+		if err == errAlreadyRunning {
+			if err := l.focusRunningInstance(); err != nil {
+				return launchFailure(errorCategorySetup, err)
+			}
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("Warning: can't check for a running demo: %v\n", err)
+		}
+	}
+
+	if err := l.SelectApp(ctx); err != nil {
+		return launchFailure(errorCategoryManifest, err)
+	}
+	checkCrashMarker(&l.Config)
+	if err := l.CheckEULA(ctx); err != nil {
+		if err == errEULADeclined {
+			fmt.Println(msg("eula_declined"))
+			return nil
+		}
+		return launchFailure(errorCategoryManifest, err)
+	}
+	// The browser shows the splash screen while the bundle is extracted,
+	// so the port is taken first. A session keeps its port, so it's
+	// opened before that.
+	if !l.Options.Check {
+		if err := l.lockSession(); err != nil {
+			return launchFailure(errorCategoryExtract, err)
+		}
+		l.startConsoleLog()
+		if err := l.Listen(); err != nil {
+			return launchFailure(errorCategoryPort, err)
+		}
+		l.publishInstance(l.baseURL + l.Config.LandingPageURL)
+		l.OpenBrowser(ctx)
+		if l.extractsBundle() {
+			l.banner.Show(msg("setup_unpacking"))
+		}
+	}
+	extractStart := l.Clock.Now()
+	if err := l.Extract(ctx); err != nil {
+		return l.showStartFailure(ctx, launchFailure(extractCategory(err), err))
+	}
+	l.timings.extraction = l.Clock.Now().Sub(extractStart)
+	if l.extractsBundle() {
+		l.verbosef("Extraction took %s with %d workers", l.timings.extraction.Round(time.Millisecond), extractWorkers())
+	}
+	if l.Options.Check {
+		return nil
+	}
+	l.applyConsoleIcon()
+	l.createShortcuts()
+	l.registerUninstaller()
+	if err := l.StartServer(ctx); err != nil {
+		return l.showStartFailure(ctx, err)
+	}
+	l.logStartup(ctx)
+
+	// Also handle duration expiry
+	var expired, windowClosed <-chan struct{}
+	if l.expiry != nil {
+		expired = l.expiry.expired
+	}
+	if l.window != nil {
+		windowClosed = l.window.closed
+	}
+
+	reason := exitReasonQuit
+	for waiting := true; waiting; {
+		waiting = false
+		select {
+		case <-ctx.Done():
+		case <-l.quit:
+		case <-expired:
+			fmt.Println(msg("demo_expired"))
+			if policy := l.expiryPolicy(); policy != expiryTerminate {
+				// Still ended by quitting, going idle or closing the window
+				l.degrade(policy)
+				expired, waiting = nil, true
+				break
+			}
+			reason = exitReasonExpired
+		case <-l.idle:
+			reason = exitReasonIdle
+		case <-windowClosed:
+			fmt.Println(msg("window_closed"))
+			reason = exitReasonWindowClosed
+		}
+	}
+	_, consoleClosed := context.Cause(ctx).(consoleCloseSignal)
+
+	l.Shutdown(reason, consoleClosed)
+	return nil
+}
+
+// SelectApp narrows a suite manifest down to one app, from Options.App or
+// the chooser page. --check without an app covers all of them.
+func (l *Launcher) SelectApp(ctx context.Context) error {
+	if len(l.Config.Apps) == 0 || (l.Options.Check && l.Options.App == "") {
+		return nil
+	}
+
+	name := l.Options.App
+	if name == "" {
+		host, err := listenHost(&l.Config)
+		if err == nil {
+			l.chooser, err = startAppChooser(host, &l.Config, l.Bundle)
+		}
+		if err != nil {
+			return fmt.Errorf("starting app chooser: %w", err)
+		}
+		fmt.Println(msg("choose_app_at", l.chooser.url))
+		l.publishInstance(l.chooser.url)
+		if err := l.OpenURL(l.chooser.url); err != nil {
+			printBrowserFallback(l.chooser.url, err)
+		}
+		select {
+		case name = <-l.chooser.chosen:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	apps, _ := appEntries(&l.Config)
+	config, err := SelectApp(&l.Config, name)
+	if err != nil {
+		return fmt.Errorf("selecting app: %w", err)
+	}
+	l.Config = config
+	if l.Options.Profile != nil {
+		ApplyProfile(&l.Config, l.Options.Profile) // checked by decodeManifest
+	}
+	l.Options.Overrides.Apply(&l.Config)
+	// Only the selected app's directory gets extracted
+	for _, app := range apps {
+		if app.Dir != config.Dir {
+			l.otherApps = append(l.otherApps, app.Dir)
+		}
+	}
+	fmt.Println(msg("starting_app", config.AppName))
+	return nil
+}
+
+// Extract unpacks the bundle into the work dir and verifies it when asked
+// to. A development build without a bundle runs in place from ExeDir.
+func (l *Launcher) Extract(ctx context.Context) error {
+	l.baseDir = l.ExeDir
+	l.dataDir = l.baseDir
+	if l.Options.ServeDir != "" {
+		if err := l.serveInPlace(); err != nil {
+			return err
+		}
+		return l.importData()
+	}
+	if !l.extractsBundle() {
+		if l.Options.Check {
+			return fmt.Errorf("checking bundle: this launcher has no embedded bundle to check")
+		}
+		if err := l.resolvePublicDir(); err != nil {
+			return err
+		}
+		return l.importData()
+	}
+	if err := checkScrambled(l.Bundle, &l.Config); err != nil {
+		return err
+	}
+
+	sweepStaleDirs()
+	sweepOrphanedDirs()
+	if l.Options.Session != "" {
+		if err := l.openSession(); err != nil {
+			return err
+		}
+	} else if l.usesExtractionCache() {
+		if err := l.openCachedCode(); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		l.workDir, l.ownsWorkDir, err = prepareWorkDir(l.Options.WorkDir)
+		if err != nil {
+			return fmt.Errorf("creating work directory: %w", err)
+		}
+
+		fmt.Println(msg("extracting", l.workDir))
+		if err := extractBundle(l.Bundle, l.workDir, l.otherApps); err != nil {
+			return fmt.Errorf("extracting bundle: %w", err)
+		}
+	}
+
+	// --check always verifies; otherwise it's opt-in as it costs a few seconds
+	if l.Options.Check || (l.Config.VerifyExtraction && !l.Options.NoVerify) {
+		if err := verifyAndRepair(l.Bundle, l.workDir, l.otherApps); err != nil {
+			return fmt.Errorf("verifying extraction: %w", err)
+		}
+		fmt.Println(msg("files_verified"))
+	}
+	l.baseDir = l.workDir
+	l.dataDir = l.baseDir
+
+	rel, err := platformPHPBinary(&l.Config, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	if rel != "" && !filepath.IsAbs(rel) {
+		// Embedded files lose their mode bits
+		bin := filepath.Join(l.workDir, rel)
+		os.Chmod(bin, 0755)
+		os.Chmod(platformExecutable(bin, runtime.GOOS), 0755)
+	}
+
+	if err := l.resolvePublicDir(); err != nil {
+		return err
+	}
+	if l.Options.Check {
+		fmt.Println(msg("check_passed"))
+		return nil
+	}
+	if l.session != nil {
+		if err := l.session.prepareData(&l.Config, l.baseDir, l.appRoot); err != nil {
+			return fmt.Errorf("preparing session data: %w", err)
+		}
+		l.dataDir = l.session.dataDir()
+	} else if l.keepsData() {
+		if err := l.prepareKeptData(); err != nil {
+			return fmt.Errorf("preparing demo data: %w", err)
+		}
+	} else if l.usesExtractionCache() {
+		if err := l.prepareRunData(); err != nil {
+			return fmt.Errorf("preparing demo data: %w", err)
+		}
+	}
+	return l.importData()
+}
+
+// extractsBundle reports whether the demo runs from the embedded bundle,
+// rather than in place from --serve-dir or a development build.
+func (l *Launcher) extractsBundle() bool {
+	return l.Options.ServeDir == "" && hasBundle(l.Bundle)
+}
+
+// lockSession opens the --session, if any. Its last port is needed before
+// the bundle is extracted.
+func (l *Launcher) lockSession() error {
+	if l.Options.Session == "" || !l.extractsBundle() {
+		return nil
+	}
+	var err error
+	l.session, err = openSession(&l.Config, l.Options.Session)
+	return err
+}
+
+// openSession locks the --session unless that was done already and uses
+// the shared code tree as the work dir, extracting it if needed.
+func (l *Launcher) openSession() error {
+	var err error
+	if l.session == nil {
+		if l.session, err = openSession(&l.Config, l.Options.Session); err != nil {
+			return err
+		}
+	}
+
+	var extracted bool
+	l.workDir, extracted, err = sharedCodeDir(&l.Config, l.Bundle, l.otherApps)
+	if err != nil {
+		return fmt.Errorf("extracting bundle: %w", err)
+	}
+	if extracted {
+		fmt.Println(msg("extracting", l.workDir))
+	}
+	fmt.Println(msg("session_started", l.session.name, l.session.dir))
+	return nil
+}
+
+// resolvePublicDir locates public_root, app_root and artisan below the base
+// dir and makes sure public_root is a Laravel public folder. Without an
+// explicit app_root the app is assumed to be the parent of public_root.
+func (l *Launcher) resolvePublicDir() error {
+	if len(l.Config.Apps) > 0 {
+		// --check of a whole suite; each app has its own public_root
+		return nil
+	}
+	l.publicDir = l.bundlePath(l.Config.PublicRoot)
+	if err := checkPublicRoot(&l.Config, l.publicDir); err != nil {
+		return fmt.Errorf("in manifest: %w", err)
+	}
+
+	l.appRoot = filepath.Dir(l.publicDir)
+	if l.Config.AppRoot != "" {
+		l.appRoot = l.bundlePath(l.Config.AppRoot)
+	}
+	l.artisan = filepath.Join(l.appRoot, "artisan")
+	if l.Config.ArtisanPath != "" {
+		l.artisan = l.bundlePath(l.Config.ArtisanPath)
+	}
+	if l.Config.ArtisanPath != "" || usesArtisan(&l.Config) {
+		if _, err := os.Stat(l.artisan); err != nil {
+			return fmt.Errorf("in manifest: artisan not found at %s; set app_root or artisan_path", l.artisan)
+		}
+	}
+	return nil
+}
+
+// readAppFile reads a manifest path relative to the packaged app. It
+// works before extraction too: from the embedded bundle, or from disk for
+// dev builds and --serve-dir.
+func (l *Launcher) readAppFile(p string) ([]byte, error) {
+	switch {
+	case filepath.IsAbs(p):
+		return ioutil.ReadFile(p)
+	case l.extractsBundle():
+		return readBundleFile(l.Bundle, filepath.ToSlash(p))
+	}
+	dir := l.ExeDir
+	if l.Options.ServeDir != "" {
+		dir = l.Options.ServeDir
+	}
+	return ioutil.ReadFile(filepath.Join(dir, p))
+}
+
+// bundlePath resolves a manifest path relative to the base dir.
+func (l *Launcher) bundlePath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(l.baseDir, p)
+}
+
+func (l *Launcher) importData() error {
+	if l.Options.ImportData == "" {
+		return nil
+	}
+	fmt.Println(msg("importing_data", l.Options.ImportData))
+	if err := importData(l.Options.ImportData, &l.Config, l.dataDir, resetPaths(&l.Config, l.dataDir, l.dataAppRoot())); err != nil {
+		return fmt.Errorf("importing demo data: %w", err)
+	}
+	return nil
+}
+
+// Listen takes the public port and starts serving the setup banner on it,
+// so the browser has something to show while the demo is prepared.
+func (l *Launcher) Listen() error {
+	// Every consumer (PHP bind, browser URL, APP_URL) uses this one literal
+	// host so localhost resolving to ::1 can't split them across families.
+	host, err := listenHost(&l.Config)
+	if err != nil {
+		return fmt.Errorf("in manifest: %w", err)
+	}
+
+	// The proxy owns the public port; PHP gets a private one behind it.
+	// A session keeps the port of its last run when it's still free, so
+	// its browser profile's cookies and bookmarks keep working.
+	port := l.Config.PHPPort
+	if l.session != nil {
+		port = l.session.meta.Port
+	}
+	public, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		if public, err = l.listenElsewhere(host, port, err); err != nil {
+			return err
+		}
+	}
+	port = public.Addr().(*net.TCPAddr).Port
+	if l.session != nil {
+		l.session.meta.Port = port
+	}
+	l.host = host
+	l.port = port
+	l.bindAddr = net.JoinHostPort(host, strconv.Itoa(port))
+	l.baseURL = serverURL(host, port)
+
+	l.icon = l.loadAppIcon()
+	l.banner = newSetupBanner(&l.Config, l.Clock, l.splashImage(), l.icon)
+	l.front = &switchHandler{h: l.banner}
+	l.proxySrv = &http.Server{Handler: l.front}
+	go l.proxySrv.Serve(public)
+	return nil
+}
+
+// listenElsewhere deals with the public port being unavailable. A session
+// moves to any free port, a taken php_port follows port_fallback, and
+// without one the user learns which program holds the port. Deterministic
+// runs need their port and always fail.
+func (l *Launcher) listenElsewhere(host string, port int, err error) (net.Listener, error) {
+	switch {
+	case port == 0:
+	case l.session != nil:
+		if public, err := net.Listen("tcp", net.JoinHostPort(host, "0")); err == nil {
+			return public, nil
+		}
+	case !addrInUse(err):
+	case l.Config.PortFallback == "" || l.Config.PortFallback == portFallbackFail || l.Options.Deterministic:
+		return nil, portTakenError(port)
+	default:
+		public, err := listenFallback(host, port, l.Config.PortFallback)
+		if err != nil {
+			return nil, fmt.Errorf("port %d is in use and no other was found: %w", port, err)
+		}
+		fmt.Println(msg("port_fallback", port, public.Addr().(*net.TCPAddr).Port))
+		return public, nil
+	}
+	return nil, fmt.Errorf("listening on port %d: %w", port, err)
+}
+
+// StartServer runs the setup commands, starts the side processes and PHP
+// and switches the public port over to the proxy once the landing page
+// answers. Periodic jobs such as the kiosk data reset start here too.
+func (l *Launcher) StartServer(ctx context.Context) error {
+	host := l.host
+	start := l.Clock.Now()
+
+	// Locate PHP binary. It should be packaged with the app or pinned in
+	// php_downloads; system 'php' is only used when the manifest explicitly
+	// allows it.
+	phpBin, err := l.downloadedPHP()
+	if err == nil && phpBin == "" {
+		phpBin, err = resolvePHPBinary(&l.Config, l.baseDir)
+	}
+	if err != nil {
+		return fmt.Errorf("locating PHP: %w", err)
+	}
+	if err := l.preparePHPIni(phpBin); err != nil {
+		return launchFailure(errorCategorySetup, err)
+	}
+	if err := checkPHPRequirements(l.Command, phpBin, l.phpIniArgs(), &l.Config, l.appRoot); err != nil {
+		return launchFailure(errorCategorySetup, fmt.Errorf("checking PHP: %w", err))
+	}
+
+	// Inject Env Vars
+	warnLiveCredentials(&l.Config, readDotEnv(filepath.Join(l.appRoot, ".env")))
+	own := appEnv(&l.Config, l.appRoot, l.baseURL, hostLocalization(&l.Config))
+	own = append(own, l.dataEnv()...)
+	own = append(own, l.deterministicEnv()...)
+	keyEnv, err := l.appKeyEnv()
+	if err != nil {
+		return launchFailure(errorCategorySetup, fmt.Errorf("generating APP_KEY: %w", err))
+	}
+	own = append(own, keyEnv...)
+	if err := l.writeDotEnv(own); err != nil {
+		return launchFailure(errorCategorySetup, err)
+	}
+	env := append(os.Environ(), own...)
+	env = append(env, l.phpIniEnv()...)
+
+	if err := l.runSetupCommands(phpBin, env); err != nil {
+		return err
+	}
+	// Kiosk demos put their data back on a schedule, or once idle, to how
+	// setup left it
+	if l.Config.AutoResetMinutes > 0 || l.Config.IdleTimeoutMinutes > 0 && l.Config.IdleAction == idleActionReset {
+		if l.resetter, err = newDataResetter(resetPaths(&l.Config, l.dataDir, l.dataAppRoot())); err != nil {
+			l.resetter = nil
+			fmt.Printf("Error preparing data reset: %v\n", err)
+		}
+	}
+
+	// Side processes come up first so their ports are known to PHP
+	env, err = l.startSideProcesses(host, phpBin, env)
+	if err != nil {
+		return err
+	}
+
+	l.banner.Step(msg("setup_starting_php"))
+	phpPorts, err := l.startPHP(host, phpBin, env)
+	if err != nil {
+		return fmt.Errorf("starting PHP server: %w", err)
+	}
+
+	l.timings.setup = l.Clock.Now().Sub(start)
+	l.verbosef("Setup took %s: setup commands, side processes and starting PHP", l.timings.setup.Round(time.Millisecond))
+	if !l.Config.SkipLandingCheck {
+		l.banner.Step(msg("setup_checking"))
+		if err := checkLanding(&l.Config, l.phpClient(), serverURL(host, phpPorts[0]), l.Clock); err != nil {
+			return fmt.Errorf("checking landing page: %w", err)
+		}
+	}
+
+	l.warmup(serverURL(host, phpPorts[0]), phpBin, env)
+
+	upstreams := make([]*url.URL, len(phpPorts))
+	for i, port := range phpPorts {
+		upstreams[i], _ = url.Parse(serverURL(host, port))
+	}
+	publicURL, _ := url.Parse(l.baseURL)
+	l.proxy = newDemoProxy(&l.Config, upstreams, publicURL)
+	for _, server := range l.servers {
+		server.OnGiveUp(l.phpGaveUp)
+	}
+	if l.phpTransport != nil {
+		l.proxy.rp.Transport = l.phpTransport
+	}
+	l.proxy.icon = l.icon
+	l.proxy.static = newStaticFiles(l.publicDir)
+	if l.Config.Tour != nil {
+		tour, err := l.loadTour()
+		if err != nil {
+			return fmt.Errorf("loading tour: %w", err)
+		}
+		l.proxy.setTour(tour)
+	}
+	for prefix, name := range l.Config.ProxyRoutes {
+		side, _ := url.Parse(serverURL(host, l.sideProcess(name).port))
+		l.proxy.addRoute(prefix, side)
+	}
+	if l.Config.AllowedDemoDurationMinutes > 0 {
+		// Created before the proxy takes requests, which report it
+		l.expiry = newExpiryTimer(l.Clock, time.Duration(l.Config.AllowedDemoDurationMinutes)*time.Minute, l.Config.ExpiryCountsSleep)
+	}
+	if l.Config.IdleTimeoutMinutes > 0 {
+		l.idleWatch = newIdleWatch(l.Clock, time.Duration(l.Config.IdleTimeoutMinutes)*time.Minute)
+		l.idle = make(chan struct{})
+		l.proxy.idle = l.idleWatch
+	}
+	if l.closesWithWindow() {
+		// A paused demo keeps running, as the pause page carries no
+		// connection
+		l.window = newWindowWatch(l.isPaused)
+		l.proxy.setWindowWatch(l.window)
+	}
+	l.proxy.timeLeft = l.timeRemaining
+	l.front.Set(l.proxy)
+	l.banner.Ready()
+	l.started = l.Clock.Now()
+	l.timings.ready = l.started.Sub(start) - l.timings.setup
+	l.verbosef("PHP was ready %s after that: landing page check and warmup", l.timings.ready.Round(time.Millisecond))
+
+	fmt.Println(msg("server_started", l.baseURL))
+
+	l.stopLoops = make(chan struct{})
+	if l.expiry != nil {
+		goSafe("expiry timer", func() { l.expiry.Run(l.stopLoops) })
+	}
+	if l.idleWatch != nil {
+		goSafe("idle watch", func() { l.idleWatch.Run(l.stopLoops, l.onIdle) })
+	}
+	if l.Config.MaxMemoryMB > 0 || l.Config.CPUGraceSeconds > 0 {
+		l.watchdog = newWatchdog(&l.Config, l.Clock)
+		for i, server := range l.servers {
+			name := "PHP server"
+			if len(l.servers) > 1 {
+				name = fmt.Sprintf("PHP worker %d", i+1)
+			}
+			l.watchdog.Watch(name, server)
+		}
+		for _, p := range l.sides {
+			l.watchdog.Watch(p.name, p)
+		}
+		goSafe("watchdog", func() { l.watchdog.Run(l.stopLoops) })
+	}
+
+	if l.Config.MaxWorkDirMB > 0 {
+		l.quota = newWorkDirQuota(&l.Config, l.dataDir, l.dataAppRoot(), l.Clock, l.proxy.SetUploadsBlocked)
+		goSafe("work dir quota", func() { l.quota.Run(l.stopLoops) })
+	}
+
+	// Resets and exports stop PHP; the tray menu has them at hand
+	l.dataGuard = newDataGuard(l.servers, l.proxy.SetBusy, l.Clock, startupTimeout(&l.Config))
+	if l.resetter != nil {
+		l.resetter.guard = l.dataGuard
+		if l.Config.AutoResetMinutes > 0 {
+			goSafe("data reset", func() {
+				l.resetter.Schedule(l.Clock, time.Duration(l.Config.AutoResetMinutes)*time.Minute, l.stopLoops)
+			})
+		}
+	}
+
+	l.quit = make(chan struct{})
+	l.control, err = startControlServer(host, l.baseURL, l.status, l.timeRemaining, l.phpOutput, map[string]func() error{
+		"pause":        l.Pause,
+		"resume":       l.Resume,
+		"open-browser": l.OpenDemo,
+		"restart":      l.RestartPHP,
+		"reset-db":     l.ResetData,
+		"export-data":  l.ExportData,
+		"shutdown":     l.Quit,
+	})
+	if err != nil {
+		fmt.Printf("Error starting control API: %v\n", err)
+	} else {
+		fmt.Println(msg("status_at", l.control.url))
+		if dir := l.stateDir(); dir != "" {
+			if err := l.control.publish(filepath.Join(dir, controlInfoFile), l.baseURL+l.Config.LandingPageURL); err != nil {
+				fmt.Printf("Warning: control.json can't be written: %v\n", err)
+			}
+		}
+	}
+	l.startTray()
+	return nil
+}
+
+// phpGaveUp is called when a PHP server keeps crashing and stays down.
+// Pages it would have served now say so instead of reloading forever.
+func (l *Launcher) phpGaveUp(err error) {
+	fmt.Println(msg("php_failed", err))
+	l.proxy.phpFailed.Store(true)
+}
+
+// phpStartAttempts is how often a PHP whose port was taken between
+// getFreePort and its bind is started again on a new port.
+const phpStartAttempts = 3
+
+// startPHP starts the PHP servers, one per php_workers, and returns their
+// ports. With php-fpm there is always one; its pool has workers of its own.
+func (l *Launcher) startPHP(host, phpBin string, env []string) ([]int, error) {
+	var fpm string
+	if l.Config.ServerMode == serverModeFPM {
+		if fpm = fpmBinary(phpBin); fpm == "" {
+			fmt.Println("server_mode is \"fpm\" but no php-fpm was bundled with PHP; using php -S instead.")
+		}
+	}
+	workers := orDefault(l.Config.PHPWorkers, 1)
+	if fpm != "" {
+		workers = 1
+	}
+	var ports []int
+	for i := 0; i < workers; i++ {
+		server, port, err := l.startPHPServer(host, phpBin, fpm, env)
+		if err != nil {
+			return nil, err
+		}
+		l.servers = append(l.servers, server)
+		ports = append(ports, port)
+	}
+	l.phpAddr = l.servers[0].addr
+	return ports, nil
+}
+
+// startPHPServer starts one PHP on a free internal port and waits until it
+// accepts connections. The port is only reserved until getFreePort returns,
+// so if another process takes it first, the whole sequence is retried.
+func (l *Launcher) startPHPServer(host, phpBin, fpm string, env []string) (*phpServer, int, error) {
+	for attempt := 1; ; attempt++ {
+		phpPort, err := getFreePort(host)
+		if err != nil {
+			return nil, 0, fmt.Errorf("finding free port: %w", err)
+		}
+		server := &phpServer{
+			bin:     phpBin,
+			addr:    net.JoinHostPort(host, strconv.Itoa(phpPort)),
+			docRoot: l.publicDir,
+			dir:     l.appRoot,
+			env:     env,
+			output:  newOutputRing(outputMaxLines, outputMaxBytes),
+			grace:   shutdownGrace(&l.Config),
+			command: l.Command,
+		}
+		if l.phpIni != "" {
+			server.args = append(l.phpIniArgs(), "-S", server.addr, "-t", server.docRoot)
+		}
+		l.outputMu.Lock()
+		l.outputs = append(l.outputs, server)
+		l.outputMu.Unlock()
+		if fpm != "" {
+			if err := l.useFPM(server, fpm, phpBin); err != nil {
+				return nil, 0, err
+			}
+		}
+		if err := server.Start(); err != nil {
+			if bin := server.bin; bin != "php" {
+				err = fmt.Errorf("%w\n%s", err, diagnosePHPBinary(bin, err))
+			}
+			return nil, 0, err
+		}
+
+		err = server.WaitReady(startupTimeout(&l.Config), l.Clock)
+		if err == nil {
+			return server, phpPort, nil
+		}
+		server.Stop()
+		if err != errPortInUse || attempt == phpStartAttempts {
+			return nil, 0, err
+		}
+		fmt.Printf("Port %d was taken by another process, retrying on a new port...\n", phpPort)
+	}
+}
+
+// OpenBrowser opens the landing page as soon as the public port answers,
+// or hands the URL to the chooser tab when there was one. Until PHP is
+// ready the page shows the setup banner.
+func (l *Launcher) OpenBrowser(ctx context.Context) {
+	url := l.baseURL + l.Config.LandingPageURL
+	l.browserDone = make(chan struct{})
+	goSafe("browser opener", func() {
+		defer close(l.browserDone)
+		if err := waitPublic(ctx, url, startupTimeout(&l.Config), l.Clock); err != nil {
+			if ctx.Err() == nil {
+				printBrowserFallback(url, err)
+			}
+			return
+		}
+		if l.chooser != nil {
+			// The chooser tab navigates there itself
+			l.chooser.redirect(url)
+			l.browserShown = true
+			return
+		}
+		opening := l.Clock.Now()
+		err := l.OpenURL(url)
+		l.timings.browser = l.Clock.Now().Sub(opening)
+		if err != nil {
+			printBrowserFallback(url, err)
+			return
+		}
+		l.verbosef("The browser took %s to start", l.timings.browser.Round(time.Millisecond))
+		l.browserShown = true
+	})
+}
+
+// showStartFailure puts err on the setup banner. When a browser shows it,
+// the launcher stays up until the user quits so the page remains readable.
+func (l *Launcher) showStartFailure(ctx context.Context, err error) error {
+	if l.banner == nil {
+		return err
+	}
+	l.banner.Fail(err)
+	<-l.browserDone
+	if !l.browserShown {
+		return err
+	}
+	fmt.Println(msg("error", err))
+	fmt.Println(msg("setup_failed_wait"))
+	<-ctx.Done()
+	return reportedError{err}
+}
+
+// Shutdown stops PHP and runs the exit steps. consoleClosed means the OS
+// grants only a few seconds, so anything slow is skipped.
+func (l *Launcher) Shutdown(reason string, consoleClosed bool) {
+	fmt.Println(msg("shutting_down"))
+	l.consoleGone = consoleClosed
+	close(l.stopLoops)
+	if l.tray != nil {
+		l.tray.Close()
+	}
+	if l.dataGuard != nil {
+		// Waits out a reset or export in progress so it can't restart PHP
+		// behind us
+		l.dataGuard.Close()
+	}
+	if l.watchdog != nil {
+		l.watchdog.Close()
+	}
+
+	// The app window, if a page of it is open, waits for the exit page.
+	// There's no time for it when the console is closing.
+	tracked := false
+	if l.window != nil && l.Config.ExitPage != "" && !consoleClosed {
+		tracked = l.window.sendExit()
+	}
+
+	// Release the public port so the exit page can take it over
+	l.proxySrv.Close()
+	l.proxySrv = nil
+
+	// Stop PHP first, as a running PHP would keep the work dir locked.
+	// When the console is closing we only have a few seconds, so nothing
+	// gets its grace period.
+	stop := l.servers.Stop
+	if consoleClosed {
+		stop = l.servers.Kill
+	}
+	if err := stop(); err != nil {
+		fmt.Printf("Error stopping server: %v\n", err)
+	}
+	l.servers = nil
+	l.stopSideProcesses(consoleClosed)
+	l.writeSessionSummary(reason)
+
+	// PHP is stopped, so the database can be copied without tearing it
+	if l.Options.ExportData != "" && !consoleClosed {
+		fmt.Println(msg("exporting_data", l.Options.ExportData))
+		if err := exportData(l.Options.ExportData, &l.Config, l.dataDir, resetPaths(&l.Config, l.dataDir, l.dataAppRoot())); err != nil {
+			fmt.Printf("Error exporting demo data: %v\n", err)
+		}
+	}
+
+	// The exit page is served to the tracked window, or else opens on its
+	// own. There's no time for it when the console is closing, and no call
+	// for it when the user closed the window.
+	if !consoleClosed && reason != exitReasonWindowClosed {
+		presentExitPage(&l.Config, l.Options.Browser, l.baseDir, l.bindAddr, reason, tracked)
+	}
+
+	if l.keepsData() {
+		fmt.Println(msg("keeping_data", l.dataDir))
+	} else {
+		fmt.Println(msg("performing_cleanup"))
+	}
+}
+
+// cleanup releases whatever the session still holds. It runs after a
+// normal shutdown as well as after a failed start.
+func (l *Launcher) cleanup() {
+	l.cleanupOnce.Do(func() {
+		if l.proxySrv != nil {
+			l.proxySrv.Close()
+		}
+		if l.control != nil {
+			l.control.Close()
+		}
+		l.servers.Stop()
+		if l.fpmConf != "" {
+			os.Remove(l.fpmConf)
+		}
+		if l.phpIni != "" {
+			os.Remove(l.phpIni)
+		}
+		l.stopSideProcesses(false)
+		// Taken before PHP started, so a failed start has one too
+		if l.resetter != nil {
+			l.resetter.Close()
+		}
+		if l.chooser != nil {
+			l.chooser.Close()
+		}
+		if l.session != nil {
+			l.session.Close()
+		}
+		if l.instance != nil {
+			l.instance.Close()
+		}
+		if l.ownsWorkDir {
+			fmt.Println(msg("removing_work_dir", l.workDir))
+			// PHP and the side processes have been waited for, but
+			// Windows may still hold their files for a moment
+			removeWorkDir(l.workDir, l.consoleGone)
+		}
+		if l.runDir != "" {
+			fmt.Println(msg("removing_work_dir", l.runDir))
+			removeWorkDir(l.runDir, l.consoleGone)
+		}
+		if l.consoleLog != nil {
+			l.consoleLog.Close()
+		}
+	})
+}
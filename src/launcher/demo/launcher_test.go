@@ -0,0 +1,340 @@
+package demo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakePHPEnv makes the test binary act as PHP; its value is the mode.
+const fakePHPEnv = "LAUNCHER_TEST_FAKE_PHP"
+
+// TestFakePHP is not a test: fakeCommand runs the test binary with it as a
+// stand-in for php, answering the preflight check and serving -S.
+func TestFakePHP(t *testing.T) {
+	mode := os.Getenv(fakePHPEnv)
+	if mode == "" {
+		t.Skip("only run by fakeCommand")
+	}
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	for i, arg := range args {
+		switch arg {
+		case "-r":
+			// Version, then the loaded extensions
+			fmt.Println("8.3.0\nctype\nmbstring\nopenssl\ntokenizer\npdo_sqlite")
+			os.Exit(0)
+		case "-S":
+			if mode == "fail" {
+				fmt.Fprintln(os.Stderr, "PHP Fatal error: the fake PHP fails to start")
+				os.Exit(255)
+			}
+			http.ListenAndServe(args[i+1], http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "<html><body>fake demo</body></html>")
+			}))
+			os.Exit(1)
+		}
+	}
+	os.Exit(2)
+}
+
+// fakeCommand runs every process as the fake PHP.
+func fakeCommand(name string, arg ...string) *exec.Cmd {
+	return exec.Command(os.Args[0], append([]string{"-test.run=^TestFakePHP$", "--"}, arg...)...)
+}
+
+// fakeClock runs in real time but can be moved forward, so readiness
+// loops work as they do while expiry is reached without waiting.
+type fakeClock struct {
+	mu      sync.Mutex
+	offset  time.Duration
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.offset)
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := fakeWaiter{at: time.Now().Add(c.offset + d), ch: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	time.AfterFunc(d, c.fire)
+	return w.ch
+}
+
+// Advance moves the clock forward by d and fires the timers due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.offset += d
+	c.mu.Unlock()
+	c.fire()
+}
+
+func (c *fakeClock) fire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().Add(c.offset)
+	kept := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(now) {
+			kept = append(kept, w)
+			continue
+		}
+		w.ch <- now
+	}
+	c.waiters = kept
+}
+
+// testLauncher returns a Launcher for a one-page bundle with the fake PHP
+// in mode and the opened URLs sent to opened.
+func testLauncher(t *testing.T, mode, manifest string, opened chan<- string) *Launcher {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv(fakePHPEnv, mode)
+
+	config, err := decodeManifest([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := []byte("<?php echo 'demo';")
+	sum := sha256.Sum256(index)
+	sums, _ := json.Marshal(map[string]string{"public/index.php": hex.EncodeToString(sum[:])})
+	l := NewLauncher(config, Options{}, t.TempDir())
+	l.Bundle = fstest.MapFS{
+		"bundle/" + checksumsFile: {Data: sums},
+		"bundle/public/index.php": {Data: index},
+	}
+	l.Command = fakeCommand
+	l.OpenURL = func(url string) error {
+		opened <- url
+		return nil
+	}
+	return l
+}
+
+const testManifest = `{
+	"app_name": "Launcher Test",
+	"public_root": "public",
+	"allow_system_php": true,
+	"landing_page_url": "/"%s
+}`
+
+func runLauncher(l *Launcher, ctx context.Context) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- l.Run(ctx) }()
+	return done
+}
+
+// waitServed waits for url to show the app. The setup banner answers it
+// until PHP is up.
+func waitServed(t *testing.T, url string) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		resp, err := loopbackClient.Get(url)
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if strings.Contains(string(body), "fake demo") {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never served the app: %v", url, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestLauncherRunServesAndQuits(t *testing.T) {
+	opened := make(chan string, 1)
+	l := testLauncher(t, "serve", fmt.Sprintf(testManifest, ""), opened)
+	done := runLauncher(l, context.Background())
+
+	var url string
+	select {
+	case url = <-opened:
+	case err := <-done:
+		t.Fatalf("Run returned before opening the browser: %v", err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("the browser was never opened")
+	}
+	waitServed(t, url)
+
+	workDir := l.workDir
+	l.Quit()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run = %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("Run didn't return after Quit")
+	}
+	if _, err := os.Stat(workDir); !os.IsNotExist(err) {
+		t.Errorf("work directory %s left behind: %v", workDir, err)
+	}
+	if conn, err := net.Dial("tcp", l.bindAddr); err == nil {
+		conn.Close()
+		t.Error("the demo still listens after Run returned")
+	}
+}
+
+func TestLauncherRunPHPStartFailure(t *testing.T) {
+	opened := make(chan string, 1)
+	l := testLauncher(t, "fail", fmt.Sprintf(testManifest, `, "startup_timeout_seconds": 5`), opened)
+	// Without a browser showing the error, Run returns it
+	l.OpenURL = func(string) error { return errors.New("no browser in tests") }
+
+	select {
+	case err := <-runLauncher(l, context.Background()):
+		if err == nil {
+			t.Fatal("Run succeeded with a PHP that doesn't start")
+		}
+		if !strings.Contains(err.Error(), "PHP") {
+			t.Errorf("Run = %v, want a PHP start failure", err)
+		}
+	case <-time.After(60 * time.Second):
+		t.Fatal("Run didn't give up on PHP")
+	}
+}
+
+func TestLauncherRunExpires(t *testing.T) {
+	opened := make(chan string, 1)
+	l := testLauncher(t, "serve", fmt.Sprintf(testManifest, `, "allowed_demo_duration_minutes": 1`), opened)
+	clock := &fakeClock{}
+	l.Clock = clock
+	done := runLauncher(l, context.Background())
+
+	select {
+	case url := <-opened:
+		waitServed(t, url)
+	case err := <-done:
+		t.Fatalf("Run returned before opening the browser: %v", err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("the browser was never opened")
+	}
+	// Steps below the sleep gap all count towards the limit
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Run = %v", err)
+			}
+			select {
+			case <-l.expiry.expired:
+			default:
+				t.Error("Run returned without the demo expiring")
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+			clock.Advance(10 * time.Second)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the demo didn't expire")
+		}
+	}
+}
+
+func TestLauncherRunIdleAfterDegradedExpiry(t *testing.T) {
+	opened := make(chan string, 1)
+	l := testLauncher(t, "serve", fmt.Sprintf(testManifest,
+		`, "allowed_demo_duration_minutes": 1, "on_expiry": "readonly", "idle_timeout_minutes": 5`), opened)
+	clock := &fakeClock{}
+	l.Clock = clock
+	done := runLauncher(l, context.Background())
+
+	select {
+	case url := <-opened:
+		waitServed(t, url)
+	case err := <-done:
+		t.Fatalf("Run returned before opening the browser: %v", err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("the browser was never opened")
+	}
+	// Past the expiry, which only degrades the demo, into the idle timeout
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Run = %v", err)
+			}
+			select {
+			case <-l.idle:
+			default:
+				t.Error("Run returned without the demo going idle")
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+			clock.Advance(10 * time.Second)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the expired, read-only demo never stopped for being idle")
+		}
+	}
+}
+
+func TestLauncherSnapshotsDataOnlyForResets(t *testing.T) {
+	for extra, wantSnapshot := range map[string]bool{
+		"":                           false,
+		`, "auto_reset_minutes": 30`: true,
+		`, "idle_timeout_minutes": 30, "idle_action": "reset"`: true,
+	} {
+		opened := make(chan string, 1)
+		l := testLauncher(t, "serve", fmt.Sprintf(testManifest, extra), opened)
+		done := runLauncher(l, context.Background())
+		select {
+		case url := <-opened:
+			waitServed(t, url)
+		case err := <-done:
+			t.Fatalf("%s: Run returned before opening the browser: %v", extra, err)
+		case <-time.After(30 * time.Second):
+			t.Fatalf("%s: the browser was never opened", extra)
+		}
+		if (l.resetter != nil) != wantSnapshot {
+			t.Errorf("manifest with %q: snapshot taken %v, want %v", extra, l.resetter != nil, wantSnapshot)
+		}
+		l.Quit()
+		if err := <-done; err != nil {
+			t.Fatalf("Run = %v", err)
+		}
+		if l.resetter != nil {
+			if _, err := os.Stat(l.resetter.pristine); !os.IsNotExist(err) {
+				t.Errorf("snapshot %s left behind: %v", l.resetter.pristine, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+
+package demo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// systemUILanguage is only needed on Windows; elsewhere LANG already
+// carries the user's language.
+func systemUILanguage() string {
+	return ""
+}
+
+// hostTimezone returns the IANA name of the local time zone, or "". Go
+// only calls it "Local", so the name comes from TZ or from where
+// /etc/localtime points.
+func hostTimezone() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" && !filepath.IsAbs(tz) {
+		if _, err := time.LoadLocation(tz); err == nil {
+			return tz
+		}
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	if data, err := os.ReadFile("/etc/timezone"); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
+
+// hostLocale returns the locale dates should be formatted for, or "".
+func hostLocale() string {
+	for _, v := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if l := normalizeLocale(os.Getenv(v)); l != "" {
+			return l
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		// Apps started from Finder get no LANG
+		if out, err := exec.Command("defaults", "read", "-g", "AppleLocale").Output(); err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	return ""
+}
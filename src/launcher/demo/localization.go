@@ -0,0 +1,75 @@
+package demo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Used when the host's time zone or locale can't be detected.
+const (
+	fallbackTimezone = "UTC"
+	fallbackLocale   = "en_US"
+)
+
+// Placeholders for env var values, like appURLPlaceholder.
+const (
+	timezonePlaceholder = "{{timezone}}"
+	localePlaceholder   = "{{locale}}"
+)
+
+// localization is the time zone and locale the app runs with, so dates
+// and generated data look like the viewer's own.
+type localization struct {
+	Timezone string // IANA name, e.g. Australia/Sydney
+	Locale   string // language_REGION, e.g. en_AU
+}
+
+// hostLocalization takes the manifest's pin_timezone and pin_locale and
+// fills in the rest from this computer, logging what was chosen.
+func hostLocalization(config *Manifest) localization {
+	loc := localization{Timezone: config.PinTimezone, Locale: normalizeLocale(config.PinLocale)}
+	tzFrom, localeFrom := "pinned", "pinned"
+	if loc.Timezone == "" {
+		loc.Timezone, tzFrom = hostTimezone(), "this computer"
+		if loc.Timezone == "" {
+			loc.Timezone, tzFrom = fallbackTimezone, "not detected"
+		}
+	}
+	if loc.Locale == "" {
+		loc.Locale, localeFrom = normalizeLocale(hostLocale()), "this computer"
+		if loc.Locale == "" {
+			loc.Locale, localeFrom = fallbackLocale, "not detected"
+		}
+	}
+	fmt.Printf("Time zone %s (%s), locale %s (%s)\n", loc.Timezone, tzFrom, loc.Locale, localeFrom)
+	return loc
+}
+
+// env returns the variables Laravel reads the localization from. The
+// app locale is just the language, as that's what lang/ folders are
+// named after; Faker wants the region too.
+func (loc localization) env() map[string]string {
+	return map[string]string{
+		"APP_TIMEZONE":     loc.Timezone,
+		"APP_LOCALE":       normalizeLanguage(loc.Locale),
+		"APP_FAKER_LOCALE": loc.Locale,
+	}
+}
+
+// normalizeLocale turns en-AU, en_AU.UTF-8 or en_AU@euro into en_AU. C
+// and POSIX say nothing about the user, so they give "".
+func normalizeLocale(s string) string {
+	if i := strings.IndexAny(s, ".@"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "C" || s == "POSIX" {
+		return ""
+	}
+	parts := strings.Split(strings.ReplaceAll(s, "-", "_"), "_")
+	parts[0] = strings.ToLower(parts[0])
+	if last := len(parts) - 1; last > 0 && len(parts[last]) == 2 {
+		// en_AU, and zh_Hans_CN without the script
+		return parts[0] + "_" + strings.ToUpper(parts[last])
+	}
+	return parts[0]
+}
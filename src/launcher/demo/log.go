@@ -0,0 +1,286 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// launcher.log records what the launcher and the processes it runs print
+// to the console, one leveled record per line:
+//
+//	time=2026-10-14T16:54:38.123+02:00 level=INFO msg="Starting PHP..." source=launcher
+//	time=2026-10-14T16:54:38.456+02:00 level=INFO msg="[200]: GET /" source=php
+//
+// With --log-level debug it also gets records of the launcher's own that
+// the console doesn't show, and with --log-format json it's JSON lines. It's
+// written in the run's stateDir, so what a double-clicked launcher printed
+// outlives its console window.
+const consoleLogFile = "launcher.log"
+
+// The log moves to launcher.1.log on every start and whenever it reaches
+// logMaxBytes; launcher.3.log is the oldest kept.
+const (
+	logMaxBytes = 10 << 20
+	logKeep     = 3
+)
+
+// consoleLogDrain is how long Close waits for output still in the pipe,
+// which a process that outlived the launcher may keep open.
+const consoleLogDrain = 2 * time.Second
+
+// consoleLog copies everything printed to the console into launcher.log
+// and with --quiet holds back all but errors from the console. os.Stdout
+// and os.Stderr become a pipe, so anything writing to them ends up in the
+// log along with the launcher's own messages; processes it starts get
+// processOutput instead, which records where lines came from.
+type consoleLog struct {
+	stdout, stderr *os.File // the console
+	pipe           *os.File
+	file           *rotatingFile // nil for a quiet run that publishes none
+	logger         *slog.Logger
+	quiet          bool
+	done           chan struct{}
+}
+
+// consoleLogPath is the launcher.log this run wrote, if any, for the
+// error dialog.
+var consoleLogPath string
+
+// activeLog is launcher.log while this run writes it.
+var activeLog atomic.Pointer[consoleLog]
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// logger returns launcher.log's logger, which discards records while the
+// log isn't open.
+func logger() *slog.Logger {
+	if c := activeLog.Load(); c != nil {
+		return c.logger
+	}
+	return discardLogger
+}
+
+// startConsoleLog starts writing launcher.log, or only quiets the console
+// in a run that publishes none.
+func (l *Launcher) startConsoleLog() {
+	var path string
+	if dir := l.stateDir(); dir != "" {
+		path = filepath.Join(dir, consoleLogFile)
+	} else if !l.Options.Quiet {
+		return
+	}
+	log, err := startConsoleLog(path, l.Options.LogLevel, l.Options.LogJSON, l.Options.Quiet)
+	if err != nil {
+		fmt.Printf("Warning: %s can't be written: %v\n", consoleLogFile, err)
+		return
+	}
+	l.consoleLog = log
+}
+
+func startConsoleLog(path string, level slog.Level, json, quiet bool) (*consoleLog, error) {
+	c := &consoleLog{
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		logger: discardLogger,
+		quiet:  quiet,
+		done:   make(chan struct{}),
+	}
+	if path != "" {
+		file, err := openRotatingFile(path, logMaxBytes, logKeep)
+		if err != nil {
+			return nil, err
+		}
+		c.file = file
+		consoleLogPath = path
+		opts := &slog.HandlerOptions{Level: level}
+		if json {
+			c.logger = slog.New(slog.NewJSONHandler(file, opts))
+		} else {
+			c.logger = slog.New(slog.NewTextHandler(file, opts))
+		}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		c.closeFile()
+		return nil, err
+	}
+	c.pipe = w
+	go func() {
+		defer close(c.done)
+		lines := &lineWriter{fn: func(line string) { c.logLine("launcher", line) }}
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				// A launcher started without a console has nowhere to
+				// print, which mustn't stop the log
+				if !quiet {
+					c.stdout.Write(buf[:n])
+				}
+				lines.Write(buf[:n])
+			}
+			if err != nil {
+				lines.Flush()
+				return
+			}
+		}
+	}()
+	os.Stdout, os.Stderr = w, w
+	activeLog.Store(c)
+	return c, nil
+}
+
+// Close puts the console back, waits for the output still on its way and
+// closes the log.
+func (c *consoleLog) Close() {
+	os.Stdout, os.Stderr = c.stdout, c.stderr
+	c.pipe.Close()
+	select {
+	case <-c.done:
+	case <-time.After(consoleLogDrain):
+	}
+	activeLog.CompareAndSwap(c, nil)
+	c.closeFile()
+}
+
+func (c *consoleLog) closeFile() {
+	if c.file != nil {
+		c.file.Close()
+	}
+}
+
+// logLine records a line of console output from source. A quiet console
+// gets it here if it's an error.
+func (c *consoleLog) logLine(source, line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	level := lineLevel(line)
+	if c.quiet && level >= slog.LevelError {
+		fmt.Fprintln(c.stdout, line)
+	}
+	c.logger.Log(context.Background(), level, line, "source", source)
+}
+
+// lineLevel tells errors and warnings from the rest by how the launcher
+// and PHP put them.
+func lineLevel(line string) slog.Level {
+	switch {
+	case strings.HasPrefix(line, "Error"), strings.Contains(line, "Fatal error"):
+		return slog.LevelError
+	case strings.HasPrefix(line, "Warning"), strings.Contains(line, "PHP Warning"):
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// processOutput is the stdout and stderr of a process the launcher runs:
+// the console, and launcher.log as records from source. Use the same
+// writer for both, which exec.Cmd then never writes to at once.
+func processOutput(source string) io.Writer {
+	c := activeLog.Load()
+	if c == nil {
+		return os.Stdout
+	}
+	lines := &lineWriter{fn: func(line string) { c.logLine(source, line) }}
+	if c.quiet {
+		return lines
+	}
+	return io.MultiWriter(c.stdout, lines)
+}
+
+// ParseLogFormat reads --log-format and reports whether it's JSON.
+func ParseLogFormat(s string) (bool, error) {
+	switch s {
+	case "text":
+		return false, nil
+	case "json":
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown --log-format %q (use text or json)", s)
+}
+
+// ParseLogLevel reads --log-level.
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	switch strings.ToLower(s) {
+	case "debug", "info", "warn", "error":
+		return level, level.UnmarshalText([]byte(s))
+	}
+	return level, fmt.Errorf("unknown --log-level %q (use debug, info, warn or error)", s)
+}
+
+// rotatingFile is a log file that moves to name.1.log, name.2.log and so
+// on, up to keep, when it's opened and when it would grow past max bytes.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	max  int64
+	keep int
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(path string, max int64, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, max: max, keep: keep}
+	if err := r.rotate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) rotated(i int) string {
+	ext := filepath.Ext(r.path)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(r.path, ext), i, ext)
+}
+
+func (r *rotatingFile) rotate() error {
+	if r.f != nil {
+		r.f.Close()
+	}
+	os.Remove(r.rotated(r.keep))
+	for i := r.keep - 1; i > 0; i-- {
+		os.Rename(r.rotated(i), r.rotated(i+1))
+	}
+	os.Rename(r.path, r.rotated(1))
+	f, err := os.Create(r.path)
+	if err != nil {
+		r.f = nil
+		return err
+	}
+	r.f, r.size = f, 0
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f != nil && r.size > 0 && r.size+int64(len(p)) > r.max {
+		r.rotate()
+	}
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,267 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// For macOS, pack --out builds an .app bundle: the launcher in
+// Contents/MacOS, the manifest in Contents/Resources next to the icon,
+// and an Info.plist naming them. Finder then shows it as an app with the
+// product icon, rather than a bare executable that opens a terminal.
+
+// appBundleExecutable is where the launcher goes in the .app bundle app,
+// named after the bundle.
+func appBundleExecutable(app string) string {
+	return filepath.Join(app, "Contents", "MacOS", strings.TrimSuffix(filepath.Base(app), ".app"))
+}
+
+// enclosingAppBundle returns the .app bundle exe runs from, if any.
+func enclosingAppBundle(exe string) (string, bool) {
+	macOS := filepath.Dir(exe)
+	contents := filepath.Dir(macOS)
+	app := filepath.Dir(contents)
+	if filepath.Base(macOS) != "MacOS" || filepath.Base(contents) != "Contents" || !strings.HasSuffix(app, ".app") {
+		return "", false
+	}
+	return app, true
+}
+
+// appBundleResources is the folder of app holding the manifest and icon.
+func appBundleResources(app string) string {
+	return filepath.Join(app, "Contents", "Resources")
+}
+
+// appBundleIcon is the icon's name in Contents/Resources.
+const appBundleIcon = "AppIcon.icns"
+
+// minMacOSVersion is the oldest macOS the Go toolchain builds for.
+const minMacOSVersion = "12.0"
+
+// writeAppBundle starts app with the Info.plist and, from icon_path in
+// the staged bundle, the icon; the launcher is built into it afterwards.
+// As on Windows, an icon that can't be used is only a warning.
+func writeAppBundle(config *Manifest, bundleDir, app string) error {
+	resources := appBundleResources(app)
+	if err := os.MkdirAll(resources, 0755); err != nil {
+		return err
+	}
+	icon := ""
+	if config.IconPath != "" {
+		err := writeICNS(filepath.Join(bundleDir, filepath.FromSlash(config.IconPath)), filepath.Join(resources, appBundleIcon))
+		if err != nil {
+			fmt.Printf("Warning: icon_path %s can't be the app's icon: %v\n", config.IconPath, err)
+		} else {
+			icon = appBundleIcon
+		}
+	}
+	plist := infoPlist(config, filepath.Base(appBundleExecutable(app)), icon)
+	return os.WriteFile(filepath.Join(app, "Contents", "Info.plist"), plist, 0644)
+}
+
+// infoPlist describes the app to Finder and Launch Services: app_name,
+// app_version, its executable and icon.
+func infoPlist(config *Manifest, executable, icon string) []byte {
+	name := config.AppName
+	if name == "" {
+		name = executable
+	}
+	version := config.AppVersion
+	if version == "" {
+		version = "1.0"
+	}
+	keys := [][2]string{
+		{"CFBundleDevelopmentRegion", "en"},
+		{"CFBundleDisplayName", name},
+		{"CFBundleExecutable", executable},
+		{"CFBundleIdentifier", appBundleID(config, executable)},
+		{"CFBundleInfoDictionaryVersion", "6.0"},
+		{"CFBundleName", name},
+		{"CFBundlePackageType", "APPL"},
+		{"CFBundleShortVersionString", version},
+		{"CFBundleVersion", version},
+		{"LSMinimumSystemVersion", minMacOSVersion},
+	}
+	if icon != "" {
+		keys = append(keys, [2]string{"CFBundleIconFile", icon})
+	}
+	if config.Publisher != "" {
+		keys = append(keys, [2]string{"NSHumanReadableCopyright", config.Publisher})
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	for _, kv := range keys {
+		fmt.Fprintf(&b, "\t<key>%s</key>\n\t<string>", kv[0])
+		xml.EscapeText(&b, []byte(kv[1]))
+		b.WriteString("</string>\n")
+	}
+	b.WriteString("\t<key>NSHighResolutionCapable</key>\n\t<true/>\n</dict>\n</plist>\n")
+	return b.Bytes()
+}
+
+var bundleIDUnsafe = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+
+// appBundleID is a reverse-DNS identifier from publisher and app_name,
+// e.g. com.acme-inc.crm-demo, which macOS keys the app's settings and
+// permissions by.
+func appBundleID(config *Manifest, executable string) string {
+	part := func(s string) string {
+		return strings.Trim(strings.ToLower(bundleIDUnsafe.ReplaceAllString(s, "-")), "-")
+	}
+	vendor, app := part(config.Publisher), part(config.AppName)
+	if vendor == "" {
+		vendor = "laravel-demo"
+	}
+	if app == "" {
+		app = part(executable)
+	}
+	return "com." + vendor + "." + app
+}
+
+// icnsTypes are the PNG icon types of .icns files by size in pixels.
+var icnsTypes = []struct {
+	size int
+	typ  string
+}{
+	{16, "icp4"}, {32, "icp5"}, {64, "icp6"}, {128, "ic07"}, {256, "ic08"}, {512, "ic09"}, {1024, "ic10"},
+}
+
+// writeICNS writes the icon file at src as an .icns to dest: an .icns is
+// copied, a PNG or the largest PNG image of an .ico is scaled to every
+// size up to its own.
+func writeICNS(src, dest string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(data, []byte("icns")) {
+		return os.WriteFile(dest, data, 0644)
+	}
+	img, err := loadPackIcon(src)
+	if err != nil {
+		return err
+	}
+	largest := img.Bounds().Dx()
+	if img.Bounds().Dy() > largest {
+		largest = img.Bounds().Dy()
+	}
+
+	var body []byte
+	for _, t := range icnsTypes {
+		if t.size > largest && t.size > 16 {
+			break
+		}
+		var scaled bytes.Buffer
+		if err := png.Encode(&scaled, scaleIcon(img, t.size)); err != nil {
+			return err
+		}
+		body = append(body, t.typ...)
+		body = binary.BigEndian.AppendUint32(body, uint32(8+scaled.Len()))
+		body = append(body, scaled.Bytes()...)
+	}
+	icns := append([]byte("icns"), binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))...)
+	return os.WriteFile(dest, append(icns, body...), 0644)
+}
+
+// largestICOImage returns the largest of the PNG images in an .ico file.
+func largestICOImage(data []byte) ([]byte, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint16(data[2:]) != 1 {
+		return nil, errors.New("not an .ico file")
+	}
+	var best []byte
+	bestSize := -1
+	for i := 0; i < int(binary.LittleEndian.Uint16(data[4:])); i++ {
+		e := 6 + 16*i
+		if len(data) < e+16 {
+			break
+		}
+		size := binary.LittleEndian.Uint32(data[e+8:])
+		offset := binary.LittleEndian.Uint32(data[e+12:])
+		if uint64(offset)+uint64(size) > uint64(len(data)) {
+			continue
+		}
+		img := data[offset : offset+size]
+		// 0 stands for 256
+		width := int(data[e])
+		if width == 0 {
+			width = 256
+		}
+		if bytes.HasPrefix(img, []byte("\x89PNG")) && width > bestSize {
+			best, bestSize = img, width
+		}
+	}
+	if best == nil {
+		return nil, errors.New("the .ico file has no PNG image; use a PNG or .icns")
+	}
+	return best, nil
+}
+
+// scaleIcon fits img into a transparent square of size pixels, averaging
+// the source pixels each one covers.
+func scaleIcon(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	scale := float64(size) / float64(w)
+	if h > w {
+		scale = float64(size) / float64(h)
+	}
+	dw, dh := int(float64(w)*scale+0.5), int(float64(h)*scale+0.5)
+	left, top := (size-dw)/2, (size-dh)/2
+	out := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			if x1 == x0 {
+				x1++
+			}
+			// Premultiplied sums, so transparent pixels don't darken edges
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			out.SetNRGBA(left+x, top+y, color.NRGBA{
+				R: uint8(r * 0xff / a), G: uint8(g * 0xff / a), B: uint8(bl * 0xff / a), A: uint8((a / n) >> 8),
+			})
+		}
+	}
+	return out
+}
+
+// signAppBundle gives app an ad-hoc signature where codesign exists, i.e.
+// when packing on a Mac, which Apple silicon needs once the bundle around
+// the launcher's own signature changed. --codesign-identity signs with a
+// Developer ID instead.
+func signAppBundle(app string) {
+	if _, err := exec.LookPath("codesign"); err != nil {
+		return
+	}
+	if out, err := exec.Command("codesign", "--force", "--deep", "--sign", "-", app).CombinedOutput(); err != nil {
+		fmt.Printf("Warning: ad-hoc signing %s failed: %v\n%s", app, err, out)
+	}
+}
@@ -0,0 +1,98 @@
+package demo
+
+import "encoding/json"
+
+// Manifest matches the structure of manifest.json
+type Manifest struct {
+	AppName                    string            `json:"app_name"`
+	AppVersion                 string            `json:"app_version"`
+	WindowWidth                int               `json:"window_width"`
+	WindowHeight               int               `json:"window_height"`
+	StartMaximized             bool              `json:"start_maximized"`
+	AppWindow                  bool              `json:"app_window"`
+	CloseWithWindow            *bool             `json:"close_with_window"` // on unless false
+	TrayIcon                   *bool             `json:"tray_icon"`         // on unless false
+	PHPPort                    int               `json:"php_port"`
+	PortFallback               string            `json:"port_fallback"`
+	ListenAddress              string            `json:"listen_address"`
+	DBType                     string            `json:"db_type"`
+	DBPath                     string            `json:"db_path"`
+	EnvVars                    map[string]string `json:"env_vars"`
+	AppKey                     string            `json:"app_key"`
+	DemoModeEnvKey             string            `json:"demo_mode_env_key"`
+	DemoModeEnvValue           string            `json:"demo_mode_env_value"`
+	DemoModeEnv                map[string]string `json:"demo_mode_env"`
+	SplashScreenImage          string            `json:"splash_screen_image"`
+	IconPath                   string            `json:"icon_path"`
+	LandingPageURL             string            `json:"landing_page_url"`
+	PHPBinaryPath              string            `json:"php_binary_path"`
+	PHPBinaries                map[string]string `json:"php_binaries"`  // by os/arch or os
+	PHPDownloads               phpDownloads      `json:"php_downloads"` // by os/arch or os
+	PHPRequirements            PHPRequirements   `json:"php_requirements"`
+	PHPIni                     string            `json:"php_ini"`
+	PublicRoot                 string            `json:"public_root"`
+	AppRoot                    string            `json:"app_root"`
+	ArtisanPath                string            `json:"artisan_path"`
+	ScrambleCode               bool              `json:"scramble_code"`
+	ScramblePluginPath         string            `json:"scramble_plugin_path"`
+	AllowSystemPHP             bool              `json:"allow_system_php"`
+	CleanOnExit                *bool             `json:"clean_on_exit"` // on unless false
+	CreateShortcuts            bool              `json:"create_shortcuts"`
+	UninstallShortcut          bool              `json:"uninstall_shortcut"`
+	RegisterUninstaller        bool              `json:"register_uninstaller"`
+	Publisher                  string            `json:"publisher"`
+	AllowedDemoDurationMinutes int               `json:"allowed_demo_duration_minutes"`
+	Apps                       []json.RawMessage `json:"apps"`
+	Profiles                   manifestProfiles  `json:"profiles"`
+	Dir                        string            `json:"dir"`
+	Description                string            `json:"description"`
+	VerifyExtraction           bool              `json:"verify_extraction"`
+	ExtractionCache            bool              `json:"extraction_cache"`
+	AutoResetMinutes           int               `json:"auto_reset_minutes"`
+	IdleTimeoutMinutes         int               `json:"idle_timeout_minutes"`
+	IdleAction                 string            `json:"idle_action"`
+	ExitPage                   string            `json:"exit_page"`
+	ExitPageGraceSeconds       int               `json:"exit_page_grace_seconds"`
+	ContactURL                 string            `json:"contact_url"`
+	MaxRequestBodyMB           int               `json:"max_request_body_mb"`
+	RequestTimeoutSeconds      int               `json:"request_timeout_seconds"`
+	MaxConcurrentRequests      int               `json:"max_concurrent_requests"`
+	Offline                    bool              `json:"offline"`
+	SkipLandingCheck           bool              `json:"skip_landing_check"`
+	ServerMode                 string            `json:"server_mode"`
+	FPMWorkers                 int               `json:"fpm_workers"`
+	PHPWorkers                 int               `json:"php_workers"`
+	StartupTimeoutSeconds      int               `json:"startup_timeout_seconds"`
+	ShutdownGraceSeconds       int               `json:"shutdown_grace_seconds"`
+	SetupCommands              [][]string        `json:"setup_commands"`
+	SideProcesses              []SideProcess     `json:"side_processes"`
+	ProxyRoutes                map[string]string `json:"proxy_routes"`
+	ProxyRules                 []ProxyRule       `json:"proxy_rules"`
+	ResponseHeaders            map[string]string `json:"response_headers"`
+	Tour                       *Tour             `json:"tour"`
+	WarmupPaths                []string          `json:"warmup_paths"`
+	WarmupArtisanCaches        bool              `json:"warmup_artisan_caches"`
+	MaxMemoryMB                int               `json:"max_memory_mb"`
+	CPUGraceSeconds            int               `json:"cpu_grace_seconds"`
+	WatchdogAction             string            `json:"watchdog_action"`
+	Language                   string            `json:"language"`
+	Messages                   map[string]string `json:"messages"`
+	SessionSummary             *bool             `json:"session_summary"` // on unless false
+	SessionSummaryDir          string            `json:"session_summary_dir"`
+	SandboxNetwork             bool              `json:"sandbox_network"`
+	SandboxEnvOverrides        map[string]string `json:"sandbox_env_overrides"`
+	PausePage                  string            `json:"pause_page"`
+	PauseStopsTimer            bool              `json:"pause_stops_timer"`
+	ExpiryCountsSleep          bool              `json:"expiry_counts_sleep"`
+	EULAPath                   string            `json:"eula_path"`
+	EULAReacceptOnUpdate       bool              `json:"eula_reaccept_on_update"`
+	MaxWorkDirMB               int               `json:"max_workdir_mb"`
+	QuotaAction                string            `json:"quota_action"`
+	PrunablePaths              []string          `json:"prunable_paths"`
+	SupportURL                 string            `json:"support_url"`
+	Accessibility              Accessibility     `json:"accessibility"`
+	Deterministic              Deterministic     `json:"deterministic"`
+	OnExpiry                   string            `json:"on_expiry"`
+	PinTimezone                string            `json:"pin_timezone"`
+	PinLocale                  string            `json:"pin_locale"`
+}
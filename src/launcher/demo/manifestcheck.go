@@ -0,0 +1,257 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// manifestError lists everything wrong with a manifest, so the vendor can
+// fix it in one go instead of one error per build.
+type manifestError struct {
+	problems []string
+}
+
+func (e *manifestError) Error() string {
+	if len(e.problems) == 1 {
+		return e.problems[0]
+	}
+	return fmt.Sprintf("%d problems:\n  %s", len(e.problems), strings.Join(e.problems, "\n  "))
+}
+
+// decodeManifest parses manifest.json strictly. Unknown keys, e.g. a
+// misspelt "php_prot", and values of the wrong type are reported with
+// their JSON path instead of silently becoming zero values, then the
+// result goes through ValidateManifest, once for a suite's shared settings
+// and once for each of its apps and profiles.
+func decodeManifest(data []byte) (Manifest, error) {
+	var config Manifest
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return config, &manifestError{[]string{jsonSyntaxProblem(data, err)}}
+	}
+	var problems []string
+	checkJSONValue(raw, reflect.TypeOf(config), "", &problems)
+	if len(problems) > 0 {
+		return config, &manifestError{problems}
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, &manifestError{[]string{err.Error()}}
+	}
+
+	problems = validationProblems(ValidateManifest(&config), "")
+	apps, err := appEntries(&config)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, app := range apps {
+		selected, err := SelectApp(&config, app.AppName)
+		if err == nil {
+			err = ValidateManifest(&selected)
+		}
+		problems = append(problems, validationProblems(err, fmt.Sprintf("app %q: ", app.AppName))...)
+	}
+	for _, name := range sortedProfileNames(&config) {
+		selected := config
+		err := ApplyProfile(&selected, config.Profiles[name])
+		if err == nil {
+			err = ValidateManifest(&selected)
+		}
+		problems = append(problems, validationProblems(err, fmt.Sprintf("profile %q: ", name))...)
+	}
+	if len(problems) > 0 {
+		return config, &manifestError{problems}
+	}
+	return config, nil
+}
+
+func validationProblems(err error, prefix string) []string {
+	if err == nil {
+		return nil
+	}
+	var list []string
+	var merr *manifestError
+	if errors.As(err, &merr) {
+		list = merr.problems
+	} else {
+		list = []string{err.Error()}
+	}
+	problems := make([]string, len(list))
+	for i, p := range list {
+		problems[i] = prefix + p
+	}
+	return problems
+}
+
+// jsonSyntaxProblem points at the line and column of a JSON syntax error.
+func jsonSyntaxProblem(data []byte, err error) string {
+	var syntax *json.SyntaxError
+	if !errors.As(err, &syntax) {
+		return err.Error()
+	}
+	before := data[:syntax.Offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("line %d, column %d: %v", line, column, err)
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// checkJSONValue compares a decoded JSON value with the Go type it will be
+// unmarshalled into.
+func checkJSONValue(v interface{}, t reflect.Type, path string, problems *[]string) {
+	if v == nil {
+		return // null keeps the default
+	}
+	if t == rawMessageType {
+		// Only apps entries are kept raw, and they are manifests themselves
+		t = reflect.TypeOf(Manifest{})
+	}
+	wrong := func(want string) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, want, jsonKind(v)))
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		checkJSONValue(v, t.Elem(), path, problems)
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			wrong("an object")
+			return
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedObjectKeys(obj) {
+			ft, ok := fields[key]
+			if !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: unknown key%s", joinJSONPath(path, key), suggestKey(key, fields)))
+				continue
+			}
+			checkJSONValue(obj[key], ft, joinJSONPath(path, key), problems)
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			wrong("an object")
+			return
+		}
+		for _, key := range sortedObjectKeys(obj) {
+			checkJSONValue(obj[key], t.Elem(), joinJSONPath(path, key), problems)
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := v.([]interface{})
+		if !ok {
+			wrong("a list")
+			return
+		}
+		for i, item := range list {
+			checkJSONValue(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			wrong("a string")
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			wrong("true or false")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			wrong("a whole number")
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := v.(float64); !ok {
+			wrong("a number")
+		}
+	}
+}
+
+func jsonKind(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("the string %q", v)
+	case float64:
+		return fmt.Sprintf("the number %v", v)
+	case bool:
+		return fmt.Sprintf("%v", v)
+	case []interface{}:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
+
+// jsonFields maps the JSON keys of struct type t to their field types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func sortedObjectKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// suggestKey names the known key closest to a misspelt one, if any is
+// close enough to be what was meant.
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		d := editDistance(strings.ToLower(key), name)
+		if d < bestDist || d == bestDist && best != "" && name < best {
+			best, bestDist = name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf("; did you mean %q?", best)
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,70 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"../manifestfmt"
+)
+
+// manifestNames are the manifest files the launcher looks for, in order.
+// YAML and TOML allow comments; manifestfmt converts them to JSON, which
+// is checked like a manifest.json.
+var manifestNames = []string{"manifest.json", "manifest.yaml", "manifest.yml", "manifest.toml"}
+
+// findManifest returns the manifest in dir, or "" if there is none. A
+// leftover manifest in another format is reported, not silently merged.
+func findManifest(dir string) string {
+	var found []string
+	for _, name := range manifestNames {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			found = append(found, p)
+		}
+	}
+	if len(found) == 0 {
+		return ""
+	}
+	if len(found) > 1 {
+		fmt.Printf("Warning: %s has several manifests; using %s and ignoring %s\n", dir, filepath.Base(found[0]), strings.Join(found[1:], ", "))
+	}
+	return found[0]
+}
+
+// ManifestPath is where the launcher at exePath reads its manifest from:
+// next to the executable, inside its macOS .app bundle, else the current
+// dir (mostly for dev). A --serve-dir checkout may bring its own. exePath
+// is "" when the executable can't be found.
+func ManifestPath(exePath, serveDir string) string {
+	var manifestPath string
+	if exePath != "" {
+		manifestPath = findManifest(filepath.Dir(exePath))
+		// In a macOS .app bundle, where pack puts it
+		if app, ok := enclosingAppBundle(exePath); ok && manifestPath == "" {
+			manifestPath = findManifest(appBundleResources(app))
+		}
+	}
+	if manifestPath == "" {
+		if manifestPath = findManifest("."); manifestPath == "" {
+			manifestPath = "manifest.json"
+		}
+	}
+	if serveDir != "" {
+		if p := serveDirManifest(serveDir); p != "" {
+			manifestPath = p
+		}
+	}
+	return manifestPath
+}
+
+// DecodeManifestFile is decodeManifest for a manifest in any of the
+// supported formats.
+func DecodeManifestFile(file string, data []byte) (Manifest, error) {
+	converted, err := manifestfmt.ToJSON(file, data)
+	if err != nil {
+		return Manifest{}, &manifestError{[]string{err.Error()}}
+	}
+	return decodeManifest(converted)
+}
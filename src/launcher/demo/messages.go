@@ -0,0 +1,110 @@
+package demo
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// messageFS holds one JSON catalog per language, keyed by message ID.
+//
+//go:embed messages/*.json
+var messageFS embed.FS
+
+const defaultLanguage = "en"
+
+// catalog resolves message IDs in one language. Lookups fall through the
+// manifest's overrides, the language's catalog and finally English.
+type catalog struct {
+	lang      string
+	overrides map[string]string
+	strings   map[string]string
+	english   map[string]string
+}
+
+// messages is English until SetLanguage has seen the manifest.
+var messages = newCatalog(defaultLanguage, nil)
+
+func newCatalog(lang string, overrides map[string]string) *catalog {
+	english := loadMessages(defaultLanguage)
+	c := &catalog{lang: lang, overrides: overrides, strings: english, english: english}
+	if lang != defaultLanguage {
+		c.strings = loadMessages(lang)
+	}
+	return c
+}
+
+// loadMessages returns the embedded catalog for lang, or nil if there is
+// none.
+func loadMessages(lang string) map[string]string {
+	data, err := messageFS.ReadFile("messages/" + lang + ".json")
+	if err != nil {
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		panic(fmt.Sprintf("messages/%s.json: %v", lang, err))
+	}
+	return m
+}
+
+// SetLanguage picks the language for all further messages: the manifest's
+// language, then the environment's locale, then the system UI language.
+func SetLanguage(config *Manifest) {
+	candidates := []string{config.Language, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG"), systemUILanguage()}
+	lang := defaultLanguage
+	for _, c := range candidates {
+		if c = normalizeLanguage(c); c != "" && loadMessages(c) != nil {
+			lang = c
+			break
+		}
+	}
+	if config.Language != "" && lang != normalizeLanguage(config.Language) {
+		fmt.Printf("Language %q is not available, using %s.\n", config.Language, lang)
+	}
+	messages = newCatalog(lang, config.Messages)
+}
+
+// normalizeLanguage reduces a locale such as de_DE.UTF-8 or ja-JP to its
+// language code.
+func normalizeLanguage(locale string) string {
+	locale = strings.ToLower(locale)
+	if i := strings.IndexAny(locale, "_-.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "c" || locale == "posix" {
+		return ""
+	}
+	return locale
+}
+
+// has reports whether key is a known message ID.
+func (c *catalog) has(key string) bool {
+	_, ok := c.english[key]
+	return ok
+}
+
+func (c *catalog) lookup(key string) string {
+	if s, ok := c.overrides[key]; ok {
+		return s
+	}
+	if s, ok := c.strings[key]; ok {
+		return s
+	}
+	if s, ok := c.english[key]; ok {
+		return s
+	}
+	return key
+}
+
+// msg returns the message key in the current language, formatted with
+// args like fmt.Sprintf.
+func msg(key string, args ...interface{}) string {
+	s := messages.lookup(key)
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
@@ -0,0 +1,94 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// defaultListenAddress is used when the manifest does not set listen_address.
+const defaultListenAddress = "127.0.0.1"
+
+// listenHost returns the literal IP the server binds to. Hostnames are
+// rejected on purpose: "localhost" may resolve to ::1 or 127.0.0.1
+// depending on the machine, which is exactly what we need to avoid.
+func listenHost(config *Manifest) (string, error) {
+	host := config.ListenAddress
+	if host == "" {
+		return defaultListenAddress, nil
+	}
+	// Accept "[::1]" as well as "::1"
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", fmt.Errorf("listen_address %q must be a literal IP address such as 127.0.0.1 or ::1", config.ListenAddress)
+	}
+	return ip.String(), nil
+}
+
+// serverURL builds the base URL for host and port, bracketing IPv6 literals.
+func serverURL(host string, port int) string {
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// getFreePort asks the OS for a free port on the given literal host.
+func getFreePort(host string) (int, error) {
+	addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return 0, err
+	}
+	l, err := net.ListenTCP("tcp", addr)
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// port_fallback values: what happens when a pinned php_port is taken.
+const (
+	portFallbackFail = "fail" // the default: say who has it and stop
+	portFallbackNext = "next" // the next free port above it
+	portFallbackAny  = "any"  // whatever port the OS hands out
+)
+
+// portFallbackTries bounds the search of "next".
+const portFallbackTries = 20
+
+// addrInUse tells whether a listen failed because the port is taken.
+// Windows also refuses ports it reserves for Hyper-V and the like with
+// WSAEACCES, which a fallback port gets around just the same.
+func addrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE) ||
+		errors.Is(err, syscall.Errno(10048)) || // WSAEADDRINUSE
+		errors.Is(err, syscall.Errno(10013)) // WSAEACCES
+}
+
+// listenFallback finds another port for a taken one, per port_fallback.
+func listenFallback(host string, port int, fallback string) (net.Listener, error) {
+	switch fallback {
+	case portFallbackNext:
+		for p := port + 1; p <= port+portFallbackTries && p <= 65535; p++ {
+			l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(p)))
+			if err == nil || !addrInUse(err) {
+				return l, err
+			}
+		}
+		return nil, fmt.Errorf("ports %d to %d are all in use", port, min(port+portFallbackTries, 65535))
+	case portFallbackAny:
+		return net.Listen("tcp", net.JoinHostPort(host, "0"))
+	}
+	return nil, fmt.Errorf("port %d is in use", port)
+}
+
+// portTakenError explains a taken port to the user, naming the program
+// holding it where the OS says.
+func portTakenError(port int) error {
+	if owner := portOwner(port); owner != "" {
+		return errors.New(msg("port_in_use", port, owner))
+	}
+	return errors.New(msg("port_in_use_unknown", port))
+}
@@ -0,0 +1,148 @@
+package demo
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// outboundDialTimeout keeps a blocked corporate network from stalling
+	// a request for the OS's full connect timeout.
+	outboundDialTimeout = 3 * time.Second
+	// outboundRequestTimeout is the deadline of a whole request.
+	outboundRequestTimeout = 10 * time.Second
+)
+
+// newOutboundClient returns the client for every request the launcher
+// itself sends to the outside world. It goes through the proxy from
+// HTTP(S)_PROXY/NO_PROXY or, without those, the system's proxy settings.
+// In offline mode it refuses anything but loopback, so no code path can
+// phone home even by accident. Loopback requests such as the landing check
+// use loopbackClient instead, which never uses a proxy.
+func newOutboundClient(offline bool) *http.Client {
+	var transport http.RoundTripper = &http.Transport{
+		Proxy: outboundProxy,
+		DialContext: (&net.Dialer{
+			Timeout:   outboundDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   outboundDialTimeout,
+		ResponseHeaderTimeout: outboundRequestTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+	if offline {
+		transport = offlineTransport{transport}
+	}
+	return &http.Client{Transport: transport, Timeout: outboundRequestTimeout}
+}
+
+// outboundProxy picks the proxy for r: none for loopback, the environment
+// when it names one, the system settings otherwise.
+func outboundProxy(r *http.Request) (*url.URL, error) {
+	if isLoopbackHost(r.URL.Hostname()) {
+		return nil, nil
+	}
+	for _, k := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"} {
+		if os.Getenv(k) != "" {
+			return http.ProxyFromEnvironment(r)
+		}
+	}
+	return systemProxy(r.URL)
+}
+
+// parseProxyList picks the entry for scheme from a proxy list in the
+// Windows format: "host:port", or "http=host:port;https=host:port".
+func parseProxyList(list, scheme string) *url.URL {
+	var fallback string
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ';' || r == ' ' }) {
+		if k, v, ok := strings.Cut(entry, "="); ok {
+			if strings.EqualFold(k, scheme) {
+				return proxyURL(v)
+			}
+			if strings.EqualFold(k, "http") && fallback == "" {
+				fallback = v
+			}
+			continue
+		}
+		return proxyURL(entry)
+	}
+	if fallback != "" {
+		return proxyURL(fallback)
+	}
+	return nil
+}
+
+func proxyURL(s string) *url.URL {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// proxyBypassed reports whether host matches a Windows proxy bypass list,
+// which holds host patterns with * wildcards and "<local>" for names
+// without a dot.
+func proxyBypassed(host, list string) bool {
+	for _, pattern := range strings.FieldsFunc(list, func(r rune) bool { return r == ';' || r == ' ' }) {
+		switch {
+		case pattern == "<local>":
+			if !strings.Contains(host, ".") {
+				return true
+			}
+		case wildcardMatch(strings.ToLower(pattern), strings.ToLower(host)):
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch matches s against a pattern where * stands for any run of
+// characters.
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(s, part)
+		}
+		j := strings.Index(s, part)
+		if j < 0 {
+			return false
+		}
+		s = s[j+len(part):]
+	}
+	return s == ""
+}
+
+// offlineTransport fails requests to non-loopback hosts.
+type offlineTransport struct {
+	next http.RoundTripper
+}
+
+func (t offlineTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !isLoopbackHost(r.URL.Hostname()) {
+		return nil, fmt.Errorf("offline mode: refusing request to %s", r.URL.Host)
+	}
+	return t.next.RoundTrip(r)
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,97 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// externalGuard fails the test on a request to anything but loopback and
+// passes the others on.
+type externalGuard struct {
+	t    *testing.T
+	next http.RoundTripper
+
+	mu   sync.Mutex
+	seen []string
+}
+
+func (g *externalGuard) RoundTrip(r *http.Request) (*http.Response, error) {
+	g.mu.Lock()
+	g.seen = append(g.seen, r.URL.Host)
+	g.mu.Unlock()
+	if !isLoopbackHost(r.URL.Hostname()) {
+		g.t.Errorf("offline mode sent a request to %s", r.URL)
+		return nil, fmt.Errorf("external request to %s in a test", r.URL.Host)
+	}
+	return g.next.RoundTrip(r)
+}
+
+// guardOutbound puts an externalGuard under l's outbound client, which
+// offline mode must have wrapped, and under http.DefaultTransport for any
+// request that bypasses the client.
+func guardOutbound(t *testing.T, l *Launcher) *externalGuard {
+	t.Helper()
+	guard := &externalGuard{t: t, next: http.DefaultTransport}
+	offline, ok := l.HTTPClient.Transport.(offlineTransport)
+	if !ok {
+		t.Fatalf("offline launcher's client uses %T, not offlineTransport", l.HTTPClient.Transport)
+	}
+	offline.next = guard
+	l.HTTPClient.Transport = offline
+
+	saved := http.DefaultTransport
+	http.DefaultTransport = guard
+	t.Cleanup(func() { http.DefaultTransport = saved })
+	return guard
+}
+
+func TestOfflineClientRefusesExternalHosts(t *testing.T) {
+	l := NewLauncher(Manifest{Offline: true}, Options{}, t.TempDir())
+	guard := guardOutbound(t, l)
+	for _, url := range []string{"https://downloads.example/php.tar.gz", "http://192.0.2.1/", "http://[2001:db8::1]/"} {
+		if resp, err := l.HTTPClient.Get(url); err == nil {
+			resp.Body.Close()
+			t.Errorf("GET %s succeeded offline", url)
+		}
+	}
+	if len(guard.seen) != 0 {
+		t.Errorf("requests reached the transport: %v", guard.seen)
+	}
+}
+
+func TestOfflineRunStaysOnLoopback(t *testing.T) {
+	opened := make(chan string, 1)
+	l := testLauncher(t, "serve", fmt.Sprintf(testManifest, `, "offline": true`), opened)
+	guardOutbound(t, l)
+	done := runLauncher(l, context.Background())
+	select {
+	case url := <-opened:
+		waitServed(t, url)
+	case err := <-done:
+		t.Fatalf("Run returned before opening the browser: %v", err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("the browser was never opened")
+	}
+	l.Quit()
+	if err := <-done; err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+}
+
+func TestOfflineRunDoesNotDownloadPHP(t *testing.T) {
+	download := fmt.Sprintf(`, "offline": true, "php_downloads": {%q: {"url": "https://downloads.example/php.tar.gz", "sha256": "%s"}}`,
+		runtime.GOOS, strings.Repeat("a", 64))
+	l := testLauncher(t, "serve", fmt.Sprintf(testManifest, download), make(chan string, 1))
+	l.OpenURL = func(string) error { return fmt.Errorf("no browser in tests") }
+	guardOutbound(t, l)
+	err := <-runLauncher(l, context.Background())
+	if err == nil || !strings.Contains(err.Error(), "offline mode") {
+		t.Errorf("Run = %v, want the offline refusal to download PHP", err)
+	}
+}
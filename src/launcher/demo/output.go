@@ -0,0 +1,130 @@
+package demo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Limits for the captured PHP output.
+const (
+	outputMaxLines    = 500
+	outputMaxBytes    = 256 << 10
+	outputMaxLineSize = 8 << 10
+)
+
+// outputRing keeps the most recent lines written to it, bounded both by
+// line count and total size, so noisy PHP deprecation output can't grow
+// without limit. It is safe for concurrent writers and readers.
+type outputRing struct {
+	mu       sync.Mutex
+	lines    []string
+	size     int // total bytes held in lines
+	partial  []byte
+	dropped  int // bytes of the current line beyond outputMaxLineSize
+	maxLines int
+	maxBytes int
+}
+
+func newOutputRing(maxLines, maxBytes int) *outputRing {
+	return &outputRing{maxLines: maxLines, maxBytes: maxBytes}
+}
+
+// Write splits p into lines and stores each complete one. A line longer
+// than outputMaxLineSize is cut off and marked as truncated.
+func (r *outputRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		chunk := p
+		if i >= 0 {
+			chunk = p[:i]
+		}
+
+		room := outputMaxLineSize - len(r.partial)
+		if len(chunk) > room {
+			r.partial = append(r.partial, chunk[:room]...)
+			r.dropped += len(chunk) - room
+		} else {
+			r.partial = append(r.partial, chunk...)
+		}
+
+		if i < 0 {
+			break
+		}
+		r.push()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// push moves the buffered partial line into the ring.
+func (r *outputRing) push() {
+	line := strings.TrimSuffix(string(r.partial), "\r")
+	if r.dropped > 0 {
+		line += fmt.Sprintf(" ... [%d bytes truncated]", r.dropped)
+	}
+	r.partial = r.partial[:0]
+	r.dropped = 0
+
+	r.lines = append(r.lines, line)
+	r.size += len(line)
+
+	// Drop the oldest lines until both limits hold again
+	for len(r.lines) > r.maxLines || (r.size > r.maxBytes && len(r.lines) > 1) {
+		r.size -= len(r.lines[0])
+		r.lines = r.lines[1:]
+	}
+}
+
+// Lines returns the captured lines, oldest first, including a trailing
+// line that hasn't been terminated yet.
+func (r *outputRing) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]string, 0, len(r.lines)+1)
+	out = append(out, r.lines...)
+	if len(r.partial) > 0 {
+		out = append(out, string(r.partial))
+	}
+	return out
+}
+
+// phpOutputLog is one PHP process's captured output, as GET /php-output
+// and the diagnostics show it.
+type phpOutputLog struct {
+	Addr  string   `json:"addr"`
+	Lines []string `json:"lines"`
+}
+
+// phpOutput lists the output of every PHP the launcher started, oldest
+// first, including the tries that failed, which diagnostics want most.
+func (l *Launcher) phpOutput() []phpOutputLog {
+	l.outputMu.Lock()
+	defer l.outputMu.Unlock()
+	logs := []phpOutputLog{}
+	for _, s := range l.outputs {
+		if s.output != nil {
+			logs = append(logs, phpOutputLog{Addr: s.addr, Lines: s.output.Lines()})
+		}
+	}
+	return logs
+}
+
+// phpOutputText is phpOutput for a text file.
+func phpOutputText(logs []phpOutputLog) string {
+	var b strings.Builder
+	for _, log := range logs {
+		fmt.Fprintf(&b, "--- PHP on %s ---\n", log.Addr)
+		for _, line := range log.Lines {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
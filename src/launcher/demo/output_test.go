@@ -0,0 +1,94 @@
+package demo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOutputRingSplitsLines(t *testing.T) {
+	r := newOutputRing(10, 1<<10)
+	r.Write([]byte("first\r\nsec"))
+	r.Write([]byte("ond\nunterminated"))
+	got := strings.Join(r.Lines(), "|")
+	if want := "first|second|unterminated"; got != want {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+}
+
+func TestOutputRingTruncatesLongLines(t *testing.T) {
+	r := newOutputRing(10, 1<<20)
+	long := strings.Repeat("x", outputMaxLineSize+100)
+	// Written in pieces, as a pipe delivers it
+	for i := 0; i < len(long); i += 1000 {
+		end := i + 1000
+		if end > len(long) {
+			end = len(long)
+		}
+		r.Write([]byte(long[i:end]))
+	}
+	r.Write([]byte("\nnext\n"))
+
+	lines := r.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	want := strings.Repeat("x", outputMaxLineSize) + " ... [100 bytes truncated]"
+	if lines[0] != want {
+		t.Errorf("long line kept %d bytes ending %q", len(lines[0]), lines[0][len(lines[0])-30:])
+	}
+	if lines[1] != "next" {
+		t.Errorf("line after the long one = %q", lines[1])
+	}
+}
+
+func TestOutputRingWrapsAtLineLimit(t *testing.T) {
+	r := newOutputRing(3, 1<<20)
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(r, "line %d\n", i)
+	}
+	if got := strings.Join(r.Lines(), "|"); got != "line 3|line 4|line 5" {
+		t.Errorf("Lines() = %q, want the last three", got)
+	}
+}
+
+func TestOutputRingWrapsAtByteLimit(t *testing.T) {
+	r := newOutputRing(100, 25)
+	for _, line := range []string{"aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc"} {
+		fmt.Fprintln(r, line)
+	}
+	if got := strings.Join(r.Lines(), "|"); got != "bbbbbbbbbb|cccccccccc" {
+		t.Errorf("Lines() = %q, want the lines that fit in 25 bytes", got)
+	}
+
+	// A single line over the byte limit is still kept, on its own
+	fmt.Fprintln(r, strings.Repeat("d", 40))
+	if lines := r.Lines(); len(lines) != 1 || len(lines[0]) != 40 {
+		t.Errorf("Lines() = %q, want only the oversized line", lines)
+	}
+}
+
+func TestOutputRingConcurrentUse(t *testing.T) {
+	r := newOutputRing(50, 1<<20)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				fmt.Fprintf(r, "writer line %d\n", i)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				r.Lines()
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(r.Lines()); n != 50 {
+		t.Errorf("kept %d lines, want 50", n)
+	}
+}
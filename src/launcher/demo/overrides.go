@@ -0,0 +1,99 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OverrideFlags are the command line flags that override manifest values
+// for one run, so QA can try another port, page or duration without
+// rebuilding the launcher.
+type OverrideFlags struct {
+	Port        int    // --port
+	LandingPage string // --landing-page
+	Duration    int    // --duration
+	WindowSize  string // --window-size
+}
+
+// Overrides are the manifest values given on the command line
+// or in LAUNCHER_ environment variables. Zero values leave the manifest
+// alone, except duration, where 0 means no limit and -1 unset.
+type Overrides struct {
+	env           []envOverride
+	port          int
+	landingPage   string
+	duration      int
+	width, height int
+	maximized     bool
+}
+
+// ParseOverrides checks the override flags and the LAUNCHER_ variables in
+// environ. Only running the demo takes them; subcommands don't look at
+// them.
+func ParseOverrides(flags OverrideFlags, environ []string) (Overrides, error) {
+	o := Overrides{port: flags.Port, landingPage: flags.LandingPage, duration: flags.Duration}
+	env, warnings, problems := envOverrides(environ)
+	o.env = env
+	for _, w := range warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+	if o.port < 0 || o.port > 65535 {
+		problems = append(problems, fmt.Sprintf("--port %d must be between 1 and 65535", o.port))
+	}
+	if o.landingPage != "" && !strings.HasPrefix(o.landingPage, "/") {
+		problems = append(problems, fmt.Sprintf("--landing-page %q must start with /", o.landingPage))
+	}
+	if o.duration < -1 {
+		problems = append(problems, fmt.Sprintf("--duration %d can't be negative", o.duration))
+	}
+	if flags.WindowSize != "" {
+		var err error
+		o.maximized = strings.EqualFold(flags.WindowSize, "maximized")
+		if o.width, o.height, err = parseWindowSize(flags.WindowSize); err != nil {
+			problems = append(problems, fmt.Sprintf("--window-size %q: %v", flags.WindowSize, err))
+		}
+	}
+	if len(problems) > 0 {
+		return o, errors.New(strings.Join(problems, "; "))
+	}
+	return o, nil
+}
+
+// Apply lays the overrides over config, the environment first and the
+// flags over it. It runs again on the app picked from a suite, whose own
+// entry would otherwise win.
+func (o Overrides) Apply(config *Manifest) {
+	for _, e := range o.env {
+		setManifestValue(config, e.key, e.value) // checked by envOverrides
+	}
+	if o.port != 0 {
+		config.PHPPort = o.port
+		if config.Deterministic.Port != 0 {
+			config.Deterministic.Port = o.port
+		}
+	}
+	if o.landingPage != "" {
+		config.LandingPageURL = o.landingPage
+	}
+	if o.duration >= 0 {
+		config.AllowedDemoDurationMinutes = o.duration
+	}
+	switch {
+	case o.maximized:
+		config.StartMaximized = true
+	case o.width > 0:
+		config.StartMaximized = false
+		config.WindowWidth, config.WindowHeight = o.width, o.height
+	}
+}
+
+// EnvNames lists the LAUNCHER_ variables among the overrides, "" when
+// there are none.
+func (o Overrides) EnvNames() string {
+	names := make([]string, len(o.env))
+	for i, e := range o.env {
+		names[i] = e.name
+	}
+	return strings.Join(names, ", ")
+}
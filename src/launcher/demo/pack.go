@@ -0,0 +1,735 @@
+package demo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultPackExclude lists file and folder names that never belong in a
+// bundle.
+var defaultPackExclude = []string{".git", "node_modules", "tests", "build", "venv", "__pycache__", ".DS_Store"}
+
+// packOptions are the flags of the pack subcommand.
+type packOptions struct {
+	source, phpDir, manifest, bundleDir, signKey string
+	exclude                                      []string
+	dryRun, encrypt, console, appImage           bool
+
+	// out is the launcher to build from the bundle, one per target;
+	// without it pack only fills bundleDir
+	out     string
+	targets []packTarget
+	signing packSigning
+	// several is set when --out builds for more than one target, across
+	// every bundle, which puts the platform in the names
+	several bool
+	// phpRuntimes is, for a manifest with php_binaries, the PHP runtime of
+	// each target, all of which go into the one bundle
+	phpRuntimes map[packTarget]string
+}
+
+// packFile is one file staged into the bundle. rel is slash-separated and
+// relative to the bundle root.
+type packFile struct {
+	src, rel string
+	size     int64
+	exec     bool
+}
+
+// RunPack implements "pack": assembling src/launcher/bundle from a Laravel
+// checkout and a PHP runtime and, with --out, compiling the launchers that
+// embed it, for vendors building a demo.
+func RunPack(args []string) int {
+	flags := flag.NewFlagSet("pack", flag.ContinueOnError)
+	var opts packOptions
+	var exclude, targets string
+	flags.StringVar(&opts.source, "source", "", "Laravel app to bundle (for a manifest with \"apps\", the folder holding one subfolder per app dir)")
+	flags.StringVar(&opts.source, "app", "", "Same as --source")
+	flags.StringVar(&opts.phpDir, "php-dir", "", "Directory with the PHP runtime to bundle; for several platforms os[/arch]=dir pairs, or a folder with a subfolder per platform such as windows-amd64")
+	flags.StringVar(&opts.manifest, "manifest", "manifest.json", "Manifest the bundle is built for")
+	flags.StringVar(&opts.bundleDir, "bundle-dir", filepath.Join("src", "launcher", "bundle"), "Bundle directory to fill, inside the launcher source; its current contents are replaced")
+	flags.StringVar(&opts.out, "out", "", "Launcher executable to build, e.g. build/demo.exe; with several --os targets each gets a -<os> suffix")
+	flags.StringVar(&targets, "platforms", defaultPackTarget(), "Comma-separated targets for --out, each an OS with an optional /arch: linux, windows, darwin/arm64")
+	flags.StringVar(&targets, "os", defaultPackTarget(), "Same as --platforms")
+	flags.StringVar(&exclude, "exclude", strings.Join(defaultPackExclude, ","), "Comma-separated file and folder names (or patterns) to leave out")
+	flags.StringVar(&opts.signKey, "sign-key", "", "PEM file with an Ed25519 private key to sign checksums.json with")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Report what would be bundled without writing anything")
+	flags.BoolVar(&opts.encrypt, "encrypt", false, "Encrypt the payload with a fresh key the launcher must be built with")
+	flags.BoolVar(&opts.console, "console", false, "Build Windows launchers as console apps, for debugging")
+	flags.BoolVar(&opts.appImage, "appimage", false, "Build Linux launchers as AppImages, or AppDirs without appimagetool")
+	flags.StringVar(&opts.signing.identity, "codesign-identity", "", "Certificate to sign the built launchers with: a subject name or .pfx file for signtool, a keychain identity for codesign, or a .p12 file for codesign or rcodesign")
+	flags.StringVar(&opts.signing.timestamp, "codesign-timestamp", "", "Timestamp server for the signatures, e.g. http://timestamp.digicert.com")
+	flags.StringVar(&opts.signing.command, "codesign-command", "", "Command that signs each built launcher instead, with {file}, {identity} and {timestamp} filled in")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if opts.source == "" {
+		fmt.Println("Usage: pack --app <laravel dir> [--php-dir <dir>] [--manifest manifest.json] [--out <executable> [--platforms linux,windows/amd64]] [--dry-run]")
+		return 2
+	}
+	if opts.signing.enabled() && opts.out == "" {
+		fmt.Println("Error: --codesign-identity and --codesign-command sign what --out builds; pass --out")
+		return 2
+	}
+	var err error
+	if opts.targets, err = parsePackTargets(targets); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+	opts.several = len(opts.targets) > 1
+	for _, name := range strings.Split(exclude, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.exclude = append(opts.exclude, name)
+		}
+	}
+
+	if err := pack(&opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func pack(opts *packOptions) error {
+	config, apps, err := loadPackManifest(opts.manifest)
+	if err != nil {
+		return err
+	}
+	bundles, err := packBundles(config, opts)
+	if err != nil {
+		return err
+	}
+	// Every bundle is checked before any is written
+	plans := make([][]packFile, len(bundles))
+	excluded := make([][]string, len(bundles))
+	var problems []string
+	seen := make(map[string]bool)
+	for i, b := range bundles {
+		if plans[i], excluded[i], err = planPack(config, b); err != nil {
+			return err
+		}
+		for _, p := range checkPack(config, apps, plans[i], b) {
+			if !seen[p] {
+				seen[p] = true
+				problems = append(problems, p)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Println("  - " + p)
+		}
+		return errors.New("the bundle would be broken; fix the problems above")
+	}
+	if opts.phpDir == "" {
+		for _, t := range opts.targets {
+			if d, ok := platformPHPDownload(config, t.goos, t.goarch); ok {
+				fmt.Printf("PHP for %s isn't bundled; the launcher downloads it from %s on first launch.\n", t, d.URL)
+			}
+		}
+	}
+
+	for i, b := range bundles {
+		if len(bundles) > 1 {
+			fmt.Printf("Packing for %s with the PHP runtime in %s...\n", joinTargets(b.targets), b.phpDir)
+		}
+		if err := packBundle(config, b, plans[i], excluded[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packBundle writes one bundle and, with --out, builds its launchers.
+func packBundle(config *Manifest, opts *packOptions, files []packFile, excluded []string) error {
+	if opts.dryRun {
+		reportPlan(files, excluded)
+		printPackSize(files)
+		fmt.Println("Dry run: nothing was written.")
+		return nil
+	}
+
+	if err := writePack(files, opts.bundleDir); err != nil {
+		return err
+	}
+	if opts.out != "" {
+		// From the staged bundle, before the payload replaces it
+		resources, err := writePackWinres(config, opts)
+		defer removeFiles(resources)
+		if err == nil {
+			err = writePackAppBundles(config, opts)
+		}
+		if err == nil && opts.appImage {
+			err = writePackAppDirs(config, opts)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	sums, err := writePackChecksums(opts.bundleDir)
+	if err != nil {
+		return err
+	}
+	embedded, err := writePackPayload(opts.bundleDir)
+	if err != nil {
+		return err
+	}
+	var ldflags []string
+	if opts.signKey != "" {
+		public, err := signPack(opts.bundleDir, opts.signKey)
+		if err != nil {
+			return err
+		}
+		ldflags = append(ldflags, "-X main.bundlePublicKey="+public)
+		if opts.out == "" {
+			fmt.Printf("Build with -ldflags \"-X main.bundlePublicKey=%s\" to have the launcher refuse bundles not signed with this key.\n", public)
+		}
+	}
+	var raw int64
+	for _, f := range files {
+		raw += f.size
+	}
+	fmt.Printf("Packed %d files into %s.\n", len(sums), opts.bundleDir)
+	fmt.Printf("Bundle size: %.1f MB uncompressed, %.1f MB embedded as %s\n", float64(raw)/(1<<20), float64(embedded)/(1<<20), payloadFile)
+	if opts.encrypt {
+		masked, err := sealPayload(opts.bundleDir)
+		if err != nil {
+			return err
+		}
+		ldflags = append(ldflags, "-X main.payloadKey="+masked)
+		if opts.out == "" {
+			fmt.Printf("Encrypted the payload; build with -ldflags \"-X main.payloadKey=%s\" so the launcher can read it.\n", masked)
+		} else {
+			fmt.Println("Encrypted the payload.")
+		}
+	}
+	if opts.out != "" {
+		return buildPackLaunchers(opts, ldflags)
+	}
+	return nil
+}
+
+// loadPackManifest reads the manifest strictly, so a misspelt key fails
+// the build instead of being ignored at runtime, and returns it with the
+// configuration of every app of a suite.
+func loadPackManifest(file string) (*Manifest, []Manifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	config, err := DecodeManifestFile(file, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	// decodeManifest has checked every entry
+	apps, _ := appEntries(&config)
+	for i, app := range apps {
+		if apps[i], err = SelectApp(&config, app.AppName); err != nil {
+			return nil, nil, fmt.Errorf("%s: app %q: %w", file, app.AppName, err)
+		}
+	}
+	return &config, apps, nil
+}
+
+// packPHPDir is the bundle folder the PHP runtime for t goes to: the one
+// holding its php_binaries entry or php_binary_path.
+func packPHPDir(config *Manifest, t packTarget) string {
+	bin, _ := platformPHPBinary(config, t.goos, t.goarch)
+	if bin == "" {
+		bin = "php/php"
+	}
+	return path.Dir(filepath.ToSlash(bin))
+}
+
+// planPack lists the files that go into the bundle, and the excluded paths.
+func planPack(config *Manifest, opts *packOptions) ([]packFile, []string, error) {
+	type tree struct{ src, rel string }
+	var trees []tree
+	if len(config.Apps) == 0 {
+		trees = append(trees, tree{opts.source, "resources/app"})
+	} else {
+		apps, _ := appEntries(config)
+		for _, app := range apps {
+			trees = append(trees, tree{filepath.Join(opts.source, filepath.FromSlash(app.Dir)), app.Dir})
+		}
+	}
+	if len(opts.phpRuntimes) > 0 {
+		added := make(map[string]packTarget)
+		for _, t := range opts.targets {
+			rel := packPHPDir(config, t)
+			other, ok := added[rel]
+			if !ok {
+				added[rel] = t
+				trees = append(trees, tree{opts.phpRuntimes[t], rel})
+			} else if opts.phpRuntimes[other] != opts.phpRuntimes[t] {
+				return nil, nil, fmt.Errorf("php_binaries puts the PHP for %s and %s in %s; give each platform its own folder", other, t, rel)
+			}
+		}
+	} else if opts.phpDir != "" {
+		trees = append(trees, tree{opts.phpDir, packPHPDir(config, opts.targets[0])})
+	}
+
+	var files []packFile
+	var excluded []string
+	for _, t := range trees {
+		err := filepath.WalkDir(t.src, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(t.src, p)
+			rel = path.Join(t.rel, filepath.ToSlash(rel))
+			if p != t.src && packExcluded(d.Name(), opts.exclude) {
+				excluded = append(excluded, rel)
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := os.Stat(p)
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				// Symlinked folders and special files can't be embedded
+				fmt.Printf("Skipping %s: not a regular file\n", p)
+				return nil
+			}
+			files = append(files, packFile{src: p, rel: rel, size: info.Size(), exec: info.Mode()&0111 != 0})
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, excluded, nil
+}
+
+func packExcluded(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPack catches what would only fail after shipping: no PHP binary, no
+// Laravel public folder, and a vendor folder that is missing or older than
+// composer.lock.
+func checkPack(config *Manifest, apps []Manifest, files []packFile, opts *packOptions) []string {
+	var problems []string
+	staged := make(map[string]bool, len(files))
+	for _, f := range files {
+		staged[f.rel] = true
+	}
+
+	// As the launchers for each target will look for it
+	for _, t := range opts.targets {
+		configured, err := platformPHPBinary(config, t.goos, t.goarch)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		configured = filepath.ToSlash(configured)
+		bin := configured
+		if opts.out != "" {
+			bin = platformExecutable(configured, t.goos)
+		}
+		if bin == "" || filepath.IsAbs(configured) || staged[bin] || staged[configured] {
+			continue
+		}
+		key := "php_binary_path"
+		if len(config.PHPBinaries) > 0 {
+			key = "php_binaries"
+		}
+		phpDir := opts.phpDir
+		if len(opts.phpRuntimes) > 0 {
+			phpDir = opts.phpRuntimes[t]
+		}
+		if _, ok := platformPHPDownload(config, t.goos, t.goarch); ok && phpDir == "" {
+			// A thin launcher, which fetches PHP on first launch
+			continue
+		}
+		if phpDir == "" {
+			if !config.AllowSystemPHP {
+				problems = append(problems, fmt.Sprintf("%s %s would be missing; pass --php-dir", key, bin))
+			}
+		} else {
+			problems = append(problems, fmt.Sprintf("%s %s would be missing; %s has no %s", key, bin, phpDir, path.Base(bin)))
+		}
+	}
+
+	if len(apps) == 0 {
+		apps = []Manifest{*config}
+	}
+	for _, app := range apps {
+		public := path.Clean(filepath.ToSlash(app.PublicRoot))
+		if !staged[path.Join(public, "index.php")] {
+			problems = append(problems, fmt.Sprintf("public_root %q has no index.php in the bundle", app.PublicRoot))
+		}
+	}
+	if ini := filepath.ToSlash(config.PHPIni); ini != "" && !filepath.IsAbs(config.PHPIni) && !staged[path.Clean(ini)] {
+		problems = append(problems, fmt.Sprintf("php_ini %q isn't in the bundle", config.PHPIni))
+	}
+
+	sources := []string{opts.source}
+	if entries, _ := appEntries(config); len(entries) > 0 {
+		sources = sources[:0]
+		for _, app := range entries {
+			sources = append(sources, filepath.Join(opts.source, filepath.FromSlash(app.Dir)))
+		}
+	}
+	for _, dir := range sources {
+		if p := checkVendor(dir); p != "" {
+			problems = append(problems, p)
+		}
+	}
+
+	if opts.out != "" {
+		problems = append(problems, checkPackBuild(opts)...)
+	}
+	if config.ScrambleCode {
+		problems = append(problems, "scramble_code is on, but pack doesn't run scramble plugins; build with src/builder/build.py or turn it off")
+	}
+	return problems
+}
+
+// checkVendor reports a Composer app whose vendor folder wasn't installed
+// or predates composer.lock.
+func checkVendor(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "composer.json")); err != nil {
+		return ""
+	}
+	installed, err := os.Stat(filepath.Join(dir, "vendor", "composer", "installed.json"))
+	if _, autoload := os.Stat(filepath.Join(dir, "vendor", "autoload.php")); err != nil || autoload != nil {
+		return fmt.Sprintf("%s has no installed vendor folder; run composer install --no-dev", dir)
+	}
+	if lock, err := os.Stat(filepath.Join(dir, "composer.lock")); err == nil && lock.ModTime().After(installed.ModTime()) {
+		return fmt.Sprintf("%s: vendor is older than composer.lock; run composer install --no-dev", dir)
+	}
+	return ""
+}
+
+// writePack replaces the contents of out (keeping the go:embed
+// placeholder) with files, normalizing permissions and stripping secrets
+// from .env files.
+func writePack(files []packFile, out string) error {
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == placeholder {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(out, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		dest := filepath.Join(out, filepath.FromSlash(f.rel))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if f.exec {
+			mode = 0755
+		}
+		if err := writePackFile(f, dest, mode); err != nil {
+			return err
+		}
+		// OpenFile keeps the mode of a file that existed, and umask applies
+		if err := os.Chmod(dest, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePackFile copies f to dest. A .env file is read whole to strip its
+// secrets; everything else is streamed, vendor archives and SQL dumps
+// included.
+func writePackFile(f packFile, dest string, mode os.FileMode) error {
+	if isDotEnv(path.Base(f.rel)) {
+		data, err := os.ReadFile(f.src)
+		if err != nil {
+			return err
+		}
+		data, stripped := stripSecrets(data)
+		if len(stripped) > 0 {
+			fmt.Printf("Stripped %s from %s\n", strings.Join(stripped, ", "), f.rel)
+		}
+		return os.WriteFile(dest, data, mode)
+	}
+	in, err := os.Open(f.src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = copyStream(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// isDotEnv matches .env and its variants, except the committed example.
+func isDotEnv(name string) bool {
+	return name == ".env" || strings.HasPrefix(name, ".env.") && name != ".env.example"
+}
+
+var dotEnvLine = regexp.MustCompile(`^(\s*(?:export\s+)?)([A-Za-z_][A-Za-z0-9_]*)(\s*=).*$`)
+
+// stripSecrets blanks the values of secret-looking keys in a .env file,
+// keeping everything else as written. APP_KEY stays: the app can't run
+// without it and it only protects the demo's own data.
+func stripSecrets(data []byte) ([]byte, []string) {
+	var stripped []string
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		m := dotEnvLine.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil || m[2] == "APP_KEY" {
+			continue
+		}
+		_, value, _ := strings.Cut(line, "=")
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		if value == "" || !secretNamePattern.MatchString(m[2]) && !liveKeyPattern.MatchString(value) {
+			continue
+		}
+		lines[i] = m[1] + m[2] + m[3] + line[len(strings.TrimRight(line, "\r\n")):]
+		stripped = append(stripped, m[2])
+	}
+	return []byte(strings.Join(lines, "")), stripped
+}
+
+// writePackChecksums hashes every bundled file into checksums.json, in the
+// format the builder writes.
+func writePackChecksums(out string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.WalkDir(out, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(out, p)
+		rel = filepath.ToSlash(rel)
+		if rel == placeholder || rel == checksumsFile || rel == signatureFile {
+			return nil
+		}
+		sum, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		sums[rel] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(sums, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return sums, os.WriteFile(filepath.Join(out, checksumsFile), data, 0644)
+}
+
+// writePackPayload moves the bundled files of out into payloadFile, in
+// the layout the builder writes, and returns its size.
+func writePackPayload(out string) (int64, error) {
+	f, err := os.Create(filepath.Join(out, payloadFile))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		return 0, err
+	}
+	tw := tar.NewWriter(zw)
+	err = filepath.WalkDir(out, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == out {
+			return err
+		}
+		rel, _ := filepath.Rel(out, p)
+		rel = filepath.ToSlash(rel)
+		if bundleMeta(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: rel, Mode: int64(info.Mode().Perm()), ModTime: info.ModTime(), Typeflag: tar.TypeReg, Size: info.Size()}
+		if d.IsDir() {
+			hdr.Name, hdr.Typeflag, hdr.Size = rel+"/", tar.TypeDir, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = copyStream(tw, in)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("writing %s: %w", payloadFile, err)
+	}
+
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if !bundleMeta(e.Name()) {
+			if err := os.RemoveAll(filepath.Join(out, e.Name())); err != nil {
+				return 0, err
+			}
+		}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// signPack signs checksums.json with the Ed25519 key in keyFile (PKCS #8
+// PEM, as written by "openssl genpkey -algorithm ed25519") and returns the
+// public key in hex for BundlePublicKey.
+func signPack(out, keyFile string) (string, error) {
+	pemData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return "", fmt.Errorf("%s is not a PEM file", keyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", keyFile, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return "", errors.New(keyFile + " is not an Ed25519 key")
+	}
+
+	sums, err := os.ReadFile(filepath.Join(out, checksumsFile))
+	if err != nil {
+		return "", err
+	}
+	sig := hex.EncodeToString(ed25519.Sign(key, sums))
+	if err := os.WriteFile(filepath.Join(out, signatureFile), []byte(sig+"\n"), 0644); err != nil {
+		return "", err
+	}
+	public := hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	fmt.Printf("Signed checksums.json (public key %s)\n", public)
+	return public, nil
+}
+
+// reportPlan prints what a dry run would bundle, summed per top-level
+// folder of each app and of the PHP runtime.
+func reportPlan(files []packFile, excluded []string) {
+	type group struct {
+		count int
+		size  int64
+	}
+	groups := make(map[string]*group)
+	for _, f := range files {
+		key := f.rel
+		parts := strings.SplitN(f.rel, "/", 4)
+		if len(parts) > 3 && parts[0] == "resources" {
+			key = strings.Join(parts[:3], "/") + "/"
+		} else if len(parts) > 2 {
+			key = strings.Join(parts[:2], "/") + "/"
+		}
+		if groups[key] == nil {
+			groups[key] = &group{}
+		}
+		groups[key].count++
+		groups[key].size += f.size
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Println("Would include:")
+	for _, k := range keys {
+		fmt.Printf("  %-40s %6d files %9.1f MB\n", k, groups[k].count, float64(groups[k].size)/(1<<20))
+	}
+	if len(excluded) > 0 {
+		fmt.Println("Would leave out:")
+		for _, p := range excluded {
+			fmt.Println("  " + p)
+		}
+	}
+}
+
+// printPackSize prints the bundle's size as embedded and as a download
+// would compress it.
+func printPackSize(files []packFile) {
+	var raw, compressed int64
+	for _, f := range files {
+		raw += f.size
+		compressed += gzipSize(f.src)
+	}
+	fmt.Printf("Bundle size: %.1f MB uncompressed, %.1f MB compressed (gzip)\n", float64(raw)/(1<<20), float64(compressed)/(1<<20))
+}
+
+func gzipSize(file string) int64 {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	var n countingWriter
+	zw := gzip.NewWriter(&n)
+	io.Copy(zw, f)
+	zw.Close()
+	return int64(n)
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,115 @@
+package demo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWritePackModesAndSecrets(t *testing.T) {
+	src := t.TempDir()
+	files := []packFile{
+		{rel: "artisan", exec: true},
+		{rel: "public/index.php"},
+		{rel: ".env"},
+	}
+	bodies := map[string]string{
+		"artisan":          "#!/usr/bin/env php\n",
+		"public/index.php": "<?php\n",
+		".env":             "APP_KEY=base64:abc\nMAIL_PASSWORD=hunter2\nAPP_NAME=Demo\n",
+	}
+	for i, f := range files {
+		files[i].src = filepath.Join(src, filepath.FromSlash(f.rel))
+		os.MkdirAll(filepath.Dir(files[i].src), 0755)
+		if err := ioutil.WriteFile(files[i].src, []byte(bodies[f.rel]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := t.TempDir()
+	// A file left from an earlier pack, with the wrong mode
+	ioutil.WriteFile(filepath.Join(out, "artisan"), []byte("old"), 0600)
+	if err := writePack(files, out); err != nil {
+		t.Fatal(err)
+	}
+
+	env, _ := ioutil.ReadFile(filepath.Join(out, ".env"))
+	if want := "APP_KEY=base64:abc\nMAIL_PASSWORD=\nAPP_NAME=Demo\n"; string(env) != want {
+		t.Errorf(".env = %q, want %q", env, want)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(out, "artisan")); string(data) != bodies["artisan"] {
+		t.Errorf("artisan = %q", data)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	for rel, want := range map[string]os.FileMode{"artisan": 0755, "public/index.php": 0644, ".env": 0644} {
+		info, err := os.Stat(filepath.Join(out, filepath.FromSlash(rel)))
+		if err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s: mode %v, %v; want %v", rel, info.Mode().Perm(), err, want)
+		}
+	}
+}
+
+func TestExtractPayloadKeepsModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows files have no mode bits")
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	sums := make(map[string]string)
+	big := strings.Repeat("x", payloadJobMax+1)
+	for _, e := range []struct {
+		name string
+		mode int64
+		body string
+	}{
+		{"artisan", 0755, "#!/usr/bin/env php\n"},
+		{"php/bin/php", 0755, big},
+		{"public/index.php", 0644, "<?php\n"},
+		{"config/readonly.php", 0444, "<?php\n"},
+	} {
+		tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: e.mode, Size: int64(len(e.body))})
+		tw.Write([]byte(e.body))
+		sum := sha256.Sum256([]byte(e.body))
+		sums[e.name] = hex.EncodeToString(sum[:])
+	}
+	tw.Close()
+	zw.Close()
+	sumsJSON, _ := json.Marshal(sums)
+	fsys := fstest.MapFS{
+		"bundle/" + checksumsFile: {Data: sumsJSON},
+		"bundle/" + payloadFile:   {Data: buf.Bytes()},
+	}
+
+	dest := t.TempDir()
+	// A reused --work-dir is extracted over, files from an older bundle
+	// included
+	ioutil.WriteFile(filepath.Join(dest, "artisan"), []byte("old"), 0600)
+	for i := 0; i < 2; i++ {
+		if err := extractBundle(fsys, dest, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for rel, want := range map[string]os.FileMode{
+		"artisan":             0755,
+		"php/bin/php":         0755,
+		"public/index.php":    0644,
+		"config/readonly.php": 0644,
+	} {
+		info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(rel)))
+		if err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s: mode %v, %v; want %v", rel, info.Mode().Perm(), err, want)
+		}
+	}
+}
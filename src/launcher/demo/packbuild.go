@@ -0,0 +1,365 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// packTarget is a platform pack --out compiles the launcher for.
+type packTarget struct {
+	goos, goarch string
+}
+
+func (t packTarget) String() string {
+	return t.goos + "/" + t.goarch
+}
+
+// packOSes are the platforms the launcher runs on.
+var packOSes = map[string]bool{"linux": true, "windows": true, "darwin": true}
+
+// defaultPackTarget is the machine pack runs on.
+func defaultPackTarget() string {
+	if runtime.GOARCH == "amd64" {
+		return runtime.GOOS
+	}
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// parsePackTargets parses --os. The architecture defaults to amd64, as in
+// build.py.
+func parsePackTargets(list string) ([]packTarget, error) {
+	var targets []packTarget
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		goos, goarch, _ := strings.Cut(item, "/")
+		if goarch == "" {
+			goarch = "amd64"
+		}
+		if !packOSes[goos] || goarch != "amd64" && goarch != "arm64" {
+			return nil, fmt.Errorf("unknown --os target %q (use linux, windows or darwin, optionally with /amd64 or /arm64)", item)
+		}
+		targets = append(targets, packTarget{goos, goarch})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("--os lists no targets")
+	}
+	return targets, nil
+}
+
+// packTargetOutput is a target with the path packOutput gave it.
+type packTargetOutput struct {
+	target packTarget
+	out    string
+}
+
+// packOutput is where the launcher for t goes, an .app bundle for macOS.
+// With several targets each gets its platform in the name, e.g. demo-linux,
+// demo-windows.exe and demo-darwin.app.
+func packOutput(out string, t packTarget, several bool) string {
+	if several {
+		out = strings.TrimSuffix(strings.TrimSuffix(out, ".exe"), ".app") + "-" + t.goos
+		if t.goarch != "amd64" {
+			out += "-" + t.goarch
+		}
+	}
+	if t.goos == "windows" && !strings.HasSuffix(out, ".exe") {
+		out += ".exe"
+	}
+	if t.goos == "darwin" && !strings.HasSuffix(out, ".app") {
+		out += ".app"
+	}
+	return out
+}
+
+// checkPackBuild catches what would stop --out after the bundle was
+// written.
+func checkPackBuild(opts *packOptions) []string {
+	var problems []string
+	if _, err := exec.LookPath("go"); err != nil {
+		problems = append(problems, "--out needs the Go toolchain on PATH to compile the launcher")
+	}
+	// go:embed only reaches the bundle folder of the package being built
+	if _, err := os.Stat(filepath.Join(filepath.Dir(opts.bundleDir), "bundle.go")); err != nil {
+		problems = append(problems, fmt.Sprintf("--bundle-dir %s isn't the bundle folder of the launcher source, so --out can't embed it", opts.bundleDir))
+	}
+	problems = append(problems, opts.signing.check(opts.targets)...)
+	return problems
+}
+
+// buildPackLaunchers compiles the launcher with the bundle just packed for
+// every target, puts the manifest next to them, where the launcher reads
+// it from, and signs what it built.
+func buildPackLaunchers(opts *packOptions, ldflags []string) error {
+	src := filepath.Dir(opts.bundleDir)
+	var dirs []string
+	var appDirs, built []packTargetOutput
+	bare := 0
+	for _, t := range opts.targets {
+		out, err := filepath.Abs(packOutput(opts.out, t, opts.several))
+		if err != nil {
+			return err
+		}
+		exe, dir := out, filepath.Dir(out)
+		if t.goos == "darwin" {
+			// The manifest goes next to the icon, as Contents/MacOS is
+			// for code only
+			exe, dir = appBundleExecutable(out), appBundleResources(out)
+		} else if t.goos == "linux" && opts.appImage {
+			exe = appDirExecutable(out+".AppDir", filepath.Base(out))
+			dir = filepath.Dir(exe)
+			appDirs = append(appDirs, packTargetOutput{t, out})
+		} else {
+			bare++
+		}
+		built = append(built, packTargetOutput{t, out})
+		if err := os.MkdirAll(filepath.Dir(exe), 0755); err != nil {
+			return err
+		}
+		fmt.Printf("Building %s for %s...\n", out, t)
+		args := []string{"build", "-trimpath", "-o", exe}
+		linkFlags := append([]string(nil), ldflags...)
+		if t.goos == "windows" && !opts.console {
+			// A GUI-subsystem app, so no console window opens behind the demo
+			linkFlags = append(linkFlags, "-H windowsgui")
+		}
+		if len(linkFlags) > 0 {
+			args = append(args, "-ldflags", strings.Join(linkFlags, " "))
+		}
+		cmd := exec.Command("go", append(args, ".")...)
+		cmd.Dir = src
+		// Like build.py: GOPATH mode for the plain package directory, and
+		// no cgo so every target cross-compiles
+		cmd.Env = append(os.Environ(), "GOOS="+t.goos, "GOARCH="+t.goarch, "GO111MODULE=off", "CGO_ENABLED=0")
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("building the launcher for %s: %w", t, err)
+		}
+		if len(dirs) == 0 || dirs[len(dirs)-1] != dir {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	manifest, err := filepath.Abs(opts.manifest)
+	if err != nil {
+		return err
+	}
+	// Under the name the launcher looks for, in the format it's written in
+	name := manifestNames[0]
+	for _, n := range manifestNames {
+		if filepath.Ext(n) == strings.ToLower(filepath.Ext(manifest)) {
+			name = n
+		}
+	}
+	for _, dir := range dirs {
+		dest := filepath.Join(dir, name)
+		if dest == manifest {
+			continue
+		}
+		// One from an earlier build in another format would win
+		for _, other := range manifestNames {
+			if other != name {
+				os.Remove(filepath.Join(dir, other))
+			}
+		}
+		if err := copyFile(manifest, dest, 0644); err != nil {
+			return fmt.Errorf("copying the manifest: %w", err)
+		}
+	}
+	for _, a := range appDirs {
+		if err := buildAppImage(a.out+".AppDir", a.out+".AppImage", a.target.goarch); err != nil {
+			return err
+		}
+	}
+	for _, b := range built {
+		file := b.out
+		if b.target.goos == "linux" && opts.appImage {
+			file += ".AppImage"
+			if _, err := os.Stat(file); err != nil {
+				continue // left as an AppDir
+			}
+		}
+		if err := opts.signing.sign(b.target.goos, file); err != nil {
+			return err
+		}
+	}
+	if bare > 0 {
+		fmt.Printf("Ship each launcher together with the %s next to it.\n", name)
+	}
+	return nil
+}
+
+// writePackWinres writes the resource object of every Windows target next
+// to the launcher source, where go build picks it up.
+func writePackWinres(config *Manifest, opts *packOptions) ([]string, error) {
+	var files []string
+	for _, t := range opts.targets {
+		if t.goos != "windows" {
+			continue
+		}
+		file := filepath.Join(filepath.Dir(opts.bundleDir), winresFile(t.goarch))
+		files = append(files, file)
+		exe := filepath.Base(packOutput(opts.out, t, opts.several))
+		if err := writeWinres(config, opts.bundleDir, exe, t.goarch, file); err != nil {
+			return files, fmt.Errorf("writing the Windows resources for %s: %w", t, err)
+		}
+	}
+	return files, nil
+}
+
+func removeFiles(files []string) {
+	for _, f := range files {
+		os.Remove(f)
+	}
+}
+
+// writePackAppBundles starts the .app bundle of every macOS target with
+// its Info.plist and icon, replacing one from an earlier build.
+func writePackAppBundles(config *Manifest, opts *packOptions) error {
+	for _, t := range opts.targets {
+		if t.goos != "darwin" {
+			continue
+		}
+		app := packOutput(opts.out, t, opts.several)
+		if err := os.RemoveAll(app); err != nil {
+			return err
+		}
+		if err := writeAppBundle(config, opts.bundleDir, app); err != nil {
+			return fmt.Errorf("writing the app bundle for %s: %w", t, err)
+		}
+	}
+	return nil
+}
+
+// writePackAppDirs starts the AppDir of every Linux target for --appimage,
+// replacing one from an earlier build.
+func writePackAppDirs(config *Manifest, opts *packOptions) error {
+	for _, t := range opts.targets {
+		if t.goos != "linux" {
+			continue
+		}
+		out := packOutput(opts.out, t, opts.several)
+		if err := os.RemoveAll(out + ".AppDir"); err != nil {
+			return err
+		}
+		if err := writeAppDir(config, opts.bundleDir, out+".AppDir", filepath.Base(out)); err != nil {
+			return fmt.Errorf("writing the AppDir for %s: %w", t, err)
+		}
+	}
+	return nil
+}
+
+// packBundles splits --out's targets by the PHP runtime --php-dir gives
+// them, into the bundles to pack one after the other. With php_binaries
+// each runtime has its own folder, so they all share one bundle.
+func packBundles(config *Manifest, opts *packOptions) ([]*packOptions, error) {
+	if opts.out == "" {
+		if strings.Contains(opts.phpDir, "=") {
+			return nil, errors.New("--php-dir with a runtime per platform needs --out")
+		}
+		return []*packOptions{opts}, nil
+	}
+	dirs, err := packPHPDirs(opts.phpDir, opts.targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.PHPBinaries) > 0 && opts.phpDir != "" {
+		opts.phpRuntimes = make(map[packTarget]string)
+		for i, t := range opts.targets {
+			opts.phpRuntimes[t] = dirs[i]
+		}
+		return []*packOptions{opts}, nil
+	}
+	var bundles []*packOptions
+	byDir := make(map[string]*packOptions)
+	for i, t := range opts.targets {
+		b := byDir[dirs[i]]
+		if b == nil {
+			copied := *opts
+			b = &copied
+			b.phpDir, b.targets = dirs[i], nil
+			byDir[dirs[i]] = b
+			bundles = append(bundles, b)
+		}
+		b.targets = append(b.targets, t)
+	}
+	return bundles, nil
+}
+
+// packPHPDirs is the PHP runtime of each target: from os[/arch]=dir pairs,
+// from the subfolder named after the platform (windows-amd64, then
+// windows), or one folder for them all, which can only suit one OS.
+func packPHPDirs(phpDir string, targets []packTarget) ([]string, error) {
+	dirs := make([]string, len(targets))
+	switch {
+	case strings.Contains(phpDir, "="):
+		pairs := make(map[string]string)
+		for _, item := range strings.Split(phpDir, ",") {
+			platform, dir, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok || dir == "" {
+				return nil, fmt.Errorf("--php-dir entry %q isn't os[/arch]=dir", item)
+			}
+			pairs[platform] = dir
+		}
+		for i, t := range targets {
+			dir, ok := pairs[t.String()]
+			if !ok {
+				dir, ok = pairs[t.goos]
+			}
+			if !ok && t.goarch == "amd64" {
+				dir, ok = pairs[t.goos+"/amd64"]
+			}
+			if !ok {
+				return nil, fmt.Errorf("--php-dir has no PHP runtime for %s", t)
+			}
+			dirs[i] = dir
+		}
+	case phpDir != "" && hasPlatformDirs(phpDir):
+		for i, t := range targets {
+			for _, name := range []string{t.goos + "-" + t.goarch, t.goos} {
+				if info, err := os.Stat(filepath.Join(phpDir, name)); err == nil && info.IsDir() {
+					dirs[i] = filepath.Join(phpDir, name)
+					break
+				}
+			}
+			if dirs[i] == "" {
+				return nil, fmt.Errorf("--php-dir %s has no %s-%s folder for %s", phpDir, t.goos, t.goarch, t)
+			}
+		}
+	default:
+		for i, t := range targets {
+			if phpDir != "" && t.goos != targets[0].goos {
+				return nil, errors.New("--php-dir holds one platform's PHP runtime; give one per platform as os[/arch]=dir pairs or subfolders such as windows-amd64")
+			}
+			dirs[i] = phpDir
+		}
+	}
+	return dirs, nil
+}
+
+// hasPlatformDirs reports whether dir holds a PHP runtime per platform
+// rather than being one.
+func hasPlatformDirs(dir string) bool {
+	for goos := range packOSes {
+		for _, name := range []string{goos, goos + "-amd64", goos + "-arm64"} {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func joinTargets(targets []packTarget) string {
+	names := make([]string, len(targets))
+	for i, t := range targets {
+		names[i] = t.String()
+	}
+	return strings.Join(names, ", ")
+}
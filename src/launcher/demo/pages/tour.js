@@ -0,0 +1,79 @@
+// Built-in guided tour overlay, injected by the demo launcher. Steps come
+// from /__launcher/tour-steps.json: a list (or {"steps": [...]}) of
+// {"title", "text", "selector", "path"}. A step with a path only shows on
+// that page; the current step survives navigation in sessionStorage.
+(function () {
+  var KEY = "__launcher_tour_step";
+  var box, highlighted;
+
+  function current() {
+    var v = sessionStorage.getItem(KEY);
+    return v === null ? 0 : parseInt(v, 10);
+  }
+
+  function unhighlight() {
+    if (highlighted) {
+      highlighted.style.outline = highlighted.__tourOutline;
+      highlighted = null;
+    }
+  }
+
+  function button(label, onclick) {
+    var b = document.createElement("button");
+    b.textContent = label;
+    b.style.cssText = "margin-left:.5em;padding:.3em .8em;border:1px solid #36c;border-radius:4px;background:#fff;color:#36c;cursor:pointer";
+    b.onclick = onclick;
+    return b;
+  }
+
+  function show(steps, i) {
+    unhighlight();
+    if (box) box.remove();
+    if (i < 0 || i >= steps.length) {
+      sessionStorage.setItem(KEY, String(steps.length));
+      return;
+    }
+    sessionStorage.setItem(KEY, String(i));
+    var step = steps[i];
+    if (step.path && step.path !== location.pathname) return;
+
+    box = document.createElement("div");
+    box.style.cssText = "position:fixed;right:1.5em;bottom:1.5em;z-index:2147483647;max-width:22em;padding:1em 1.2em;background:#fff;color:#222;border-radius:8px;box-shadow:0 4px 24px rgba(0,0,0,.25);font:14px/1.4 sans-serif";
+    if (step.title) {
+      var h = document.createElement("strong");
+      h.textContent = step.title;
+      h.style.display = "block";
+      box.appendChild(h);
+    }
+    var p = document.createElement("p");
+    p.textContent = step.text || "";
+    p.style.margin = ".5em 0 1em";
+    box.appendChild(p);
+    var nav = document.createElement("div");
+    nav.style.textAlign = "right";
+    var count = document.createElement("span");
+    count.textContent = (i + 1) + " / " + steps.length;
+    count.style.cssText = "float:left;color:#888;line-height:2";
+    nav.appendChild(count);
+    nav.appendChild(button("×", function () { show(steps, steps.length); }));
+    if (i > 0) nav.appendChild(button("←", function () { show(steps, i - 1); }));
+    nav.appendChild(button(i + 1 < steps.length ? "→" : "✓", function () { show(steps, i + 1); }));
+    box.appendChild(nav);
+    document.body.appendChild(box);
+
+    var target = step.selector && document.querySelector(step.selector);
+    if (target) {
+      target.__tourOutline = target.style.outline;
+      target.style.outline = "3px solid #f90";
+      target.scrollIntoView({block: "center", behavior: "smooth"});
+      highlighted = target;
+    }
+  }
+
+  fetch("/__launcher/tour-steps.json")
+    .then(function (r) { return r.json(); })
+    .then(function (data) {
+      var steps = Array.isArray(data) ? data : data.steps || [];
+      show(steps, current());
+    });
+})();
@@ -0,0 +1,20 @@
+// Injected by the demo launcher into every page of an app window. The
+// connection stays open while the page is shown; once no page holds one,
+// the launcher takes the window to be closed and ends the demo.
+// When the demo ends, the page waits for the exit page to be served on
+// the same address and shows it.
+new EventSource("/__launcher/window").addEventListener("exit", function (e) {
+  this.close();
+  var page = e.data;
+  (function show() {
+    fetch(page, { cache: "no-store" }).then(function (r) {
+      if (r.headers.get("X-Demo-Exit-Page")) {
+        location.replace(page);
+      } else {
+        setTimeout(show, 250);
+      }
+    }, function () {
+      setTimeout(show, 250);
+    });
+  })();
+});
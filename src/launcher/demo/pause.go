@@ -0,0 +1,90 @@
+package demo
+
+import (
+	_ "embed"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//go:embed pages/paused.html
+var defaultPausePage string
+
+// pauseHandler answers every request with the pause page while the demo is
+// paused. PHP keeps running behind it, so resuming is instant.
+type pauseHandler struct {
+	page string
+}
+
+func (p *pauseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", "5")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprint(w, p.page)
+}
+
+// renderPausePage loads the manifest's pause_page from the bundle, or the
+// built-in page that reloads itself until the demo resumes. {{app_name}}
+// is replaced in both.
+func (l *Launcher) renderPausePage() (string, error) {
+	page := defaultPausePage
+	if l.Config.PausePage != "" {
+		data, err := ioutil.ReadFile(l.bundlePath(l.Config.PausePage))
+		if err != nil {
+			return "", err
+		}
+		page = string(data)
+	}
+	r := strings.NewReplacer(
+		"{{app_name}}", html.EscapeString(l.Config.AppName),
+		"{{title}}", html.EscapeString(msg("paused_title")),
+		"{{text}}", html.EscapeString(msg("paused_text")),
+	)
+	return r.Replace(page), nil
+}
+
+// Pause puts the pause page in front of the app and, with
+// pause_stops_timer, stops the demo clock.
+func (l *Launcher) Pause() error {
+	l.pauseMu.Lock()
+	defer l.pauseMu.Unlock()
+	if l.paused {
+		return nil
+	}
+	page, err := l.renderPausePage()
+	if err != nil {
+		return fmt.Errorf("rendering pause page: %w", err)
+	}
+	l.front.Set(&pauseHandler{page: page})
+	if l.expiry != nil && l.Config.PauseStopsTimer {
+		l.expiry.SetStopped(true)
+	}
+	l.paused = true
+	fmt.Println(msg("demo_paused"))
+	return nil
+}
+
+// Resume puts the app back.
+func (l *Launcher) Resume() error {
+	l.pauseMu.Lock()
+	defer l.pauseMu.Unlock()
+	if !l.paused {
+		return nil
+	}
+	l.front.Set(l.proxy)
+	if l.expiry != nil {
+		l.expiry.SetStopped(false)
+	}
+	l.paused = false
+	fmt.Println(msg("demo_resumed"))
+	return nil
+}
+
+func (l *Launcher) isPaused() bool {
+	l.pauseMu.Lock()
+	defer l.pauseMu.Unlock()
+	return l.paused
+}
@@ -0,0 +1,183 @@
+package demo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// payloadFile is the compressed tarball the builder packs the bundle into,
+// so thousands of small vendor files become one embedded file that is
+// streamed to disk. checksums.json and its signature stay next to it.
+// Bundles staged file by file, as older builds did, still work.
+const payloadFile = "payload.tar.gz"
+
+// hasPayload reports whether the bundle in fsys is packed into payloadFile,
+// plain or sealed.
+func hasPayload(fsys fs.FS) bool {
+	return payloadName(fsys) != ""
+}
+
+func payloadName(fsys fs.FS) string {
+	for _, name := range []string{payloadFile, sealedPayloadFile} {
+		if _, err := fs.Stat(fsys, path.Join(bundleRoot, name)); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// payloadReader reads the entries of payloadFile in order.
+type payloadReader struct {
+	*tar.Reader
+	file fs.File
+	zr   *gzip.Reader
+}
+
+func openPayload(fsys fs.FS) (*payloadReader, error) {
+	name := payloadName(fsys)
+	f, err := fsys.Open(path.Join(bundleRoot, name))
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = f
+	if name == sealedPayloadFile {
+		if r, err = newUnsealReader(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &payloadReader{Reader: tar.NewReader(zr), file: f, zr: zr}, nil
+}
+
+func (p *payloadReader) Close() error {
+	p.zr.Close()
+	return p.file.Close()
+}
+
+// next returns the following entry with its slash-separated name relative
+// to the bundle root, or io.EOF after the last.
+func (p *payloadReader) next() (*tar.Header, string, error) {
+	hdr, err := p.Next()
+	if err != nil {
+		return nil, "", err
+	}
+	return hdr, strings.TrimSuffix(strings.TrimPrefix(hdr.Name, "./"), "/"), nil
+}
+
+// openBundleFile opens the bundle file rel. From the payload that means
+// decompressing up to it, which is fine for the few files read before
+// extraction (icon, splash screen, agreement), not for extracting.
+func openBundleFile(fsys fs.FS, rel string) (io.ReadCloser, error) {
+	if !hasPayload(fsys) {
+		return fsys.Open(path.Join(bundleRoot, rel))
+	}
+	p, err := openPayload(fsys)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		hdr, name, err := p.next()
+		if err == io.EOF {
+			p.Close()
+			return nil, &fs.PathError{Op: "open", Path: path.Join(bundleRoot, rel), Err: fs.ErrNotExist}
+		}
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		if name == rel && hdr.Typeflag == tar.TypeReg {
+			return struct {
+				io.Reader
+				io.Closer
+			}{p, p}, nil
+		}
+	}
+}
+
+// readBundleFile is fs.ReadFile for a bundle file, packed or not.
+func readBundleFile(fsys fs.FS, rel string) ([]byte, error) {
+	f, err := openBundleFile(fsys, rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// payloadJobMax is the largest file handed to the pool in memory; bigger
+// ones are streamed from the tarball on the spot.
+const payloadJobMax = copyBufferSize
+
+// extractPayload streams the payload to below dest in one pass, handing
+// the entries want accepts to pool. Decompressing is serial, so small
+// files are read into memory and written by the pool.
+func extractPayload(fsys fs.FS, dest string, want func(rel string) bool, pool *extractPool) error {
+	p, err := openPayload(fsys)
+	if errors.Is(err, errPayloadKey) {
+		return err
+	}
+	if err != nil {
+		return damagedPayload(err)
+	}
+	defer p.Close()
+	for {
+		hdr, rel, err := p.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return damagedPayload(err)
+		}
+		if rel == "" || !want(rel) {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			dir, err := safeJoin(dest, rel)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			// The mode pack and the builder recorded, still writable so
+			// the next run can extract over it
+			mode := hdr.FileInfo().Mode().Perm() | 0600
+			if hdr.Size > payloadJobMax {
+				pool.record(writeBundleFile(dest, rel, p, mode))
+				continue
+			}
+			data := make([]byte, hdr.Size)
+			if _, err := io.ReadFull(p, data); err != nil {
+				return damagedPayload(err)
+			}
+			if !pool.submit(func() (extractedFile, error) { return writeBundleFile(dest, rel, bytes.NewReader(data), mode) }) {
+				return nil // wait reports the error
+			}
+		default:
+			// The builders only pack files and folders
+			return &unsafeEntryError{rel, "not a file or folder"}
+		}
+	}
+}
+
+// damagedPayload reports a payload that can't be read to the end, e.g.
+// because the executable was cut short by a download.
+func damagedPayload(err error) error {
+	return &integrityError{[]string{fmt.Sprintf("%s: %v", payloadFile, err)}}
+}
@@ -0,0 +1,492 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolvePHPBinary returns the PHP executable to run. The bundled binary is
+// resolved relative to baseDir; falling back to a system-wide php is opt-in
+// because a missing bundled binary usually means something deleted it.
+func resolvePHPBinary(config *Manifest, baseDir string) (string, error) {
+	phpBin, err := platformPHPBinary(config, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", err
+	}
+	if phpBin != "" && !filepath.IsAbs(phpBin) {
+		phpBin = filepath.Join(baseDir, phpBin)
+	}
+
+	if phpBin != "" {
+		if _, err := os.Stat(phpBin); os.IsNotExist(err) {
+			if alt := platformExecutable(phpBin, runtime.GOOS); alt != phpBin {
+				if _, err := os.Stat(alt); err == nil {
+					phpBin = alt
+				}
+			}
+		}
+		if _, err := os.Stat(phpBin); err == nil {
+			return phpBin, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	if config.AllowSystemPHP {
+		fmt.Println("Bundled PHP not found, using system php (allow_system_php is set).")
+		return "php", nil
+	}
+
+	if phpBin == "" {
+		return "", errors.New("php_binary_path is not set and allow_system_php is false")
+	}
+	return "", fmt.Errorf("bundled PHP binary %s is missing.\n%s", phpBin, diagnosePHPBinary(phpBin, os.ErrNotExist))
+}
+
+// hasBundledPHP reports whether the bundle in baseDir has a PHP binary for
+// this machine.
+func hasBundledPHP(config *Manifest, baseDir string) bool {
+	bin, err := platformPHPBinary(config, runtime.GOOS, runtime.GOARCH)
+	if err != nil || bin == "" {
+		return false
+	}
+	if !filepath.IsAbs(bin) {
+		bin = filepath.Join(baseDir, bin)
+	}
+	for _, p := range []string{bin, platformExecutable(bin, runtime.GOOS)} {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// platformPHPBinary is the PHP binary for goos/goarch: from php_binaries,
+// keyed "darwin/arm64" or just "darwin", or else php_binary_path. A bundle
+// with php_binaries but none for this platform is an error rather than a
+// fall back to system php, which would run some other PHP version, unless
+// php_downloads has one to fetch.
+func platformPHPBinary(config *Manifest, goos, goarch string) (string, error) {
+	if len(config.PHPBinaries) == 0 {
+		return config.PHPBinaryPath, nil
+	}
+	for _, key := range []string{goos + "/" + goarch, goos} {
+		if bin, ok := config.PHPBinaries[key]; ok {
+			return bin, nil
+		}
+	}
+	if config.PHPBinaryPath != "" {
+		return config.PHPBinaryPath, nil
+	}
+	if _, ok := platformPHPDownload(config, goos, goarch); ok {
+		return "", nil
+	}
+	return "", fmt.Errorf("no bundled PHP for %s/%s: php_binaries only has %s", goos, goarch, strings.Join(sortedKeys(config.PHPBinaries), ", "))
+}
+
+// platformExecutable is executable p as named on goos, with .exe on
+// Windows and without elsewhere, so one php_binary_path serves a launcher
+// packed for several platforms.
+func platformExecutable(p, goos string) string {
+	ext := filepath.Ext(p)
+	if goos == "windows" {
+		if ext == "" {
+			return p + ".exe"
+		}
+		return p
+	}
+	if strings.EqualFold(ext, ".exe") {
+		return strings.TrimSuffix(p, ext)
+	}
+	return p
+}
+
+// diagnosePHPBinary works out why the bundled PHP binary could not be run
+// and returns a message telling the user what to do about it. Antivirus and
+// application-control policies are by far the most common cause on Windows,
+// so each of their symptoms gets its own explanation.
+func diagnosePHPBinary(phpBin string, startErr error) string {
+	name := filepath.Base(phpBin)
+	dir := filepath.Dir(phpBin)
+
+	info, err := os.Stat(phpBin)
+	if os.IsNotExist(err) {
+		return fmt.Sprintf("Your antivirus probably removed %s; add an exclusion for %s or extract the demo to a different folder with --work-dir.", name, dir)
+	}
+	if err != nil {
+		return fmt.Sprintf("Cannot inspect %s: %v", phpBin, err)
+	}
+	if info.Size() == 0 {
+		return fmt.Sprintf("%s is empty (0 bytes), which usually means it was quarantined by antivirus software; restore it or extract the demo to a different folder with --work-dir.", name)
+	}
+
+	if msg := classifyExecError(name, dir, startErr); msg != "" {
+		return msg
+	}
+
+	// Start may fail for reasons unrelated to the binary itself, so see
+	// whether the binary runs on its own.
+	out, err := newCommand(phpBin, "-v").CombinedOutput()
+	if err != nil {
+		if msg := classifyExecError(name, dir, err); msg != "" {
+			return msg
+		}
+		if hasMarkOfTheWeb(phpBin) {
+			return fmt.Sprintf("%s is marked as downloaded from the internet and was blocked; unblock the demo archive (Properties > Unblock) before extracting it.", name)
+		}
+		return fmt.Sprintf("%s -v failed: %v\n%s", name, err, out)
+	}
+
+	if hasMarkOfTheWeb(phpBin) {
+		return fmt.Sprintf("%s runs, but is marked as downloaded from the internet; if it keeps failing, unblock the demo archive (Properties > Unblock) before extracting it.", name)
+	}
+	return fmt.Sprintf("%s runs on its own; the failure is not caused by a blocked binary.", name)
+}
+
+// phpServer is a `php -S` process, or php-fpm with server_mode "fpm",
+// that can be stopped and started again, e.g. to swap data files
+// underneath it.
+type phpServer struct {
+	bin     string
+	addr    string
+	docRoot string
+	dir     string // working directory
+	env     []string
+	output  *outputRing // keeps recent PHP output; may be nil
+	args    []string    // command line after bin; -S addr -t docRoot when nil
+	// grace is how long Stop waits for the server to exit once asked,
+	// so requests in flight and SQLite writes can finish, before killing
+	// it; php-fpm also takes its workers down with it that way
+	grace time.Duration
+
+	// command creates the process; newCommand when nil
+	command func(name string, arg ...string) *exec.Cmd
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	tree     *processTree  // cmd and the workers it starts
+	exited   chan struct{} // closed when cmd has exited
+	started  time.Time
+	restarts int         // crashes in a row
+	restart  *time.Timer // pending restart after a crash
+	onGiveUp func(error) // called once the server is left stopped
+}
+
+// PHP that exits without being asked to is started again, first after
+// phpRestartFirstDelay and twice as long each time after that, until
+// phpMaxRestarts in a row have failed. A server that stayed up for
+// phpStableAfter starts counting again.
+const (
+	phpRestartFirstDelay = time.Second
+	phpMaxRestarts       = 5
+	phpStableAfter       = time.Minute
+)
+
+// Start launches the server process.
+func (s *phpServer) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restarts = 0
+	return s.start()
+}
+
+// OnGiveUp sets what happens when the server keeps crashing and is no
+// longer restarted.
+func (s *phpServer) OnGiveUp(f func(error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onGiveUp = f
+}
+
+// outputWriter forwards PHP's output for debugging, keeping a copy of the
+// tail.
+func (s *phpServer) outputWriter() io.Writer {
+	w := processOutput("php")
+	if s.output != nil {
+		w = io.MultiWriter(w, s.output)
+	}
+	return w
+}
+
+func (s *phpServer) start() error {
+	s.cancelRestart()
+	command := s.command
+	if command == nil {
+		command = newCommand
+	}
+	args := s.args
+	if args == nil {
+		args = []string{"-S", s.addr, "-t", s.docRoot}
+	}
+	cmd := command(s.bin, args...)
+	cmd.Env = s.env
+	cmd.Dir = s.dir
+	ownProcessGroup(cmd)
+	w := s.outputWriter()
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	s.cmd = cmd
+	s.started = time.Now()
+	logger().Debug("PHP started", "pid", cmd.Process.Pid, "addr", s.addr)
+	tree := newProcessTree(cmd.Process)
+	s.tree = tree
+	exited := make(chan struct{})
+	s.exited = exited
+	go func() {
+		err := cmd.Wait()
+		tree.Close()
+		close(exited)
+		s.crashed(cmd, err)
+	}()
+	return nil
+}
+
+func (s *phpServer) cancelRestart() {
+	if s.restart != nil {
+		s.restart.Stop()
+		s.restart = nil
+	}
+}
+
+// crashed restarts the server if cmd exited without stop asking it to.
+func (s *phpServer) crashed(cmd *exec.Cmd, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd != cmd {
+		return // stopped or restarted on purpose
+	}
+	s.cmd = nil
+	logger().Debug("PHP exited", "pid", cmd.Process.Pid, "addr", s.addr, "uptime", time.Since(s.started), "err", err)
+	if time.Since(s.started) >= phpStableAfter {
+		s.restarts = 0
+	}
+	s.scheduleRestart(fmt.Errorf("PHP on %s exited: %v", s.addr, err))
+}
+
+// scheduleRestart starts the server again after the backoff delay, or
+// gives up once phpMaxRestarts in a row have failed.
+func (s *phpServer) scheduleRestart(cause error) {
+	if s.restarts >= phpMaxRestarts {
+		fmt.Printf("%v; giving up after %d restarts\n", cause, s.restarts)
+		if s.onGiveUp != nil {
+			goSafe("PHP give-up", func() { s.onGiveUp(cause) })
+		}
+		return
+	}
+	delay := phpRestartFirstDelay << s.restarts
+	s.restarts++
+	fmt.Printf("%v; restarting in %s\n", cause, delay)
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		defer RecoverCrash("PHP restart")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.restart != t {
+			return // stopped or started in the meantime
+		}
+		s.restart = nil
+		if err := s.start(); err != nil {
+			s.scheduleRestart(fmt.Errorf("restarting PHP on %s: %w", s.addr, err))
+		}
+	})
+	s.restart = t
+}
+
+// Stop asks the server process to exit, kills it once its grace period is
+// up and waits for it to exit, which releases its handles on the files it
+// had open.
+func (s *phpServer) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop(s.grace)
+}
+
+// Kill stops the server without a grace period, for when the OS won't
+// wait for it.
+func (s *phpServer) Kill() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop(0)
+}
+
+// Restart stops and starts the server in one step, so nothing else can
+// restart it in between. Like Start, it gives a PHP that kept crashing
+// another round of automatic restarts.
+func (s *phpServer) Restart() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restarts = 0
+	s.stop(s.grace)
+	return s.start()
+}
+
+// Pid returns the server's process ID, or 0 while it isn't running.
+func (s *phpServer) Pid() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd == nil {
+		return 0
+	}
+	return s.cmd.Process.Pid
+}
+
+// phpPool is the php -S workers behind the proxy (php_workers), which are
+// stopped and started together around a data reset.
+type phpPool []*phpServer
+
+// Start starts every worker, stopping at the first that fails.
+func (p phpPool) Start() error {
+	for _, s := range p {
+		if err := s.Start(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops every worker and returns the first error. The workers stop
+// side by side, so the grace periods don't add up.
+func (p phpPool) Stop() error {
+	errs := make([]error, len(p))
+	var wg sync.WaitGroup
+	for i, s := range p {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.Stop()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitReady waits until every worker accepts connections.
+func (p phpPool) WaitReady(timeout time.Duration, clock Clock) error {
+	for _, s := range p {
+		if err := s.WaitReady(timeout, clock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restart restarts the workers one after the other, so with php_workers
+// the others keep serving meanwhile.
+func (p phpPool) Restart() error {
+	for _, s := range p {
+		if err := s.Restart(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Kill kills every worker without a grace period.
+func (p phpPool) Kill() error {
+	var first error
+	for _, s := range p {
+		if err := s.Kill(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (s *phpServer) stop(grace time.Duration) error {
+	s.cancelRestart()
+	if s.cmd == nil {
+		return nil
+	}
+	err := stopProcess(s.tree, s.exited, grace)
+	s.cmd = nil
+	return err
+}
+
+// stopProcess interrupts tree, gives it grace to exit and kills it after
+// that, then waits until exited is closed. A grace of 0 kills at once.
+func stopProcess(tree *processTree, exited <-chan struct{}, grace time.Duration) error {
+	if grace > 0 && tree.Interrupt() == nil {
+		select {
+		case <-exited:
+			return nil
+		case <-time.After(grace):
+			fmt.Printf("Still running after %s; killing pid %d\n", grace, tree.p.Pid)
+		}
+	}
+	err := tree.Kill()
+	<-exited
+	return err
+}
+
+// defaultShutdownGraceSeconds is how long PHP and the side processes get
+// to exit on their own when shutdown_grace_seconds isn't set.
+const defaultShutdownGraceSeconds = 5
+
+func shutdownGrace(config *Manifest) time.Duration {
+	return time.Duration(orDefault(config.ShutdownGraceSeconds, defaultShutdownGraceSeconds)) * time.Second
+}
+
+// errPortInUse means the port PHP was given got taken by another process
+// between getFreePort and PHP binding it.
+var errPortInUse = errors.New("port was taken by another process")
+
+// WaitReady waits until PHP accepts connections, telling a port lost to
+// another process apart from PHP failing to start.
+func (s *phpServer) WaitReady(timeout time.Duration, clock Clock) error {
+	s.mu.Lock()
+	exited := s.exited
+	s.mu.Unlock()
+
+	deadline := clock.Now().Add(timeout)
+	for failures := 0; ; failures++ {
+		conn, err := net.DialTimeout("tcp", s.addr, time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		select {
+		case <-exited:
+			// Something else may have answered on the stolen port
+			if s.output != nil && portInUse(s.output.Lines()) {
+				return errPortInUse
+			}
+			return errors.New("PHP exited right after starting; see its output above")
+		default:
+		}
+		if err == nil {
+			return nil
+		}
+		if clock.Now().After(deadline) {
+			return fmt.Errorf("PHP did not accept connections on %s within %s; see its output above, or raise startup_timeout_seconds", s.addr, timeout)
+		}
+		<-clock.After(probeWait(failures))
+	}
+}
+
+// portInUse recognizes php -S failing to bind because the address is
+// taken, on Unix and on Windows.
+func portInUse(output []string) bool {
+	for _, line := range output {
+		if strings.Contains(line, "Failed to listen") &&
+			(strings.Contains(line, "in use") || strings.Contains(line, "Only one usage")) {
+			return true
+		}
+	}
+	return false
+}
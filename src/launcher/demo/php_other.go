@@ -0,0 +1,23 @@
+//go:build !windows
+
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// classifyExecError maps well-known exec failures to advice. It returns an
+// empty string when the error is not one it recognizes.
+func classifyExecError(name, dir string, err error) string {
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Sprintf("%s is not executable; run chmod +x on it or extract the demo to a different folder with --work-dir.", name)
+	}
+	return ""
+}
+
+// hasMarkOfTheWeb is a Windows concept; other platforms never report it.
+func hasMarkOfTheWeb(path string) bool {
+	return false
+}
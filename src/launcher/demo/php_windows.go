@@ -0,0 +1,48 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Windows error and NTSTATUS codes reported when antivirus or
+// application-control policies refuse to run a binary.
+const (
+	errorAccessDenied           = syscall.Errno(5)
+	errorVirusInfected          = syscall.Errno(225)
+	errorVirusDeleted           = syscall.Errno(226)
+	errorAccessDisabledByPolicy = syscall.Errno(1260)
+	statusAccessDenied          = 0xC0000022
+)
+
+// classifyExecError maps well-known Windows failures to advice. It returns
+// an empty string when the error is not one it recognizes.
+func classifyExecError(name, dir string, err error) string {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case errorVirusInfected, errorVirusDeleted:
+			return fmt.Sprintf("Windows reported %s as infected and refused to run it (antivirus false positive); add an exclusion for %s or extract the demo to a different folder with --work-dir.", name, dir)
+		case errorAccessDisabledByPolicy:
+			return fmt.Sprintf("A group policy (AppLocker or Software Restriction) blocks %s; ask your IT department to allow programs in %s.", name, dir)
+		case errorAccessDenied:
+			return fmt.Sprintf("Access to %s was denied, usually by antivirus or SmartScreen; add an exclusion for %s or extract the demo to a different folder with --work-dir.", name, dir)
+		}
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && uint32(exitErr.ExitCode()) == statusAccessDenied {
+		return fmt.Sprintf("%s was started but killed with STATUS_ACCESS_DENIED (0xC0000022), typically by antivirus; add an exclusion for %s.", name, dir)
+	}
+	return ""
+}
+
+// hasMarkOfTheWeb reports whether the file carries a Zone.Identifier
+// alternate data stream, meaning it came from an internet download.
+func hasMarkOfTheWeb(path string) bool {
+	_, err := os.Stat(path + ":Zone.Identifier")
+	return err == nil
+}
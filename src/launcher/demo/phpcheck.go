@@ -0,0 +1,156 @@
+package demo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PHPRequirements is what the demo needs of PHP, checked before it starts
+// so a system php, or a bundled build, that lacks them is reported in one
+// go instead of as a crash halfway through the first page.
+type PHPRequirements struct {
+	// MinVersion defaults to the "php" constraint in composer.json
+	MinVersion string `json:"min_version"`
+	// Extensions are added to Laravel's own, pdo_sqlite for db_type
+	// "sqlite" and the ext-* requirements of composer.json
+	Extensions []string `json:"extensions"`
+}
+
+// laravelExtensions are the extensions every Laravel app loads.
+var laravelExtensions = []string{"ctype", "mbstring", "openssl", "tokenizer"}
+
+var versionPrefix = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// phpPreflightScript prints the version and then the loaded extensions
+// one per line, what php -v and php -m tell, in one process.
+const phpPreflightScript = `echo PHP_VERSION, "\n", implode("\n", get_loaded_extensions()), "\n";`
+
+// checkPHPRequirements runs phpBin once, with iniArgs, to compare its
+// version and extensions with what the manifest and composer.json in
+// appRoot require.
+// A bundled binary that doesn't run at all is left to the real start,
+// which explains why.
+func checkPHPRequirements(command func(string, ...string) *exec.Cmd, phpBin string, iniArgs []string, config *Manifest, appRoot string) error {
+	out, err := command(phpBin, append(iniArgs, "-r", phpPreflightScript)...).Output()
+	if err != nil {
+		if phpBin == "php" {
+			return fmt.Errorf("no usable php on PATH: %w", err)
+		}
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	version := strings.TrimSpace(lines[0])
+	if phpBin == "php" {
+		fmt.Printf("Using system PHP %s\n", version)
+	}
+	loaded := make(map[string]bool)
+	for _, ext := range lines[1:] {
+		loaded[strings.ToLower(strings.TrimSpace(ext))] = true
+	}
+
+	minVersion, extensions := phpRequirements(config, appRoot)
+	var problems []string
+	if need, have := versionPrefix.FindStringSubmatch(minVersion), versionPrefix.FindStringSubmatch(version); need != nil && have != nil && compareMinor(have, need) < 0 {
+		problems = append(problems, fmt.Sprintf("PHP %s is too old; the demo needs %s or newer", version, need[0]))
+	}
+	var missing []string
+	for _, ext := range extensions {
+		if !loaded[strings.ToLower(ext)] {
+			missing = append(missing, ext)
+		}
+	}
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing extensions: %s", strings.Join(missing, ", ")))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s can't run the demo:\n", phpDisplayName(phpBin))
+	for _, p := range problems {
+		fmt.Fprintf(&b, "  - %s\n", p)
+	}
+	if phpBin == "php" {
+		b.WriteString("Install a newer PHP or the missing extensions with your package manager (e.g. php-mbstring, php-sqlite3), or enable them in php.ini with extension=<name>; `php --ini` shows which php.ini is used.")
+	} else {
+		b.WriteString("The bundled PHP build lacks them; bundle a build with these extensions compiled in or enabled in its php.ini.")
+	}
+	return errors.New(b.String())
+}
+
+func phpDisplayName(phpBin string) string {
+	if phpBin == "php" {
+		return "the php on PATH"
+	}
+	return "the PHP at " + phpBin
+}
+
+// phpRequirements are the minimum version and the extensions the demo
+// needs, from php_requirements, db_type and composer.json.
+func phpRequirements(config *Manifest, appRoot string) (string, []string) {
+	minVersion := config.PHPRequirements.MinVersion
+	seen := make(map[string]bool)
+	var extensions []string
+	add := func(ext string) {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext != "" && !seen[ext] {
+			seen[ext] = true
+			extensions = append(extensions, ext)
+		}
+	}
+	for _, ext := range laravelExtensions {
+		add(ext)
+	}
+	if config.DBType == "sqlite" {
+		add("pdo_sqlite")
+	}
+	for _, ext := range config.PHPRequirements.Extensions {
+		add(ext)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(appRoot, "composer.json"))
+	if err != nil {
+		return minVersion, extensions
+	}
+	var composer struct {
+		Require map[string]string `json:"require"`
+	}
+	if json.Unmarshal(data, &composer) != nil {
+		return minVersion, extensions
+	}
+	if minVersion == "" {
+		minVersion = composer.Require["php"]
+	}
+	var required []string
+	for name := range composer.Require {
+		if strings.HasPrefix(name, "ext-") {
+			required = append(required, strings.TrimPrefix(name, "ext-"))
+		}
+	}
+	sort.Strings(required)
+	for _, ext := range required {
+		add(ext)
+	}
+	return minVersion, extensions
+}
+
+// compareMinor compares two major.minor matches of versionPrefix.
+func compareMinor(a, b []string) int {
+	for i := 1; i <= 2; i++ {
+		x, _ := strconv.Atoi(a[i])
+		y, _ := strconv.Atoi(b[i])
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
@@ -0,0 +1,270 @@
+package demo
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// A "thin" launcher ships without PHP: php_downloads pins a static PHP
+// build per platform by URL and SHA-256, and the first launch on a machine
+// downloads it into the user data folder, where every later launch and
+// every demo pinning the same build finds it.
+
+// phpDownloads are the PHP builds of php_downloads, keyed "os/arch" or
+// "os" like php_binaries.
+type phpDownloads map[string]PHPDownload
+
+// PHPDownload is a static PHP build the launcher fetches on first launch.
+type PHPDownload struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	// Binary is the PHP executable inside a .zip or .tar.gz, slash-
+	// separated; php or php.exe by default. A download that isn't an
+	// archive is the binary itself.
+	Binary string `json:"binary"`
+}
+
+// binary is the executable's path in the extracted download for goos.
+func (d PHPDownload) binary(goos string) string {
+	if d.Binary != "" {
+		return d.Binary
+	}
+	return platformExecutable("php", goos)
+}
+
+// archive is the archive format the URL names, or "" for a bare binary.
+func (d PHPDownload) archive() string {
+	name := strings.ToLower(d.URL)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// platformPHPDownload is the php_downloads entry for goos/goarch.
+func platformPHPDownload(config *Manifest, goos, goarch string) (PHPDownload, bool) {
+	for _, key := range []string{goos + "/" + goarch, goos} {
+		if d, ok := config.PHPDownloads[key]; ok {
+			return d, true
+		}
+	}
+	return PHPDownload{}, false
+}
+
+func sortedPHPDownloadKeys(downloads phpDownloads) []string {
+	keys := make([]string, 0, len(downloads))
+	for k := range downloads {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// phpRuntimeDir is where the download with checksum sum is kept, shared by
+// every demo on the machine.
+func phpRuntimeDir(sum string) (string, error) {
+	dir, err := userDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "laravel_demo", "php", strings.ToLower(sum)), nil
+}
+
+// downloadedPHP returns the downloaded PHP binary for this machine,
+// fetching it first when it isn't cached yet, or "" when the bundle has
+// PHP of its own or php_downloads has nothing for this platform.
+func (l *Launcher) downloadedPHP() (string, error) {
+	d, ok := platformPHPDownload(&l.Config, runtime.GOOS, runtime.GOARCH)
+	if !ok {
+		return "", nil
+	}
+	if hasBundledPHP(&l.Config, l.baseDir) {
+		return "", nil
+	}
+	dir, err := phpRuntimeDir(d.SHA256)
+	if err != nil {
+		return "", err
+	}
+	bin, err := lexicalJoin(dir, d.binary(runtime.GOOS))
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(bin); err == nil {
+		return bin, nil
+	}
+	if l.Config.Offline {
+		return "", errors.New("this demo downloads PHP on its first launch, which offline mode doesn't allow; start it once with a network connection")
+	}
+
+	l.banner.Step(msg("setup_downloading_php"))
+	client := *l.HTTPClient
+	// The request timeout suits API calls, not tens of megabytes
+	client.Timeout = 0
+	if err := fetchPHPRuntime(&client, d, dir); err != nil {
+		return "", fmt.Errorf("downloading PHP from %s: %w", d.URL, err)
+	}
+	if _, err := os.Stat(bin); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("the PHP download from %s has no %s", d.URL, d.binary(runtime.GOOS))
+	}
+	return bin, nil
+}
+
+// fetchPHPRuntime downloads d, checks it against its SHA-256 and unpacks it
+// to dir. It works in a folder next to dir and renames it into place last,
+// so an interrupted download is never taken for a complete one.
+func fetchPHPRuntime(client *http.Client, d PHPDownload, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".download-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	resp, err := client.Get(d.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the server answered %s", resp.Status)
+	}
+	download := filepath.Join(tmp, "download")
+	f, err := os.Create(download)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, d.SHA256) {
+		return fmt.Errorf("its SHA-256 is %s, not the pinned %s; the file was changed or is damaged", sum, d.SHA256)
+	}
+
+	out := filepath.Join(tmp, "php")
+	switch d.archive() {
+	case "zip":
+		err = extractZipRuntime(download, out)
+	case "tar.gz":
+		err = extractTarRuntime(download, out)
+	default:
+		var target string
+		if target, err = lexicalJoin(out, d.binary(runtime.GOOS)); err == nil {
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = os.Rename(download, target)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if bin, err := lexicalJoin(out, d.binary(runtime.GOOS)); err == nil {
+		os.Chmod(bin, 0755)
+	}
+	// Another launcher may have finished the same download meanwhile
+	if err := os.Rename(out, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func extractZipRuntime(file, dest string) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		if strings.HasSuffix(zf.Name, "/") {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = writeRuntimeFile(dest, zf.Name, r, zf.Mode())
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarRuntime(file, dest string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// PHP builds are plain files; links and the like are left out
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := writeRuntimeFile(dest, hdr.Name, tr, hdr.FileInfo().Mode()); err != nil {
+			return err
+		}
+	}
+}
+
+// writeRuntimeFile writes the archive entry name below dest.
+func writeRuntimeFile(dest, name string, r io.Reader, mode os.FileMode) error {
+	target, err := safeJoin(dest, path.Clean(strings.TrimPrefix(name, "./")))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package demo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// phpIniPath is where php_ini goes once its placeholders are filled in.
+// Like the php-fpm configuration it's outside the work dir, which
+// --serve-dir points at the developer's checkout.
+func phpIniPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("laravel_demo_php_%d.ini", os.Getpid()))
+}
+
+// preparePHPIni renders php_ini for PHP at phpBin: {{work_dir}},
+// {{app_root}}, {{public_dir}}, {{data_dir}}, {{php_dir}} and {{temp_dir}}
+// become this run's folders, with forward slashes, which PHP takes on
+// Windows too and which need no escaping in quoted values.
+func (l *Launcher) preparePHPIni(phpBin string) error {
+	if l.Config.PHPIni == "" {
+		return nil
+	}
+	src := l.bundlePath(l.Config.PHPIni)
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("reading php_ini: %w", err)
+	}
+	phpDir := ""
+	if phpBin != "php" {
+		phpDir = filepath.Dir(phpBin)
+	}
+	slash := filepath.ToSlash
+	r := strings.NewReplacer(
+		"{{work_dir}}", slash(l.baseDir),
+		"{{app_root}}", slash(l.appRoot),
+		"{{public_dir}}", slash(l.publicDir),
+		"{{data_dir}}", slash(l.dataDir),
+		"{{php_dir}}", slash(phpDir),
+		"{{temp_dir}}", slash(os.TempDir()),
+	)
+	ini := phpIniPath()
+	if err := ioutil.WriteFile(ini, []byte(r.Replace(string(data))), 0644); err != nil {
+		return fmt.Errorf("writing php.ini: %w", err)
+	}
+	l.phpIni = ini
+	return nil
+}
+
+// phpIniArgs are the arguments that start PHP with php_ini instead of the
+// host's php.ini.
+func (l *Launcher) phpIniArgs() []string {
+	if l.phpIni == "" {
+		return nil
+	}
+	return []string{"-c", l.phpIni}
+}
+
+// phpIniEnv points the PHP of setup commands, side processes and whatever
+// PHP starts itself, e.g. a queue worker, at php_ini too.
+func (l *Launcher) phpIniEnv() []string {
+	if l.phpIni == "" {
+		return nil
+	}
+	return []string{"PHPRC=" + l.phpIni}
+}
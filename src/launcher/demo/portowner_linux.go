@@ -0,0 +1,54 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// portOwner names the process listening on port, found through the
+// socket inode in /proc/net/tcp and the fds in /proc. It returns "" when
+// it can't tell, e.g. for another user's process.
+func portOwner(port int) string {
+	sockets := make(map[string]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		data, err := os.ReadFile(table)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			// local_address is IP:PORT in hex, state 0A is LISTEN and
+			// the inode is the tenth field
+			fields := strings.Fields(line)
+			if len(fields) < 10 || fields[3] != "0A" {
+				continue
+			}
+			_, hexPort, _ := strings.Cut(fields[1], ":")
+			if p, err := strconv.ParseUint(hexPort, 16, 16); err == nil && int(p) == port {
+				sockets["socket:["+fields[9]+"]"] = true
+			}
+		}
+	}
+	if len(sockets) == 0 {
+		return ""
+	}
+
+	procs, _ := os.ReadDir("/proc")
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", proc.Name(), "fd")
+		fds, _ := os.ReadDir(fdDir)
+		for _, fd := range fds {
+			if link, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && sockets[link] {
+				name, _ := os.ReadFile(filepath.Join("/proc", proc.Name(), "comm"))
+				return fmt.Sprintf("%s (PID %d)", strings.TrimSpace(string(name)), pid)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+//go:build !linux && !windows
+
+package demo
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// portOwner asks lsof for the process listening on port. It returns ""
+// when it can't tell.
+func portOwner(port int) string {
+	out, err := exec.Command("lsof", "-nP", "-iTCP:"+strconv.Itoa(port), "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return ""
+	}
+	// One field per line: p<pid>, then c<command>
+	var pid int
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "p"):
+			pid, _ = strconv.Atoi(line[1:])
+		case strings.HasPrefix(line, "c") && pid != 0:
+			return fmt.Sprintf("%s (PID %d)", line[1:], pid)
+		}
+	}
+	return ""
+}
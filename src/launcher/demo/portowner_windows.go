@@ -0,0 +1,59 @@
+package demo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// portOwner names the process listening on port, from netstat and
+// tasklist. It returns "" when it can't tell.
+func portOwner(port int) string {
+	netstat := newCommand("netstat", "-ano", "-p", "TCP")
+	if netstat.SysProcAttr == nil {
+		netstat.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	netstat.SysProcAttr.HideWindow = true
+	out, err := netstat.Output()
+	if err != nil {
+		return ""
+	}
+	suffix := ":" + strconv.Itoa(port)
+	for _, line := range strings.Split(string(out), "\n") {
+		// The state column is translated, but only listening sockets
+		// have no foreign port
+		fields := strings.Fields(line)
+		if len(fields) != 5 || !strings.HasSuffix(fields[1], suffix) || !strings.HasSuffix(fields[2], ":0") {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil {
+			continue
+		}
+		if name := processName(pid); name != "" {
+			return fmt.Sprintf("%s (PID %d)", name, pid)
+		}
+		return fmt.Sprintf("PID %d", pid)
+	}
+	return ""
+}
+
+// processName returns the image name tasklist reports for pid.
+func processName(pid int) string {
+	tasklist := newCommand("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH")
+	if tasklist.SysProcAttr == nil {
+		tasklist.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	tasklist.SysProcAttr.HideWindow = true
+	out, err := tasklist.Output()
+	if err != nil {
+		return ""
+	}
+	record, err := csv.NewReader(strings.NewReader(string(out))).Read()
+	if err != nil || len(record) < 2 || record[1] != strconv.Itoa(pid) {
+		return ""
+	}
+	return record[0]
+}
@@ -0,0 +1,37 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, which is 100 on every Linux architecture Go
+// supports.
+const clockTicks = 100
+
+// sampleProcess reads the resident memory and CPU time used so far from
+// /proc.
+func sampleProcess(pid int) (procSample, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procSample{}, err
+	}
+	// The command name in parentheses may contain spaces
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	if len(fields) < 22 {
+		return procSample{}, fmt.Errorf("unexpected /proc/%d/stat format", pid)
+	}
+	// Counting from the state field: utime is the 12th, stime the 13th and
+	// rss (in pages) the 22nd
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	rss, _ := strconv.ParseUint(fields[21], 10, 64)
+
+	return procSample{
+		RSS: rss * uint64(os.Getpagesize()),
+		CPU: time.Duration(utime+stime) * time.Second / clockTicks,
+	}, nil
+}
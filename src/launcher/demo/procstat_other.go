@@ -0,0 +1,61 @@
+//go:build !linux && !windows
+
+package demo
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sampleProcess asks ps for the resident memory and CPU time used so far.
+func sampleProcess(pid int) (procSample, error) {
+	out, err := exec.Command("ps", "-o", "rss=,time=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return procSample{}, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return procSample{}, fmt.Errorf("unexpected ps output %q", out)
+	}
+	rss, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return procSample{}, err
+	}
+	cpu, err := parsePSTime(fields[1])
+	if err != nil {
+		return procSample{}, err
+	}
+	return procSample{RSS: rss << 10, CPU: cpu}, nil
+}
+
+// parsePSTime parses ps's [[dd-]hh:]mm:ss[.cc] CPU time.
+func parsePSTime(s string) (time.Duration, error) {
+	var total time.Duration
+	if days, rest, ok := strings.Cut(s, "-"); ok {
+		d, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("bad ps time %q", s)
+		}
+		total = time.Duration(d) * 24 * time.Hour
+		s = rest
+	}
+	parts := strings.Split(s, ":")
+	secs, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad ps time %q", s)
+	}
+	total += time.Duration(secs * float64(time.Second))
+	unit := time.Minute
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, fmt.Errorf("bad ps time %q", s)
+		}
+		total += time.Duration(n) * unit
+		unit *= 60
+	}
+	return total, nil
+}
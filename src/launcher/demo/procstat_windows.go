@@ -0,0 +1,56 @@
+package demo
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	psapi                    = syscall.NewLazyDLL("psapi.dll")
+	procGetProcessMemoryInfo = psapi.NewProc("GetProcessMemoryInfo")
+)
+
+const processQueryLimitedInformation = 0x1000
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS.
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// sampleProcess reads the working set and CPU time used so far with
+// GetProcessMemoryInfo and GetProcessTimes.
+func sampleProcess(pid int) (procSample, error) {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return procSample{}, err
+	}
+	defer syscall.CloseHandle(h)
+
+	var mem processMemoryCounters
+	mem.cb = uint32(unsafe.Sizeof(mem))
+	if r, _, err := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&mem)), uintptr(mem.cb)); r == 0 {
+		return procSample{}, err
+	}
+
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return procSample{}, err
+	}
+	// FILETIME durations count 100ns intervals
+	ticks := func(ft syscall.Filetime) int64 { return int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime) }
+
+	return procSample{
+		RSS: uint64(mem.WorkingSetSize),
+		CPU: time.Duration(ticks(kernel)+ticks(user)) * 100,
+	}, nil
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+
+package demo
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// processTree is a started process and the processes it starts. Killing
+// PHP leaves no grandchildren behind here: php -S workers exit with their
+// parent and php-fpm is stopped gracefully.
+type processTree struct {
+	p *os.Process
+}
+
+func newProcessTree(p *os.Process) *processTree {
+	return &processTree{p}
+}
+
+// ownProcessGroup starts cmd in a process group of its own, so a Ctrl+C
+// in the terminal reaches only the launcher, which then stops the process
+// with Interrupt instead of both getting SIGINT at once.
+func ownProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// Interrupt asks the process to exit with SIGTERM.
+func (t *processTree) Interrupt() error {
+	return t.p.Signal(syscall.SIGTERM)
+}
+
+// Kill kills the process.
+func (t *processTree) Kill() error {
+	return t.p.Kill()
+}
+
+// Close releases the tree once the process has exited.
+func (t *processTree) Close() {}
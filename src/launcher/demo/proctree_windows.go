@@ -0,0 +1,137 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject       = kernel32.NewProc("TerminateJobObject")
+	procGenerateConsoleCtrlEvent = kernel32.NewProc("GenerateConsoleCtrlEvent")
+)
+
+const (
+	jobObjectExtendedLimitInformation = 9
+	jobObjectLimitKillOnJobClose      = 0x2000
+	processSetQuota                   = 0x0100
+	processTerminate                  = 0x0001
+	createNewProcessGroup             = 0x00000200
+	ctrlBreakEvent                    = 1
+)
+
+// jobExtendedLimitInformation is JOBOBJECT_EXTENDED_LIMIT_INFORMATION.
+type jobExtendedLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+	IoCounters              [6]uint64
+	ProcessMemoryLimit      uintptr
+	JobMemoryLimit          uintptr
+	PeakProcessMemoryUsed   uintptr
+	PeakJobMemoryUsed       uintptr
+}
+
+// processTree is a started process and the processes it starts. Killing
+// just the process leaves its children (the php -S workers, whatever a
+// side process spawns) running on Windows, holding the port and the work
+// dir, so the tree is put in a Job Object and killed as a whole. The job
+// also dies with the launcher, however it ends.
+type processTree struct {
+	p *os.Process
+
+	mu  sync.Mutex
+	job syscall.Handle // 0 when the job couldn't be set up, or closed
+}
+
+func newProcessTree(p *os.Process) *processTree {
+	t := &processTree{p: p}
+	job, _, _ := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return t
+	}
+	info := jobExtendedLimitInformation{LimitFlags: jobObjectLimitKillOnJobClose}
+	ok, _, _ := procSetInformationJobObject.Call(job, jobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info))
+	if ok != 0 {
+		h, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(p.Pid))
+		if err == nil {
+			ok, _, _ = procAssignProcessToJobObject.Call(job, uintptr(h))
+			syscall.CloseHandle(h)
+		}
+		if err == nil && ok != 0 {
+			t.job = syscall.Handle(job)
+			return t
+		}
+	}
+	syscall.CloseHandle(syscall.Handle(job))
+	return t
+}
+
+// ownProcessGroup starts cmd in a process group of its own, which is what
+// Interrupt addresses with CTRL_BREAK. The group also ignores Ctrl+C, so
+// only the launcher sees it and stops the process gracefully.
+func ownProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= createNewProcessGroup
+}
+
+// Interrupt sends CTRL_BREAK to the process group ownProcessGroup made,
+// which PHP, like most console programs, treats as a request to exit. It
+// fails when the launcher has no console to send it through.
+func (t *processTree) Interrupt() error {
+	ok, _, err := procGenerateConsoleCtrlEvent.Call(ctrlBreakEvent, uintptr(t.p.Pid))
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+// Kill kills the process and everything it started. Without a job,
+// taskkill walks the tree instead.
+func (t *processTree) Kill() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.job != 0 {
+		ok, _, err := procTerminateJobObject.Call(uintptr(t.job), 1)
+		if ok != 0 {
+			return nil
+		}
+		fmt.Printf("Warning: can't end the processes of pid %d together: %v\n", t.p.Pid, err)
+	}
+	cmd := newCommand("taskkill", "/T", "/F", "/PID", strconv.Itoa(t.p.Pid))
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.HideWindow = true
+	if cmd.Run() == nil {
+		return nil
+	}
+	return t.p.Kill()
+}
+
+// Close releases the job once the process has exited, which ends any
+// children still running.
+func (t *processTree) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.job != 0 {
+		syscall.CloseHandle(t.job)
+		t.job = 0
+	}
+}
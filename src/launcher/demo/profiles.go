@@ -0,0 +1,69 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// manifestProfiles are named variants of one manifest, e.g. "sales",
+// "kiosk" and "developer", each a partial manifest laid over the rest.
+type manifestProfiles map[string]json.RawMessage
+
+// profileForbiddenKeys can't appear in a profile: a profile tunes how one
+// bundle runs, it doesn't change what is bundled.
+var profileForbiddenKeys = []string{"apps", "dir", "profiles"}
+
+// ManifestProfile returns the named entry of the manifest's profiles,
+// matched case-insensitively like app names.
+func ManifestProfile(config *Manifest, name string) (json.RawMessage, error) {
+	names := sortedProfileNames(config)
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return config.Profiles[n], nil
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no profile named %q; the manifest has no profiles", name)
+	}
+	return nil, fmt.Errorf("no profile named %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// ApplyProfile lays a profile over config. Objects such as env_vars are
+// merged key by key, so a profile lists only what it changes.
+func ApplyProfile(config *Manifest, profile json.RawMessage) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(profile, &keys); err != nil {
+		return err
+	}
+	for _, k := range profileForbiddenKeys {
+		if _, ok := keys[k]; ok {
+			return fmt.Errorf("%s can't be set in a profile", k)
+		}
+	}
+	// The maps are shared with the manifest the profile came from
+	v := reflect.ValueOf(config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() != reflect.Map || f.IsNil() {
+			continue
+		}
+		clone := reflect.MakeMapWithSize(f.Type(), f.Len())
+		for it := f.MapRange(); it.Next(); {
+			clone.SetMapIndex(it.Key(), it.Value())
+		}
+		f.Set(clone)
+	}
+	return json.Unmarshal(profile, config)
+}
+
+func sortedProfileNames(config *Manifest) []string {
+	names := make([]string, 0, len(config.Profiles))
+	for n := range config.Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,319 @@
+package demo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for the proxy guards, generous enough for normal demos.
+const (
+	defaultMaxRequestBodyMB      = 512
+	defaultRequestTimeoutSeconds = 300
+	defaultMaxConcurrentRequests = 64
+
+	// proxyQueueWait is how long a request waits for a free slot before it
+	// is turned away with 503.
+	proxyQueueWait = 5 * time.Second
+)
+
+// demoProxy sits between the browser and PHP. It owns the public port and
+// keeps a single misbehaving request (a huge upload, a hanging page) from
+// wedging the PHP worker for everyone.
+type demoProxy struct {
+	rp        *httputil.ReverseProxy
+	publicURL *url.URL
+	routes    []proxyRoute // longest prefix first
+	rules     []proxyRule
+	tour      *tourAssets
+	icon      *appIcon
+	static    *staticFiles
+	headers   http.Header // response_headers, added to every response
+	log       *requestLog
+
+	maxBody int64
+	timeout time.Duration
+	slots   chan struct{}
+
+	requests     atomic.Int64
+	inFlight     atomic.Int64
+	tooLarge     atomic.Int64
+	timedOut     atomic.Int64
+	rejectedBusy atomic.Int64
+	blocked      atomic.Int64
+
+	hiccups       atomic.Int64 // upstream failures answered with the hiccup page
+	failuresInRow atomic.Int64 // for the hiccup page's backoff
+
+	blockedPage string
+	config      *Manifest
+	expired     expiryState // set once the demo expired under readonly or nag
+	timeLeft    func() timeRemaining
+
+	uploadsBlocked atomic.Bool            // set while the work dir is over its quota
+	phpFailed      atomic.Bool            // while PHP kept crashing and was left stopped
+	idle           *idleWatch             // told about every request; nil without idle_timeout_minutes
+	window         *windowWatch           // nil unless the demo closes with its app window
+	sendHome       atomic.Bool            // set by an idle reset until the next page load
+	busy           atomic.Pointer[string] // see SetBusy
+}
+
+// newDemoProxy forwards to the PHP server at upstream. publicURL is the
+// address browsers use, reported to PHP in X-Forwarded-* headers.
+func newDemoProxy(config *Manifest, upstreams []*url.URL, publicURL *url.URL) *demoProxy {
+	p := &demoProxy{
+		config:    config,
+		publicURL: publicURL,
+		log:       newRequestLog(),
+		maxBody:   int64(orDefault(config.MaxRequestBodyMB, defaultMaxRequestBodyMB)) << 20,
+		timeout:   time.Duration(orDefault(config.RequestTimeoutSeconds, defaultRequestTimeoutSeconds)) * time.Second,
+		slots:     make(chan struct{}, orDefault(config.MaxConcurrentRequests, defaultMaxConcurrentRequests)),
+		headers:   make(http.Header),
+	}
+	for k, v := range config.ResponseHeaders {
+		p.headers.Set(k, v)
+	}
+	for _, rule := range config.ProxyRules {
+		// ValidateManifest has rejected rules that don't compile
+		if compiled, err := compileProxyRule(rule); err == nil {
+			p.rules = append(p.rules, compiled)
+		}
+	}
+	if len(p.rules) > 0 {
+		p.blockedPage = renderBlockedPage(config)
+	}
+	p.rp = p.reverseProxy(upstreams...)
+	return p
+}
+
+// proxyRoute sends a path prefix to a side process instead of PHP.
+type proxyRoute struct {
+	prefix string
+	rp     *httputil.ReverseProxy
+}
+
+// addRoute forwards requests below prefix to upstream. These are typically
+// websocket connections, which are long-lived, so they bypass the request
+// timeout and the concurrency limit.
+func (p *demoProxy) addRoute(prefix string, upstream *url.URL) {
+	p.routes = append(p.routes, proxyRoute{prefix: prefix, rp: p.reverseProxy(upstream)})
+	sort.SliceStable(p.routes, func(i, j int) bool {
+		return len(p.routes[i].prefix) > len(p.routes[j].prefix)
+	})
+}
+
+// reverseProxy forwards to upstreams, taking turns when there are several
+// PHP workers.
+func (p *demoProxy) reverseProxy(upstreams ...*url.URL) *httputil.ReverseProxy {
+	var next atomic.Uint64
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstreams[(next.Add(1)-1)%uint64(len(upstreams))])
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Forwarded-Port", p.publicURL.Port())
+			if p.tour != nil || p.window != nil {
+				// Plain bodies, so the script tags can be injected
+				pr.Out.Header.Del("Accept-Encoding")
+			}
+		},
+		ModifyResponse: p.dropOwnHeaders,
+		ErrorHandler:   p.handleError,
+	}
+}
+
+// dropOwnHeaders removes the upstream's copies of the response_headers,
+// which ServeHTTP has already set, so the manifest's values win.
+func (p *demoProxy) dropOwnHeaders(resp *http.Response) error {
+	for k := range p.headers {
+		resp.Header.Del(k)
+	}
+	return nil
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func (p *demoProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.requests.Add(1)
+	if r.URL.Path != timeRemainingPath {
+		// Badges poll it on their own; that's no one using the demo
+		p.idle.Touch()
+	}
+	p.setTimeRemaining(w, r)
+	for k, v := range p.headers {
+		w.Header()[k] = v
+	}
+	rec := &statusRecorder{ResponseWriter: w}
+	start := time.Now()
+	defer func() {
+		if !rec.uncounted {
+			p.log.record(r.URL.Path, rec.status)
+		}
+		logger().Debug("request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	}()
+	p.serve(rec, r)
+	if rec.status != 0 && rec.status < 500 {
+		p.failuresInRow.Store(0)
+	}
+}
+
+func (p *demoProxy) serve(w http.ResponseWriter, r *http.Request) {
+	if p.tour != nil && p.tour.serve(w, r) || p.window.serve(w, r) || p.serveTimeRemaining(w, r) || p.icon.serve(w, r) {
+		return
+	}
+	if p.serveSendHome(w, r) || !p.serveExpired(w, r) || !p.applyRules(w, r) {
+		return
+	}
+
+	for _, route := range p.routes {
+		if strings.HasPrefix(r.URL.Path, route.prefix) {
+			route.rp.ServeHTTP(w, r)
+			return
+		}
+	}
+	if p.static.serve(w, r) || p.serveBusy(w, r) {
+		return
+	}
+
+	if p.uploadsBlocked.Load() && isUpload(r) {
+		p.tooLarge.Add(1)
+		http.Error(w, "The demo has run out of space for uploads. Please delete some files or restart the demo.", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if r.ContentLength > p.maxBody {
+		p.tooLarge.Add(1)
+		http.Error(w, fmt.Sprintf("Uploads are limited to %d MB in this demo.", p.maxBody>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-time.After(proxyQueueWait):
+		p.rejectedBusy.Add(1)
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "The demo is busy, please try again in a moment.", http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	defer func() { <-p.slots }()
+
+	p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+
+	ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+	// Catches chunked uploads that don't announce their length
+	r.Body = http.MaxBytesReader(w, r.Body, p.maxBody)
+
+	p.rp.ServeHTTP(w, r)
+}
+
+// setTour serves t and injects it into the app's pages. Side process routes
+// are left alone.
+func (p *demoProxy) setTour(t *tourAssets) {
+	p.tour = t
+	p.rp.ModifyResponse = p.injectScripts
+}
+
+// setWindowWatch serves ww and injects its script into the app's pages
+// and the launcher's own.
+func (p *demoProxy) setWindowWatch(ww *windowWatch) {
+	p.window = ww
+	p.rp.ModifyResponse = p.injectScripts
+}
+
+// injectScripts is the PHP proxy's ModifyResponse once the tour or the
+// window watch put their script tags into pages.
+func (p *demoProxy) injectScripts(resp *http.Response) error {
+	p.dropOwnHeaders(resp)
+	if p.tour != nil {
+		if err := p.tour.inject(resp); err != nil {
+			return err
+		}
+	}
+	if p.window != nil {
+		return injectTag(resp, windowTag)
+	}
+	return nil
+}
+
+// withScripts adds the window watch's tag to a page the launcher serves
+// in place of the app's, so showing it doesn't count as the window
+// having closed.
+func (p *demoProxy) withScripts(page string) string {
+	if p.window == nil {
+		return page
+	}
+	if i := strings.LastIndex(strings.ToLower(page), "</body>"); i >= 0 {
+		return page[:i] + string(windowTag) + page[i:]
+	}
+	return page + string(windowTag)
+}
+
+// SetUploadsBlocked turns refusing uploads on or off.
+func (p *demoProxy) SetUploadsBlocked(blocked bool) {
+	p.uploadsBlocked.Store(blocked)
+}
+
+// isUpload reports whether r carries a file upload.
+func isUpload(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "multipart/form-data"
+}
+
+// handleError maps upstream failures caused by the guards to the matching
+// status codes. Timeouts and PHP not answering at all get the hiccup page.
+func (p *demoProxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		p.tooLarge.Add(1)
+		http.Error(w, fmt.Sprintf("Uploads are limited to %d MB in this demo.", p.maxBody>>20), http.StatusRequestEntityTooLarge)
+	case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+		p.timedOut.Add(1)
+		p.serveHiccup(w, r, http.StatusGatewayTimeout, "The demo took too long to respond.")
+	case r.Context().Err() != nil:
+		// The browser went away; nobody is listening for a response
+	case p.serveBusy(w, r):
+		// PHP was stopped under the request for a data reset or export
+	case p.phpFailed.Load():
+		p.servePHPFailed(w, r)
+	default:
+		fmt.Printf("Proxy error for %s: %v\n", r.URL.Path, err)
+		p.serveHiccup(w, r, http.StatusBadGateway, "The demo could not answer, please try again.")
+	}
+}
+
+// Stats reports the configured limits and how often they were hit.
+func (p *demoProxy) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"max_request_body_mb":     p.maxBody >> 20,
+		"request_timeout_seconds": int(p.timeout / time.Second),
+		"max_concurrent_requests": cap(p.slots),
+		"requests":                p.requests.Load(),
+		"in_flight":               p.inFlight.Load(),
+		"rejected_too_large":      p.tooLarge.Load(),
+		"timed_out":               p.timedOut.Load(),
+		"rejected_busy":           p.rejectedBusy.Load(),
+		"blocked_by_rules":        p.blocked.Load(),
+		"upstream_errors":         p.hiccups.Load(),
+		"static_files":            p.static.Served(),
+		"server_errors":           p.log.ServerErrors(),
+	}
+}
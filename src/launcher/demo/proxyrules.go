@@ -0,0 +1,160 @@
+package demo
+
+import (
+	_ "embed"
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+//go:embed pages/notice.html
+var noticePage string
+
+// ProxyRule hides or moves part of the app without touching its code.
+// Match is a path prefix when it starts with /, matching whole segments,
+// and a regular expression matched against the path when it starts with
+// ^. Either sees the path as Laravel's router does, see rulePath. For
+// redirect and rewrite, Target replaces the matched part; regex rules may
+// use $1 etc.
+type ProxyRule struct {
+	Match  string `json:"match"`
+	Action string `json:"action"` // block, redirect or rewrite
+	Target string `json:"target"`
+	Status int    `json:"status"`
+}
+
+const (
+	ruleBlock    = "block"
+	ruleRedirect = "redirect"
+	ruleRewrite  = "rewrite"
+)
+
+// proxyRule is a ProxyRule with its pattern compiled.
+type proxyRule struct {
+	ProxyRule
+	re *regexp.Regexp
+}
+
+func compileProxyRule(rule ProxyRule) (proxyRule, error) {
+	compiled := proxyRule{ProxyRule: rule}
+	switch {
+	case strings.HasPrefix(rule.Match, "/"):
+	case strings.HasPrefix(rule.Match, "^"):
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.re = re
+	default:
+		return compiled, fmt.Errorf("match %q must be a path prefix starting with / or a regex starting with ^", rule.Match)
+	}
+
+	switch rule.Action {
+	case ruleBlock:
+		if rule.Status != 0 && (rule.Status < 400 || rule.Status > 599) {
+			return compiled, fmt.Errorf("block status %d must be an error status", rule.Status)
+		}
+	case ruleRedirect, ruleRewrite:
+		if rule.Target == "" {
+			return compiled, fmt.Errorf("%s needs a target", rule.Action)
+		}
+		if rule.Action == ruleRedirect && rule.Status != 0 && (rule.Status < 300 || rule.Status > 399) {
+			return compiled, fmt.Errorf("redirect status %d must be a 3xx status", rule.Status)
+		}
+	default:
+		return compiled, fmt.Errorf("action %q must be \"block\", \"redirect\" or \"rewrite\"", rule.Action)
+	}
+	return compiled, nil
+}
+
+// rulePath is the route Laravel serves for the unescaped urlPath: without
+// repeated slashes, dot segments, a trailing slash or a leading
+// /index.php, all of which would otherwise get a request for a blocked
+// page past its rule.
+func rulePath(urlPath string) string {
+	p := path.Clean("/" + urlPath)
+	if p == "/index.php" {
+		return "/"
+	}
+	if strings.HasPrefix(p, "/index.php/") {
+		return strings.TrimPrefix(p, "/index.php")
+	}
+	return p
+}
+
+// apply returns the path with the matched part replaced by Target, and
+// whether the rule matched at all. path is a rulePath.
+func (rule *proxyRule) apply(path string) (string, bool) {
+	if rule.re == nil {
+		// "/admin" is "/admin" and below, not "/administrator"
+		prefix := strings.TrimSuffix(rule.Match, "/")
+		rest := strings.TrimPrefix(path, prefix)
+		if !strings.HasPrefix(path, prefix) || rest != "" && !strings.HasPrefix(rest, "/") {
+			return "", false
+		}
+		target := strings.TrimSuffix(rule.Target, "/") + rest
+		if target == "" {
+			target = "/"
+		}
+		return target, true
+	}
+	loc := rule.re.FindStringSubmatchIndex(path)
+	if loc == nil {
+		return "", false
+	}
+	target := rule.re.ExpandString(nil, rule.Target, path, loc)
+	return path[:loc[0]] + string(target) + path[loc[1]:], true
+}
+
+// applyRules runs the first matching rule. It returns false when the rule
+// already answered the request; a rewrite changes r in place and lets it
+// through.
+func (p *demoProxy) applyRules(w http.ResponseWriter, r *http.Request) bool {
+	for i := range p.rules {
+		rule := &p.rules[i]
+		path, ok := rule.apply(rulePath(r.URL.Path))
+		if !ok {
+			continue
+		}
+		switch rule.Action {
+		case ruleBlock:
+			p.blocked.Add(1)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(orDefault(rule.Status, http.StatusForbidden))
+			fmt.Fprint(w, p.withScripts(p.blockedPage))
+			return false
+		case ruleRedirect:
+			if r.URL.RawQuery != "" && !strings.Contains(path, "?") {
+				path += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, path, orDefault(rule.Status, http.StatusFound))
+			return false
+		case ruleRewrite:
+			r.URL.Path = path
+			r.URL.RawPath = ""
+			return true
+		}
+	}
+	return true
+}
+
+func renderBlockedPage(config *Manifest) string {
+	return renderNoticePage(config, "blocked_title", "blocked_text", "blocked_back", "/")
+}
+
+// renderNoticePage fills the built-in notice page with the messages with
+// IDs title, text and link, the link going to href.
+func renderNoticePage(config *Manifest, title, text, link, href string) string {
+	r := strings.NewReplacer(
+		"{{app_name}}", html.EscapeString(config.AppName),
+		"{{title}}", html.EscapeString(msg(title)),
+		"{{text}}", html.EscapeString(msg(text)),
+		"{{link}}", html.EscapeString(msg(link)),
+		"{{href}}", html.EscapeString(href),
+	)
+	return r.Replace(noticePage)
+}
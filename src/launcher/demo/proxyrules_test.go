@@ -0,0 +1,97 @@
+package demo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRulePath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/admin", "/admin"},
+		{"//admin", "/admin"},
+		{"/./admin", "/admin"},
+		{"/x/../admin", "/admin"},
+		{"/admin/", "/admin"},
+		{"/index.php/admin", "/admin"},
+		{"/index.php", "/"},
+		{"/index.php.bak", "/index.php.bak"},
+		{"", "/"},
+	}
+	for _, tt := range tests {
+		if got := rulePath(tt.in); got != tt.want {
+			t.Errorf("rulePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestProxyRuleApply(t *testing.T) {
+	tests := []struct {
+		rule  ProxyRule
+		path  string
+		want  string
+		match bool
+	}{
+		{ProxyRule{Match: "/admin", Action: ruleBlock}, "/admin", "", true},
+		{ProxyRule{Match: "/admin", Action: ruleBlock}, "/admin/settings", "", true},
+		{ProxyRule{Match: "/admin", Action: ruleBlock}, "/administrator", "", false},
+		{ProxyRule{Match: "/admin/", Action: ruleBlock}, "/admin", "", true},
+		{ProxyRule{Match: "/", Action: ruleBlock}, "/anything", "", true},
+		{ProxyRule{Match: "/old", Action: ruleRewrite, Target: "/new"}, "/old/page", "/new/page", true},
+		{ProxyRule{Match: "/old/", Action: ruleRewrite, Target: "/new/"}, "/old/page", "/new/page", true},
+		{ProxyRule{Match: "/old", Action: ruleRewrite, Target: "/"}, "/old", "/", true},
+		{ProxyRule{Match: `^/users/(\d+)/export$`, Action: ruleRewrite, Target: "/users/$1"}, "/users/7/export", "/users/7", true},
+		{ProxyRule{Match: `^/users/(\d+)/export$`, Action: ruleRewrite, Target: "/users/$1"}, "/users/x/export", "", false},
+	}
+	for _, tt := range tests {
+		rule, err := compileProxyRule(tt.rule)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.rule, err)
+		}
+		got, ok := rule.apply(tt.path)
+		if tt.rule.Action == ruleBlock {
+			// A block has no target
+			got = ""
+		}
+		if ok != tt.match || got != tt.want {
+			t.Errorf("%s on %q = %q, %v; want %q, %v", tt.rule.Match, tt.path, got, ok, tt.want, tt.match)
+		}
+	}
+}
+
+func TestApplyRulesBlocksNormalizedPaths(t *testing.T) {
+	rule, err := compileProxyRule(ProxyRule{Match: "/admin", Action: ruleBlock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &demoProxy{rules: []proxyRule{rule}}
+	for target, blocked := range map[string]bool{
+		"/admin":              true,
+		"//admin":             true,
+		"/./admin":            true,
+		"/index.php/admin":    true,
+		"/%61dmin":            true,
+		"/admin%2Fsettings":   true,
+		"/public/../admin":    true,
+		"/administrator":      false,
+		"/dashboard?x=/admin": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://demo"+target, nil)
+		w := httptest.NewRecorder()
+		passed := p.applyRules(w, r)
+		if passed == blocked {
+			t.Errorf("%s: passed = %v, want blocked = %v", target, passed, blocked)
+		}
+		if blocked && w.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403", target, w.Code)
+		}
+	}
+}
+
+func TestCompileProxyRuleRejectsBadRegex(t *testing.T) {
+	if _, err := compileProxyRule(ProxyRule{Match: "^/(unclosed", Action: ruleBlock}); err == nil {
+		t.Error("compiled a malformed regex")
+	}
+}
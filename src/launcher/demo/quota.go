@@ -0,0 +1,155 @@
+package demo
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	quotaInterval = time.Minute
+	quotaPrune    = "prune"
+)
+
+// workDirQuota keeps the writable parts of the work dir (storage, the
+// SQLite database) under max_workdir_mb. Over the quota it either has the
+// proxy refuse uploads until usage drops again, or deletes the oldest
+// files below prunable_paths.
+type workDirQuota struct {
+	max      int64
+	paths    []string // measured
+	prunable []string
+	prune    bool
+	clock    Clock
+	block    func(blocked bool)
+
+	mu   sync.Mutex
+	used int64
+	over bool
+}
+
+func newWorkDirQuota(config *Manifest, baseDir, appRoot string, clock Clock, block func(bool)) *workDirQuota {
+	storage := filepath.Join(appRoot, "storage")
+	q := &workDirQuota{
+		max:   int64(config.MaxWorkDirMB) << 20,
+		paths: []string{storage},
+		prune: config.QuotaAction == quotaPrune,
+		clock: clock,
+		block: block,
+	}
+	for _, p := range resetPaths(config, baseDir, appRoot) {
+		// storage/app is already counted with storage
+		if !strings.HasPrefix(p, storage+string(filepath.Separator)) {
+			q.paths = append(q.paths, p)
+		}
+	}
+	for _, p := range config.PrunablePaths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(baseDir, p)
+		}
+		q.prunable = append(q.prunable, p)
+	}
+	return q
+}
+
+// Run checks the usage every quotaInterval until stop is closed.
+func (q *workDirQuota) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-q.clock.After(quotaInterval):
+			q.check()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (q *workDirQuota) check() {
+	used := q.measure()
+	fmt.Printf("Work dir usage: %d MB of %d MB\n", used>>20, q.max>>20)
+	if used > q.max && q.prune {
+		freed := q.pruneOldest(used - q.max)
+		fmt.Printf("Pruned %d MB of old files to stay within max_workdir_mb.\n", freed>>20)
+		used = q.measure()
+	}
+
+	over := used > q.max
+	q.mu.Lock()
+	changed := over != q.over
+	q.used, q.over = used, over
+	q.mu.Unlock()
+
+	if changed && !q.prune {
+		if over {
+			fmt.Println("The work dir is over max_workdir_mb; uploads are refused until space is freed.")
+		}
+		q.block(over)
+	}
+}
+
+func (q *workDirQuota) measure() int64 {
+	var total int64
+	for _, p := range q.paths {
+		filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && !d.IsDir() {
+				total += info.Size()
+			}
+			return nil
+		})
+	}
+	return total
+}
+
+// pruneOldest deletes the least recently modified files below the
+// prunable paths until at least need bytes are freed, and returns how
+// much it freed.
+func (q *workDirQuota) pruneOldest(need int64) int64 {
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	for _, p := range q.prunable {
+		filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || d.Name() == ".gitignore" {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				files = append(files, file{path, info.Size(), info.ModTime()})
+			}
+			return nil
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var freed int64
+	for _, f := range files {
+		if freed >= need {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			freed += f.size
+		}
+	}
+	return freed
+}
+
+// Stats reports the last measurement for /status and the session summary.
+func (q *workDirQuota) Stats() map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return map[string]interface{}{
+		"used_mb":    q.used >> 20,
+		"max_mb":     q.max >> 20,
+		"over_quota": q.over,
+	}
+}
@@ -0,0 +1,97 @@
+package demo
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// requestLogMaxPaths caps how many distinct paths are counted, so a crawler
+// or a page with random query-free URLs can't grow the log without bound.
+// Further paths are counted under requestLogOther.
+const (
+	requestLogMaxPaths = 10000
+	requestLogOther    = "(other)"
+)
+
+// requestLog counts the proxied requests per path and the 5xx responses.
+type requestLog struct {
+	mu           sync.Mutex
+	paths        map[string]int64
+	serverErrors int64
+}
+
+func newRequestLog() *requestLog {
+	return &requestLog{paths: make(map[string]int64)}
+}
+
+func (rl *requestLog) record(path string, status int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if _, ok := rl.paths[path]; !ok && len(rl.paths) >= requestLogMaxPaths {
+		path = requestLogOther
+	}
+	rl.paths[path]++
+	if status >= 500 {
+		rl.serverErrors++
+	}
+}
+
+type pathCount struct {
+	Path  string `json:"path"`
+	Count int64  `json:"count"`
+}
+
+// top returns the n most requested paths, most requested first.
+func (rl *requestLog) top(n int) []pathCount {
+	rl.mu.Lock()
+	counts := make([]pathCount, 0, len(rl.paths))
+	for p, c := range rl.paths {
+		counts = append(counts, pathCount{p, c})
+	}
+	rl.mu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Path < counts[j].Path
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+func (rl *requestLog) ServerErrors() int64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.serverErrors
+}
+
+// statusRecorder remembers the status code written through it. Unwrap
+// lets http.ResponseController reach the real writer, which websocket
+// upgrades need for hijacking.
+type statusRecorder struct {
+	http.ResponseWriter
+	status    int
+	uncounted bool // the busy page, which is no failure of the app
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.status == 0 {
+		sr.status = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
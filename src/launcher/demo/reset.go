@@ -0,0 +1,207 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// dataGuard runs work on the demo's mutable data with PHP stopped, so no
+// request can write half a database while files are copied, and one piece
+// of work at a time: a scheduled reset, the tray and the control API can't
+// interleave.
+type dataGuard struct {
+	server  phpPool
+	busy    func(kind string) // the proxy's SetBusy
+	clock   Clock
+	timeout time.Duration // for PHP to accept connections again
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Kinds of work a dataGuard does, for the page the proxy shows meanwhile.
+const (
+	dataResetting = "resetting"
+	dataExporting = "exporting"
+)
+
+func newDataGuard(server phpPool, busy func(kind string), clock Clock, timeout time.Duration) *dataGuard {
+	return &dataGuard{server: server, busy: busy, clock: clock, timeout: timeout}
+}
+
+// Do stops PHP, runs fn and starts PHP again. Until PHP is back, the proxy
+// answers with a page saying what kind of work is going on.
+func (g *dataGuard) Do(kind string, fn func() error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return errors.New("the demo is shutting down")
+	}
+
+	g.busy(kind)
+	defer g.busy("")
+	if err := g.server.Stop(); err != nil {
+		fmt.Printf("Error stopping server: %v\n", err)
+	}
+	err := fn()
+	if startErr := g.server.Start(); startErr != nil {
+		return fmt.Errorf("restarting server: %w", startErr)
+	}
+	if readyErr := g.server.WaitReady(g.timeout, g.clock); readyErr != nil {
+		return fmt.Errorf("restarting server: %w", readyErr)
+	}
+	return err
+}
+
+// Close waits for work in progress to finish, so it can't restart PHP
+// behind the shutdown, and refuses any more.
+func (g *dataGuard) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+}
+
+// dataResetter puts the demo's mutable data (the SQLite database and
+// storage/app) back to the state it had right after extraction.
+type dataResetter struct {
+	guard    *dataGuard // set once PHP runs
+	pristine string     // snapshot taken at startup
+	paths    []string   // absolute paths being reset
+}
+
+// newDataResetter snapshots the current contents of paths into a fresh
+// temp directory. Paths that don't exist are reset by deleting them.
+// Nothing may write to paths meanwhile, so it runs before PHP and the
+// side processes start.
+func newDataResetter(paths []string) (*dataResetter, error) {
+	pristine, err := makeTempDir(workDirPrefix + "pristine_")
+	if err != nil {
+		return nil, err
+	}
+	r := &dataResetter{pristine: pristine, paths: paths}
+	for i, p := range paths {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyTree(p, r.snapshotPath(i)); err != nil {
+			os.RemoveAll(pristine)
+			return nil, fmt.Errorf("snapshotting %s: %w", p, err)
+		}
+	}
+	return r, nil
+}
+
+func (r *dataResetter) snapshotPath(i int) string {
+	return filepath.Join(r.pristine, fmt.Sprint(i))
+}
+
+// Reset restores every path from the snapshot with PHP stopped.
+// Concurrent calls (timer and manual) run one after the other.
+func (r *dataResetter) Reset() error {
+	start := time.Now()
+	err := r.guard.Do(dataResetting, func() error {
+		fmt.Println(msg("resetting_data"))
+		var restoreErr error
+		for i, p := range r.paths {
+			if err := os.RemoveAll(p); err != nil {
+				restoreErr = err
+				continue
+			}
+			snap := r.snapshotPath(i)
+			if _, err := os.Stat(snap); os.IsNotExist(err) {
+				continue
+			}
+			if err := copyTree(snap, p); err != nil {
+				restoreErr = err
+			}
+		}
+		if restoreErr != nil {
+			return fmt.Errorf("restoring demo data: %w", restoreErr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(msg("data_reset", time.Since(start).Round(time.Millisecond)))
+	return nil
+}
+
+// Schedule resets the data every interval until stop is closed.
+func (r *dataResetter) Schedule(clock Clock, interval time.Duration, stop <-chan struct{}) {
+	for {
+		select {
+		case <-clock.After(interval):
+			if err := r.Reset(); err != nil {
+				fmt.Printf("Error resetting demo data: %v\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Close removes the snapshot, once the guard is closed.
+func (r *dataResetter) Close() error {
+	return os.RemoveAll(r.pristine)
+}
+
+// copyTree copies a file or a directory tree from src to dst.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(p, target, info.Mode())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// resetPaths lists the demo's mutable data, which data resets restore and
+// --export-data saves: the SQLite database and the app's storage/app
+// directory.
+func resetPaths(config *Manifest, baseDir, appRoot string) []string {
+	var paths []string
+	if config.DBType == "sqlite" && config.DBPath != "" {
+		dbPath := config.DBPath
+		if !filepath.IsAbs(dbPath) {
+			dbPath = filepath.Join(baseDir, dbPath)
+		}
+		paths = append(paths, dbPath)
+	}
+	return append(paths, filepath.Join(appRoot, "storage", "app"))
+}
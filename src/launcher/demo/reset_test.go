@@ -0,0 +1,88 @@
+package demo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDataResetterRestoresAndMarksBusy(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "database.sqlite")
+	uploads := filepath.Join(dir, "storage", "app")
+	if err := ioutil.WriteFile(db, []byte("pristine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var busy []string
+	guard := newDataGuard(nil, func(kind string) { busy = append(busy, kind) }, systemClock{}, time.Second)
+	r, err := newDataResetter([]string{db, uploads})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.guard = guard
+	defer r.Close()
+
+	ioutil.WriteFile(db, []byte("trashed"), 0644)
+	os.MkdirAll(uploads, 0755)
+	ioutil.WriteFile(filepath.Join(uploads, "upload.png"), []byte("x"), 0644)
+	if err := r.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(db); string(data) != "pristine" {
+		t.Errorf("database = %q after reset", data)
+	}
+	if _, err := os.Stat(uploads); !os.IsNotExist(err) {
+		t.Errorf("storage/app, absent at startup, is back: %v", err)
+	}
+	if strings.Join(busy, ",") != dataResetting+"," {
+		t.Errorf("busy calls %q, want resetting then done", busy)
+	}
+}
+
+func TestDataGuardRefusesAfterClose(t *testing.T) {
+	guard := newDataGuard(nil, func(string) {}, systemClock{}, time.Second)
+	guard.Close()
+	ran := false
+	if err := guard.Do(dataExporting, func() error { ran = true; return nil }); err == nil || ran {
+		t.Errorf("Do after Close = %v, ran %v", err, ran)
+	}
+}
+
+func TestProxyBusyPageIsNotAnError(t *testing.T) {
+	// Nothing listens there, as while PHP is stopped
+	dead, _ := url.Parse("http://127.0.0.1:1")
+	public, _ := url.Parse("http://127.0.0.1:8000")
+	p := newDemoProxy(&Manifest{AppName: "Demo"}, []*url.URL{dead}, public)
+
+	p.SetBusy(dataResetting)
+	r := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("busy page: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, msg("busy_resetting_title")) || !strings.Contains(body, `http-equiv="refresh"`) {
+		t.Errorf("busy page lacks its title or the reload: %s", body)
+	}
+	if p.log.ServerErrors() != 0 || p.hiccups.Load() != 0 || p.failuresInRow.Load() != 0 {
+		t.Errorf("busy page counted: %d server errors, %d hiccups, %d failures in a row",
+			p.log.ServerErrors(), p.hiccups.Load(), p.failuresInRow.Load())
+	}
+
+	p.SetBusy("")
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusBadGateway || p.hiccups.Load() != 1 {
+		t.Errorf("after the reset: status %d, %d hiccups; want the hiccup page", w.Code, p.hiccups.Load())
+	}
+}
@@ -0,0 +1,90 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// unsafeEntryError names an archive or bundle entry that would be written
+// outside the directory it is extracted to.
+type unsafeEntryError struct {
+	entry, reason string
+}
+
+func (e *unsafeEntryError) Error() string {
+	return fmt.Sprintf("refusing entry %q: %s", e.entry, e.reason)
+}
+
+// safeJoin returns where the slash-separated entry name goes below dest.
+// Every extraction goes through it, both for the embedded bundle and for
+// archives, so an entry like ../../outside, /etc/passwd, C:\x or a\..\..\x
+// can't land outside dest. Symlinked folders inside dest that point
+// elsewhere (a reused --work-dir, say) are caught too.
+func safeJoin(dest, name string) (string, error) {
+	target, err := lexicalJoin(dest, name)
+	if err != nil {
+		return "", err
+	}
+	if err := checkNoSymlinkEscape(filepath.Clean(dest), target); err != nil {
+		return "", &unsafeEntryError{name, err.Error()}
+	}
+	return target, nil
+}
+
+// lexicalJoin is safeJoin without looking at the disk: symlinks below dest
+// are followed wherever they lead.
+func lexicalJoin(dest, name string) (string, error) {
+	switch {
+	case name == "":
+		return "", &unsafeEntryError{name, "empty path"}
+	case strings.Contains(name, `\`):
+		// A separator on Windows, and never in a slash-separated entry
+		return "", &unsafeEntryError{name, "contains a backslash"}
+	case strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "":
+		return "", &unsafeEntryError{name, "absolute path"}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", &unsafeEntryError{name, "leaves the target directory"}
+		}
+	}
+
+	root := filepath.Clean(dest)
+	target := filepath.Join(root, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(root, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", &unsafeEntryError{name, "leaves the target directory"}
+	}
+	return target, nil
+}
+
+// checkNoSymlinkEscape makes sure target, below root, doesn't reach
+// outside root through a symlink, whether in one of its folders or as the
+// file itself. Parts that don't exist yet are fine: extraction creates
+// them as plain folders and files.
+func checkNoSymlinkEscape(root, target string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		// Not created yet, so nothing below it can be a symlink
+		return nil
+	}
+	for p := target; ; p = filepath.Dir(p) {
+		if p == root || len(p) < len(root) {
+			return nil
+		}
+		if _, err := os.Lstat(p); err != nil {
+			continue
+		}
+		real, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(realRoot, real)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s is a symlink to %s, outside %s", p, real, root)
+		}
+		// Everything above an existing path was resolved with it
+		return nil
+	}
+}
@@ -0,0 +1,167 @@
+package demo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSafeJoin(t *testing.T) {
+	dest := t.TempDir()
+	for _, name := range []string{
+		"public/index.php",
+		"./public/index.php",
+		"..data/x",
+		"x..",
+	} {
+		if target, err := safeJoin(dest, name); err != nil || !strings.HasPrefix(target, dest+string(filepath.Separator)) {
+			t.Errorf("safeJoin(%q) = %q, %v; want a path below dest", name, target, err)
+		}
+	}
+	for _, name := range []string{
+		"",
+		"..",
+		"../outside",
+		"../../../tmp/pwned.txt",
+		"a/../../outside",
+		"a/..",
+		"a/b/../c",
+		"/etc/passwd",
+		`C:\Windows\evil.dll`,
+		`a\..\..\x`,
+		`public\index.php`,
+	} {
+		target, err := safeJoin(dest, name)
+		var unsafe *unsafeEntryError
+		if !errors.As(err, &unsafe) {
+			t.Errorf("safeJoin(%q) = %q, %v; want it refused", name, target, err)
+		}
+	}
+}
+
+// symlinkOutside makes dest/link a symlink to a directory outside dest
+// and returns that directory, skipping where symlinks can't be made.
+func symlinkOutside(t *testing.T, dest, link string) string {
+	t.Helper()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dest, link)); err != nil {
+		t.Skipf("can't create a symlink: %v", err)
+	}
+	return outside
+}
+
+func TestSafeJoinSymlinkEscape(t *testing.T) {
+	dest := t.TempDir()
+	outside := symlinkOutside(t, dest, "resources")
+	ioutil.WriteFile(filepath.Join(outside, "secret.txt"), nil, 0644)
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dest, "secret.txt")); err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(filepath.Join(dest, "public"), 0755)
+	if err := os.Symlink("public", filepath.Join(dest, "web")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"resources/x.txt", "resources/deep/x.txt", "secret.txt"} {
+		if _, err := safeJoin(dest, name); err == nil {
+			t.Errorf("safeJoin(%q) followed a symlink out of dest", name)
+		}
+	}
+	// Links that stay inside dest are fine
+	if _, err := safeJoin(dest, "web/index.php"); err != nil {
+		t.Errorf("safeJoin(web/index.php) = %v with web linking to public", err)
+	}
+}
+
+// payloadBundle returns a bundle packed into a payload holding the named
+// files.
+func payloadBundle(t *testing.T, names ...string) fstest.MapFS {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 4}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte("evil"))
+	}
+	tw.Close()
+	zw.Close()
+	return fstest.MapFS{
+		"bundle/" + checksumsFile: {Data: []byte("{}")},
+		"bundle/" + payloadFile:   {Data: buf.Bytes()},
+	}
+}
+
+func TestExtractPayloadRejectsEscapes(t *testing.T) {
+	for _, name := range []string{
+		"../evil.txt",
+		"public/../../evil.txt",
+		"/tmp/evil.txt",
+		`public\..\..\evil.txt`,
+	} {
+		parent := t.TempDir()
+		dest := filepath.Join(parent, "work")
+		err := extractBundle(payloadBundle(t, name), dest, nil)
+		var unsafe *unsafeEntryError
+		if !errors.As(err, &unsafe) {
+			t.Errorf("extracting %q: %v, want it refused", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "evil.txt")); err == nil {
+			t.Errorf("extracting %q wrote outside the work directory", name)
+		}
+	}
+}
+
+func TestExtractPayloadRejectsSymlinkEscape(t *testing.T) {
+	dest := t.TempDir()
+	outside := symlinkOutside(t, dest, "resources")
+	err := extractBundle(payloadBundle(t, "resources/evil.txt"), dest, nil)
+	var unsafe *unsafeEntryError
+	if !errors.As(err, &unsafe) {
+		t.Errorf("extracting through a symlinked folder: %v, want it refused", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil.txt")); err == nil {
+		t.Error("extraction wrote through the symlink")
+	}
+}
+
+func TestImportDataRejectsEscapes(t *testing.T) {
+	config := &Manifest{AppName: "Demo", AppVersion: "1.0", DBType: "sqlite", DBPath: "database/database.sqlite"}
+	for _, name := range []string{
+		"../outside",
+		"storage/app/../../../outside",
+		"/tmp/outside",
+		`storage\app\..\..\..\outside`,
+	} {
+		parent := t.TempDir()
+		dest := filepath.Join(parent, "demo")
+		archive := writeDataFile(t, config, map[string]string{name: "x"})
+		err := importData(archive, config, dest, resetPaths(config, dest, dest))
+		var unsafe *unsafeEntryError
+		if !errors.As(err, &unsafe) {
+			t.Errorf("importing %q: %v, want it refused", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "outside")); err == nil {
+			t.Errorf("importing %q wrote outside the demo", name)
+		}
+	}
+
+	dest := t.TempDir()
+	outside := symlinkOutside(t, dest, "storage")
+	archive := writeDataFile(t, config, map[string]string{"storage/app/evil.txt": "x"})
+	if err := importData(archive, config, dest, resetPaths(config, dest, dest)); err == nil {
+		t.Error("importing through a symlinked storage folder succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "app", "evil.txt")); err == nil {
+		t.Error("import wrote through the symlink")
+	}
+}
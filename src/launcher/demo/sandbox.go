@@ -0,0 +1,107 @@
+package demo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sandboxDefaults keep a demo from talking to the outside world through
+// Laravel: mail goes to the log and jobs run in-process instead of on a
+// remote queue.
+var sandboxDefaults = map[string]string{
+	"MAIL_MAILER":      "log",
+	"QUEUE_CONNECTION": "sync",
+}
+
+// sandboxEnv returns the variables sandbox_network forces on PHP: the
+// defaults merged with sandbox_env_overrides.
+func sandboxEnv(config *Manifest) map[string]string {
+	if !config.SandboxNetwork {
+		return nil
+	}
+	env := make(map[string]string, len(sandboxDefaults)+len(config.SandboxEnvOverrides))
+	for k, v := range sandboxDefaults {
+		env[k] = v
+	}
+	for k, v := range config.SandboxEnvOverrides {
+		env[k] = v
+	}
+	return env
+}
+
+var (
+	localMailHosts = []string{"", "localhost", "127.0.0.1", "::1", "mailpit", "mailhog", "sandbox.smtp.mailtrap.io", "smtp.mailtrap.io"}
+
+	// liveKeyPattern matches well-known production key formats (Stripe,
+	// AWS, Slack, SendGrid, Mailgun, GitHub).
+	liveKeyPattern = regexp.MustCompile(`^(sk_live_|rk_live_|pk_live_|AKIA[0-9A-Z]{12}|xox[abp]-|SG\.|key-[0-9a-f]{20}|ghp_)`)
+
+	secretNamePattern = regexp.MustCompile(`(_KEY|_SECRET|_TOKEN|_PASSWORD)$`)
+	placeholderWords  = []string{"test", "fake", "dummy", "example", "changeme", "secret", "xxx", "null"}
+)
+
+// liveCredentials lists the variables in vars whose values look like real
+// credentials: mail hosts that aren't local and keys in production
+// formats or long enough to be real.
+func liveCredentials(vars map[string]string) []string {
+	var found []string
+	for _, k := range sortedKeys(vars) {
+		v := vars[k]
+		switch {
+		case k == "MAIL_HOST" || strings.HasSuffix(k, "SMTP_HOST"):
+			if !containsFold(localMailHosts, v) {
+				found = append(found, fmt.Sprintf("%s=%s is not a local mail server", k, v))
+			}
+		case liveKeyPattern.MatchString(v):
+			found = append(found, fmt.Sprintf("%s looks like a production key", k))
+		case k != "APP_KEY" && secretNamePattern.MatchString(k) && len(v) >= 16 && !isPlaceholder(v):
+			found = append(found, fmt.Sprintf("%s looks like a real secret", k))
+		}
+	}
+	return found
+}
+
+// warnLiveCredentials prints a warning for every credential-looking value
+// in env_vars or the bundled .env.
+func warnLiveCredentials(config *Manifest, bundled map[string]string) {
+	var found []string
+	for _, c := range liveCredentials(config.EnvVars) {
+		found = append(found, "env_vars: "+c)
+	}
+	for _, c := range liveCredentials(bundled) {
+		found = append(found, ".env: "+c)
+	}
+	if len(found) == 0 {
+		return
+	}
+
+	fmt.Println("!!! WARNING: the demo bundle seems to contain live credentials !!!")
+	for _, c := range found {
+		fmt.Println("  - " + c)
+	}
+	if config.SandboxNetwork {
+		fmt.Println("sandbox_network is on, so mail and queues are redirected; other keys still reach real services.")
+	} else {
+		fmt.Println("Set \"sandbox_network\": true to keep the demo from sending real mail.")
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPlaceholder(v string) bool {
+	lower := strings.ToLower(v)
+	for _, w := range placeholderWords {
+		if strings.Contains(lower, w) {
+			return true
+		}
+	}
+	return false
+}
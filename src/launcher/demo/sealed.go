@@ -0,0 +1,216 @@
+package demo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// sealedPayloadFile is payloadFile encrypted with AES-256-GCM, so
+// unzipping the executable or carving files out of it turns up nothing
+// readable. The key is compiled into the launcher, so this deters casual
+// lifting of the source rather than a determined reverse engineer, and
+// the extracted work dir is as readable as ever while the demo runs.
+const sealedPayloadFile = payloadFile + ".enc"
+
+// PayloadKey is the AES key, masked with payloadKeyMask so it doesn't
+// appear in the executable as is. main sets it from the value compiled in
+// at build time:
+//
+//	go build -ldflags "-X main.payloadKey=<hex>"
+//
+// seal prints the value.
+var PayloadKey string
+
+var payloadKeyMask = [32]byte{
+	0x5c, 0x21, 0xe7, 0x93, 0x0a, 0xb4, 0x6f, 0xd8, 0x31, 0x8e, 0x47, 0xc2, 0x19, 0xf5, 0x7a, 0x03,
+	0xa6, 0x5d, 0x94, 0x2b, 0xe0, 0x17, 0xcb, 0x68, 0x3f, 0x82, 0xd1, 0x4e, 0x9b, 0x26, 0x70, 0xbd,
+}
+
+// The sealed file is sealMagic, a random nonce prefix and the payload in
+// chunks of sealChunk bytes, each sealed on its own so decryption streams.
+// Nonces are the prefix, the chunk number and a flag marking the last
+// chunk, which catch reordered, dropped and cut-off chunks.
+const (
+	sealMagic  = "LDSEAL1\n"
+	sealPrefix = 7
+	sealChunk  = 64 << 10
+)
+
+func maskKey(key []byte) []byte {
+	masked := make([]byte, len(key))
+	for i := range key {
+		masked[i] = key[i] ^ payloadKeyMask[i%len(payloadKeyMask)]
+	}
+	return masked
+}
+
+func sealAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealNonce(prefix []byte, chunk uint32, last bool) []byte {
+	nonce := make([]byte, 0, sealPrefix+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, chunk)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// sealStream encrypts r to w. The last chunk is always shorter than
+// sealChunk, if need be empty, so the reader can tell it's the last.
+func sealStream(w io.Writer, r io.Reader, key []byte) error {
+	aead, err := sealAEAD(key)
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, sealPrefix)
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, sealMagic); err != nil {
+		return err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	buf := make([]byte, sealChunk)
+	for chunk := uint32(0); ; chunk++ {
+		n, err := io.ReadFull(r, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+		if _, err := w.Write(aead.Seal(nil, sealNonce(prefix, chunk, last), buf[:n], nil)); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// unsealReader decrypts a sealed payload chunk by chunk.
+type unsealReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	chunk  uint32
+	buf    []byte // sealed chunk being read
+	plain  []byte // decrypted bytes not yet returned
+	done   bool
+}
+
+// errPayloadKey is a build mistake rather than a damaged bundle.
+var errPayloadKey = errors.New("the bundle is encrypted but this launcher was built without its key, or with an invalid one")
+
+func newUnsealReader(r io.Reader) (*unsealReader, error) {
+	masked, err := hex.DecodeString(PayloadKey)
+	if err != nil || len(masked) != 32 {
+		return nil, errPayloadKey
+	}
+	aead, err := sealAEAD(maskKey(masked))
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(sealMagic)+sealPrefix)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(sealMagic)]) != sealMagic {
+		return nil, fmt.Errorf("%s is not a sealed payload", sealedPayloadFile)
+	}
+	return &unsealReader{
+		r:      r,
+		aead:   aead,
+		prefix: header[len(sealMagic):],
+		buf:    make([]byte, sealChunk+aead.Overhead()),
+	}, nil
+}
+
+func (u *unsealReader) Read(p []byte) (int, error) {
+	for len(u.plain) == 0 {
+		if u.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(u.r, u.buf)
+		last := err == io.ErrUnexpectedEOF
+		if err == io.EOF {
+			// A full chunk can't be the last one
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil && !last {
+			return 0, err
+		}
+		plain, err := u.aead.Open(u.buf[:0], sealNonce(u.prefix, u.chunk, last), u.buf[:n], nil)
+		if err != nil {
+			return 0, fmt.Errorf("chunk %d doesn't decrypt; wrong key or a modified bundle", u.chunk)
+		}
+		u.plain, u.done = plain, last
+		u.chunk++
+	}
+	n := copy(p, u.plain)
+	u.plain = u.plain[n:]
+	return n, nil
+}
+
+// sealPayload replaces payloadFile in dir with sealedPayloadFile under a
+// fresh key and returns the masked key for PayloadKey.
+func sealPayload(dir string) (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	plain := filepath.Join(dir, payloadFile)
+	in, err := os.Open(plain)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	out, err := os.Create(filepath.Join(dir, sealedPayloadFile))
+	if err != nil {
+		return "", err
+	}
+	err = sealStream(out, in, key)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("encrypting %s: %w", payloadFile, err)
+	}
+	in.Close()
+	if err := os.Remove(plain); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(maskKey(key)), nil
+}
+
+// RunSeal implements "seal", which build.py runs to encrypt the payload
+// it staged. The last line of output is the -X value for the build.
+func RunSeal(args []string) int {
+	flags := flag.NewFlagSet("seal", flag.ContinueOnError)
+	dir := flags.String("dir", "bundle", "Bundle directory holding "+payloadFile)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	masked, err := sealPayload(*dir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Encrypted %s\n", filepath.Join(*dir, sealedPayloadFile))
+	fmt.Println("main.payloadKey=" + masked)
+	return 0
+}
@@ -0,0 +1,370 @@
+package demo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named sessions (--session) let several visitors share one machine and OS
+// account without sharing data. All sessions of an app run from one
+// extracted code tree in the user cache dir; each session has its own
+// copy of the mutable data, its own port and its own browser profile:
+//
+//	<cache>/laravel_demo/<app>/code/<bundle key>/   shared code
+//	<cache>/laravel_demo/<app>/sessions/<name>/data/    database and storage
+//	<cache>/laravel_demo/<app>/sessions/<name>/browser/ browser profile
+//	<cache>/laravel_demo/<app>/sessions/<name>/session.json
+//	<cache>/laravel_demo/<app>/sessions/<name>/lock
+
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// appCacheDir is where the launcher keeps per-app state that outlives a
+// run, such as the EULA record and named sessions.
+func appCacheDir(config *Manifest) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "laravel_demo", appSlug(config))
+}
+
+// sessionMeta is a session's session.json.
+type sessionMeta struct {
+	Port     int       `json:"port"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// demoSession is a named session opened by this launcher. It holds the
+// session's lock until Close.
+type demoSession struct {
+	name    string
+	dir     string
+	meta    sessionMeta
+	release func() // drops the lock
+}
+
+func (s *demoSession) dataDir() string    { return filepath.Join(s.dir, "data") }
+func (s *demoSession) browserDir() string { return filepath.Join(s.dir, "browser") }
+func (s *demoSession) metaPath() string   { return filepath.Join(s.dir, "session.json") }
+func (s *demoSession) lockPath() string   { return filepath.Join(s.dir, "lock") }
+
+// openSession locks the named session, creating it on first use.
+func openSession(config *Manifest, name string) (*demoSession, error) {
+	if !sessionNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("session name %q may only contain letters, digits, - and _", name)
+	}
+	s := &demoSession{name: name, dir: filepath.Join(appCacheDir(config), "sessions", name)}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := s.lock(); err != nil {
+		return nil, err
+	}
+	if data, err := ioutil.ReadFile(s.metaPath()); err == nil {
+		json.Unmarshal(data, &s.meta)
+	}
+	if s.meta.Created.IsZero() {
+		s.meta.Created = time.Now()
+	}
+	s.meta.LastUsed = time.Now()
+	s.save()
+	return s, nil
+}
+
+// lock takes the session's lock: the same flock or named mutex as the
+// instance lock, so one left by a killed launcher is gone with it. The
+// lock file records the PID for "sessions list".
+func (s *demoSession) lock() error {
+	release, err := lockInstanceFile(s.lockPath(), sessionLockName(s.dir))
+	if err == errAlreadyRunning {
+		if pid, _ := sessionLockHolder(s.dir); pid != 0 {
+			return fmt.Errorf("session %q is already running (pid %d)", s.name, pid)
+		}
+		return fmt.Errorf("session %q is already running", s.name)
+	}
+	if err != nil {
+		return err
+	}
+	s.release = release
+	ioutil.WriteFile(s.lockPath(), []byte(strconv.Itoa(os.Getpid())), 0644)
+	return nil
+}
+
+// sessionLockName names the lock of the session in dir: app and session,
+// as the Windows mutex is per user, not per directory.
+func sessionLockName(dir string) string {
+	return filepath.Base(filepath.Dir(filepath.Dir(dir))) + "_session_" + filepath.Base(dir)
+}
+
+// sessionLockHolder reports whether a launcher holds the lock of the
+// session in dir, and its PID when the lock file names it.
+func sessionLockHolder(dir string) (int, bool) {
+	if !instanceFileLocked(filepath.Join(dir, "lock"), sessionLockName(dir)) {
+		return 0, false
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "lock"))
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid, true
+}
+
+func (s *demoSession) save() error {
+	data, _ := json.MarshalIndent(s.meta, "", "  ")
+	return ioutil.WriteFile(s.metaPath(), data, 0644)
+}
+
+// Close records the port for the next run and releases the lock.
+func (s *demoSession) Close() {
+	s.meta.LastUsed = time.Now()
+	s.save()
+	// The file stays: removing it could let a launcher that just opened
+	// it lock a file nobody else sees
+	s.release()
+}
+
+// sharedCodeDir returns the code tree shared by every session of the app
+// and by extraction_cache runs, extracting it first if this bundle hasn't
+// been extracted before.
+func sharedCodeDir(config *Manifest, fsys fs.FS, skip []string) (string, bool, error) {
+	key, err := bundleKey(config, fsys)
+	if err != nil {
+		return "", false, err
+	}
+	dir := filepath.Join(appCacheDir(config), "code", key)
+	extracted, err := extractOnce(fsys, dir, skip)
+	return dir, extracted, err
+}
+
+// bundleKey names the extracted trees of this build: a hash of the
+// checksums.json the builder wrote, so an updated launcher gets a fresh
+// tree, and of the app's dir in a suite.
+func bundleKey(config *Manifest, fsys fs.FS) (string, error) {
+	sums, err := fs.ReadFile(fsys, path.Join(bundleRoot, checksumsFile))
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(sums)
+	h.Write([]byte(config.Dir))
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// extractOnce extracts the bundle to dir unless it exists, and reports
+// whether it did. Extraction goes to a temp name and is renamed into
+// place, so a session started at the same moment never sees half a tree.
+func extractOnce(fsys fs.FS, dir string, skip []string) (bool, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return false, err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), "extracting_")
+	if err != nil {
+		return false, err
+	}
+	if err := extractBundle(fsys, tmp, skip); err != nil {
+		os.RemoveAll(tmp)
+		return false, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		if _, serr := os.Stat(dir); serr == nil {
+			// Another session won the race
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// prepareData gives the session its own copy of the mutable data on first
+// use.
+func (s *demoSession) prepareData(config *Manifest, baseDir, appRoot string) error {
+	_, err := prepareDataDir(config, baseDir, appRoot, s.dataDir())
+	return err
+}
+
+// prepareDataDir copies the mutable data of the code tree to dir unless
+// it exists, and reports whether it did.
+func prepareDataDir(config *Manifest, baseDir, appRoot, dir string) (bool, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return false, err
+	}
+	tmp := dir + ".tmp"
+	os.RemoveAll(tmp)
+	if err := copyData(config, baseDir, appRoot, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return false, err
+	}
+	return true, os.Rename(tmp, dir)
+}
+
+// copyData copies the mutable data of the code tree in baseDir to dest:
+// the SQLite database and the whole storage directory, at the same paths
+// relative to dest as they have in the code tree.
+func copyData(config *Manifest, baseDir, appRoot, dest string) error {
+	paths := []string{filepath.Join(appRoot, "storage")}
+	if config.DBType == "sqlite" && config.DBPath != "" {
+		paths = append(paths, resetPaths(config, baseDir, appRoot)[0])
+	}
+	for _, p := range paths {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			continue
+		}
+		rel, err := filepath.Rel(baseDir, p)
+		if err != nil || !filepath.IsLocal(rel) {
+			return fmt.Errorf("%s is outside the bundle; db_path and app_root must be inside it", p)
+		}
+		if err := copyTree(p, filepath.Join(dest, rel)); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(dest, 0755)
+}
+
+// dataEnv points Laravel at the data dir when it isn't the code tree (a
+// session, a cached run or kept data): LARAVEL_STORAGE_PATH (Laravel 11 and later),
+// APP_CONFIG_CACHE and, for SQLite, DB_DATABASE.
+func (l *Launcher) dataEnv() []string {
+	if l.dataDir == l.baseDir {
+		return nil
+	}
+	env := []string{
+		"LARAVEL_STORAGE_PATH=" + filepath.Join(l.dataAppRoot(), "storage"),
+		"APP_CONFIG_CACHE=" + l.configCachePath(),
+	}
+	if l.Config.DBType == "sqlite" && l.Config.DBPath != "" {
+		env = append(env, "DB_DATABASE="+resetPaths(&l.Config, l.dataDir, l.dataAppRoot())[0])
+	}
+	return env
+}
+
+// dataAppRoot is app_root below the data dir: the app root itself, or
+// its mirror in a session's or a cached run's data.
+func (l *Launcher) dataAppRoot() string {
+	if l.dataDir == l.baseDir {
+		return l.appRoot
+	}
+	rel, err := filepath.Rel(l.baseDir, l.appRoot)
+	if err != nil {
+		return l.appRoot
+	}
+	return filepath.Join(l.dataDir, rel)
+}
+
+// RunSessionsCommand implements "sessions list" and "sessions delete
+// <name>" and returns the exit code.
+func RunSessionsCommand(config *Manifest, args []string) int {
+	root := filepath.Join(appCacheDir(config), "sessions")
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		if err := listSessions(config, root); err != nil {
+			fmt.Printf("Error listing sessions: %v\n", err)
+			return 1
+		}
+		return 0
+	case len(args) == 2 && args[0] == "delete":
+		if err := deleteSession(root, args[1]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Deleted session %q.\n", args[1])
+		return 0
+	default:
+		fmt.Println("Usage: sessions list | sessions delete <name>")
+		return 2
+	}
+}
+
+func listSessions(config *Manifest, root string) error {
+	entries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	type row struct {
+		name, status string
+		size         int64
+		lastUsed     time.Time
+	}
+	var rows []row
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		r := row{name: e.Name(), status: "stored", size: dirSize(dir)}
+		if pid, running := sessionLockHolder(dir); running {
+			r.status = "active"
+			if pid != 0 {
+				r.status = fmt.Sprintf("active (pid %d)", pid)
+			}
+		}
+		var meta sessionMeta
+		if data, err := ioutil.ReadFile(filepath.Join(dir, "session.json")); err == nil {
+			json.Unmarshal(data, &meta)
+		}
+		r.lastUsed = meta.LastUsed
+		rows = append(rows, r)
+	}
+	if len(rows) == 0 {
+		fmt.Println("No sessions.")
+		return nil
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].lastUsed.After(rows[j].lastUsed) })
+
+	fmt.Printf("%-20s %-18s %8s  %s\n", "SESSION", "STATUS", "SIZE", "LAST USED")
+	for _, r := range rows {
+		lastUsed := "-"
+		if !r.lastUsed.IsZero() {
+			lastUsed = r.lastUsed.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-20s %-18s %6d MB  %s\n", r.name, r.status, r.size>>20, lastUsed)
+	}
+	fmt.Printf("\nShared code: %d MB in %s\n", dirSize(filepath.Join(appCacheDir(config), "code"))>>20, filepath.Join(appCacheDir(config), "code"))
+	return nil
+}
+
+func deleteSession(root, name string) error {
+	if !sessionNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid session name %q", name)
+	}
+	dir := filepath.Join(root, name)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("no session %q", name)
+	}
+	if _, running := sessionLockHolder(dir); running {
+		return fmt.Errorf("session %q is running; quit it first", name)
+	}
+	return removeAllRetry(dir, removeTimeout)
+}
+
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,101 @@
+package demo
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// holdLockEnv makes the test binary take the session lock of the dir it
+// names and wait to be killed.
+const holdLockEnv = "LAUNCHER_TEST_HOLD_LOCK"
+
+// TestHoldSessionLock is not a test: TestSessionLockDiesWithProcess runs
+// the test binary with it as a launcher holding a session.
+func TestHoldSessionLock(t *testing.T) {
+	dir := os.Getenv(holdLockEnv)
+	if dir == "" {
+		t.Skip("only run by TestSessionLockDiesWithProcess")
+	}
+	s := &demoSession{name: filepath.Base(dir), dir: dir}
+	if err := s.lock(); err != nil {
+		os.Exit(1)
+	}
+	os.Stdout.WriteString("locked\n")
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func testSessionDir(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "app", "sessions", "alice")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestSessionLockIsExclusive(t *testing.T) {
+	dir := testSessionDir(t)
+	s := &demoSession{name: "alice", dir: dir}
+	if err := s.lock(); err != nil {
+		t.Fatal(err)
+	}
+	if pid, running := sessionLockHolder(dir); !running || pid != os.Getpid() {
+		t.Errorf("sessionLockHolder = %d, %v; want this process", pid, running)
+	}
+	if err := (&demoSession{name: "alice", dir: dir}).lock(); err == nil {
+		t.Error("a second launcher locked the running session")
+	}
+
+	s.Close()
+	if _, running := sessionLockHolder(dir); running {
+		t.Error("the session counts as running after Close")
+	}
+	again := &demoSession{name: "alice", dir: dir}
+	if err := again.lock(); err != nil {
+		t.Fatalf("relocking after Close: %v", err)
+	}
+	again.release()
+}
+
+func TestSessionLockDiesWithProcess(t *testing.T) {
+	dir := testSessionDir(t)
+	// A stale PID in the file alone doesn't count
+	if err := os.WriteFile(filepath.Join(dir, "lock"), []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, running := sessionLockHolder(dir); running {
+		t.Fatal("an unlocked lock file counts as running")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHoldSessionLock$")
+	cmd.Env = append(os.Environ(), holdLockEnv+"="+dir)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	if line, err := bufio.NewReader(out).ReadString('\n'); err != nil || line != "locked\n" {
+		t.Fatalf("the holder printed %q, %v", line, err)
+	}
+	if pid, running := sessionLockHolder(dir); !running || pid != cmd.Process.Pid {
+		t.Errorf("sessionLockHolder = %d, %v; want pid %d", pid, running, cmd.Process.Pid)
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+	if _, running := sessionLockHolder(dir); running {
+		t.Error("the lock outlived the killed launcher")
+	}
+	s := &demoSession{name: "alice", dir: dir}
+	if err := s.lock(); err != nil {
+		t.Fatalf("taking over after the kill: %v", err)
+	}
+	s.release()
+}
@@ -0,0 +1,59 @@
+package demo
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runSetupCommands runs the manifest's setup_commands (migrations, seeders)
+// one after another in app_root, streaming their output to the console and
+// the setup banner. {{php}} and {{artisan}} in a command are replaced by
+// the PHP binary and the artisan script.
+func (l *Launcher) runSetupCommands(phpBin string, env []string) error {
+	expand := strings.NewReplacer("{{php}}", phpBin, "{{artisan}}", l.artisan)
+	display := strings.NewReplacer("{{php}}", "php", "{{artisan}}", "artisan")
+
+	for _, argv := range l.Config.SetupCommands {
+		args := make([]string, len(argv))
+		for i, arg := range argv {
+			args[i] = expand.Replace(arg)
+		}
+		shown := display.Replace(strings.Join(argv, " "))
+		l.banner.Step(msg("setup_running", shown))
+
+		out := &lineWriter{fn: l.banner.Output}
+		cmd := l.Command(args[0], args[1:]...)
+		cmd.Env = env
+		cmd.Dir = l.appRoot
+		w := io.MultiWriter(processOutput("setup"), out)
+		cmd.Stdout, cmd.Stderr = w, w
+		err := cmd.Run()
+		out.Flush()
+		if err != nil {
+			return launchFailure(errorCategorySetup, fmt.Errorf("setup command %q failed: %w", shown, err))
+		}
+	}
+	return nil
+}
+
+// usesArtisan reports whether a setup command, side process or the
+// warm-up runs artisan.
+func usesArtisan(config *Manifest) bool {
+	if config.WarmupArtisanCaches {
+		return true
+	}
+	var commands [][]string
+	commands = append(commands, config.SetupCommands...)
+	for _, side := range config.SideProcesses {
+		commands = append(commands, side.Command)
+	}
+	for _, argv := range commands {
+		for _, arg := range argv {
+			if strings.Contains(arg, "{{artisan}}") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shortcutRecord is shortcuts.json in the app's cache dir: the shortcuts
+// created for the launcher at exe. They are made once, so a shortcut the
+// user deleted stays deleted, unless the launcher has moved since.
+type shortcutRecord struct {
+	Exe     string   `json:"exe"`
+	Created []string `json:"created"`
+}
+
+func shortcutRecordPath(config *Manifest) string {
+	return filepath.Join(appCacheDir(config), "shortcuts.json")
+}
+
+// createShortcuts gives the demo a desktop shortcut and a Start Menu or
+// applications menu entry on its first run, when the manifest asks for
+// them with create_shortcuts. Failures only warn: the demo runs anyway.
+func (l *Launcher) createShortcuts() {
+	if !l.Config.CreateShortcuts || !l.extractsBundle() {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	// An AppImage runs from a mount that is gone once it exits
+	if image := os.Getenv("APPIMAGE"); image != "" {
+		exe = image
+	}
+	var record shortcutRecord
+	if data, err := ioutil.ReadFile(shortcutRecordPath(&l.Config)); err == nil {
+		json.Unmarshal(data, &record)
+	}
+	if record.Exe == exe {
+		return
+	}
+
+	icon := l.shortcutIcon()
+	record = shortcutRecord{Exe: exe}
+	for _, p := range shortcutPaths(&l.Config) {
+		if err := createShortcut(p, exe, l.Config.AppName, icon); err != nil {
+			fmt.Printf("Warning: can't create shortcut %s: %v\n", p, err)
+			continue
+		}
+		record.Created = append(record.Created, p)
+	}
+	if len(record.Created) > 0 {
+		fmt.Println(msg("shortcuts_created", strings.Join(record.Created, ", ")))
+	}
+	data, _ := json.MarshalIndent(record, "", "  ")
+	os.MkdirAll(appCacheDir(&l.Config), 0755)
+	ioutil.WriteFile(shortcutRecordPath(&l.Config), data, 0644)
+}
+
+// shortcutIcon copies icon_path to the app's data dir, which outlives the
+// work dir, and returns the copy, or "" for the launcher's own icon.
+func (l *Launcher) shortcutIcon() string {
+	if l.Config.IconPath == "" {
+		return ""
+	}
+	data, err := l.readAppFile(l.Config.IconPath)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	dir, err := appDataDir(&l.Config)
+	if err != nil {
+		return ""
+	}
+	icon := filepath.Join(dir, "icon"+filepath.Ext(l.Config.IconPath))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ""
+	}
+	if err := ioutil.WriteFile(icon, data, 0644); err != nil {
+		return ""
+	}
+	return icon
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package demo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// createShortcut creates a .desktop file at path that starts exe in a
+// terminal, or on macOS a Finder link to exe, or to the .app bundle it's
+// in, which can't have an icon of its own. Only the menu folder is
+// created; a missing Desktop is an error.
+func createShortcut(path, exe, name, icon string) error {
+	if runtime.GOOS == "darwin" {
+		if app, ok := enclosingAppBundle(exe); ok {
+			exe = app
+		}
+		// Only an older link is replaced, never something of the user's
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			os.Remove(path)
+		}
+		return os.Symlink(exe, path)
+	}
+	if filepath.Base(filepath.Dir(path)) == "applications" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+	var b strings.Builder
+	b.WriteString("[Desktop Entry]\nType=Application\n")
+	fmt.Fprintf(&b, "Name=%s\n", name)
+	fmt.Fprintf(&b, "Exec=%s\n", desktopExecQuote(exe))
+	fmt.Fprintf(&b, "Path=%s\n", filepath.Dir(exe))
+	if icon != "" {
+		fmt.Fprintf(&b, "Icon=%s\n", icon)
+	}
+	// The console shows the URL and quits the demo with Ctrl+C
+	b.WriteString("Terminal=true\nCategories=Office;\n")
+	// Desktops only start launchers marked executable
+	return os.WriteFile(path, []byte(b.String()), 0755)
+}
+
+// desktopExecQuote quotes an Exec argument as the Desktop Entry
+// specification asks.
+func desktopExecQuote(s string) string {
+	r := strings.NewReplacer(`"`, `\"`, "`", "\\`", "$", `\$`, `\`, `\\`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,42 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// createShortcut writes a .lnk file at path through the WScript.Shell COM
+// object, which PowerShell reaches without any helper binary. Without an
+// .ico icon the shortcut shows the launcher's own. A missing Desktop is an
+// error; the Start Menu folder always exists.
+func createShortcut(path, exe, name, icon string) error {
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if !strings.EqualFold(filepath.Ext(icon), ".ico") {
+		icon = exe
+	}
+	script := "$s = (New-Object -ComObject WScript.Shell).CreateShortcut(" + psQuote(path) + "); " +
+		"$s.TargetPath = " + psQuote(exe) + "; " +
+		"$s.WorkingDirectory = " + psQuote(filepath.Dir(exe)) + "; " +
+		"$s.IconLocation = " + psQuote(icon) + "; " +
+		"$s.Description = " + psQuote(name) + "; " +
+		"$s.Save()"
+	cmd := newCommand("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.HideWindow = true
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// psQuote quotes s as a PowerShell literal string.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,242 @@
+package demo
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SideProcess is a helper the app needs next to PHP, e.g. a Reverb
+// websocket server. Its arguments may use {{php}}, {{artisan}}, {{host}}
+// and {{port}}.
+type SideProcess struct {
+	Name          string   `json:"name"`
+	Command       []string `json:"command"`
+	PortEnvKey    string   `json:"port_env_key"`
+	ReadinessPort int      `json:"readiness_port"` // 0: the allocated port
+}
+
+const (
+	sideReadyTimeout = 30 * time.Second
+	sideRestartDelay = time.Second
+	sideMaxRestarts  = 5
+)
+
+// sideProcess runs one SideProcess and restarts it when it exits on its
+// own, up to sideMaxRestarts times.
+type sideProcess struct {
+	name    string
+	args    []string
+	dir     string
+	env     []string
+	port    int
+	grace   time.Duration // how long Stop waits before killing
+	command func(name string, arg ...string) *exec.Cmd
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	tree     *processTree
+	exited   chan struct{}
+	restarts int
+	stopping bool
+}
+
+// newSideProcess expands the placeholders in spec's command. env is the
+// PHP environment, which already carries every side process port.
+func newSideProcess(spec SideProcess, host string, port int, phpBin, artisan, dir string, env []string, grace time.Duration, command func(string, ...string) *exec.Cmd) *sideProcess {
+	r := strings.NewReplacer("{{php}}", phpBin, "{{artisan}}", artisan, "{{host}}", host, "{{port}}", strconv.Itoa(port))
+	args := make([]string, len(spec.Command))
+	for i, arg := range spec.Command {
+		args[i] = r.Replace(arg)
+	}
+	return &sideProcess{name: spec.Name, args: args, dir: dir, env: env, port: port, grace: grace, command: command}
+}
+
+// Start launches the process and begins supervising it.
+func (p *sideProcess) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.start()
+}
+
+func (p *sideProcess) start() error {
+	cmd := p.command(p.args[0], p.args[1:]...)
+	cmd.Env = p.env
+	cmd.Dir = p.dir
+	ownProcessGroup(cmd)
+	w := processOutput(p.name)
+	cmd.Stdout, cmd.Stderr = w, w
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.cmd = cmd
+	logger().Debug("process started", "name", p.name, "pid", cmd.Process.Pid)
+	p.tree = newProcessTree(cmd.Process)
+	p.exited = make(chan struct{})
+	exited, tree := p.exited, p.tree
+	goSafe(p.name, func() { p.wait(cmd, tree, exited) })
+	return nil
+}
+
+func (p *sideProcess) wait(cmd *exec.Cmd, tree *processTree, exited chan struct{}) {
+	err := cmd.Wait()
+	tree.Close()
+	close(exited)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopping {
+		return
+	}
+	p.cmd = nil
+	if p.restarts >= sideMaxRestarts {
+		fmt.Printf("%s exited (%v); giving up after %d restarts\n", p.name, err, p.restarts)
+		return
+	}
+	p.restarts++
+	fmt.Printf("%s exited (%v); restarting\n", p.name, err)
+	time.AfterFunc(sideRestartDelay, func() {
+		defer RecoverCrash(p.name)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.stopping {
+			return
+		}
+		if err := p.start(); err != nil {
+			fmt.Printf("Error restarting %s: %v\n", p.name, err)
+		}
+	})
+}
+
+// Stop ends the process for good: it is asked to exit, killed once its
+// grace period is up, and waited for.
+func (p *sideProcess) Stop() {
+	p.stop(p.grace)
+}
+
+// Kill ends the process for good without a grace period.
+func (p *sideProcess) Kill() {
+	p.stop(0)
+}
+
+func (p *sideProcess) stop(grace time.Duration) {
+	p.mu.Lock()
+	p.stopping = true
+	cmd, tree, exited := p.cmd, p.tree, p.exited
+	p.mu.Unlock()
+
+	if cmd != nil {
+		stopProcess(tree, exited, grace)
+	}
+}
+
+// Restart kills the current process and lets the supervisor start a new
+// one.
+func (p *sideProcess) Restart() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return fmt.Errorf("%s is not running", p.name)
+	}
+	return p.tree.Kill()
+}
+
+// Pid returns the process ID, or 0 while it isn't running.
+func (p *sideProcess) Pid() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return 0
+	}
+	return p.cmd.Process.Pid
+}
+
+// Status reports the process for GET /status.
+func (p *sideProcess) Status() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return map[string]interface{}{
+		"name":     p.name,
+		"port":     p.port,
+		"running":  p.cmd != nil,
+		"restarts": p.restarts,
+	}
+}
+
+// waitForPort polls addr until it accepts connections or timeout passes.
+func waitForPort(addr string, timeout time.Duration, clock Clock) error {
+	deadline := clock.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if clock.Now().After(deadline) {
+			return fmt.Errorf("nothing listening on %s after %s", addr, timeout)
+		}
+		<-clock.After(200 * time.Millisecond)
+	}
+}
+
+// startSideProcesses allocates a port for every side process, starts them
+// in manifest order and waits until each one is ready. The PORT_ENV_KEY
+// entries are appended to env for PHP as well.
+func (l *Launcher) startSideProcesses(host, phpBin string, env []string) ([]string, error) {
+	ports := make([]int, len(l.Config.SideProcesses))
+	for i, spec := range l.Config.SideProcesses {
+		port, err := getFreePort(host)
+		if err != nil {
+			return env, fmt.Errorf("finding free port for %s: %w", spec.Name, err)
+		}
+		ports[i] = port
+		if spec.PortEnvKey != "" {
+			env = append(env, fmt.Sprintf("%s=%d", spec.PortEnvKey, port))
+		}
+	}
+
+	for i, spec := range l.Config.SideProcesses {
+		p := newSideProcess(spec, host, ports[i], phpBin, l.artisan, l.appRoot, env, shutdownGrace(&l.Config), l.Command)
+		l.banner.Step(msg("starting_app", spec.Name))
+		if err := p.Start(); err != nil {
+			return env, fmt.Errorf("starting %s: %w", spec.Name, err)
+		}
+		l.sides = append(l.sides, p)
+
+		ready := spec.ReadinessPort
+		if ready == 0 {
+			ready = p.port
+		}
+		if err := waitForPort(net.JoinHostPort(host, strconv.Itoa(ready)), sideReadyTimeout, l.Clock); err != nil {
+			return env, fmt.Errorf("waiting for %s: %w", spec.Name, err)
+		}
+	}
+	return env, nil
+}
+
+// stopSideProcesses stops the side processes in reverse start order,
+// killing them outright when kill is set.
+func (l *Launcher) stopSideProcesses(kill bool) {
+	for i := len(l.sides) - 1; i >= 0; i-- {
+		if kill {
+			l.sides[i].Kill()
+		} else {
+			l.sides[i].Stop()
+		}
+	}
+	l.sides = nil
+}
+
+// sideProcess returns the running side process called name.
+func (l *Launcher) sideProcess(name string) *sideProcess {
+	for _, p := range l.sides {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package demo
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// BundlePublicKey is the hex Ed25519 public key the bundle must be signed
+// with. main sets it from the value build.py --public-key compiles in:
+//
+//	go build -ldflags "-X main.bundlePublicKey=<hex>"
+//
+// Launchers built without one accept unsigned bundles.
+var BundlePublicKey string
+
+// verifyBundleSignature checks the signature pack --sign-key wrote for
+// checksums.json. Every extracted file is checked against checksums.json,
+// so this covers the whole bundle.
+func verifyBundleSignature(fsys fs.FS, sums []byte) error {
+	if BundlePublicKey == "" {
+		return nil
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(BundlePublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("the launcher was built with an invalid bundle public key")
+	}
+	data, err := fs.ReadFile(fsys, path.Join(bundleRoot, signatureFile))
+	if err != nil {
+		return &integrityError{[]string{signatureFile + ": missing; this launcher only runs signed bundles"}}
+	}
+	sig, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), sums, sig) {
+		return &integrityError{[]string{signatureFile + ": the signature doesn't match the bundle"}}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// startupTimings records where cold-start time goes: extracting the
+// bundle, setup (setup commands, side processes, starting PHP), getting
+// ready (landing check and warmup) and starting the browser.
+type startupTimings struct {
+	extraction, setup, ready, browser time.Duration
+}
+
+type startupPhase struct {
+	name string
+	took time.Duration
+}
+
+// phases returns the timings in order, leaving out the browser when none
+// was opened.
+func (t startupTimings) phases(browser bool) []startupPhase {
+	phases := []startupPhase{{"extraction", t.extraction}, {"setup", t.setup}, {"ready", t.ready}}
+	if browser {
+		phases = append(phases, startupPhase{"browser", t.browser})
+	}
+	return phases
+}
+
+// seconds is the timings for the session summary.
+func (t startupTimings) seconds(browser bool) map[string]float64 {
+	out := make(map[string]float64)
+	for _, p := range t.phases(browser) {
+		out[p.name] = math.Round(p.took.Seconds()*10) / 10
+	}
+	return out
+}
+
+// verbosef prints a timing detail with --verbose.
+func (l *Launcher) verbosef(format string, args ...interface{}) {
+	if l.Options.Verbose {
+		fmt.Printf(format+"\n", args...)
+	}
+}
+
+// logStartup prints the startup timings once the browser was opened or
+// given up on.
+func (l *Launcher) logStartup(ctx context.Context) {
+	select {
+	case <-l.browserDone:
+	case <-ctx.Done():
+		return
+	}
+	var parts []string
+	for _, p := range l.timings.phases(l.browserShown) {
+		parts = append(parts, fmt.Sprintf("%s %.1fs", p.name, p.took.Seconds()))
+	}
+	fmt.Println("Startup: " + strings.Join(parts, ", "))
+}
@@ -0,0 +1,94 @@
+package demo
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// staticFiles serves the app's assets (CSS, JavaScript, images, fonts)
+// from public_root itself, so a page with dozens of them doesn't queue
+// behind php -S's single worker. Only the extensions of staticTypes are
+// served; PHP scripts, folders, HTML pages, which the tour may rewrite,
+// and anything else still go to PHP, as does anything that isn't plainly
+// a file inside public_root: storage:link's symlink to storage/app/public
+// is served by PHP.
+type staticFiles struct {
+	dir    string
+	served atomic.Int64
+}
+
+// staticTypes are the extensions served from disk. An allow-list, since
+// Windows opens index.php for "index.php.", "index.php " and
+// "index.php::$DATA", which a list of what not to serve would let through
+// as PHP source.
+var staticTypes = map[string]bool{
+	".css": true, ".js": true, ".mjs": true, ".map": true, ".json": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+	".avif": true, ".svg": true, ".ico": true, ".bmp": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp4": true, ".webm": true, ".mp3": true, ".ogg": true, ".wav": true,
+	".pdf": true, ".txt": true, ".xml": true, ".wasm": true,
+}
+
+// staticName is the file below public_root urlPath names, if it may be
+// served from disk.
+func staticName(urlPath string) (string, bool) {
+	name := strings.TrimPrefix(urlPath, "/")
+	if !plainName(name) || !staticTypes[strings.ToLower(path.Ext(name))] {
+		return "", false
+	}
+	return name, true
+}
+
+// plainName reports whether name opens the file it says on every system
+// and isn't hidden.
+func plainName(name string) bool {
+	// Windows drops trailing dots and spaces and reads ':' as a stream
+	if name != strings.TrimRight(name, ". ") || strings.ContainsAny(name, ":\\\x00") {
+		return false
+	}
+	// .htaccess, .well-known and the like are the app's business
+	return !strings.HasPrefix(name, ".") && !strings.Contains(name, "/.")
+}
+
+func newStaticFiles(dir string) *staticFiles {
+	return &staticFiles{dir: dir}
+}
+
+// Served is how many requests were answered from disk.
+func (s *staticFiles) Served() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.served.Load()
+}
+
+// serve answers r from disk and reports whether it did.
+func (s *staticFiles) serve(w http.ResponseWriter, r *http.Request) bool {
+	if s == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	name, ok := staticName(r.URL.Path)
+	if !ok {
+		return false
+	}
+	file, err := safeJoin(s.dir, name)
+	if err != nil {
+		return false
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	s.served.Add(1)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	return true
+}
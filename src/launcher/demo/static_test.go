@@ -0,0 +1,67 @@
+package demo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticFilesServesOnlyAssets(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"index.php":   "<?php echo getenv('APP_KEY');",
+		"css/app.css": "body{}",
+		"index.html":  "<html>",
+		".env":        "APP_KEY=secret",
+	} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newStaticFiles(dir)
+
+	tests := []struct {
+		path   string
+		served bool
+	}{
+		{"/css/app.css", true},
+		{"/CSS/../css/app.css", false},
+		{"/index.php", false},
+		{"/index.php.", false},
+		{"/index.php%20", false},
+		{"/index.php ", false},
+		{"/index.php::$DATA", false},
+		{"/index.php:.css", false},
+		{"/css/app.css.", false},
+		{"/index.html", false},
+		{"/.env", false},
+		{"/css", false},
+		{"/missing.css", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL.Path = tt.path
+		w := httptest.NewRecorder()
+		if got := s.serve(w, r); got != tt.served {
+			t.Errorf("serve(%q) = %v, want %v", tt.path, got, tt.served)
+		}
+	}
+}
+
+func TestStaticFilesIgnoresPost(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/app.js", nil)
+	if newStaticFiles(dir).serve(httptest.NewRecorder(), r) {
+		t.Error("served a POST from disk")
+	}
+}
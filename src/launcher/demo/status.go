@@ -0,0 +1,52 @@
+package demo
+
+// status reports the state of the running demo for the control API's
+// GET /status.
+func (l *Launcher) status() map[string]interface{} {
+	sides := make([]map[string]interface{}, len(l.sides))
+	for i, p := range l.sides {
+		sides[i] = p.Status()
+	}
+	status := map[string]interface{}{
+		"app_name":       l.Config.AppName,
+		"app_version":    l.Config.AppVersion,
+		"url":            l.baseURL,
+		"port":           l.port,
+		"offline":        l.Config.Offline,
+		"paused":         l.isPaused(),
+		"uptime_seconds": int(l.Clock.Now().Sub(l.started).Seconds()),
+		"proxy":          l.proxy.Stats(),
+		"server_mode":    l.serverMode(),
+		"php_workers":    len(l.servers),
+		"side_processes": sides,
+	}
+	if l.Options.ServeDir != "" {
+		status["dev_mode"] = l.Options.ServeDir
+	}
+	if l.Options.Deterministic {
+		status["deterministic"] = l.Config.Deterministic
+	}
+	if l.Config.Accessibility.enabled() {
+		status["accessibility"] = l.Config.Accessibility
+	}
+	if dest := l.lastExport.Load(); dest != nil {
+		status["last_export"] = *dest
+	}
+	if l.session != nil {
+		status["session"] = l.session.name
+	}
+	if l.expiry != nil {
+		status["remaining_seconds"] = int(l.expiry.Remaining().Seconds())
+		status["on_expiry"] = l.expiryPolicy()
+		if _, at := l.proxy.expired.get(); !at.IsZero() {
+			status["expired_at"] = at
+		}
+	}
+	if l.quota != nil {
+		status["workdir"] = l.quota.Stats()
+	}
+	if l.watchdog != nil {
+		status["watchdog"] = l.watchdog.Stats()
+	}
+	return status
+}
@@ -0,0 +1,109 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	sessionSummaryFile  = "session-summary.json"
+	sessionSummaryPaths = 20
+)
+
+// sessionSummary is what the sales team gets after a demo.
+type sessionSummary struct {
+	AppName         string             `json:"app_name"`
+	AppVersion      string             `json:"app_version"`
+	StartTime       time.Time          `json:"start_time"`
+	EndTime         time.Time          `json:"end_time"`
+	DurationSeconds int                `json:"duration_seconds"`
+	ExitReason      string             `json:"exit_reason"`
+	Requests        int64              `json:"requests"`
+	ServerErrors    int64              `json:"server_errors"`
+	UpstreamErrors  int64              `json:"upstream_errors"` // PHP didn't answer; the hiccup page was shown
+	TopPaths        []pathCount        `json:"top_paths"`
+	StartupSeconds  map[string]float64 `json:"startup_seconds"`
+	WorkDirMB       *int64             `json:"workdir_mb,omitempty"` // with max_workdir_mb
+	Accessibility   *Accessibility     `json:"accessibility,omitempty"`
+	OnExpiry        string             `json:"on_expiry,omitempty"` // with a demo duration
+	ExpiredAt       *time.Time         `json:"expired_at,omitempty"`
+}
+
+// Exit reasons as recorded in the summary.
+var summaryReasons = map[string]string{
+	exitReasonQuit:         "user_quit",
+	exitReasonExpired:      "expired",
+	exitReasonIdle:         "idle",
+	exitReasonWindowClosed: "window_closed",
+}
+
+// writeSessionSummary saves the summary of the session that just ended.
+// It runs before the work dir is removed, so it only uses what's in
+// memory.
+func (l *Launcher) writeSessionSummary(reason string) {
+	if l.Config.SessionSummary != nil && !*l.Config.SessionSummary {
+		return
+	}
+	end := l.Clock.Now()
+	summary := sessionSummary{
+		AppName:         l.Config.AppName,
+		AppVersion:      l.Config.AppVersion,
+		StartTime:       l.started,
+		EndTime:         end,
+		DurationSeconds: int(end.Sub(l.started).Seconds()),
+		ExitReason:      summaryReasons[reason],
+		Requests:        l.proxy.requests.Load(),
+		ServerErrors:    l.proxy.log.ServerErrors(),
+		UpstreamErrors:  l.proxy.hiccups.Load(),
+		TopPaths:        l.proxy.log.top(sessionSummaryPaths),
+		StartupSeconds:  l.timings.seconds(l.browserShown),
+	}
+	if l.expiry != nil {
+		summary.OnExpiry = l.expiryPolicy()
+		if _, at := l.proxy.expired.get(); !at.IsZero() {
+			summary.ExpiredAt = &at
+		} else if l.expiry.Remaining() == 0 {
+			// terminate: the demo ends now
+			summary.ExpiredAt = &end
+		}
+	}
+	if a := l.Config.Accessibility; a.enabled() {
+		summary.Accessibility = &a
+	}
+	if l.quota != nil {
+		used := l.quota.measure() >> 20
+		summary.WorkDirMB = &used
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		fmt.Printf("Error writing session summary: %v\n", err)
+		return
+	}
+
+	for _, dir := range l.sessionSummaryDirs() {
+		path := filepath.Join(dir, sessionSummaryFile)
+		if err = ioutil.WriteFile(path, data, 0644); err == nil {
+			fmt.Printf("Session summary written to %s\n", path)
+			return
+		}
+	}
+	fmt.Printf("Error writing session summary: %v\n", err)
+}
+
+// sessionSummaryDirs lists where the summary may go, in order of
+// preference: session_summary_dir, else next to the executable, falling
+// back to the Desktop when that isn't writable (e.g. Program Files).
+func (l *Launcher) sessionSummaryDirs() []string {
+	if l.Config.SessionSummaryDir != "" {
+		return []string{l.Config.SessionSummaryDir}
+	}
+	dirs := []string{l.ExeDir}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "Desktop"), home)
+	}
+	return dirs
+}
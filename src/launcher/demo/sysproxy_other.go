@@ -0,0 +1,11 @@
+//go:build !windows
+
+package demo
+
+import "net/url"
+
+// systemProxy is the proxy from the OS settings. Elsewhere than Windows
+// those are exported as HTTP(S)_PROXY, which outboundProxy already reads.
+func systemProxy(u *url.URL) (*url.URL, error) {
+	return nil, nil
+}
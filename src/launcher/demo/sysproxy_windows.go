@@ -0,0 +1,151 @@
+package demo
+
+import (
+	"net/url"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	winhttp                                   = syscall.NewLazyDLL("winhttp.dll")
+	procWinHttpGetIEProxyConfigForCurrentUser = winhttp.NewProc("WinHttpGetIEProxyConfigForCurrentUser")
+	procWinHttpOpen                           = winhttp.NewProc("WinHttpOpen")
+	procWinHttpSetTimeouts                    = winhttp.NewProc("WinHttpSetTimeouts")
+	procWinHttpGetProxyForUrl                 = winhttp.NewProc("WinHttpGetProxyForUrl")
+	procGlobalFree                            = kernel32.NewProc("GlobalFree")
+)
+
+// WinHTTP constants.
+const (
+	winhttpAccessTypeNoProxy    = 1
+	winhttpAccessTypeNamedProxy = 3
+	winhttpAutoproxyAutoDetect  = 1
+	winhttpAutoproxyConfigURL   = 2
+	winhttpAutoDetectTypeDHCP   = 1
+	winhttpAutoDetectTypeDNSA   = 2
+	// pacTimeoutMillis bounds finding and running the PAC script.
+	pacTimeoutMillis = 3000
+)
+
+type winhttpIEProxyConfig struct {
+	autoDetect    int32
+	autoConfigURL *uint16
+	proxy         *uint16
+	proxyBypass   *uint16
+}
+
+type winhttpAutoproxyOptions struct {
+	flags                 uint32
+	autoDetectFlags       uint32
+	autoConfigURL         *uint16
+	reserved              uintptr
+	reservedDword         uint32
+	autoLogonIfChallenged int32
+}
+
+type winhttpProxyInfo struct {
+	accessType  uint32
+	proxy       *uint16
+	proxyBypass *uint16
+}
+
+// ieProxy is the user's proxy configuration (Internet Options), read once.
+var ieProxy struct {
+	once       sync.Once
+	autoDetect bool
+	pacURL     string
+	proxy      string
+	bypass     string
+	session    uintptr // WinHTTP session for PAC lookups
+
+	mu    sync.Mutex
+	cache map[string]*url.URL // PAC results per scheme and host
+}
+
+// systemProxy applies the Internet Options proxy settings: a fixed proxy
+// with its bypass list, or a PAC script (configured or auto-detected),
+// which WinHTTP evaluates.
+func systemProxy(u *url.URL) (*url.URL, error) {
+	ieProxy.once.Do(loadIEProxy)
+
+	if ieProxy.pacURL != "" || ieProxy.autoDetect {
+		key := u.Scheme + "://" + u.Host
+		ieProxy.mu.Lock()
+		defer ieProxy.mu.Unlock()
+		if p, ok := ieProxy.cache[key]; ok {
+			return p, nil
+		}
+		if p, ok := pacProxy(u); ok {
+			ieProxy.cache[key] = p
+			return p, nil
+		}
+		// No PAC script found; use the fixed settings from now on rather
+		// than searching again for every request
+		ieProxy.pacURL, ieProxy.autoDetect = "", false
+	}
+	if ieProxy.proxy == "" || proxyBypassed(u.Hostname(), ieProxy.bypass) {
+		return nil, nil
+	}
+	return parseProxyList(ieProxy.proxy, u.Scheme), nil
+}
+
+func loadIEProxy() {
+	ieProxy.cache = make(map[string]*url.URL)
+	var cfg winhttpIEProxyConfig
+	if r, _, _ := procWinHttpGetIEProxyConfigForCurrentUser.Call(uintptr(unsafe.Pointer(&cfg))); r == 0 {
+		return
+	}
+	ieProxy.autoDetect = cfg.autoDetect != 0
+	ieProxy.pacURL = takeWinHTTPString(cfg.autoConfigURL)
+	ieProxy.proxy = takeWinHTTPString(cfg.proxy)
+	ieProxy.bypass = takeWinHTTPString(cfg.proxyBypass)
+}
+
+// pacProxy runs the PAC script for u. ok is false when there's no script
+// to run.
+func pacProxy(u *url.URL) (proxy *url.URL, ok bool) {
+	if ieProxy.session == 0 {
+		agent, _ := syscall.UTF16PtrFromString("laravel_demo")
+		h, _, _ := procWinHttpOpen.Call(uintptr(unsafe.Pointer(agent)), winhttpAccessTypeNoProxy, 0, 0, 0)
+		if h == 0 {
+			return nil, false
+		}
+		procWinHttpSetTimeouts.Call(h, pacTimeoutMillis, pacTimeoutMillis, pacTimeoutMillis, pacTimeoutMillis)
+		ieProxy.session = h
+	}
+
+	opts := winhttpAutoproxyOptions{autoLogonIfChallenged: 1}
+	if ieProxy.pacURL != "" {
+		opts.flags = winhttpAutoproxyConfigURL
+		opts.autoConfigURL, _ = syscall.UTF16PtrFromString(ieProxy.pacURL)
+	} else {
+		opts.flags = winhttpAutoproxyAutoDetect
+		opts.autoDetectFlags = winhttpAutoDetectTypeDHCP | winhttpAutoDetectTypeDNSA
+	}
+	target, _ := syscall.UTF16PtrFromString(u.String())
+	var info winhttpProxyInfo
+	r, _, _ := procWinHttpGetProxyForUrl.Call(ieProxy.session, uintptr(unsafe.Pointer(target)), uintptr(unsafe.Pointer(&opts)), uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return nil, false
+	}
+	list := takeWinHTTPString(info.proxy)
+	takeWinHTTPString(info.proxyBypass)
+	if info.accessType != winhttpAccessTypeNamedProxy || list == "" {
+		return nil, true
+	}
+	return parseProxyList(list, u.Scheme), true
+}
+
+// takeWinHTTPString copies a string WinHTTP allocated and frees it.
+func takeWinHTTPString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	var chars []uint16
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; ptr = unsafe.Add(ptr, 2) {
+		chars = append(chars, *(*uint16)(ptr))
+	}
+	procGlobalFree.Call(uintptr(unsafe.Pointer(p)))
+	return syscall.UTF16ToString(chars)
+}
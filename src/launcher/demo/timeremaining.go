@@ -0,0 +1,64 @@
+package demo
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// timeRemainingHeader carries the seconds left in the demo on every
+// response, and on requests to PHP so pages can show it when rendered.
+// It is left out when there's no demo duration.
+const timeRemainingHeader = "X-Demo-Seconds-Remaining"
+
+// timeRemainingPath serves GET /time-remaining on the demo's own origin,
+// for pages that don't know the control API's port.
+const timeRemainingPath = "/__launcher/time-remaining"
+
+// timeRemaining answers GET /time-remaining. Without a demo duration every
+// field is null.
+type timeRemaining struct {
+	SecondsRemaining *int       `json:"seconds_remaining"`
+	ExpiresAt        *time.Time `json:"expires_at"` // moves while the timer is stopped
+	Policy           *string    `json:"policy"`
+}
+
+// timeRemaining reads the expiry timer, so paused and slept-through time
+// is accounted exactly as for the expiry itself.
+func (l *Launcher) timeRemaining() timeRemaining {
+	if l.expiry == nil {
+		return timeRemaining{}
+	}
+	left := l.expiry.Remaining()
+	seconds := int(left.Seconds())
+	policy := l.expiryPolicy()
+	at := l.Clock.Now().Add(left)
+	if _, expired := l.proxy.expired.get(); !expired.IsZero() {
+		at = expired
+	}
+	return timeRemaining{SecondsRemaining: &seconds, ExpiresAt: &at, Policy: &policy}
+}
+
+// setTimeRemaining replaces any X-Demo-Seconds-Remaining the browser sent
+// with the launcher's own, on the request and the response.
+func (p *demoProxy) setTimeRemaining(w http.ResponseWriter, r *http.Request) {
+	r.Header.Del(timeRemainingHeader)
+	if p.timeLeft == nil {
+		return
+	}
+	if left := p.timeLeft(); left.SecondsRemaining != nil {
+		seconds := strconv.Itoa(*left.SecondsRemaining)
+		r.Header.Set(timeRemainingHeader, seconds)
+		w.Header().Set(timeRemainingHeader, seconds)
+	}
+}
+
+// serveTimeRemaining answers timeRemainingPath.
+func (p *demoProxy) serveTimeRemaining(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Path != timeRemainingPath || p.timeLeft == nil {
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, p.timeLeft())
+	return true
+}
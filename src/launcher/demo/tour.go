@@ -0,0 +1,149 @@
+package demo
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//go:embed pages/tour.js
+var defaultTourScript []byte
+
+// Tour is a guided tour layered over the app. StepsPath is a JSON file in
+// the bundle; ScriptPath replaces the built-in overlay script.
+type Tour struct {
+	ScriptPath string `json:"script_path"`
+	StepsPath  string `json:"steps_path"`
+}
+
+const (
+	tourScriptPath = "/__launcher/tour.js"
+	tourStepsPath  = "/__launcher/tour-steps.json"
+	// tourSkipParam in the query string turns the overlay off for a
+	// request, e.g. for testing the app itself.
+	tourSkipParam = "no_tour"
+	// injectMaxPage bounds the pages the proxy buffers to inject a tag;
+	// bigger ones pass through untouched.
+	injectMaxPage = 8 << 20
+)
+
+var tourTag = []byte(`<script src="` + tourScriptPath + `" defer></script>`)
+
+// tourAssets is a loaded Tour.
+type tourAssets struct {
+	script []byte
+	steps  []byte
+}
+
+// loadTour reads the manifest's tour from the bundle.
+func (l *Launcher) loadTour() (*tourAssets, error) {
+	tour := &tourAssets{script: defaultTourScript}
+	if l.Config.Tour.ScriptPath != "" {
+		script, err := ioutil.ReadFile(l.bundlePath(l.Config.Tour.ScriptPath))
+		if err != nil {
+			return nil, err
+		}
+		tour.script = script
+	}
+	steps, err := ioutil.ReadFile(l.bundlePath(l.Config.Tour.StepsPath))
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(steps) {
+		return nil, fmt.Errorf("%s is not valid JSON", l.Config.Tour.StepsPath)
+	}
+	tour.steps = steps
+	return tour, nil
+}
+
+// serveTour answers requests for the tour's own files.
+func (t *tourAssets) serve(w http.ResponseWriter, r *http.Request) bool {
+	var body []byte
+	switch r.URL.Path {
+	case tourScriptPath:
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		body = t.script
+	case tourStepsPath:
+		w.Header().Set("Content-Type", "application/json")
+		body = t.steps
+	default:
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(body)
+	return true
+}
+
+// inject adds the tour's script tag to successful HTML pages. It runs as
+// part of the PHP proxy's ModifyResponse.
+func (t *tourAssets) inject(resp *http.Response) error {
+	if resp.Request.URL.Query().Has(tourSkipParam) {
+		return nil
+	}
+	return injectTag(resp, tourTag)
+}
+
+// injectTag adds tag before the </body> of a successful HTML page from
+// PHP, or at its end when there's none.
+func injectTag(resp *http.Response, tag []byte) error {
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return nil
+	}
+	if resp.ContentLength > injectMaxPage {
+		return nil
+	}
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	if encoding != "" && encoding != "gzip" {
+		return nil
+	}
+
+	body := resp.Body
+	data, err := ioutil.ReadAll(io.LimitReader(body, injectMaxPage+1))
+	if err != nil {
+		body.Close()
+		return err
+	}
+	if len(data) > injectMaxPage {
+		// Too big to rewrite; send it on as it came
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), body), body}
+		return nil
+	}
+	body.Close()
+	if encoding == "gzip" {
+		// The proxy asks PHP for plain responses, but an app may
+		// compress on its own
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		if data, err = ioutil.ReadAll(zr); err != nil {
+			return err
+		}
+		resp.Header.Del("Content-Encoding")
+	}
+
+	if i := bytes.LastIndex(bytes.ToLower(data), []byte("</body>")); i >= 0 {
+		data = append(data[:i:i], append(tag, data[i:]...)...)
+	} else {
+		data = append(data, tag...)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	resp.Header.Del("ETag")
+	return nil
+}
@@ -0,0 +1,132 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// trayItem is one entry of the tray icon's menu.
+type trayItem struct {
+	label   string
+	confirm string // asked before action runs, if set
+	action  func() error
+}
+
+// showsTray reports whether the demo gets a tray icon: where the platform
+// has one the launcher can draw, unless tray_icon is false.
+func (l *Launcher) showsTray() bool {
+	return trayAvailable && (l.Config.TrayIcon == nil || *l.Config.TrayIcon)
+}
+
+// startTray shows the tray icon once the demo is up.
+func (l *Launcher) startTray() {
+	if !l.showsTray() {
+		return
+	}
+	var icon string
+	if l.Config.IconPath != "" {
+		icon = l.bundlePath(l.Config.IconPath)
+	}
+	tray, err := showTray(icon, l.trayTooltip, l.trayItems())
+	if err != nil {
+		fmt.Printf("Warning: the tray icon can't be shown: %v\n", err)
+		return
+	}
+	l.tray = tray
+}
+
+// trayItems are the tray menu, the first being what a double-click does.
+func (l *Launcher) trayItems() []trayItem {
+	items := []trayItem{
+		{label: msg("tray_open"), action: l.OpenDemo},
+		{label: msg("tray_restart"), action: l.RestartPHP},
+	}
+	if l.resetter != nil {
+		items = append(items, trayItem{label: msg("tray_reset"), confirm: msg("tray_reset_confirm"), action: l.ResetData})
+	}
+	items = append(items, trayItem{label: msg("tray_export"), action: l.ExportData})
+	return append(items, trayItem{label: msg("tray_quit"), action: l.Quit})
+}
+
+// trayTooltip names the app and, with a demo duration, the time left. It
+// is asked again every trayRefresh.
+func (l *Launcher) trayTooltip() string {
+	left := l.timeRemaining()
+	if left.SecondsRemaining == nil {
+		return l.Config.AppName
+	}
+	minutes := (*left.SecondsRemaining + 59) / 60
+	return l.Config.AppName + " - " + msg("tray_time_left", minutes)
+}
+
+// OpenDemo opens the landing page again, e.g. after the browser was closed.
+func (l *Launcher) OpenDemo() error {
+	return l.OpenURL(l.baseURL + l.Config.LandingPageURL)
+}
+
+// RestartPHP restarts every PHP worker, for an app that got stuck or a
+// PHP that was given up on.
+func (l *Launcher) RestartPHP() error {
+	fmt.Println(msg("restarting_php"))
+	if err := l.servers.Restart(); err != nil {
+		return fmt.Errorf("restarting PHP: %w", err)
+	}
+	l.proxy.phpFailed.Store(false)
+	return nil
+}
+
+// ResetData puts the demo data back as auto_reset_minutes would.
+func (l *Launcher) ResetData() error {
+	if l.resetter == nil {
+		return errors.New("data reset isn't set up for this demo")
+	}
+	if err := l.resetter.Reset(); err != nil {
+		return fmt.Errorf("resetting demo data: %w", err)
+	}
+	return nil
+}
+
+// ExportData saves the demo's data as --export-data does at exit, to the
+// Desktop or the home folder, pausing PHP for the copy so the database
+// isn't torn. The control API's status then names the file.
+func (l *Launcher) ExportData() error {
+	if l.Options.ServeDir != "" {
+		return errors.New("the data of --serve-dir is the checkout's own")
+	}
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "Desktop"), home)
+	}
+	dirs = append(dirs, l.ExeDir)
+	name := exportFileName(&l.Config, l.Clock.Now())
+	paths := resetPaths(&l.Config, l.dataDir, l.dataAppRoot())
+
+	var err error
+	for _, dir := range dirs {
+		if info, statErr := os.Stat(dir); statErr != nil || !info.IsDir() {
+			continue
+		}
+		dest := filepath.Join(dir, name)
+		fmt.Println(msg("exporting_data", dest))
+		err = l.dataGuard.Do(dataExporting, func() error {
+			return exportData(dest, &l.Config, l.dataDir, paths)
+		})
+		if err == nil {
+			l.lastExport.Store(&dest)
+			fmt.Println(msg("data_exported", dest))
+			return nil
+		}
+	}
+	if err == nil {
+		err = errors.New("no folder to write it to")
+	}
+	return fmt.Errorf("exporting demo data: %w", err)
+}
+
+// Quit ends the demo as Ctrl+C would.
+func (l *Launcher) Quit() error {
+	l.quitOnce.Do(func() { close(l.quit) })
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package demo
+
+// trayAvailable is false: the macOS menu bar and the Linux tray protocols
+// need Cocoa or D-Bus bindings the launcher doesn't link. The same actions
+// are on the control API.
+const trayAvailable = false
+
+type trayIcon struct{}
+
+func showTray(icon string, tooltip func() string, items []trayItem) (*trayIcon, error) {
+	return &trayIcon{}, nil
+}
+
+func (t *trayIcon) Close() {}
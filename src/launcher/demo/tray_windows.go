@@ -0,0 +1,260 @@
+package demo
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	shell32                    = syscall.NewLazyDLL("shell32.dll")
+	procShellNotifyIconW       = shell32.NewProc("Shell_NotifyIconW")
+	procRegisterWindowMessageW = user32.NewProc("RegisterWindowMessageW")
+	procPostMessageW           = user32.NewProc("PostMessageW")
+	procPostQuitMessage        = user32.NewProc("PostQuitMessage")
+	procDestroyWindow          = user32.NewProc("DestroyWindow")
+	procSetTimer               = user32.NewProc("SetTimer")
+	procLoadIconW              = user32.NewProc("LoadIconW")
+	procCreatePopupMenu        = user32.NewProc("CreatePopupMenu")
+	procAppendMenuW            = user32.NewProc("AppendMenuW")
+	procSetMenuDefaultItem     = user32.NewProc("SetMenuDefaultItem")
+	procTrackPopupMenu         = user32.NewProc("TrackPopupMenu")
+	procDestroyMenu            = user32.NewProc("DestroyMenu")
+	procGetCursorPos           = user32.NewProc("GetCursorPos")
+	procSetForegroundWindow    = user32.NewProc("SetForegroundWindow")
+	procMessageBoxW            = user32.NewProc("MessageBoxW")
+)
+
+// trayAvailable is true: the notification area is part of every desktop.
+const trayAvailable = true
+
+const (
+	nimAdd    = 0
+	nimModify = 1
+	nimDelete = 2
+
+	nifMessage = 0x1
+	nifIcon    = 0x2
+	nifTip     = 0x4
+
+	wmNull          = 0x0
+	wmDestroy       = 0x2
+	wmClose         = 0x10
+	wmTimer         = 0x113
+	wmLButtonDblClk = 0x203
+	wmRButtonUp     = 0x205
+	wmTrayIcon      = 0x8001 // WM_APP+1
+
+	mfString          = 0x0
+	tpmRightButton    = 0x2
+	tpmReturnCmd      = 0x100
+	mbOKCancel        = 0x1
+	mbIconQuestion    = 0x20
+	mbSetForeground   = 0x10000
+	idOK              = 1
+	idiApplication    = 32512
+	exeIconID         = 1 // the icon group winres writes
+	trayRefreshMillis = 15000
+)
+
+// notifyIconData is NOTIFYICONDATAW.
+type notifyIconData struct {
+	Size            uint32
+	Wnd             syscall.Handle
+	ID              uint32
+	Flags           uint32
+	CallbackMessage uint32
+	Icon            syscall.Handle
+	Tip             [128]uint16
+	State           uint32
+	StateMask       uint32
+	Info            [256]uint16
+	Version         uint32
+	InfoTitle       [64]uint16
+	InfoFlags       uint32
+	GUIDItem        [16]byte
+	BalloonIcon     syscall.Handle
+}
+
+// trayIcon is the launcher's icon in the notification area, on a hidden
+// window of its own thread that gets its clicks.
+type trayIcon struct {
+	hwnd uintptr
+	data notifyIconData
+}
+
+// showTray adds the icon, the .ico file or the generic application icon,
+// with tooltip() as its tooltip, refreshed every trayRefreshMillis. A
+// right-click opens the menu of items, whose actions run off the window's
+// thread.
+func showTray(icon string, tooltip func() string, items []trayItem) (*trayIcon, error) {
+	ready := make(chan error, 1)
+	t := &trayIcon{}
+	go t.run(icon, tooltip, items, ready)
+	if err := <-ready; err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *trayIcon) run(icon string, tooltip func() string, items []trayItem, ready chan<- error) {
+	// The window belongs to this thread, which must pump its messages
+	runtime.LockOSThread()
+	instance, _, _ := procGetModuleHandleW.Call(0)
+	class, _ := syscall.UTF16PtrFromString("laravel_demo_tray")
+	created, _ := syscall.UTF16PtrFromString("TaskbarCreated")
+	// Sent when Explorer restarts, which empties the notification area
+	taskbarCreated, _, _ := procRegisterWindowMessageW.Call(uintptr(unsafe.Pointer(created)))
+
+	wc := wndClassEx{
+		WndProc: syscall.NewCallback(func(hwnd, message, wParam, lParam uintptr) uintptr {
+			switch {
+			case message == wmTrayIcon:
+				switch lParam & 0xffff {
+				case wmLButtonDblClk:
+					runTrayItem(items[0])
+				case wmRButtonUp:
+					if i, ok := trayMenu(hwnd, items); ok {
+						runTrayItem(items[i])
+					}
+				}
+				return 0
+			case message == wmTimer:
+				t.setTip(tooltip())
+				procShellNotifyIconW.Call(nimModify, uintptr(unsafe.Pointer(&t.data)))
+				return 0
+			case message == taskbarCreated && taskbarCreated != 0:
+				procShellNotifyIconW.Call(nimAdd, uintptr(unsafe.Pointer(&t.data)))
+				return 0
+			case message == wmClose:
+				procDestroyWindow.Call(hwnd)
+				return 0
+			case message == wmDestroy:
+				procPostQuitMessage.Call(0)
+				return 0
+			}
+			ret, _, _ := procDefWindowProcW.Call(hwnd, message, wParam, lParam)
+			return ret
+		}),
+		Instance:  syscall.Handle(instance),
+		ClassName: class,
+	}
+	wc.Size = uint32(unsafe.Sizeof(wc))
+	if atom, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); atom == 0 {
+		ready <- fmt.Errorf("registering its window class: %w", err)
+		return
+	}
+	hwnd, _, err := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(class)), 0, 0,
+		0, 0, 0, 0, 0, 0, instance, 0)
+	if hwnd == 0 {
+		ready <- fmt.Errorf("creating its window: %w", err)
+		return
+	}
+
+	t.hwnd = hwnd
+	t.data.Wnd = syscall.Handle(hwnd)
+	t.data.ID = 1
+	t.data.Flags = nifMessage | nifIcon | nifTip
+	t.data.CallbackMessage = wmTrayIcon
+	t.data.Icon = loadTrayIcon(icon)
+	t.setTip(tooltip())
+	t.data.Size = uint32(unsafe.Sizeof(t.data))
+	if ok, _, err := procShellNotifyIconW.Call(nimAdd, uintptr(unsafe.Pointer(&t.data))); ok == 0 {
+		procDestroyWindow.Call(hwnd)
+		ready <- fmt.Errorf("adding it: %w", err)
+		return
+	}
+	procSetTimer.Call(hwnd, 1, trayRefreshMillis, 0)
+	ready <- nil
+
+	var m winMsg
+	for {
+		if r, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0); int32(r) <= 0 {
+			return
+		}
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
+
+// Close removes the icon right away, so it doesn't linger after the
+// launcher exited, and ends the window's thread.
+func (t *trayIcon) Close() {
+	procShellNotifyIconW.Call(nimDelete, uintptr(unsafe.Pointer(&t.data)))
+	procPostMessageW.Call(t.hwnd, wmClose, 0, 0)
+}
+
+// setTip sets the tooltip, cut to the 127 characters Windows shows.
+func (t *trayIcon) setTip(tip string) {
+	u, err := syscall.UTF16FromString(tip)
+	if err != nil {
+		return
+	}
+	if len(u) > len(t.data.Tip) {
+		u = append(u[:len(t.data.Tip)-1], 0)
+	}
+	t.data.Tip = [128]uint16{}
+	copy(t.data.Tip[:], u)
+}
+
+// loadTrayIcon loads the small size of an .ico file, falling back to the
+// icon built into the exe and then the generic application icon.
+func loadTrayIcon(file string) syscall.Handle {
+	size, _, _ := procGetSystemMetrics.Call(smCXSmIcon)
+	if name, err := syscall.UTF16PtrFromString(file); err == nil && file != "" {
+		if h, _, _ := procLoadImageW.Call(0, uintptr(unsafe.Pointer(name)), imageIcon, size, size, lrLoadFromFile); h != 0 {
+			return syscall.Handle(h)
+		}
+	}
+	instance, _, _ := procGetModuleHandleW.Call(0)
+	if h, _, _ := procLoadImageW.Call(instance, exeIconID, imageIcon, size, size, 0); h != 0 {
+		return syscall.Handle(h)
+	}
+	h, _, _ := procLoadIconW.Call(0, idiApplication)
+	return syscall.Handle(h)
+}
+
+// trayMenu shows the menu at the cursor and returns the item picked.
+func trayMenu(hwnd uintptr, items []trayItem) (int, bool) {
+	menu, _, _ := procCreatePopupMenu.Call()
+	if menu == 0 {
+		return 0, false
+	}
+	defer procDestroyMenu.Call(menu)
+	for i, item := range items {
+		label, _ := syscall.UTF16PtrFromString(item.label)
+		procAppendMenuW.Call(menu, mfString, uintptr(i+1), uintptr(unsafe.Pointer(label)))
+	}
+	procSetMenuDefaultItem.Call(menu, 1, 0)
+
+	var pt struct{ X, Y int32 }
+	procGetCursorPos.Call(uintptr(unsafe.Pointer(&pt)))
+	// Without this the menu stays open when clicking elsewhere
+	procSetForegroundWindow.Call(hwnd)
+	cmd, _, _ := procTrackPopupMenu.Call(menu, tpmRightButton|tpmReturnCmd, uintptr(pt.X), uintptr(pt.Y), 0, hwnd, 0)
+	procPostMessageW.Call(hwnd, wmNull, 0, 0)
+	if cmd == 0 {
+		return 0, false
+	}
+	return int(cmd) - 1, true
+}
+
+// runTrayItem asks item's question, if any, and runs its action, both off
+// the window's thread so the icon stays responsive.
+func runTrayItem(item trayItem) {
+	goSafe("tray menu", func() {
+		if item.confirm != "" && !trayConfirm(item.label, item.confirm) {
+			return
+		}
+		if err := item.action(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	})
+}
+
+func trayConfirm(title, text string) bool {
+	t, _ := syscall.UTF16PtrFromString(title)
+	q, _ := syscall.UTF16PtrFromString(text)
+	r, _, _ := procMessageBoxW.Call(0, uintptr(unsafe.Pointer(q)), uintptr(unsafe.Pointer(t)), mbOKCancel|mbIconQuestion|mbSetForeground)
+	return r == idOK
+}
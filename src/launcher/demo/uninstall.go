@@ -0,0 +1,265 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PerformUninstall implements --uninstall: it removes everything the
+// launcher left on this machine for the app, prints what went and
+// returns the exit code. The launcher itself is left for the user to
+// delete.
+func PerformUninstall(config *Manifest) int {
+	fmt.Println(msg("uninstalling"))
+
+	// Removing files under a running demo would break it. A running demo
+	// has created its cache dir.
+	if _, err := os.Stat(appCacheDir(config)); err == nil {
+		instance, err := lockInstance(config)
+		if err == errAlreadyRunning {
+			fmt.Println(msg("uninstall_running"))
+			return 1
+		}
+		if instance != nil {
+			defer instance.Close()
+		}
+	}
+	configs := uninstallConfigs(config)
+	for _, c := range configs {
+		if name := runningSession(c); name != "" {
+			fmt.Println(msg("uninstall_session_running", name))
+			return 1
+		}
+	}
+
+	u := &uninstaller{}
+	if exePath, err := os.Executable(); err == nil && config.DBPath != "" {
+		// A development build keeps its database next to the executable
+		dbPath := config.DBPath
+		if !filepath.IsAbs(dbPath) {
+			dbPath = filepath.Join(filepath.Dir(exePath), dbPath)
+		}
+		u.remove(dbPath)
+	}
+	for _, c := range configs {
+		if dir, err := appDataDir(c); err == nil {
+			u.remove(dir)
+			os.Remove(filepath.Dir(dir)) // if no other demo keeps data
+		}
+		// Before the cache dir, which holds the record of them
+		if c.UninstallShortcut {
+			for _, p := range createdShortcuts(c) {
+				u.removeShortcut(p)
+			}
+		}
+		// Extracted code, sessions, the EULA record and crash reports
+		u.remove(appCacheDir(c))
+		for _, suffix := range []string{"_diagnostics.txt", "_error.html", "_exit.html"} {
+			u.remove(filepath.Join(os.TempDir(), appSlug(c)+suffix))
+		}
+		u.removed = append(u.removed, removeRegistryEntries(c)...)
+	}
+	sweepStaleDirs()
+	u.removed = append(u.removed, removeOrphanedDirs()...)
+	u.removeSharedCache()
+
+	if len(u.removed) == 0 {
+		fmt.Println(msg("uninstall_nothing"))
+	} else {
+		fmt.Println(msg("uninstall_removed", len(u.removed)))
+		for _, p := range u.removed {
+			fmt.Printf("  %s\n", p)
+		}
+	}
+	if u.failed {
+		return 1
+	}
+	fmt.Println(msg("cleanup_complete"))
+	return 0
+}
+
+// uninstaller collects what an uninstall removed.
+type uninstaller struct {
+	removed []string
+	failed  bool
+}
+
+// remove deletes p if it exists.
+func (u *uninstaller) remove(p string) {
+	if _, err := os.Lstat(p); err != nil {
+		return
+	}
+	if err := removeAllRetry(p, removeTimeout); err != nil {
+		fmt.Printf("Error removing %s: %v\n", p, err)
+		u.failed = true
+		return
+	}
+	u.removed = append(u.removed, p)
+}
+
+// removeShortcut deletes the shortcut file or link p. A folder is never a
+// shortcut the launcher made, whatever its name, so it stays.
+func (u *uninstaller) removeShortcut(p string) {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		fmt.Printf("Leaving %s: it's a folder, not a shortcut\n", p)
+		return
+	}
+	if err := os.Remove(p); err != nil {
+		fmt.Printf("Error removing %s: %v\n", p, err)
+		u.failed = true
+		return
+	}
+	u.removed = append(u.removed, p)
+}
+
+// createdShortcuts are the shortcuts to remove: those shortcuts.json
+// records and, for launchers that predate it, links at the usual places
+// that point at this launcher.
+func createdShortcuts(config *Manifest) []string {
+	var record shortcutRecord
+	if data, err := ioutil.ReadFile(shortcutRecordPath(config)); err == nil {
+		json.Unmarshal(data, &record)
+	}
+	paths := record.Created
+	recorded := make(map[string]bool)
+	for _, p := range paths {
+		recorded[p] = true
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return paths
+	}
+	targets := []string{exe}
+	if app, ok := enclosingAppBundle(exe); ok {
+		targets = append(targets, app)
+	}
+	for _, p := range shortcutPaths(config) {
+		if !recorded[p] && linksTo(p, targets) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// linksTo reports whether p is a symlink to one of targets.
+func linksTo(p string, targets []string) bool {
+	dest, err := os.Readlink(p)
+	if err != nil {
+		return false
+	}
+	for _, t := range targets {
+		if filepath.Clean(dest) == filepath.Clean(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeSharedCache removes the cache that all demos on the machine
+// share, the browser locations, once no other demo's folder is left.
+func (u *uninstaller) removeSharedCache() {
+	dir := filepath.Dir(browserCachePath())
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.Name() != filepath.Base(browserCachePath()) {
+			return
+		}
+	}
+	u.remove(dir)
+}
+
+// uninstallConfigs returns config and, for a suite, each of its apps,
+// which keep their files under their own names.
+func uninstallConfigs(config *Manifest) []*Manifest {
+	configs := []*Manifest{config}
+	apps, _ := appEntries(config)
+	for _, app := range apps {
+		if selected, err := SelectApp(config, app.AppName); err == nil {
+			configs = append(configs, &selected)
+		}
+	}
+	return configs
+}
+
+// runningSession returns the name of a --session of the app that is
+// running, if any.
+func runningSession(config *Manifest) string {
+	entries, _ := os.ReadDir(filepath.Join(appCacheDir(config), "sessions"))
+	for _, e := range entries {
+		if _, running := sessionLockHolder(filepath.Join(appCacheDir(config), "sessions", e.Name())); running {
+			return e.Name()
+		}
+	}
+	return ""
+}
+
+// appSlug is the app's name as the launcher uses it in file names.
+func appSlug(config *Manifest) string {
+	return strings.ReplaceAll(strings.ToLower(config.AppName), " ", "_")
+}
+
+// shortcutPaths are where the app's desktop shortcut and Start Menu or
+// applications menu entry go.
+func shortcutPaths(config *Manifest) []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	desktop := filepath.Join(home, "Desktop")
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, config.AppName)
+
+	switch runtime.GOOS {
+	case "windows":
+		paths := []string{filepath.Join(desktop, name+".lnk")}
+		if appData, err := os.UserConfigDir(); err == nil {
+			paths = append(paths, filepath.Join(appData, "Microsoft", "Windows", "Start Menu", "Programs", name+".lnk"))
+		}
+		return paths
+	case "darwin":
+		return []string{filepath.Join(desktop, name)}
+	}
+	paths := []string{filepath.Join(desktop, appSlug(config)+".desktop")}
+	if data, err := userDataDir(); err == nil {
+		paths = append(paths, filepath.Join(data, "applications", appSlug(config)+".desktop"))
+	}
+	return paths
+}
+
+// registerUninstaller adds the app to Programs and Features on Windows
+// when the manifest asks for it with register_uninstaller, so IT can
+// remove the demo the usual way; the entry runs --uninstall. It's kept up
+// to date when the launcher moves. Failures only warn.
+func (l *Launcher) registerUninstaller() {
+	if !l.Config.RegisterUninstaller || !l.extractsBundle() {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	if err := addRegistryEntries(&l.Config, exe, l.shortcutIcon); err != nil {
+		fmt.Printf("Warning: can't add the demo to Programs and Features: %v\n", err)
+	}
+}
+
+// arpRegistryKey is the app's entry in Programs and Features on Windows.
+func arpRegistryKey(config *Manifest) string {
+	return `HKCU\Software\Microsoft\Windows\CurrentVersion\Uninstall\laravel_demo_` + appSlug(config)
+}
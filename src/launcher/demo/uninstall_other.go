@@ -0,0 +1,13 @@
+//go:build !windows
+
+package demo
+
+// removeRegistryEntries has nothing to do: there is no registry.
+func removeRegistryEntries(config *Manifest) []string {
+	return nil
+}
+
+// addRegistryEntries has nothing to do: there is no registry.
+func addRegistryEntries(config *Manifest, exe string, icon func() string) error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package demo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUninstallRemovesOnlyShortcuts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	t.Setenv("APPDATA", filepath.Join(home, "AppData"))
+	config := &Manifest{AppName: "Demo", UninstallShortcut: true}
+	paths := shortcutPaths(config)
+	if len(paths) < 2 {
+		t.Fatalf("shortcutPaths = %q", paths)
+	}
+	for _, p := range paths {
+		os.MkdirAll(filepath.Dir(p), 0755)
+	}
+
+	// The user's own folder where the first shortcut would go, a
+	// shortcut the launcher made at the second
+	userDir := paths[0]
+	os.Mkdir(userDir, 0755)
+	ioutil.WriteFile(filepath.Join(userDir, "notes.txt"), []byte("mine"), 0644)
+	ioutil.WriteFile(paths[1], []byte("[Desktop Entry]"), 0755)
+	record, _ := json.Marshal(shortcutRecord{Exe: "/demo", Created: paths})
+	os.MkdirAll(appCacheDir(config), 0755)
+	ioutil.WriteFile(shortcutRecordPath(config), record, 0644)
+
+	u := &uninstaller{}
+	for _, p := range createdShortcuts(config) {
+		u.removeShortcut(p)
+	}
+	if _, err := os.Stat(filepath.Join(userDir, "notes.txt")); err != nil {
+		t.Errorf("the user's %s was removed: %v", userDir, err)
+	}
+	if _, err := os.Lstat(paths[1]); !os.IsNotExist(err) {
+		t.Errorf("shortcut %s left: %v", paths[1], err)
+	}
+	if u.failed || len(u.removed) != 1 {
+		t.Errorf("removed %q, failed %v; want only the shortcut", u.removed, u.failed)
+	}
+}
+
+func TestUninstallFindsUnrecordedLinks(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	config := &Manifest{AppName: "Demo"}
+	paths := shortcutPaths(config)
+	exe, _ := os.Executable()
+	os.MkdirAll(filepath.Dir(paths[0]), 0755)
+	if err := os.Symlink(exe, paths[0]); err != nil {
+		t.Skipf("can't create a symlink: %v", err)
+	}
+	// A link to something else is the user's
+	os.MkdirAll(filepath.Dir(paths[1]), 0755)
+	os.Symlink(home, paths[1])
+
+	got := createdShortcuts(config)
+	if len(got) != 1 || got[0] != paths[0] {
+		t.Errorf("createdShortcuts = %q, want only the link to the launcher %s", got, paths[0])
+	}
+}
@@ -0,0 +1,59 @@
+package demo
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// removeRegistryEntries deletes the app's Programs and Features entry, if
+// there is one, and returns the keys it removed.
+func removeRegistryEntries(config *Manifest) []string {
+	key := arpRegistryKey(config)
+	if newCommand("reg", "query", key).Run() != nil {
+		return nil
+	}
+	if out, err := newCommand("reg", "delete", key, "/f").CombinedOutput(); err != nil {
+		fmt.Printf("Error removing %s: %v %s\n", key, err, strings.TrimSpace(string(out)))
+		return nil
+	}
+	return []string{key}
+}
+
+// addRegistryEntries writes the app's Programs and Features entry unless
+// it already points at exe. icon returns the .ico to show, if any.
+func addRegistryEntries(config *Manifest, exe string, icon func() string) error {
+	key := arpRegistryKey(config)
+	uninstall := `"` + exe + `" --uninstall`
+	if out, err := newCommand("reg", "query", key, "/v", "UninstallString").Output(); err == nil &&
+		strings.Contains(string(out), uninstall) {
+		return nil
+	}
+
+	displayIcon := exe
+	if ico := icon(); strings.EqualFold(filepath.Ext(ico), ".ico") {
+		displayIcon = ico
+	}
+	values := [][3]string{
+		{"DisplayName", "REG_SZ", config.AppName},
+		{"DisplayVersion", "REG_SZ", config.AppVersion},
+		{"Publisher", "REG_SZ", config.Publisher},
+		{"UninstallString", "REG_SZ", uninstall},
+		{"QuietUninstallString", "REG_SZ", uninstall},
+		{"InstallLocation", "REG_SZ", filepath.Dir(exe)},
+		{"DisplayIcon", "REG_SZ", displayIcon},
+		{"URLInfoAbout", "REG_SZ", config.SupportURL},
+		{"NoModify", "REG_DWORD", "1"},
+		{"NoRepair", "REG_DWORD", "1"},
+	}
+	for _, v := range values {
+		if v[2] == "" {
+			continue
+		}
+		out, err := newCommand("reg", "add", key, "/v", v[0], "/t", v[1], "/d", v[2], "/f").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v %s", v[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package demo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateManifest rejects configurations that would otherwise fail
+// silently at runtime.
+func ValidateManifest(config *Manifest) error {
+	var problems []string
+
+	if config.AppName == "" {
+		problems = append(problems, "app_name is required")
+	} else if reason := appNameProblem(config.AppName); reason != "" {
+		problems = append(problems, "app_name "+reason)
+	}
+	if len(config.Apps) == 0 && config.PublicRoot == "" {
+		problems = append(problems, "public_root is required, e.g. \"resources/app/public\"")
+	}
+
+	if config.PHPPort < 0 || config.PHPPort > 65535 {
+		problems = append(problems, fmt.Sprintf("php_port %d must be between 0 (any free port) and 65535", config.PHPPort))
+	}
+	if p := config.Deterministic.Port; p < 0 || p > 65535 {
+		problems = append(problems, fmt.Sprintf("deterministic.port %d must be between 0 and 65535", p))
+	}
+	for _, n := range []struct {
+		key   string
+		value int
+	}{
+		{"allowed_demo_duration_minutes", config.AllowedDemoDurationMinutes},
+		{"auto_reset_minutes", config.AutoResetMinutes},
+		{"idle_timeout_minutes", config.IdleTimeoutMinutes},
+		{"exit_page_grace_seconds", config.ExitPageGraceSeconds},
+		{"max_request_body_mb", config.MaxRequestBodyMB},
+		{"request_timeout_seconds", config.RequestTimeoutSeconds},
+		{"max_concurrent_requests", config.MaxConcurrentRequests},
+		{"startup_timeout_seconds", config.StartupTimeoutSeconds},
+		{"shutdown_grace_seconds", config.ShutdownGraceSeconds},
+		{"fpm_workers", config.FPMWorkers},
+		{"php_workers", config.PHPWorkers},
+		{"max_memory_mb", config.MaxMemoryMB},
+		{"cpu_grace_seconds", config.CPUGraceSeconds},
+		{"max_workdir_mb", config.MaxWorkDirMB},
+	} {
+		if n.value < 0 {
+			problems = append(problems, fmt.Sprintf("%s %d can't be negative", n.key, n.value))
+		}
+	}
+
+	if config.LandingPageURL != "" && !strings.HasPrefix(config.LandingPageURL, "/") {
+		problems = append(problems, fmt.Sprintf("landing_page_url %q must start with /", config.LandingPageURL))
+	}
+
+	if config.DemoModeEnvKey != "" {
+		if v, ok := config.DemoModeEnv[config.DemoModeEnvKey]; ok && config.DemoModeEnvValue != "" && v != config.DemoModeEnvValue {
+			problems = append(problems, fmt.Sprintf("%s is set to %q by demo_mode_env_value but %q by demo_mode_env", config.DemoModeEnvKey, config.DemoModeEnvValue, v))
+		}
+	}
+	demoEnv := demoModeEnv(config)
+	for _, k := range sortedKeys(demoEnv) {
+		if v, ok := config.EnvVars[k]; ok && v != demoEnv[k] {
+			problems = append(problems, fmt.Sprintf("%s is set to %q in env_vars but %q as a demo mode flag", k, v, demoEnv[k]))
+		}
+	}
+
+	for _, key := range sortedKeys(config.PHPBinaries) {
+		goos, goarch, _ := strings.Cut(key, "/")
+		switch {
+		case !packOSes[goos] || goarch != "" && goarch != "amd64" && goarch != "arm64":
+			problems = append(problems, fmt.Sprintf("php_binaries key %q must be linux, windows or darwin, optionally with /amd64 or /arm64", key))
+		case config.PHPBinaries[key] == "":
+			problems = append(problems, fmt.Sprintf("php_binaries has no path for %s", key))
+		}
+	}
+
+	for _, key := range sortedPHPDownloadKeys(config.PHPDownloads) {
+		d := config.PHPDownloads[key]
+		goos, goarch, _ := strings.Cut(key, "/")
+		switch {
+		case !packOSes[goos] || goarch != "" && goarch != "amd64" && goarch != "arm64":
+			problems = append(problems, fmt.Sprintf("php_downloads key %q must be linux, windows or darwin, optionally with /amd64 or /arm64", key))
+		case !strings.HasPrefix(d.URL, "https://") && !strings.HasPrefix(d.URL, "http://"):
+			problems = append(problems, fmt.Sprintf("php_downloads %s needs an http(s) url", key))
+		case len(d.SHA256) != 64 || strings.Trim(strings.ToLower(d.SHA256), "0123456789abcdef") != "":
+			problems = append(problems, fmt.Sprintf("php_downloads %s needs the sha256 of the download, 64 hex digits", key))
+		}
+	}
+
+	for i, argv := range config.SetupCommands {
+		if len(argv) == 0 {
+			problems = append(problems, fmt.Sprintf("setup_commands[%d] is empty", i))
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, side := range config.SideProcesses {
+		switch {
+		case side.Name == "":
+			problems = append(problems, fmt.Sprintf("side_processes[%d] has no name", i))
+		case names[side.Name]:
+			problems = append(problems, fmt.Sprintf("side process %q is defined twice", side.Name))
+		case len(side.Command) == 0:
+			problems = append(problems, fmt.Sprintf("side process %q has no command", side.Name))
+		case side.ReadinessPort < 0 || side.ReadinessPort > 65535:
+			problems = append(problems, fmt.Sprintf("side process %q: readiness_port %d must be between 0 and 65535", side.Name, side.ReadinessPort))
+		}
+		names[side.Name] = true
+	}
+	for _, prefix := range sortedKeys(config.ProxyRoutes) {
+		if !strings.HasPrefix(prefix, "/") {
+			problems = append(problems, fmt.Sprintf("proxy_routes prefix %q must start with /", prefix))
+		}
+		if name := config.ProxyRoutes[prefix]; !names[name] {
+			problems = append(problems, fmt.Sprintf("proxy_routes sends %s to unknown side process %q", prefix, name))
+		}
+	}
+
+	for i, rule := range config.ProxyRules {
+		if _, err := compileProxyRule(rule); err != nil {
+			problems = append(problems, fmt.Sprintf("proxy_rules[%d]: %v", i, err))
+		}
+	}
+
+	for _, path := range config.WarmupPaths {
+		if !strings.HasPrefix(path, "/") {
+			problems = append(problems, fmt.Sprintf("warmup_paths entry %q must start with /", path))
+		}
+	}
+
+	if config.Tour != nil && config.Tour.StepsPath == "" {
+		problems = append(problems, "tour needs steps_path")
+	}
+
+	if config.WindowWidth < 0 || config.WindowHeight < 0 {
+		problems = append(problems, fmt.Sprintf("window_width and window_height (%d x %d) can't be negative", config.WindowWidth, config.WindowHeight))
+	}
+
+	if z := config.Accessibility.Zoom; z != 0 && (z < 0.5 || z > 3) {
+		problems = append(problems, fmt.Sprintf("accessibility zoom %g must be between 0.5 and 3", z))
+	}
+
+	switch config.WatchdogAction {
+	case "", "warn", watchdogRestart:
+	default:
+		problems = append(problems, fmt.Sprintf("watchdog_action %q must be \"warn\" or \"restart\"", config.WatchdogAction))
+	}
+
+	switch config.PortFallback {
+	case "", portFallbackFail, portFallbackNext, portFallbackAny:
+	default:
+		problems = append(problems, fmt.Sprintf("port_fallback %q must be \"fail\", \"next\" or \"any\"", config.PortFallback))
+	}
+
+	switch config.AppKey {
+	case "", appKeyInstallation, appKeyRun, appKeyBundled:
+	default:
+		problems = append(problems, fmt.Sprintf("app_key %q must be \"installation\", \"run\" or \"bundled\"", config.AppKey))
+	}
+
+	switch config.IdleAction {
+	case "", idleActionShutdown, idleActionReset:
+	default:
+		problems = append(problems, fmt.Sprintf("idle_action %q must be \"shutdown\" or \"reset\"", config.IdleAction))
+	}
+
+	switch config.ServerMode {
+	case "", serverModeBuiltin, serverModeFPM:
+	default:
+		problems = append(problems, fmt.Sprintf("server_mode %q must be \"builtin\" or \"fpm\"", config.ServerMode))
+	}
+
+	switch config.OnExpiry {
+	case "", expiryTerminate, expiryReadonly, expiryNag:
+	default:
+		problems = append(problems, fmt.Sprintf("on_expiry %q must be \"terminate\", \"readonly\" or \"nag\"", config.OnExpiry))
+	}
+
+	switch config.QuotaAction {
+	case "", "block_uploads", quotaPrune:
+	default:
+		problems = append(problems, fmt.Sprintf("quota_action %q must be \"block_uploads\" or \"prune\"", config.QuotaAction))
+	}
+	if config.QuotaAction == quotaPrune && len(config.PrunablePaths) == 0 {
+		problems = append(problems, "quota_action \"prune\" needs prunable_paths")
+	}
+
+	for _, key := range sortedKeys(config.Messages) {
+		if !messages.has(key) {
+			problems = append(problems, fmt.Sprintf("messages has unknown key %q", key))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &manifestError{problems}
+	}
+	return nil
+}
+
+// appNameProblem says why name can't be an app_name, or returns "". The
+// name becomes a folder below laravel_demo in the data and cache dirs, so
+// it can't step out of them or hold what a file system refuses.
+func appNameProblem(name string) string {
+	switch {
+	case strings.ContainsAny(name, "/\\"):
+		return fmt.Sprintf("%q can't contain / or \\", name)
+	case strings.Contains(name, ".."):
+		return fmt.Sprintf("%q can't contain ..", name)
+	case strings.ContainsAny(name, `<>:"|?*`):
+		return fmt.Sprintf("%q can't contain any of < > : \" | ? *", name)
+	case strings.IndexFunc(name, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0:
+		return fmt.Sprintf("%q can't contain control characters", name)
+	case name != strings.TrimSpace(name) || strings.HasSuffix(name, "."):
+		// Windows drops them, so two apps would share a folder
+		return fmt.Sprintf("%q can't start or end with a space or end with a dot", name)
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package demo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateManifestAppName(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"Acme CRM":     true,
+		"Acme CRM 2.0": true,
+		"Ünïcödé Demo": true,
+		"../../victim": false,
+		"..":           false,
+		"acme/crm":     false,
+		`acme\crm`:     false,
+		"C:":           false,
+		"acme?":        false,
+		"acme\x00":     false,
+		"acme.":        false,
+		" acme":        false,
+	} {
+		err := ValidateManifest(&Manifest{AppName: name, PublicRoot: "public"})
+		if got := err == nil || !strings.Contains(err.Error(), "app_name"); got != ok {
+			t.Errorf("app_name %q: %v", name, err)
+		}
+	}
+}
+
+func TestSuiteAppNameChecked(t *testing.T) {
+	_, err := decodeManifest([]byte(`{"app_name": "Suite", "apps": [{"app_name": "../other", "dir": "a"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "app_name") {
+		t.Errorf("decodeManifest = %v, want app_name ../other refused", err)
+	}
+}
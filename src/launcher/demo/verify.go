@@ -0,0 +1,173 @@
+package demo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// loadChecksums reads the SHA-256 list the builder embedded with the bundle,
+// checks its signature and leaves out paths in the skip dirs. Keys are
+// slash-separated paths relative to the bundle root.
+func loadChecksums(fsys fs.FS, skip []string) (map[string]string, error) {
+	data, err := fs.ReadFile(fsys, path.Join(bundleRoot, checksumsFile))
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyBundleSignature(fsys, data); err != nil {
+		return nil, err
+	}
+	var sums map[string]string
+	if err := json.Unmarshal(data, &sums); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", checksumsFile, err)
+	}
+	for rel := range sums {
+		if skipped(rel, skip) {
+			delete(sums, rel)
+		}
+	}
+	return sums, nil
+}
+
+// integrityErrorsShown caps the files an integrityError lists.
+const integrityErrorsShown = 10
+
+// integrityError means the bundle doesn't match its checksums.json: the
+// executable was truncated or modified after it was built.
+type integrityError struct {
+	problems []string
+}
+
+func (e *integrityError) Error() string {
+	shown, more := e.problems, ""
+	if len(shown) > integrityErrorsShown {
+		more = fmt.Sprintf("\n  ... and %d more", len(shown)-integrityErrorsShown)
+		shown = shown[:integrityErrorsShown]
+	}
+	return fmt.Sprintf("the bundle failed its integrity check; the launcher is damaged or was modified after it was built:\n  %s%s",
+		strings.Join(shown, "\n  "), more)
+}
+
+// checkIntegrity compares the checksums of the files written by an
+// extraction with checksums.json, so a damaged bundle fails before PHP
+// runs instead of somewhere inside Laravel.
+func checkIntegrity(sums, written map[string]string) error {
+	var problems []string
+	for rel, sum := range written {
+		want, ok := sums[rel]
+		switch {
+		case !ok:
+			problems = append(problems, rel+": not in "+checksumsFile)
+		case !strings.EqualFold(sum, want):
+			problems = append(problems, rel+": checksum mismatch")
+		}
+	}
+	for rel := range sums {
+		if _, ok := written[rel]; !ok {
+			problems = append(problems, rel+": missing from the bundle")
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return &integrityError{problems}
+}
+
+// extractCategory is the error page category for a failed extraction.
+func extractCategory(err error) string {
+	var ie *integrityError
+	if errors.As(err, &ie) {
+		return errorCategoryIntegrity
+	}
+	return errorCategoryExtract
+}
+
+// verifyExtraction hashes every extracted file in parallel and returns the
+// sorted list of paths that are missing or don't match.
+func verifyExtraction(dest string, sums map[string]string) []string {
+	paths := make(chan string)
+	var mu sync.Mutex
+	var bad []string
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range paths {
+				// A checksum key that escapes dest counts as a mismatch
+				target, err := safeJoin(dest, rel)
+				if err != nil || !fileMatches(target, sums[rel]) {
+					mu.Lock()
+					bad = append(bad, rel)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for rel := range sums {
+		paths <- rel
+	}
+	close(paths)
+	wg.Wait()
+
+	sort.Strings(bad)
+	return bad
+}
+
+func fileMatches(file, want string) bool {
+	sum, err := fileSHA256(file)
+	return err == nil && strings.EqualFold(sum, want)
+}
+
+// fileSHA256 returns the hex SHA-256 of file, as checksums.json lists it.
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := copyStream(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyAndRepair checks the extracted bundle and, when files are wrong or
+// missing, extracts just those files once more before giving up.
+func verifyAndRepair(fsys fs.FS, dest string, skip []string) error {
+	sums, err := loadChecksums(fsys, skip)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(msg("verifying_files", len(sums)))
+	bad := verifyExtraction(dest, sums)
+	if len(bad) == 0 {
+		return nil
+	}
+
+	fmt.Printf("%d files failed verification, extracting them again...\n", len(bad))
+	reextract(fsys, dest, bad)
+
+	retry := make(map[string]string, len(bad))
+	for _, rel := range bad {
+		retry[rel] = sums[rel]
+	}
+	if bad = verifyExtraction(dest, retry); len(bad) > 0 {
+		return fmt.Errorf("extracted files are corrupt or missing:\n  %s", strings.Join(bad, "\n  "))
+	}
+	return nil
+}
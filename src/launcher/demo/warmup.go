@@ -0,0 +1,102 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// warmupBudget caps the whole warm-up so a slow page can't hold up
+	// the launch.
+	warmupBudget         = 10 * time.Second
+	warmupRequestTimeout = 5 * time.Second
+)
+
+// warmupArtisanCaches are run by warmup_artisan_caches, in this order.
+var warmupArtisanCaches = []string{"config:cache", "route:cache", "view:cache"}
+
+// warmup runs the artisan caches and requests warmup_paths from PHP so the
+// first click doesn't pay for compiling views. Failures are logged and
+// otherwise ignored: a cold demo is still a working one.
+func (l *Launcher) warmup(phpURL, phpBin string, env []string) {
+	if len(l.Config.WarmupPaths) == 0 && !l.Config.WarmupArtisanCaches {
+		return
+	}
+	l.banner.Step(msg("setup_warming_up"))
+	deadline := l.Clock.Now().Add(warmupBudget)
+
+	if l.Config.WarmupArtisanCaches {
+		for _, command := range warmupArtisanCaches {
+			left := deadline.Sub(l.Clock.Now())
+			if left <= 0 {
+				fmt.Println("Warm-up time is up, skipping the rest")
+				return
+			}
+			start := l.Clock.Now()
+			if err := l.runWithTimeout(left, env, phpBin, l.artisan, command); err != nil {
+				fmt.Printf("Warm-up artisan %s failed: %v\n", command, err)
+				continue
+			}
+			fmt.Printf("Warm-up artisan %s: done in %s\n", command, l.Clock.Now().Sub(start).Round(time.Millisecond))
+		}
+	}
+
+	for _, path := range l.Config.WarmupPaths {
+		left := deadline.Sub(l.Clock.Now())
+		if left <= 0 {
+			fmt.Println("Warm-up time is up, skipping the rest")
+			return
+		}
+		if left > warmupRequestTimeout {
+			left = warmupRequestTimeout
+		}
+		start := l.Clock.Now()
+		status, err := warmupGet(l.phpClient(), phpURL+path, left)
+		if err != nil {
+			fmt.Printf("Warm-up GET %s failed: %v\n", path, err)
+			continue
+		}
+		fmt.Printf("Warm-up GET %s: %d in %s\n", path, status, l.Clock.Now().Sub(start).Round(time.Millisecond))
+	}
+}
+
+func warmupGet(client *http.Client, target string, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// runWithTimeout runs a command in app_root and kills it after timeout.
+func (l *Launcher) runWithTimeout(timeout time.Duration, env []string, name string, arg ...string) error {
+	cmd := l.Command(name, arg...)
+	cmd.Env = env
+	cmd.Dir = l.appRoot
+	w := processOutput("warmup")
+	cmd.Stdout, cmd.Stderr = w, w
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	tree := newProcessTree(cmd.Process)
+	defer tree.Close()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-l.Clock.After(timeout):
+		tree.Kill()
+		<-done
+		return fmt.Errorf("killed after %s", timeout)
+	}
+}
@@ -0,0 +1,165 @@
+package demo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	watchdogInterval   = 5 * time.Second
+	watchdogCPUPercent = 90
+	watchdogRestart    = "restart"
+)
+
+// procSample is a process's resident memory and its CPU time so far.
+type procSample struct {
+	RSS uint64
+	CPU time.Duration
+}
+
+// watchedProcess is a managed child the watchdog can sample and recycle.
+type watchedProcess interface {
+	Pid() int
+	Restart() error
+}
+
+// watchTarget is the watchdog's view of one process.
+type watchTarget struct {
+	name string
+	proc watchedProcess
+
+	pid      int
+	last     procSample
+	lastAt   time.Time
+	cpu      float64   // percent of one core over the last interval
+	busy     time.Time // start of the current stretch above the CPU limit
+	overMem  bool
+	warnings int
+	restarts int
+}
+
+// watchdog samples the managed processes and warns or restarts them when
+// they stay above max_memory_mb or keep a core busy for cpu_grace_seconds.
+type watchdog struct {
+	maxRSS   uint64
+	cpuGrace time.Duration
+	restart  bool
+	clock    Clock
+
+	mu      sync.Mutex
+	targets []*watchTarget
+}
+
+func newWatchdog(config *Manifest, clock Clock) *watchdog {
+	return &watchdog{
+		maxRSS:   uint64(config.MaxMemoryMB) << 20,
+		cpuGrace: time.Duration(config.CPUGraceSeconds) * time.Second,
+		restart:  config.WatchdogAction == watchdogRestart,
+		clock:    clock,
+	}
+}
+
+func (w *watchdog) Watch(name string, proc watchedProcess) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.targets = append(w.targets, &watchTarget{name: name, proc: proc})
+}
+
+// Run samples every watchdogInterval until stop is closed.
+func (w *watchdog) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-w.clock.After(watchdogInterval):
+			w.check()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (w *watchdog) check() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock.Now()
+	for _, t := range w.targets {
+		pid := t.proc.Pid()
+		if pid == 0 {
+			continue
+		}
+		sample, err := sampleProcess(pid)
+		if err != nil {
+			// Exited between Pid and the sample; the next round sees the new one
+			continue
+		}
+		if pid != t.pid {
+			// A new process; CPU time starts over
+			*t = watchTarget{name: t.name, proc: t.proc, pid: pid, warnings: t.warnings, restarts: t.restarts}
+		} else if elapsed := now.Sub(t.lastAt); elapsed > 0 {
+			t.cpu = float64(sample.CPU-t.last.CPU) / float64(elapsed) * 100
+		}
+		t.last, t.lastAt = sample, now
+
+		var problem string
+		if w.maxRSS > 0 && sample.RSS > w.maxRSS {
+			if !t.overMem {
+				problem = fmt.Sprintf("uses %d MB of memory (limit %d MB)", sample.RSS>>20, w.maxRSS>>20)
+			}
+			t.overMem = true
+		} else {
+			t.overMem = false
+		}
+
+		if w.cpuGrace > 0 && t.cpu > watchdogCPUPercent {
+			if t.busy.IsZero() {
+				t.busy = now
+			} else if now.Sub(t.busy) >= w.cpuGrace {
+				problem = fmt.Sprintf("has kept a CPU core %.0f%% busy for %s", t.cpu, now.Sub(t.busy).Round(time.Second))
+				t.busy = now
+			}
+		} else {
+			t.busy = time.Time{}
+		}
+
+		if problem == "" {
+			continue
+		}
+		t.warnings++
+		fmt.Printf("Warning: %s (pid %d) %s\n", t.name, pid, problem)
+		if w.restart {
+			fmt.Printf("Restarting %s...\n", t.name)
+			if err := t.proc.Restart(); err != nil {
+				fmt.Printf("Error restarting %s: %v\n", t.name, err)
+			}
+			t.restarts++
+		}
+	}
+}
+
+// Close waits for a check in progress and stops watching, so a restart
+// can't bring a process back after shutdown stopped it.
+func (w *watchdog) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.targets = nil
+}
+
+// Stats reports the latest sample of every process for GET /status.
+func (w *watchdog) Stats() []map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := make([]map[string]interface{}, len(w.targets))
+	for i, t := range w.targets {
+		stats[i] = map[string]interface{}{
+			"name":        t.name,
+			"pid":         t.pid,
+			"rss_mb":      t.last.RSS >> 20,
+			"cpu_percent": int(t.cpu),
+			"warnings":    t.warnings,
+			"restarts":    t.restarts,
+		}
+	}
+	return stats
+}
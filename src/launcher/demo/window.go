@@ -0,0 +1,194 @@
+package demo
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// appWindowNoted is set once the fallback to a browser tab was explained.
+var appWindowNoted bool
+
+// appWindowBrowser picks the browser for an app window: browser, the one
+// given with --browser, if it's Chrome or Edge, else whichever of the two
+// is installed, Chrome first. It returns "" when there's none to use.
+func appWindowBrowser(browser string) string {
+	switch browser {
+	case "chrome", "edge":
+		return browser
+	case "default":
+		installed := installedBrowsers()
+		for _, b := range []string{"chrome", "edge"} {
+			if installed[b] != "" {
+				return b
+			}
+		}
+	}
+	return ""
+}
+
+// appWindowArgs returns the switches that size the window as the manifest
+// asks: maximized, window_width x window_height, or the browser's choice.
+func appWindowArgs(config *Manifest) []string {
+	switch {
+	case config.StartMaximized:
+		return []string{"--start-maximized"}
+	case config.WindowWidth > 0 && config.WindowHeight > 0:
+		return []string{fmt.Sprintf("--window-size=%d,%d", config.WindowWidth, config.WindowHeight)}
+	}
+	return nil
+}
+
+// appWindowDir is the browser profile of the app window when the session
+// or deterministic mode don't bring their own. A running browser ignores
+// the window size, so the window always gets a profile of its own.
+func appWindowDir(config *Manifest) string {
+	return filepath.Join(appCacheDir(config), "window")
+}
+
+// openAppWindow opens url in a Chrome or Edge app window: no tabs, address
+// bar or bookmarks, and the size from the manifest. The title comes from
+// the page. It returns handled false when neither browser is available, so
+// the caller falls back to a normal browser tab.
+func (l *Launcher) openAppWindow(url, profileDir string, extra []string) (handled bool, err error) {
+	browser := appWindowBrowser(l.Options.Browser)
+	if browser == "" {
+		if !appWindowNoted {
+			fmt.Println("The demo can only open in its own window with Chrome or Edge; using a browser tab instead.")
+			appWindowNoted = true
+		}
+		return false, nil
+	}
+	p := installedBrowsers()[browser]
+	if p == "" {
+		return true, fmt.Errorf("%s is not installed (see doctor)", browser)
+	}
+	if profileDir == "" {
+		profileDir = appWindowDir(&l.Config)
+	}
+	os.MkdirAll(profileDir, 0755)
+
+	args := browserProfileArgs(browser, profileDir)
+	args = append(args, appWindowArgs(&l.Config)...)
+	args = append(args, extra...)
+	return true, launchBrowser(browserLaunch(p), args, "--app="+url)
+}
+
+//go:embed pages/window.js
+var windowScript []byte
+
+const (
+	windowScriptPath = "/__launcher/window.js"
+	windowWatchPath  = "/__launcher/window"
+	// windowCloseGrace is how long no page may be open before the window
+	// counts as closed. Going from one page to the next takes far less.
+	windowCloseGrace = 10 * time.Second
+)
+
+var windowTag = []byte(`<script src="` + windowScriptPath + `" defer></script>`)
+
+// windowWatch notices the app window being closed. Each page in it holds
+// an EventSource on windowWatchPath; when the last one has been gone for
+// windowCloseGrace, closed is closed. Until the first page connects
+// nothing counts, so a browser without JavaScript never ends the demo.
+type windowWatch struct {
+	hold func() bool // true while a closed window shouldn't end the demo
+
+	mu       sync.Mutex
+	open     int
+	timer    *time.Timer
+	closed   chan struct{}
+	once     sync.Once
+	exit     chan struct{} // closed by sendExit
+	exitOnce sync.Once
+}
+
+func newWindowWatch(hold func() bool) *windowWatch {
+	return &windowWatch{hold: hold, closed: make(chan struct{}), exit: make(chan struct{})}
+}
+
+// serve answers the window script and holds its connections. A nil watch
+// serves nothing.
+func (ww *windowWatch) serve(w http.ResponseWriter, r *http.Request) bool {
+	if ww == nil {
+		return false
+	}
+	switch r.URL.Path {
+	case windowScriptPath:
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(windowScript)
+		return true
+	case windowWatchPath:
+	default:
+		return false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		return true
+	}
+	ww.opened()
+	defer ww.left()
+	select {
+	case <-r.Context().Done():
+	case <-ww.exit:
+		// window.js waits for the exit page to be served, then goes there
+		fmt.Fprint(w, "event: exit\ndata: "+exitPagePath+"\n\n")
+		http.NewResponseController(w).Flush()
+	}
+	return true
+}
+
+// sendExit tells every page in the window that the demo ends, and reports
+// whether there was one, so the exit page is worth serving on the demo's
+// address.
+func (ww *windowWatch) sendExit() bool {
+	ww.mu.Lock()
+	open := ww.open > 0
+	ww.mu.Unlock()
+	ww.exitOnce.Do(func() { close(ww.exit) })
+	return open
+}
+
+func (ww *windowWatch) opened() {
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+	ww.open++
+	if ww.timer != nil {
+		ww.timer.Stop()
+		ww.timer = nil
+	}
+}
+
+func (ww *windowWatch) left() {
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+	ww.open--
+	if ww.open == 0 {
+		ww.timer = time.AfterFunc(windowCloseGrace, ww.check)
+	}
+}
+
+func (ww *windowWatch) check() {
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+	if ww.open == 0 && !ww.hold() {
+		ww.once.Do(func() { close(ww.closed) })
+	}
+}
+
+// closesWithWindow tells whether the demo ends when its app window is
+// closed: with app_window, unless close_with_window is false, and when
+// there is a browser to open the window with.
+func (l *Launcher) closesWithWindow() bool {
+	if !l.Config.AppWindow || l.Config.CloseWithWindow != nil && !*l.Config.CloseWithWindow {
+		return false
+	}
+	return l.Options.Browser != BrowserNone && appWindowBrowser(l.Options.Browser) != ""
+}
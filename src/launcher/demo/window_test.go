@@ -0,0 +1,55 @@
+package demo
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWindowWatchSendsExit(t *testing.T) {
+	ww := newWindowWatch(func() bool { return false })
+	if ww.sendExit() {
+		t.Fatal("sendExit reported a window before any page connected")
+	}
+
+	ww = newWindowWatch(func() bool { return false })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww.serve(w, r)
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + windowWatchPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ww.mu.Lock()
+		open := ww.open
+		ww.mu.Unlock()
+		if open > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the page never counted as open")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !ww.sendExit() {
+		t.Error("sendExit reported no window with a page open")
+	}
+
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "event: exit" {
+		t.Fatalf("first line %q, %v; want the exit event", line, err)
+	}
+	line, _ = r.ReadString('\n')
+	if strings.TrimSpace(line) != "data: "+exitPagePath {
+		t.Errorf("data line %q, want the exit page's path", line)
+	}
+}
@@ -0,0 +1,398 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The Windows launcher carries the product icon, version strings and an
+// application manifest as resources, which the Go linker takes from a COFF
+// object (.syso) in the package directory. Without them Explorer shows a
+// generic icon and blank file details, and SmartScreen and UAC treat the
+// exe with more suspicion.
+
+// Resource types.
+const (
+	rtIcon      = 3
+	rtGroupIcon = 14
+	rtVersion   = 16
+	rtManifest  = 24
+)
+
+// resourceLanguage is US English, which Explorer falls back to for any
+// display language.
+const resourceLanguage = 0x0409
+
+// winresFile is the object for one architecture; the suffix limits it to
+// Windows builds of that architecture.
+func winresFile(arch string) string {
+	return "winres_windows_" + arch + ".syso"
+}
+
+// winresMachines are the COFF machine and the relocation type of an
+// image-relative address for each architecture.
+var winresMachines = map[string]struct{ machine, reloc uint16 }{
+	"amd64": {0x8664, 3}, // IMAGE_REL_AMD64_ADDR32NB
+	"arm64": {0xaa64, 2}, // IMAGE_REL_ARM64_ADDR32NB
+}
+
+// winResource is one resource, in the language resourceLanguage.
+type winResource struct {
+	typ, id uint16
+	data    []byte
+}
+
+// appManifest runs the launcher with the user's rights, so Windows never
+// asks to elevate it, declares the Windows versions it was tested with and
+// gives message boxes the current look.
+const appManifest = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0">
+  <trustInfo xmlns="urn:schemas-microsoft-com:asm.v3">
+    <security>
+      <requestedPrivileges>
+        <requestedExecutionLevel level="asInvoker" uiAccess="false"/>
+      </requestedPrivileges>
+    </security>
+  </trustInfo>
+  <compatibility xmlns="urn:schemas-microsoft-com:compatibility.v1">
+    <application>
+      <supportedOS Id="{35138b9a-5d96-4fbd-8e2d-a2440225f93a}"/>
+      <supportedOS Id="{4a2f28e3-53b9-4441-ba9c-d69d4a4a6e38}"/>
+      <supportedOS Id="{1f676c76-80e1-4239-95bb-83d0f6d0da78}"/>
+      <supportedOS Id="{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}"/>
+    </application>
+  </compatibility>
+  <dependency>
+    <dependentAssembly>
+      <assemblyIdentity type="win32" name="Microsoft.Windows.Common-Controls" version="6.0.0.0" processorArchitecture="*" publicKeyToken="6595b64144ccf1df" language="*"/>
+    </dependentAssembly>
+  </dependency>
+</assembly>
+`
+
+// writeWinres writes the resource object for a launcher named exe: the
+// icon_path from the staged bundle, the version details from config and
+// appManifest. An icon that can't be used is only a warning, as at runtime.
+func writeWinres(config *Manifest, bundleDir, exe, arch, out string) error {
+	machine, ok := winresMachines[arch]
+	if !ok {
+		return fmt.Errorf("no Windows resources for %s", arch)
+	}
+	var res []winResource
+	if config.IconPath != "" {
+		icons, err := iconResources(filepath.Join(bundleDir, filepath.FromSlash(config.IconPath)))
+		if err != nil {
+			fmt.Printf("Warning: icon_path %s can't be the exe's icon: %v\n", config.IconPath, err)
+		}
+		res = append(res, icons...)
+	}
+	res = append(res,
+		winResource{rtVersion, 1, versionResource(config, exe)},
+		winResource{rtManifest, 1, []byte(appManifest)},
+	)
+	return os.WriteFile(out, coffResources(machine.machine, machine.reloc, res), 0644)
+}
+
+// iconResources turns an .ico file into its images and the group that
+// lists them. A PNG of up to 256x256 pixels becomes a group of one, as
+// Windows reads PNG images in icons.
+func iconResources(file string) ([]winResource, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	type image struct {
+		width, height, colors byte
+		planes, bits          uint16
+		data                  []byte
+	}
+	var images []image
+	if strings.EqualFold(filepath.Ext(file), ".png") {
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Width > 256 || cfg.Height > 256 {
+			return nil, fmt.Errorf("it's %dx%d; use an .ico or a PNG of at most 256x256", cfg.Width, cfg.Height)
+		}
+		// 0 stands for 256
+		images = append(images, image{byte(cfg.Width), byte(cfg.Height), 0, 1, 32, data})
+	} else {
+		if len(data) < 6 || binary.LittleEndian.Uint16(data[2:]) != 1 {
+			return nil, errors.New("not an .ico or PNG file")
+		}
+		count := int(binary.LittleEndian.Uint16(data[4:]))
+		for i := 0; i < count; i++ {
+			e := 6 + 16*i
+			if len(data) < e+16 {
+				return nil, errors.New("the .ico file is cut off")
+			}
+			size := binary.LittleEndian.Uint32(data[e+8:])
+			offset := binary.LittleEndian.Uint32(data[e+12:])
+			if uint64(offset)+uint64(size) > uint64(len(data)) {
+				return nil, errors.New("the .ico file is cut off")
+			}
+			images = append(images, image{data[e], data[e+1], data[e+2],
+				binary.LittleEndian.Uint16(data[e+4:]), binary.LittleEndian.Uint16(data[e+6:]),
+				data[offset : offset+size]})
+		}
+		if len(images) == 0 {
+			return nil, errors.New("the .ico file holds no images")
+		}
+	}
+
+	// GRPICONDIR, its entries naming the RT_ICON resources
+	group := []byte{0, 0, 1, 0}
+	group = binary.LittleEndian.AppendUint16(group, uint16(len(images)))
+	var res []winResource
+	for i, img := range images {
+		id := uint16(i + 1)
+		group = append(group, img.width, img.height, img.colors, 0)
+		group = binary.LittleEndian.AppendUint16(group, img.planes)
+		group = binary.LittleEndian.AppendUint16(group, img.bits)
+		group = binary.LittleEndian.AppendUint32(group, uint32(len(img.data)))
+		group = binary.LittleEndian.AppendUint16(group, id)
+		res = append(res, winResource{rtIcon, id, img.data})
+	}
+	return append(res, winResource{rtGroupIcon, 1, group}), nil
+}
+
+// versionResource is VS_VERSIONINFO with app_name as product and file
+// description, app_version as both versions and publisher as company.
+func versionResource(config *Manifest, exe string) []byte {
+	version := fileVersion(config.AppVersion)
+	fixed := make([]byte, 0, 52)
+	for _, v := range []uint32{
+		0xfeef04bd, 0x00010000, // signature, structure version
+		uint32(version[0])<<16 | uint32(version[1]), uint32(version[2])<<16 | uint32(version[3]), // file
+		uint32(version[0])<<16 | uint32(version[1]), uint32(version[2])<<16 | uint32(version[3]), // product
+		0x3f, 0, // flags mask, flags
+		0x40004, 1, 0, // VOS_NT_WINDOWS32, VFT_APP, no subtype
+		0, 0, // date
+	} {
+		fixed = binary.LittleEndian.AppendUint32(fixed, v)
+	}
+
+	var strs [][]byte
+	for _, s := range [][2]string{
+		{"CompanyName", config.Publisher},
+		{"FileDescription", config.AppName},
+		{"FileVersion", config.AppVersion},
+		{"InternalName", strings.TrimSuffix(exe, filepath.Ext(exe))},
+		{"OriginalFilename", exe},
+		{"ProductName", config.AppName},
+		{"ProductVersion", config.AppVersion},
+	} {
+		if s[1] != "" {
+			value := utf16z(s[1])
+			strs = append(strs, versionBlock(s[0], 1, value, uint16(len(value)/2)))
+		}
+	}
+	// US English in Unicode, matching the string table's name
+	translation := []byte{0x09, 0x04, 0xb0, 0x04}
+	return versionBlock("VS_VERSION_INFO", 0, fixed, uint16(len(fixed)),
+		versionBlock("StringFileInfo", 1, nil, 0, versionBlock("040904B0", 1, nil, 0, strs...)),
+		versionBlock("VarFileInfo", 1, nil, 0, versionBlock("Translation", 0, translation, uint16(len(translation)))),
+	)
+}
+
+// versionBlock is one node of VS_VERSIONINFO: its length, the value's
+// length (in characters for text), the type (1 for text), the key, the
+// value and the children, each starting on a 32-bit boundary.
+func versionBlock(key string, typ uint16, value []byte, valueLength uint16, children ...[]byte) []byte {
+	b := []byte{0, 0}
+	b = binary.LittleEndian.AppendUint16(b, valueLength)
+	b = binary.LittleEndian.AppendUint16(b, typ)
+	b = append(b, utf16z(key)...)
+	b = pad4(b)
+	b = append(b, value...)
+	for _, c := range children {
+		b = append(pad4(b), c...)
+	}
+	binary.LittleEndian.PutUint16(b, uint16(len(b)))
+	return b
+}
+
+// fileVersion reads the numeric part of app_version, e.g. 2.1.0 out of
+// v2.1.0-beta, into the four numbers Windows versions have.
+func fileVersion(v string) [4]uint16 {
+	var version [4]uint16
+	parts := strings.Split(strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V"), ".")
+	for i := 0; i < len(parts) && i < len(version); i++ {
+		digits := strings.IndexFunc(parts[i], func(r rune) bool { return r < '0' || r > '9' })
+		if digits < 0 {
+			digits = len(parts[i])
+		}
+		n, err := strconv.ParseUint(parts[i][:digits], 10, 16)
+		if err != nil {
+			break
+		}
+		version[i] = uint16(n)
+		if digits < len(parts[i]) {
+			break // a suffix such as -beta ends the version
+		}
+	}
+	return version
+}
+
+// coffResources lays res out as a resource directory (type, ID, language)
+// in the .rsrc section of a COFF object. The data entries hold addresses
+// relative to the image, so each gets a relocation against the section.
+func coffResources(machine, relocType uint16, res []winResource) []byte {
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].typ != res[j].typ {
+			return res[i].typ < res[j].typ
+		}
+		return res[i].id < res[j].id
+	})
+	var types []uint16
+	for i, r := range res {
+		if i == 0 || r.typ != res[i-1].typ {
+			types = append(types, r.typ)
+		}
+	}
+	dirSize := func(entries int) uint32 { return 16 + 8*uint32(entries) }
+
+	// Directories first, then the data entries, then the data
+	off := dirSize(len(types))
+	idDirs := make(map[uint16]uint32)
+	for _, t := range types {
+		idDirs[t] = off
+		n := 0
+		for _, r := range res {
+			if r.typ == t {
+				n++
+			}
+		}
+		off += dirSize(n)
+	}
+	langDirs, entries, data := make([]uint32, len(res)), make([]uint32, len(res)), make([]uint32, len(res))
+	for i := range res {
+		langDirs[i] = off
+		off += dirSize(1)
+	}
+	for i := range res {
+		entries[i] = off
+		off += 16
+	}
+	for i, r := range res {
+		off = (off + 7) &^ 7
+		data[i] = off
+		off += uint32(len(r.data))
+	}
+	section := make([]byte, (off+7)&^7)
+
+	le := binary.LittleEndian
+	dir := func(at uint32, ids []uint32, targets []uint32) {
+		le.PutUint16(section[at+14:], uint16(len(ids)))
+		for i := range ids {
+			le.PutUint32(section[at+16+8*uint32(i):], ids[i])
+			le.PutUint32(section[at+20+8*uint32(i):], targets[i])
+		}
+	}
+	const subdir = 0x80000000
+	var typeIDs, typeTargets []uint32
+	for _, t := range types {
+		typeIDs = append(typeIDs, uint32(t))
+		typeTargets = append(typeTargets, idDirs[t]|subdir)
+		var ids, targets []uint32
+		for i, r := range res {
+			if r.typ == t {
+				ids = append(ids, uint32(r.id))
+				targets = append(targets, langDirs[i]|subdir)
+			}
+		}
+		dir(idDirs[t], ids, targets)
+	}
+	dir(0, typeIDs, typeTargets)
+	var relocs []byte
+	for i, r := range res {
+		dir(langDirs[i], []uint32{resourceLanguage}, []uint32{entries[i]})
+		le.PutUint32(section[entries[i]:], data[i])
+		le.PutUint32(section[entries[i]+4:], uint32(len(r.data)))
+		copy(section[data[i]:], r.data)
+
+		relocs = le.AppendUint32(relocs, entries[i])
+		relocs = le.AppendUint32(relocs, 0) // the section's symbol
+		relocs = le.AppendUint16(relocs, relocType)
+	}
+
+	const headers = 20 + 40
+	var b []byte
+	// File header
+	b = le.AppendUint16(b, machine)
+	b = le.AppendUint16(b, 1)                                        // sections
+	b = le.AppendUint32(b, 0)                                        // timestamp, for reproducible builds
+	b = le.AppendUint32(b, uint32(headers+len(section)+len(relocs))) // symbol table
+	b = le.AppendUint32(b, 1)                                        // symbols
+	b = le.AppendUint32(b, 0)                                        // no optional header, no flags
+	// Section header
+	b = append(b, ".rsrc\x00\x00\x00"...)
+	b = le.AppendUint32(b, 0) // virtual size
+	b = le.AppendUint32(b, 0) // virtual address
+	b = le.AppendUint32(b, uint32(len(section)))
+	b = le.AppendUint32(b, headers)
+	b = le.AppendUint32(b, uint32(headers+len(section)))
+	b = le.AppendUint32(b, 0) // line numbers
+	b = le.AppendUint16(b, uint16(len(res)))
+	b = le.AppendUint16(b, 0)
+	b = le.AppendUint32(b, 0x40000040) // initialized data, readable
+	b = append(b, section...)
+	b = append(b, relocs...)
+	// The section symbol, then an empty string table
+	b = append(b, ".rsrc\x00\x00\x00"...)
+	b = le.AppendUint32(b, 0) // value
+	b = le.AppendUint16(b, 1) // section number
+	b = le.AppendUint16(b, 0) // type
+	b = append(b, 3, 0)       // IMAGE_SYM_CLASS_STATIC, no aux symbols
+	return le.AppendUint32(b, 4)
+}
+
+func utf16z(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s + "\x00")) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// RunWinres implements "winres", which build.py runs while the bundle is
+// staged to give the Windows launcher its icon, version details and
+// manifest.
+func RunWinres(args []string) int {
+	flags := flag.NewFlagSet("winres", flag.ContinueOnError)
+	manifest := flags.String("manifest", "manifest.json", "Manifest with app_name, app_version, publisher and icon_path")
+	dir := flags.String("dir", "bundle", "Staged bundle directory icon_path is relative to")
+	exe := flags.String("exe", "laravel_demo.exe", "File name of the launcher being built")
+	arch := flags.String("arch", "amd64", "Architecture of the launcher being built: amd64 or arm64")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	config, _, err := loadPackManifest(*manifest)
+	if err == nil {
+		err = writeWinres(config, *dir, *exe, *arch, winresFile(*arch))
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s\n", winresFile(*arch))
+	return 0
+}
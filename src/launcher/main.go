@@ -1,226 +1,266 @@
+// Command launcher runs the demo bundled into it. The demo package does
+// the work; main parses the command line, reads the manifest and hands
+// both to a demo.Launcher.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"runtime"
 	"syscall"
-	"time"
-)
 
-// Manifest matches the structure of manifest.json
-type Manifest struct {
-	AppName                    string            `json:"app_name"`
-	AppVersion                 string            `json:"app_version"`
-	WindowWidth                int               `json:"window_width"`
-	WindowHeight               int               `json:"window_height"`
-	StartMaximized             bool              `json:"start_maximized"`
-	PHPPort                    int               `json:"php_port"`
-	DBType                     string            `json:"db_type"`
-	DBPath                     string            `json:"db_path"`
-	EnvVars                    map[string]string `json:"env_vars"`
-	DemoModeEnvKey             string            `json:"demo_mode_env_key"`
-	SplashScreenImage          string            `json:"splash_screen_image"`
-	IconPath                   string            `json:"icon_path"`
-	LandingPageURL             string            `json:"landing_page_url"`
-	PHPBinaryPath              string            `json:"php_binary_path"`
-	PublicRoot                 string            `json:"public_root"`
-	ScrambleCode               bool              `json:"scramble_code"`
-	ScramblePluginPath         string            `json:"scramble_plugin_path"`
-	CleanOnExit                bool              `json:"clean_on_exit"`
-	UninstallShortcut          bool              `json:"uninstall_shortcut"`
-	AllowedDemoDurationMinutes int               `json:"allowed_demo_duration_minutes"`
-}
+	"./demo"
+)
 
 var (
 	uninstallFlag = flag.Bool("uninstall", false, "Clean up all demo files and exit")
+	workDirFlag   = flag.String("work-dir", "", "Extract the demo to this directory instead of a temp dir")
+	noVerifyFlag  = flag.Bool("no-verify", false, "Skip verifying extracted files against their checksums")
+	checkFlag     = flag.Bool("check", false, "Extract and verify the bundle, then exit")
+	appFlag       = flag.String("app", "", "Name of the bundled app to start when the manifest lists several")
+	profileFlag   = flag.String("profile", "", "Start with the named profile from the manifest's profiles, e.g. kiosk")
+	exportFlag    = flag.String("export-data", "", "On exit, save the demo's database and storage/app to this file")
+	importFlag    = flag.String("import-data", "", "Restore demo data saved with --export-data before starting")
+	browserFlag   = flag.String("browser", "default", "Browser to open: none, default, chrome, edge or firefox")
+	offlineFlag   = flag.Bool("offline", false, "Disable every outbound network call (same as \"offline\": true)")
+	acceptEULA    = flag.Bool("accept-eula", false, "Accept the evaluation agreement without showing it, e.g. for --check in CI")
+	sessionFlag   = flag.String("session", "", "Run a named session with its own data, port and browser profile")
+	zoomFlag      = flag.Float64("zoom", 0, "Zoom the demo by this factor, e.g. 1.25 (Chrome and Edge)")
+	highContrast  = flag.Bool("high-contrast", false, "Show the demo in high contrast (Chrome and Edge) and tell the app")
+	reducedMotion = flag.Bool("reduced-motion", false, "Ask the browser and the app to turn off animations")
+	deterministic = flag.Bool("deterministic", false, "Same port, time, random seed and fresh data on every run, for recording videos")
+	serveDirFlag  = flag.String("serve-dir", "", "Dev mode: serve this Laravel checkout in place instead of the embedded bundle")
+	verboseFlag   = flag.Bool("verbose", false, "Print timing details, e.g. how long extraction took")
+	consoleFlag   = flag.Bool("console", false, "Windows: show a console window with the launcher's output")
+	validateFlag  = flag.Bool("validate-manifest", false, "Check manifest.json, list every problem with its JSON path and exit")
+	logLevelFlag  = flag.String("log-level", "info", "Lowest level written to launcher.log: debug, info, warn or error")
+	logFormatFlag = flag.String("log-format", "text", "Format of launcher.log: text or json, one object per line")
+	quietFlag     = flag.Bool("quiet", false, "Print only errors to the console, e.g. on kiosks; launcher.log still gets everything")
+)
+
+// Flags that override manifest values for one run, so QA can try another
+// port, page or duration without rebuilding the launcher.
+var (
+	portFlag       = flag.Int("port", 0, "Serve the demo on this port instead of php_port")
+	landingFlag    = flag.String("landing-page", "", "Open this path instead of landing_page_url, e.g. /reports")
+	durationFlag   = flag.Int("duration", -1, "Demo duration in minutes instead of allowed_demo_duration_minutes; 0 for no limit")
+	windowSizeFlag = flag.String("window-size", "", "App window size as WIDTHxHEIGHT, or maximized, instead of the manifest's")
+	noBrowserFlag  = flag.Bool("no-browser", false, "Don't open a browser; same as --browser none")
 )
 
 func main() {
+	defer demo.RecoverCrash("main")
+	demo.BundleFS = bundleFS
+	demo.BundlePublicKey, demo.PayloadKey = bundlePublicKey, payloadKey
+	demo.AttachConsole()
 	flag.Parse()
-
-	// 1. Read Configuration
-	manifestPath := "manifest.json"
-	// In a real scenario, this might be embedded or next to the executable
-	exePath, err := os.Executable()
-	if err == nil {
-		manifestPath = filepath.Join(filepath.Dir(exePath), "manifest.json")
+	if *consoleFlag {
+		demo.OpenConsole()
+	}
+	if !demo.KnownBrowser(*browserFlag) {
+		fmt.Printf("Error: unknown --browser %q (use none, default, chrome, edge or firefox)\n", *browserFlag)
+		os.Exit(2)
+	}
+	logLevel, err := demo.ParseLogLevel(*logLevelFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	logJSON, err := demo.ParseLogFormat(*logFormatFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	if *quietFlag && *verboseFlag {
+		fmt.Println("Error: --quiet and --verbose contradict each other")
+		os.Exit(2)
+	}
+	// Vendor tooling: "pack" assembles the bundle from a manifest of its own
+	if flag.Arg(0) == "pack" {
+		os.Exit(demo.RunPack(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "init" {
+		os.Exit(demo.RunInit(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "seal" {
+		os.Exit(demo.RunSeal(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "winres" {
+		os.Exit(demo.RunWinres(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "doctor" {
+		os.Exit(demo.RunDoctor())
 	}
 
-	// Fallback to current dir if not found (mostly for dev)
-	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
-		manifestPath = "manifest.json"
+	// Errors before the demo runs only get a dialog or error page where
+	// the run would have shown one
+	errorBrowser := *browserFlag
+	if *checkFlag || *noBrowserFlag {
+		errorBrowser = demo.BrowserNone
 	}
 
+	// 1. Read Configuration
+	exePath, _ := os.Executable()
+	manifestPath := demo.ManifestPath(exePath, *serveDirFlag)
+
 	data, err := ioutil.ReadFile(manifestPath)
 	if err != nil {
 		fmt.Printf("Error reading manifest: %v\n", err)
 		// Try minimal default if manifest fails? No, better to fail.
+		demo.PresentManifestError(nil, errorBrowser, err)
 		os.Exit(1)
 	}
 
-	var config Manifest
-	if err := json.Unmarshal(data, &config); err != nil {
-		fmt.Printf("Error parsing manifest: %v\n", err)
-		os.Exit(1)
+	config, err := demo.DecodeManifestFile(manifestPath, data)
+	if *validateFlag {
+		if err != nil {
+			fmt.Printf("%s: %v\n", manifestPath, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid.\n", manifestPath)
+		os.Exit(0)
 	}
-
-	// 2. Handle Uninstall
-	if *uninstallFlag {
-		performUninstall(&config)
-		return
+	if err != nil {
+		fmt.Printf("Error in manifest %s: %v\n", manifestPath, err)
+		demo.PresentManifestError(&config, errorBrowser, err)
+		os.Exit(1)
 	}
-
-	// 3. Find Port
-	port := config.PHPPort
-	if port == 0 {
-		port, err = getFreePort()
+	var profile json.RawMessage
+	if *profileFlag != "" {
+		if profile, err = demo.ManifestProfile(&config, *profileFlag); err == nil {
+			err = demo.ApplyProfile(&config, profile)
+		}
 		if err != nil {
-			fmt.Printf("Error finding free port: %v\n", err)
-			os.Exit(1)
+			fmt.Printf("Error: --profile: %v\n", err)
+			os.Exit(2)
 		}
 	}
+	demo.SetLanguage(&config)
 
-	// 4. Start PHP Server
-	// Locate PHP binary. In dev, we might use system 'php'.
-	// In prod, it should be packaged relative to exe.
-	phpBin := config.PHPBinaryPath
-	if _, err := os.Stat(phpBin); os.IsNotExist(err) {
-		// Fallback to system php
-		phpBin = "php"
-	} else {
-		if filepath.IsAbs(phpBin) == false {
-			phpBin = filepath.Join(filepath.Dir(exePath), phpBin)
+	// Session management: "sessions list" and "sessions delete <name>"
+	if flag.Arg(0) == "sessions" {
+		if *appFlag != "" && len(config.Apps) > 0 {
+			if config, err = demo.SelectApp(&config, *appFlag); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
 		}
+		os.Exit(demo.RunSessionsCommand(&config, flag.Args()[1:]))
 	}
-
-	publicDir := config.PublicRoot
-	if filepath.IsAbs(publicDir) == false {
-		publicDir = filepath.Join(filepath.Dir(exePath), publicDir)
+	// The running demo: "status", "stop" and "logs". A session of a suite
+	// needs --app, as sessions are kept per app
+	switch flag.Arg(0) {
+	case "status", "stop", "logs":
+		if *sessionFlag != "" && *appFlag != "" && len(config.Apps) > 0 {
+			if config, err = demo.SelectApp(&config, *appFlag); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		os.Exit(demo.RunInstanceCommand(&config, *sessionFlag, flag.Arg(0)))
 	}
-
-	cmd := exec.Command(phpBin, "-S", fmt.Sprintf("127.0.0.1:%d", port), "-t", publicDir)
-
-	// Inject Env Vars
-	env := os.Environ()
-	env = append(env, fmt.Sprintf("%s=true", config.DemoModeEnvKey))
-	for k, v := range config.EnvVars {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	if *sessionFlag != "" && *workDirFlag != "" {
+		fmt.Println("Error: --session and --work-dir can't be combined; sessions live in the user cache dir")
+		os.Exit(2)
 	}
-	cmd.Env = env
 
-	// Forward stdout/stderr for debugging
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Start(); err != nil {
-		fmt.Printf("Error starting PHP server: %v\n", err)
-		os.Exit(1)
+	// 2. Handle Uninstall
+	if *uninstallFlag {
+		os.Exit(demo.PerformUninstall(&config))
 	}
 
-	fmt.Printf("Server started on http://127.0.0.1:%d\n", port)
-
-	// 5. Open Browser
-	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, config.LandingPageURL)
-	go func() {
-		// Give server a moment to start
-		time.Sleep(1 * time.Second)
-		openBrowser(url)
-	}()
-
-	// 6. Handle Shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-
-	// Also handle duration expiry
-	if config.AllowedDemoDurationMinutes > 0 {
-		go func() {
-			time.Sleep(time.Duration(config.AllowedDemoDurationMinutes) * time.Minute)
-			fmt.Println("Demo duration expired.")
-			c <- os.Interrupt
-		}()
+	// 3. Run the demo until the user quits or it expires
+	overrides, err := demo.ParseOverrides(demo.OverrideFlags{
+		Port:        *portFlag,
+		LandingPage: *landingFlag,
+		Duration:    *durationFlag,
+		WindowSize:  *windowSizeFlag,
+	}, os.Environ())
+	browser := *browserFlag
+	if err == nil && *noBrowserFlag {
+		if browser != "default" && browser != demo.BrowserNone {
+			err = fmt.Errorf("--no-browser and --browser %s contradict each other", browser)
+		}
+		browser = demo.BrowserNone
 	}
-
-	<-c
-	fmt.Println("Shutting down...")
-
-	// Kill PHP process
-	if err := cmd.Process.Kill(); err != nil {
-		fmt.Printf("Error killing server: %v\n", err)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
 	}
-
-	// 7. Cleanup
-	if config.CleanOnExit {
-		// In a real app, this might delete the temp DB or log files
-		fmt.Println("Performing cleanup...")
+	if *offlineFlag {
+		config.Offline = true
 	}
-}
-
-func getFreePort() (int, error) {
-	addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
-	if err != nil {
-		return 0, err
+	if *zoomFlag != 0 {
+		if *zoomFlag < 0.5 || *zoomFlag > 3 {
+			fmt.Printf("Error: --zoom %g must be between 0.5 and 3\n", *zoomFlag)
+			os.Exit(2)
+		}
+		config.Accessibility.Zoom = *zoomFlag
 	}
-	l, err := net.ListenTCP("tcp", addr)
-	if err != nil {
-		return 0, err
+	if *highContrast {
+		config.Accessibility.HighContrast = true
 	}
-	defer l.Close()
-	return l.Addr().(*net.TCPAddr).Port, nil
-}
-
-func openBrowser(url string) {
-	var err error
-	switch runtime.GOOS {
-	case "linux":
-		err = exec.Command("xdg-open", url).Start()
-	case "windows":
-		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
-	case "darwin":
-		err = exec.Command("open", url).Start()
-	default:
-		err = fmt.Errorf("unsupported platform")
+	if *reducedMotion {
+		config.Accessibility.ReducedMotion = true
 	}
-	if err != nil {
-		fmt.Printf("Error opening browser: %v\n", err)
+	overrides.Apply(&config)
+	if names := overrides.EnvNames(); names != "" {
+		fmt.Printf("Manifest settings from the environment: %s\n", names)
+		if err := demo.ValidateManifest(&config); err != nil {
+			fmt.Printf("Error: manifest with LAUNCHER_ overrides: %v\n", err)
+			os.Exit(2)
+		}
 	}
-}
-
-func performUninstall(config *Manifest) {
-	fmt.Println("Uninstalling/Cleaning up demo...")
-
-	// Determine paths relative to executable if needed
-	exePath, err := os.Executable()
-	if err != nil {
-		fmt.Printf("Error determining executable path: %v\n", err)
-		return
+	opts := demo.Options{
+		WorkDir:    *workDirFlag,
+		NoVerify:   *noVerifyFlag,
+		Check:      *checkFlag,
+		App:        *appFlag,
+		ExportData: *exportFlag,
+		ImportData: *importFlag,
+		AcceptEULA: *acceptEULA,
+		Session:    *sessionFlag,
+		ServeDir:   *serveDirFlag,
+		Verbose:    *verboseFlag,
+		LogLevel:   logLevel,
+		LogJSON:    logJSON,
+		Quiet:      *quietFlag,
+		Browser:    browser,
+		Overrides:  overrides,
+		Profile:    profile,
 	}
-	exeDir := filepath.Dir(exePath)
-
-	dbPath := config.DBPath
-	if !filepath.IsAbs(dbPath) {
-		dbPath = filepath.Join(exeDir, dbPath)
+	if *deterministic {
+		if err := demo.ApplyDeterministic(&config, &opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
 	}
-
-	fmt.Printf("Removing database at %s...\n", dbPath)
-	if err := os.Remove(dbPath); err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("Database file does not exist, skipping.")
-		} else {
-			fmt.Printf("Error removing database: %v\n", err)
+	if opts.ServeDir != "" {
+		if err := demo.ApplyServeDir(&config, &opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
 		}
-	} else {
-		fmt.Println("Database removed.")
 	}
+	l := demo.NewLauncher(config, opts, filepath.Dir(exePath))
 
-	// Additional cleanup could go here (e.g. log files)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	consoleDone := demo.NotifyConsoleClose(c)
+	go func() {
+		sig := <-c
+		cancel(demo.SignalCause(sig))
+	}()
 
-	fmt.Println("Cleanup complete.")
+	err = l.Run(ctx)
+	consoleDone()
+	if err != nil {
+		l.ShowError(err)
+		os.Exit(1)
+	}
 }
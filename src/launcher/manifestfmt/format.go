@@ -0,0 +1,22 @@
+// Package manifestfmt converts manifests written in YAML or TOML, which
+// allow comments, to the JSON the launcher decodes, so every format is
+// checked the same way. The readers resolve values as build.py's PyYAML
+// and tomllib do, so the builder and the launcher read one file alike.
+package manifestfmt
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ToJSON converts data, the contents of file, to JSON by the file's
+// extension. Anything but .yaml, .yml and .toml is returned as is.
+func ToJSON(file string, data []byte) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return YAMLToJSON(data)
+	case ".toml":
+		return TOMLToJSON(data)
+	}
+	return data, nil
+}
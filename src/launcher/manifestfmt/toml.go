@@ -0,0 +1,443 @@
+package manifestfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TOMLToJSON converts a manifest written in TOML 1.0 to JSON. Dates and
+// times become strings, which is how the manifest takes them anyway.
+func TOMLToJSON(data []byte) ([]byte, error) {
+	p := &tomlParser{s: strings.ReplaceAll(string(data), "\r\n", "\n"), line: 1}
+	root := make(map[string]interface{})
+	table := root
+	var err error
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return json.Marshal(root)
+		}
+		switch {
+		case strings.HasPrefix(p.rest(), "[["):
+			p.i += 2
+			var keys []string
+			if keys, err = p.key(); err == nil {
+				err = p.expect("]]")
+			}
+			if err == nil {
+				table, err = tomlArrayTable(root, keys)
+			}
+		case p.peek() == '[':
+			p.i++
+			var keys []string
+			if keys, err = p.key(); err == nil {
+				err = p.expect("]")
+			}
+			if err == nil {
+				table, err = tomlTable(root, keys)
+			}
+		default:
+			err = p.keyValue(table)
+		}
+		if err == nil {
+			err = p.endOfLine()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", p.line, err)
+		}
+	}
+}
+
+type tomlParser struct {
+	s    string
+	i    int
+	line int
+}
+
+func (p *tomlParser) eof() bool    { return p.i >= len(p.s) }
+func (p *tomlParser) rest() string { return p.s[p.i:] }
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		switch p.s[p.i] {
+		case ' ', '\t':
+			p.i++
+		case '\n':
+			p.i++
+			p.line++
+		case '#':
+			for !p.eof() && p.s[p.i] != '\n' {
+				p.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) expect(token string) error {
+	p.skipSpace()
+	if !strings.HasPrefix(p.rest(), token) {
+		return fmt.Errorf("expected %s", token)
+	}
+	p.i += len(token)
+	return nil
+}
+
+// endOfLine allows only a comment after a statement.
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	if p.peek() == '#' {
+		for !p.eof() && p.s[p.i] != '\n' {
+			p.i++
+		}
+	}
+	if !p.eof() && p.s[p.i] != '\n' {
+		return fmt.Errorf("unexpected %q; one key = value per line", firstWord(p.rest()))
+	}
+	return nil
+}
+
+func firstWord(s string) string {
+	if i := strings.IndexAny(s, " \t\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+
+// key reads a dotted key such as env_vars."APP ENV".
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var k string
+		switch p.peek() {
+		case '"', '\'':
+			v, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			k = v
+		default:
+			k = tomlBareKey.FindString(p.rest())
+			if k == "" {
+				return nil, fmt.Errorf("expected a key, got %q", firstWord(p.rest()))
+			}
+			p.i += len(k)
+		}
+		keys = append(keys, k)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.i++
+	}
+}
+
+func (p *tomlParser) keyValue(table map[string]interface{}) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return fmt.Errorf("expected = after %s", strings.Join(keys, "."))
+	}
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := table[k]
+		if !ok {
+			next = make(map[string]interface{})
+			table[k] = next
+		}
+		if table, ok = next.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s is not a table", strings.Join(keys, "."))
+		}
+	}
+	last := keys[len(keys)-1]
+	if _, dup := table[last]; dup {
+		return fmt.Errorf("%s is set twice", strings.Join(keys, "."))
+	}
+	table[last] = v
+	return nil
+}
+
+// tomlTable returns the table a [header] names, creating it if need be.
+// A header inside an array of tables refers to its last element.
+func tomlTable(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	table := root
+	for _, k := range keys {
+		next, ok := table[k]
+		if !ok {
+			next = make(map[string]interface{})
+			table[k] = next
+		}
+		switch v := next.(type) {
+		case map[string]interface{}:
+			table = v
+		case []interface{}:
+			last, ok := lastTable(v)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", strings.Join(keys, "."))
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%s is not a table", strings.Join(keys, "."))
+		}
+	}
+	return table, nil
+}
+
+func lastTable(list []interface{}) (map[string]interface{}, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	m, ok := list[len(list)-1].(map[string]interface{})
+	return m, ok
+}
+
+// tomlArrayTable appends a table to the array a [[header]] names.
+func tomlArrayTable(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	parent, err := tomlTable(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	list, _ := parent[last].([]interface{})
+	if _, exists := parent[last]; exists && list == nil {
+		return nil, fmt.Errorf("%s is not an array of tables", strings.Join(keys, "."))
+	}
+	table := make(map[string]interface{})
+	parent[last] = append(list, table)
+	return table, nil
+}
+
+var tomlDateTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?)?|^\d{2}:\d{2}:\d{2}(\.\d+)?`)
+
+func (p *tomlParser) value() (interface{}, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(p.rest(), "true"):
+		p.i += 4
+		return true, nil
+	case strings.HasPrefix(p.rest(), "false"):
+		p.i += 5
+		return false, nil
+	}
+	if dt := tomlDateTime.FindString(p.rest()); dt != "" {
+		p.i += len(dt)
+		return strings.Replace(dt, " ", "T", 1), nil
+	}
+	end := strings.IndexAny(p.rest(), " \t\n,]}#")
+	if end < 0 {
+		end = len(p.rest())
+	}
+	word := p.rest()[:end]
+	if n, ok := tomlNumber(strings.ReplaceAll(word, "_", "")); ok && word != "" {
+		p.i += end
+		return n, nil
+	}
+	switch word {
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return nil, fmt.Errorf("%s can't be used in a manifest", word)
+	}
+	return nil, fmt.Errorf("unexpected %q; strings need quotes", word)
+}
+
+func (p *tomlParser) array() (interface{}, error) {
+	p.i++ // [
+	list := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.i++
+			return list, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.i++
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected , or ] in an array")
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (interface{}, error) {
+	p.i++ // {
+	table := make(map[string]interface{})
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.i++
+		return table, nil
+	}
+	for {
+		if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.i++
+		case '}':
+			p.i++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected , or } in an inline table")
+		}
+	}
+}
+
+// str reads a basic ("..."), literal ('...') or multi-line string.
+func (p *tomlParser) str() (string, error) {
+	q := p.s[p.i]
+	multi := strings.HasPrefix(p.rest(), strings.Repeat(string(q), 3))
+	delim := string(q)
+	if multi {
+		delim = strings.Repeat(delim, 3)
+		p.i += 3
+		// A newline right after the opening quotes is trimmed
+		if p.peek() == '\n' {
+			p.i++
+			p.line++
+		}
+	} else {
+		p.i++
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() || !multi && p.s[p.i] == '\n' {
+			return "", fmt.Errorf("unterminated string")
+		}
+		if strings.HasPrefix(p.rest(), delim) {
+			p.i += len(delim)
+			return b.String(), nil
+		}
+		c := p.s[p.i]
+		if c == '\n' {
+			p.line++
+		}
+		if c != '\\' || q == '\'' {
+			r, size := utf8.DecodeRuneInString(p.rest())
+			b.WriteRune(r)
+			p.i += size
+			continue
+		}
+		if err := p.escape(&b, multi); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *tomlParser) escape(b *strings.Builder, multi bool) error {
+	p.i++ // backslash
+	if p.eof() {
+		return fmt.Errorf("unterminated string")
+	}
+	c := p.s[p.i]
+	p.i++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte(0x1b)
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if len(p.rest()) < n {
+			return fmt.Errorf("short \\%c escape", c)
+		}
+		r, err := strconv.ParseUint(p.rest()[:n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return fmt.Errorf("bad \\%c escape", c)
+		}
+		b.WriteRune(rune(r))
+		p.i += n
+	default:
+		if !multi || !strings.ContainsRune(" \t\n", rune(c)) {
+			return fmt.Errorf("unknown escape \\%c", c)
+		}
+		// A backslash at the end of a line joins it with the next one
+		p.i--
+		for !p.eof() && strings.ContainsRune(" \t\n", rune(p.s[p.i])) {
+			if p.s[p.i] == '\n' {
+				p.line++
+			}
+			p.i++
+		}
+	}
+	return nil
+}
+
+var (
+	tomlDecimal   = regexp.MustCompile(`^[-+]?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+	tomlPrefixInt = regexp.MustCompile(`^(0x[0-9A-Fa-f]+|0o[0-7]+|0b[01]+)$`)
+)
+
+// tomlNumber parses a TOML integer or float, underscores removed, and
+// returns it in JSON form, keeping integers exact. TOML has no leading
+// zeros, so 010 is an error as it is for Python's tomllib.
+func tomlNumber(text string) (json.Number, bool) {
+	if tomlPrefixInt.MatchString(text) {
+		n, err := strconv.ParseInt(text, 0, 64)
+		return json.Number(strconv.FormatInt(n, 10)), err == nil
+	}
+	if !tomlDecimal.MatchString(text) {
+		return "", false
+	}
+	if n, err := strconv.ParseInt(strings.TrimPrefix(text, "+"), 10, 64); err == nil {
+		return json.Number(strconv.FormatInt(n, 10)), true
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return "", false
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), true
+}
@@ -0,0 +1,49 @@
+package manifestfmt
+
+import "testing"
+
+// The expected values are what Python's tomllib gives build.py.
+func TestTOMLToJSON(t *testing.T) {
+	tests := []struct {
+		name, toml, want string
+	}{
+		{"booleans", "a = true\nb = false\n", `{"a": true, "b": false}`},
+		{"numbers", "port = 8080\nsep = 1_000\nhex = 0x1F\noctal = 0o10\nfloat = 1.5\nexp = 1e3\nneg = -42\n",
+			`{"exp": 1000.0, "float": 1.5, "hex": 31, "neg": -42, "octal": 8, "port": 8080, "sep": 1000}`},
+		{"quoting", "a = \"tab\\there\"\nb = 'C:\\path'\nc = \"caf\\u00e9\"\nd = \"a # not a comment\"\n",
+			`{"a": "tab\there", "b": "C:\\path", "c": "caf\u00e9", "d": "a # not a comment"}`},
+		{"multiline", "a = \"\"\"\none\ntwo\"\"\"\nb = '''\nraw \\n\nline'''\nc = \"\"\"\\\n   joined \\\n   here\"\"\"\n",
+			`{"a": "one\ntwo", "b": "raw \\n\nline", "c": "joined here"}`},
+		{"comments", "# top\napp_name = \"Demo\" # trailing\n\n[deterministic] # table\nport = 8000\n",
+			`{"app_name": "Demo", "deterministic": {"port": 8000}}`},
+		{"dates", "fake_now = 2025-01-06T09:00:00Z\nday = 2025-01-06\n",
+			`{"day": "2025-01-06", "fake_now": "2025-01-06T09:00:00Z"}`},
+		{"tables", "[[apps]]\nname = \"a\"\n[[apps]]\nname = \"b\"\n[env_vars]\nX = \"1\"\n",
+			`{"apps": [{"name": "a"}, {"name": "b"}], "env_vars": {"X": "1"}}`},
+	}
+	for _, tt := range tests {
+		got, err := TOMLToJSON([]byte(tt.toml))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !sameJSON(t, got, []byte(tt.want)) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTOMLToJSONRejects(t *testing.T) {
+	for name, toml := range map[string]string{
+		"leading zero":   "a = 010\n",
+		"bare string":    "a = yes\n",
+		"infinity":       "a = inf\n",
+		"duplicate key":  "a = 1\na = 2\n",
+		"unterminated":   "a = \"open\n",
+		"missing equals": "a 1\n",
+	} {
+		if got, err := TOMLToJSON([]byte(toml)); err == nil {
+			t.Errorf("%s: accepted as %s", name, got)
+		}
+	}
+}
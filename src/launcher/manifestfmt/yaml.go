@@ -0,0 +1,642 @@
+package manifestfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// YAMLToJSON converts a manifest written in YAML to JSON. It covers what a
+// configuration file needs: block mappings and sequences, flow collections,
+// quoted and plain scalars, | and > blocks and comments. Anchors, tags and
+// multiple documents are rejected rather than misread.
+func YAMLToJSON(data []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		p.lines = append(p.lines, newYAMLLine(raw, i+1))
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].text == "---" {
+		p.pos++
+	}
+	v, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.text == "---" || l.text == "..." {
+			return nil, fmt.Errorf("line %d: only one YAML document is allowed", l.number)
+		}
+		return nil, fmt.Errorf("line %d: unexpected %q; check the indentation", l.number, l.text)
+	}
+	if v == nil {
+		v = map[string]interface{}{}
+	}
+	return json.Marshal(v)
+}
+
+type yamlLine struct {
+	raw    string
+	number int
+	indent int
+	text   string // without indentation, comment and trailing space
+	tab    bool   // indented with a tab, which YAML forbids
+}
+
+func newYAMLLine(raw string, number int) yamlLine {
+	trimmed := strings.TrimLeft(raw, " ")
+	l := yamlLine{raw: raw, number: number, indent: len(raw) - len(trimmed)}
+	l.tab = strings.HasPrefix(trimmed, "\t")
+	l.text = strings.TrimRight(stripYAMLComment(trimmed), " \t")
+	return l
+}
+
+// stripYAMLComment cuts a # comment that isn't inside quotes.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			// Quotes only open a scalar at its start, not inside it
+			if i == 0 || strings.ContainsRune(" [{,:-", rune(s[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+}
+
+// next returns the next line with content, or nil at the end.
+func (p *yamlParser) next() (*yamlLine, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	l := &p.lines[p.pos]
+	if l.tab {
+		return nil, fmt.Errorf("line %d: indent with spaces, YAML doesn't allow tabs", l.number)
+	}
+	return l, nil
+}
+
+// parseNode parses the node whose lines are indented at least indent.
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	l, err := p.next()
+	if err != nil || l == nil || l.indent < indent {
+		return nil, err
+	}
+	if isYAMLSeqItem(l.text) {
+		return p.parseSeq(l.indent)
+	}
+	if _, _, ok, err := splitYAMLKey(l); err != nil {
+		return nil, err
+	} else if ok {
+		return p.parseMap(l.indent)
+	}
+	p.pos++
+	return p.inlineValue(l, l.text)
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		l, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if l == nil || l.indent < indent {
+			return m, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.number)
+		}
+		if isYAMLSeqItem(l.text) {
+			return nil, fmt.Errorf("line %d: a list item where a key was expected", l.number)
+		}
+		key, rest, ok, err := splitYAMLKey(l)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\", got %q", l.number, l.text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: %q is set twice", l.number, key)
+		}
+		p.pos++
+
+		var v interface{}
+		switch {
+		case rest == "":
+			// The value is on the following lines; a list may sit at the
+			// key's own indentation
+			var n *yamlLine
+			if n, err = p.next(); err != nil {
+				return nil, err
+			}
+			if n != nil && n.indent == indent && isYAMLSeqItem(n.text) {
+				v, err = p.parseSeq(indent)
+			} else {
+				v, err = p.parseNode(indent + 1)
+			}
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			v, err = p.blockScalar(l, rest, indent)
+		default:
+			v, err = p.inlineValue(l, rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+}
+
+func (p *yamlParser) parseSeq(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for {
+		l, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if l == nil || l.indent < indent {
+			return list, nil
+		}
+		if l.indent > indent || !isYAMLSeqItem(l.text) {
+			if l.indent == indent {
+				return list, nil // a key of the enclosing mapping
+			}
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.number)
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.parseNode(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		// The item's content starts a node of its own right after the
+		// dash, e.g. "- name: queue"; parse the line again from there
+		l.indent += len(l.text) - len(rest)
+		l.text = rest
+		var v interface{}
+		if _, _, ok, err := splitYAMLKey(l); err != nil {
+			return nil, err
+		} else if ok {
+			v, err = p.parseMap(l.indent)
+			if err != nil {
+				return nil, err
+			}
+		} else if isYAMLSeqItem(rest) {
+			if v, err = p.parseSeq(l.indent); err != nil {
+				return nil, err
+			}
+		} else {
+			p.pos++
+			if v, err = p.inlineValue(l, rest); err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, v)
+	}
+}
+
+// splitYAMLKey splits "key: value" at the first colon that is followed by
+// a space or ends the line and isn't quoted.
+func splitYAMLKey(l *yamlLine) (string, string, bool, error) {
+	text := l.text
+	if strings.HasPrefix(text, "? ") {
+		return "", "", false, fmt.Errorf("line %d: complex keys aren't supported", l.number)
+	}
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false, nil
+	}
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end := closingQuote(text)
+		if end < 0 || !strings.HasPrefix(text[end+1:], ":") {
+			return "", "", false, nil
+		}
+		key, err := yamlScalar(l, text[:end+1])
+		if err != nil {
+			return "", "", false, err
+		}
+		rest := text[end+2:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false, nil
+		}
+		return fmt.Sprint(key), strings.TrimSpace(rest), true, nil
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// closingQuote returns the index of the quote closing the scalar text
+// starts with, or -1.
+func closingQuote(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case text[i] == q && q == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++ // '' is an escaped quote
+		case text[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// inlineValue parses the value on a line, joining the following lines
+// while a flow collection is still open.
+func (p *yamlParser) inlineValue(l *yamlLine, text string) (interface{}, error) {
+	if text[0] != '[' && text[0] != '{' {
+		return yamlScalar(l, text)
+	}
+	for !flowClosed(text) {
+		if p.pos >= len(p.lines) {
+			return nil, fmt.Errorf("line %d: %c is never closed", l.number, text[0])
+		}
+		text += " " + strings.TrimSpace(p.lines[p.pos].text)
+		p.pos++
+	}
+	f := &yamlFlow{s: text, line: l}
+	v, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	if f.skipSpace(); f.i < len(f.s) {
+		return nil, fmt.Errorf("line %d: unexpected %q after %c...%c", l.number, f.s[f.i:], text[0], text[f.i-1])
+	}
+	return v, nil
+}
+
+func flowClosed(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// blockScalar reads a | (literal) or > (folded) block: the following lines
+// indented deeper than the key.
+func (p *yamlParser) blockScalar(l *yamlLine, header string, indent int) (interface{}, error) {
+	chomp := strings.TrimLeft(header[1:], " ")
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, fmt.Errorf("line %d: unsupported block header %q", l.number, header)
+	}
+	var lines []string
+	block := -1
+	for p.pos < len(p.lines) {
+		raw := p.lines[p.pos].raw
+		trimmed := strings.TrimLeft(raw, " ")
+		if trimmed == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		n := len(raw) - len(trimmed)
+		if n <= indent || block >= 0 && n < block {
+			break
+		}
+		if block < 0 {
+			block = n
+		}
+		lines = append(lines, raw[block:])
+		p.pos++
+	}
+	// Trailing blank lines belong to the chomping, not the content
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	trailing := len(lines) - end
+	lines = lines[:end]
+	if trailing > 0 {
+		// Give them back so the next key's line number stays right
+		p.pos -= trailing
+	}
+
+	var s string
+	if header[0] == '|' {
+		s = strings.Join(lines, "\n")
+	} else {
+		// A line break between text lines folds into a space, and one
+		// before blank lines is dropped, except next to more-indented lines
+		// or leading blank ones
+		var b strings.Builder
+		last := -1 // the previous line with text
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "":
+				b.WriteString("\n")
+			case lines[i-1] == "":
+				if last < 0 || moreIndented(line) || moreIndented(lines[last]) {
+					b.WriteString("\n")
+				}
+			case moreIndented(line) || moreIndented(lines[i-1]):
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+			if line != "" {
+				last = i
+			}
+		}
+		s = b.String()
+	}
+	switch {
+	case len(lines) == 0:
+		return "", nil
+	case chomp == "-":
+		return s, nil
+	case chomp == "+":
+		return s + strings.Repeat("\n", trailing+1), nil
+	}
+	return s + "\n", nil
+}
+
+// moreIndented reports whether a line of a > block is indented beyond the
+// block, which keeps its line breaks.
+func moreIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+// yamlScalar resolves a single scalar the way PyYAML does, which reads
+// the same file in build.py: quotes make a string, otherwise the YAML 1.1
+// null, booleans (yes, no, on and off too) and numbers are recognized and
+// everything else is a string. Timestamps stay strings.
+func yamlScalar(l *yamlLine, text string) (interface{}, error) {
+	switch text[0] {
+	case '"':
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("line %d: unterminated or trailing text after %s", l.number, text)
+		}
+		var s string
+		if err := json.Unmarshal([]byte(text), &s); err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", l.number, text, err)
+		}
+		return s, nil
+	case '\'':
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("line %d: unterminated or trailing text after %s", l.number, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case '&', '*', '!', '%', '@', '`':
+		return nil, fmt.Errorf("line %d: %q: anchors, aliases and tags aren't supported", l.number, text)
+	}
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE", "yes", "Yes", "YES", "on", "On", "ON":
+		return true, nil
+	case "false", "False", "FALSE", "no", "No", "NO", "off", "Off", "OFF":
+		return false, nil
+	}
+	n, ok, err := yamlNumber(text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", l.number, err)
+	}
+	if ok {
+		return n, nil
+	}
+	return text, nil
+}
+
+// The YAML 1.1 numbers, as PyYAML's resolver matches them
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?(0b[01_]+|0[0-7_]+|0|[1-9][0-9_]*|0x[0-9a-fA-F_]+|[1-9][0-9_]*(:[0-5]?[0-9])+)$`)
+	yamlFloat = regexp.MustCompile(`^([-+]?[0-9][0-9_]*\.[0-9_]*([eE][-+][0-9]+)?|\.[0-9][0-9_]*([eE][-+][0-9]+)?|[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+\.[0-9_]*)$`)
+	yamlNaN   = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+)
+
+// yamlNumber parses text if it's a YAML 1.1 integer or float and returns
+// it in JSON form, keeping integers exact. 010 is octal and 1e3 a string,
+// as they are for PyYAML.
+func yamlNumber(text string) (json.Number, bool, error) {
+	if yamlNaN.MatchString(text) {
+		return "", false, fmt.Errorf("%s can't be used in a manifest", text)
+	}
+	isInt := yamlInt.MatchString(text)
+	if !isInt && !yamlFloat.MatchString(text) {
+		return "", false, nil
+	}
+	digits := strings.ReplaceAll(text, "_", "")
+	sign := ""
+	if digits[0] == '-' || digits[0] == '+' {
+		if digits[0] == '-' {
+			sign = "-"
+		}
+		digits = digits[1:]
+	}
+	if strings.Contains(digits, ":") {
+		return sexagesimal(sign, digits, isInt), true, nil
+	}
+	if !isInt {
+		f, err := strconv.ParseFloat(sign+digits, 64)
+		if err != nil {
+			return "", false, fmt.Errorf("%s: %v", text, err)
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), true, nil
+	}
+	base := 10
+	switch {
+	case strings.HasPrefix(digits, "0b"):
+		base, digits = 2, digits[2:]
+	case strings.HasPrefix(digits, "0x"):
+		base, digits = 16, digits[2:]
+	case len(digits) > 1 && digits[0] == '0':
+		base = 8
+	}
+	n, ok := new(big.Int).SetString(sign+digits, base)
+	if !ok {
+		// Only underscores after the prefix, like 0x_
+		return "", false, fmt.Errorf("%s isn't a number", text)
+	}
+	return json.Number(n.String()), true, nil
+}
+
+// sexagesimal evaluates a base 60 number such as 1:30 (90) or 1:30.5.
+func sexagesimal(sign, digits string, isInt bool) json.Number {
+	parts := strings.Split(digits, ":")
+	if isInt {
+		n := new(big.Int)
+		for _, part := range parts {
+			d, _ := new(big.Int).SetString(part, 10)
+			n.Mul(n, big.NewInt(60)).Add(n, d)
+		}
+		if sign == "-" {
+			n.Neg(n)
+		}
+		return json.Number(n.String())
+	}
+	var f float64
+	for _, part := range parts {
+		d, _ := strconv.ParseFloat(part, 64)
+		f = f*60 + d
+	}
+	if sign == "-" {
+		f = -f
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// yamlFlow parses a flow collection such as [a, "b"] or {key: value}.
+type yamlFlow struct {
+	s    string
+	i    int
+	line *yamlLine
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", f.line.number, fmt.Sprintf(format, args...))
+}
+
+func (f *yamlFlow) value() (interface{}, error) {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return nil, f.errorf("unexpected end of %s", f.s)
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		list := []interface{}{}
+		for {
+			if f.skipSpace(); f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return list, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		m := make(map[string]interface{})
+		for {
+			if f.skipSpace(); f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return m, nil
+			}
+			k, err := f.scalar(":,}")
+			if err != nil {
+				return nil, err
+			}
+			if f.skipSpace(); f.i >= len(f.s) || f.s[f.i] != ':' {
+				return nil, f.errorf("expected : after %v in %s", k, f.s)
+			}
+			f.i++
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = v
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f.scalar(",]}")
+}
+
+// separator consumes the comma after an item, or leaves the closing
+// bracket for the caller.
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	switch {
+	case f.i < len(f.s) && f.s[f.i] == ',':
+		f.i++
+		return nil
+	case f.i < len(f.s) && f.s[f.i] == closing:
+		return nil
+	}
+	return f.errorf("expected , or %c in %s", closing, f.s)
+}
+
+// scalar reads a quoted or plain scalar up to one of the stop characters.
+func (f *yamlFlow) scalar(stops string) (interface{}, error) {
+	f.skipSpace()
+	start := f.i
+	if f.i < len(f.s) && (f.s[f.i] == '"' || f.s[f.i] == '\'') {
+		end := closingQuote(f.s[f.i:])
+		if end < 0 {
+			return nil, f.errorf("unterminated string in %s", f.s)
+		}
+		f.i += end + 1
+		return yamlScalar(f.line, f.s[start:f.i])
+	}
+	// Colons only end keys, so URLs survive as values
+	for f.i < len(f.s) && !strings.ContainsRune(stops, rune(f.s[f.i])) {
+		f.i++
+	}
+	text := strings.TrimSpace(f.s[start:f.i])
+	if text == "" {
+		return nil, nil
+	}
+	return yamlScalar(f.line, text)
+}
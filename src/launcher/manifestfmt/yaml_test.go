@@ -0,0 +1,75 @@
+package manifestfmt
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// sameJSON reports whether a and b hold the same values, so 1500 and
+// 1500.0 compare equal.
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("%s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("%s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// The expected values are what PyYAML's safe_load gives build.py.
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"booleans", "a: yes\nb: No\nc: on\nd: OFF\ne: true\nf: y\ng: n\n",
+			`{"a": true, "b": false, "c": true, "d": false, "e": true, "f": "y", "g": "n"}`},
+		{"quoted booleans", "a: \"yes\"\nb: 'off'\n",
+			`{"a": "yes", "b": "off"}`},
+		{"null", "a: ~\nb: null\nc:\n",
+			`{"a": null, "b": null, "c": null}`},
+		{"numbers", "port: 8080\noctal: 010\nnot_octal: 0o10\nhex: 0x1F\nbinary: 0b11\nsep: 1_000\nbase60: 1:30\nfloat: 1.5\nexp: 1.5e+3\nbare_exp: 1e3\nlead: 09\ndot: 1.\nneg: -42\n",
+			`{"bare_exp": "1e3", "base60": 90, "binary": 3, "dot": 1.0, "exp": 1500.0, "float": 1.5, "hex": 31, "lead": "09", "neg": -42, "not_octal": "0o10", "octal": 8, "port": 8080, "sep": 1000}`},
+		{"quoting", "a: \"tab\\there\"\nb: 'it''s'\nc: \"caf\\u00e9\"\nd: 'a # not a comment'\ne: \"x: y\"\nf: plain words here\n",
+			`{"a": "tab\there", "b": "it's", "c": "caf\u00e9", "d": "a # not a comment", "e": "x: y", "f": "plain words here"}`},
+		{"multiline", "lit: |\n  one\n  two\nfold: >\n  one\n  two\n\n  three\nstrip: |-\n  kept\nkeep: |+\n  kept\n\nafter: x\n",
+			`{"after": "x", "fold": "one two\nthree\n", "keep": "kept\n\n", "lit": "one\ntwo\n", "strip": "kept"}`},
+		{"folding", "a: >\n  one\n\n\n  three\nb: >\n  one\n    deep\n  two\nc: >\n  one\n\n    deep\n\n  two\nd: >\n\n  lead\n",
+			`{"a": "one\n\nthree\n", "b": "one\n  deep\ntwo\n", "c": "one\n\n  deep\n\ntwo\n", "d": "\nlead\n"}`},
+		{"comments", "# top\napp_name: Demo # trailing\n\n# between\nurl: http://example.com/#frag\nlist:\n  - a # one\n  # skipped\n  - b\n",
+			`{"app_name": "Demo", "list": ["a", "b"], "url": "http://example.com/#frag"}`},
+		{"flow", "a: [yes, \"no\", 1_0, ~]\nb: {x: off, y: 'a, b'}\n",
+			`{"a": [true, "no", 10, null], "b": {"x": false, "y": "a, b"}}`},
+		{"empty", "# nothing but a comment\n", `{}`},
+	}
+	for _, tt := range tests {
+		got, err := YAMLToJSON([]byte(tt.yaml))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !sameJSON(t, got, []byte(tt.want)) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestYAMLToJSONRejects(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"anchor":          "a: &x 1\nb: *x\n",
+		"tag":             "a: !!str 1\n",
+		"two documents":   "a: 1\n---\nb: 2\n",
+		"tab indentation": "a:\n\tb: 1\n",
+		"infinity":        "a: .inf\n",
+		"empty hex":       "a: 0x_\n",
+		"unterminated":    "a: \"open\n",
+		"bad indentation": "a: 1\n   b: 2\n",
+	} {
+		if got, err := YAMLToJSON([]byte(yaml)); err == nil {
+			t.Errorf("%s: accepted as %s", name, got)
+		}
+	}
+}